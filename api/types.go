@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
 	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
 	"go.vocdoni.io/dvote/crypto/zk/artifacts"
 	"go.vocdoni.io/dvote/types"
@@ -54,6 +55,9 @@ type APIrequest struct {
 	CensusDump   []byte                         `json:"censusDump,omitempty"`
 	CensusType   models.Census_Type             `json:"censusType,omitempty"`
 	Content      []byte                         `json:"content,omitempty"`
+	Date         int64                          `json:"date,omitempty"`
+	DateFrom     int64                          `json:"dateFrom,omitempty"`
+	DateTo       int64                          `json:"dateTo,omitempty"`
 	Digested     bool                           `json:"digested,omitempty"`
 	EntityId     types.HexBytes                 `json:"entityId,omitempty"`
 	EthProof     *ethstorageproof.StorageResult `json:"storageProof,omitempty"`
@@ -73,6 +77,7 @@ type APIrequest struct {
 	RootHash     types.HexBytes                 `json:"rootHash,omitempty"`
 	SearchTerm   string                         `json:"searchTerm,omitempty"`
 	Signature    types.HexBytes                 `json:"signature,omitempty"`
+	SortBy       string                         `json:"sortBy,omitempty"`
 	SrcNetId     string                         `json:"sourceNetworkId,omitempty"`
 	Status       string                         `json:"status,omitempty"`
 	Timestamp    int32                          `json:"timestamp"`
@@ -153,8 +158,10 @@ func (a APIrequest) String() string {
 // Fields must be in alphabetical order
 // Those fields with valid zero-values (such as bool) must be pointers
 type APIresponse struct {
+	AccountTxList        []*indexertypes.TxReference      `json:"accountTxList,omitempty"`
 	APIList              []string                         `json:"apiList,omitempty"`
 	Balance              *uint64                          `json:"balance,omitempty"`
+	BalanceHistory       []*indexertypes.AccountBalance   `json:"balanceHistory,omitempty"`
 	Block                *indexertypes.BlockMetadata      `json:"block,omitempty"`
 	BlockList            []*indexertypes.BlockMetadata    `json:"blockList,omitempty"`
 	BlockTime            *[5]int32                        `json:"blockTime,omitempty"`
@@ -189,6 +196,8 @@ type APIresponse struct {
 	Nullifier            string                           `json:"nullifier,omitempty"`
 	Nullifiers           *[]string                        `json:"nullifiers,omitempty"`
 	Ok                   bool                             `json:"ok"`
+	OracleResults        []*OracleResults                 `json:"oracleResults,omitempty"`
+	OverwriteCount       *uint32                          `json:"overwriteCount,omitempty"`
 	Paused               *bool                            `json:"paused,omitempty"`
 	Payload              string                           `json:"payload,omitempty"`
 	ProcessSummary       *ProcessSummary                  `json:"processSummary,omitempty"`
@@ -196,6 +205,7 @@ type APIresponse struct {
 	ProcessIDs           []string                         `json:"processIds,omitempty"`
 	Process              *indexertypes.Process            `json:"process,omitempty"`
 	ProcessList          []string                         `json:"processList,omitempty"`
+	RankedResults        *indexertypes.RankedResults      `json:"rankedResults,omitempty"`
 	Registered           *bool                            `json:"registered,omitempty"`
 	Request              string                           `json:"request"`
 	Results              [][]string                       `json:"results,omitempty"`
@@ -205,12 +215,16 @@ type APIresponse struct {
 	State                string                           `json:"state,omitempty"`
 	Stats                *VochainStats                    `json:"stats,omitempty"`
 	Timestamp            int32                            `json:"timestamp"`
+	TokenStats           *indexertypes.TokenStats         `json:"tokenStats,omitempty"`
+	TokenTransfers       []*indexertypes.TokenTransfer    `json:"tokenTransfers,omitempty"`
 	Type                 string                           `json:"type,omitempty"`
 	Tx                   *indexertypes.TxPackage          `json:"tx,omitempty"`
 	TxList               []*indexertypes.TxMetadata       `json:"txList,omitempty"`
+	TxSimulation         *TxSimulation                    `json:"txSimulation,omitempty"`
 	URI                  string                           `json:"uri,omitempty"`
 	ValidatorList        []*models.Validator              `json:"validatorlist,omitempty"`
 	ValidProof           *bool                            `json:"validProof,omitempty"`
+	VoteProof            *VoteProof                       `json:"voteProof,omitempty"`
 	Weight               *types.BigInt                    `json:"weight,omitempty"`
 }
 
@@ -245,6 +259,42 @@ type ProcessSummary struct {
 	StartBlock      uint32               `json:"startBlock,omitempty"`
 	State           string               `json:"state,omitempty"`
 	EnvelopeType    *models.EnvelopeType `json:"envelopeType,omitempty"`
+	// RegistrationPhase is true while a pre-register process accepts new
+	// keys in its rolling census, that is, before it starts.
+	RegistrationPhase bool `json:"registrationPhase,omitempty"`
+}
+
+// OracleResults contains the results of a process submitted by an oracle, and
+// whether they match the results agreed by the oracle quorum.
+type OracleResults struct {
+	Oracle  types.HexBytes `json:"oracle"`
+	Results [][]string     `json:"results"`
+	// Ranked is only set on ranked choice processes
+	Ranked    *indexertypes.RankedResults `json:"ranked,omitempty"`
+	Signature types.HexBytes              `json:"signature,omitempty"`
+	Agreed    bool                        `json:"agreed"`
+}
+
+// VoteProof contains a vote stored in the Vochain state and the Merkle proofs
+// of its inclusion, from the process votes tree up to the state root of the
+// given height.  The state root of height H is the AppHash of block H+1.
+type VoteProof struct {
+	ProcessID types.HexBytes     `json:"processId"`
+	Nullifier types.HexBytes     `json:"nullifier"`
+	Height    uint32             `json:"height"`
+	Vote      types.HexBytes     `json:"vote"`
+	ProofOps  []tmcrypto.ProofOp `json:"proofOps"`
+}
+
+// TxSimulation is the would-be result of executing a transaction against the
+// current Vochain state, without broadcasting it.
+type TxSimulation struct {
+	TxHash    types.HexBytes `json:"txHash"`
+	Valid     bool           `json:"valid"`
+	Error     string         `json:"error,omitempty"`
+	Nullifier types.HexBytes `json:"nullifier,omitempty"`
+	Cost      uint64         `json:"cost"`
+	Nonce     *uint32        `json:"nonce,omitempty"`
 }
 
 // Key associates a key string with an index, so clients can check
@@ -281,6 +331,11 @@ type NewProcess struct {
 	EnvelopeType *models.EnvelopeType       `json:"envelopeType,omitempty"`
 	VoteOptions  *models.ProcessVoteOptions `json:"voteOptions,omitempty"`
 	EthIndexSlot *uint32                    `json:"ethIndexSlot,omitempty"`
+	// EthOwnersIndexSlot is the index slot of the ERC721 owners mapping
+	EthOwnersIndexSlot *uint32 `json:"ethOwnersIndexSlot,omitempty"`
+	// TokenID is the census token of ERC1155 processes, and a token owned
+	// by the process creator on ERC721 processes
+	TokenID *types.BigInt `json:"tokenId,omitempty"`
 }
 
 func (p NewProcess) String() string {
@@ -18,6 +18,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vocdoni/arbo"
 	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/crypto/babyjubjub"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/zk/artifacts"
 	"go.vocdoni.io/dvote/log"
@@ -75,6 +76,52 @@ func (c *Client) GetEnvelopeStatus(nullifier, pid []byte) (bool, error) {
 	return *resp.Registered, nil
 }
 
+// GetEnvelopeProof returns the vote with nullifier of the process pid, together
+// with its inclusion proof in the Vochain state at height.  If height is 0, the
+// last state is used.
+func (c *Client) GetEnvelopeProof(pid, nullifier []byte, height uint32) (*api.VoteProof, error) {
+	var req api.APIrequest
+	req.Method = "getEnvelopeProof"
+	req.ProcessID = pid
+	req.Nullifier = nullifier
+	req.Height = height
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Ok || resp.VoteProof == nil {
+		return nil, fmt.Errorf("cannot get envelope proof (%s)", resp.Message)
+	}
+	return resp.VoteProof, nil
+}
+
+// VerifyEnvelopeProof fetches the inclusion proof of the vote with nullifier of
+// the process pid and verifies it against appHash, the AppHash of the header
+// of the block at height.  Since the gateway is not trusted, appHash must come
+// from another source, such as a light client or a trusted node.  Returns the
+// verified vote and its proof.
+func (c *Client) VerifyEnvelopeProof(pid, nullifier []byte, height uint32,
+	appHash []byte) (*models.StateDBVote, *api.VoteProof, error) {
+	if height < 2 {
+		return nil, nil, fmt.Errorf("invalid block height %d", height)
+	}
+	// The state after block height-1 is committed in the header of the
+	// block at height.
+	proof, err := c.GetEnvelopeProof(pid, nullifier, height-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if proof.Height != height-1 {
+		return nil, nil, fmt.Errorf("got envelope proof at height %d, expected %d",
+			proof.Height, height-1)
+	}
+	vote, err := vochain.VerifyVoteProof(proof.ProofOps, pid, nullifier, proof.Vote, appHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid envelope proof: %w", err)
+	}
+	return vote, proof, nil
+}
+
 func (c *Client) GetProof(pubkey, root []byte, digested bool) ([]byte, []byte, error) {
 	var req api.APIrequest
 	req.Method = "genProof"
@@ -231,6 +278,47 @@ func (c *Client) GetRollingCensusVoterWeight(pid []byte, address common.Address)
 	return resp.Weight.ToInt(), nil
 }
 
+// GetRollingCensusProof returns the weight of a key registered in the
+// non-anonymous rolling census of a process, and its merkle siblings.
+func (c *Client) GetRollingCensusProof(pid []byte, address common.Address) (*big.Int, []byte, error) {
+	var req api.APIrequest
+	req.Method = "getRollingCensusProof"
+	req.ProcessID = pid
+	req.VoterAddress = address.Bytes()
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !resp.Ok {
+		return nil, nil, fmt.Errorf("cannot get rolling census proof for process %x: %s", pid, resp.Message)
+	}
+	return resp.Weight.ToInt(), resp.Siblings, nil
+}
+
+// GetAccountsCensusProof returns the proof of the account address in the
+// census of the vochain tokens census process pid, and its weight.  The proof
+// can be used by the account owner or any of its delegates.
+func (c *Client) GetAccountsCensusProof(pid []byte, address common.Address) (*models.Proof, *big.Int, error) {
+	var req api.APIrequest
+	req.Method = "getAccountsCensusProof"
+	req.ProcessID = pid
+	req.VoterAddress = address.Bytes()
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !resp.Ok {
+		return nil, nil, fmt.Errorf("cannot get accounts census proof for process %x: %s", pid, resp.Message)
+	}
+	return &models.Proof{Payload: &models.Proof_VochainAccount{
+		VochainAccount: &models.ProofVochainAccount{
+			Address:  address.Bytes(),
+			Account:  resp.CensusValue,
+			Siblings: resp.Siblings,
+		},
+	}}, resp.Weight.ToInt(), nil
+}
+
 func (c *Client) TestResults(pid []byte, totalVotes int, withWeight uint64) ([][]string, error) {
 	log.Infof("waiting for results...")
 	var err error
@@ -734,6 +822,11 @@ func (c *Client) TestSendVotes(
 		if len(keys) == 0 {
 			return 0, fmt.Errorf("process keys is empty")
 		}
+		// on threshold keys, the votes are encrypted only with the
+		// shared public key
+		if keyIndexes[0] == vochain.ThresholdKeyIndex {
+			keyIndexes, keys = keyIndexes[:1], keys[:1]
+		}
 		log.Infof("got encryption keys!")
 	}
 	// Send votes
@@ -750,7 +843,12 @@ func (c *Client) TestSendVotes(
 
 	for i := 0; i < len(signers); i++ {
 		s := signers[i]
-		if vpb, err = genVote(encrypted, keys); err != nil {
+		if encrypted && keyIndexes[0] == vochain.ThresholdKeyIndex {
+			vpb, err = genThresholdVote(keys[0])
+		} else {
+			vpb, err = genVote(encrypted, keys)
+		}
+		if err != nil {
 			return 0, err
 		}
 		v := &models.VoteEnvelope{
@@ -875,6 +973,44 @@ func (c *Client) TestSendVotes(
 	return votingElapsedTime, nil
 }
 
+// SendBabyJubJubVote sends a vote envelope for the process pid signed with a
+// BabyJubJub key, whose census key is proved by siblings on an iden3 census
+// tree.  Returns the vote nullifier.
+func (c *Client) SendBabyJubJubVote(signer *babyjubjub.SignKeys, pid, votePackage,
+	siblings []byte, keyIndexes []uint32) ([]byte, error) {
+	v := &models.VoteEnvelope{
+		Nonce:                util.RandomBytes(32),
+		ProcessId:            pid,
+		VotePackage:          votePackage,
+		EncryptionKeyIndexes: keyIndexes,
+		Proof: &models.Proof{
+			Payload: &models.Proof_Iden3{
+				Iden3: &models.ProofIden3{Siblings: siblings},
+			},
+		},
+	}
+	var err error
+	stx := &models.SignedTx{}
+	if stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{Vote: v}}); err != nil {
+		return nil, err
+	}
+	if stx.Signature, err = signer.SignVocdoniTx(stx.Tx); err != nil {
+		return nil, err
+	}
+	req := api.APIrequest{Method: "submitRawTx"}
+	if req.Payload, err = proto.Marshal(stx); err != nil {
+		return nil, err
+	}
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Ok {
+		return nil, fmt.Errorf("%s failed: %s", req.Method, resp.Message)
+	}
+	return hex.DecodeString(util.TrimHex(resp.Payload))
+}
+
 func (c *Client) TestSendAnonVotes(
 	pid,
 	eid,
@@ -1177,6 +1313,39 @@ func (c *Client) GetCurrentBlock() (uint32, error) {
 	return *resp.Height, nil
 }
 
+// EstimateBlockAtDateTime returns the estimated height of the block at date.
+func (c *Client) EstimateBlockAtDateTime(date time.Time) (uint32, error) {
+	var req api.APIrequest
+	req.Method = "estimateBlockAtDateTime"
+	req.Date = date.Unix()
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Ok {
+		return 0, fmt.Errorf("%s failed: %s", req.Method, resp.Message)
+	}
+	if resp.Height == nil {
+		return 0, fmt.Errorf("height is nil")
+	}
+	return *resp.Height, nil
+}
+
+// EstimateDateAtBlock returns the estimated date of the block at height.
+func (c *Client) EstimateDateAtBlock(height uint32) (time.Time, error) {
+	var req api.APIrequest
+	req.Method = "estimateDateAtBlock"
+	req.Height = height
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !resp.Ok {
+		return time.Time{}, fmt.Errorf("%s failed: %s", req.Method, resp.Message)
+	}
+	return time.Unix(int64(resp.BlockTimestamp), 0), nil
+}
+
 // CreateCensus creates a new census on the remote gateway and publishes it.
 // Users public keys can be added using censusSigner (ethereum.SignKeys) or
 // censusPubKeys (raw hex public keys).
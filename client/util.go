@@ -8,8 +8,10 @@ import (
 	"os"
 	"time"
 
+	"go.vocdoni.io/dvote/crypto/babyjubjub"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/threshold"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/vochain"
@@ -45,6 +47,20 @@ func CreateEthRandomKeysBatch(n int) []*ethereum.SignKeys {
 	return s
 }
 
+// CreateBabyJubJubRandomKeysBatch creates n random BabyJubJub keys, which can
+// be used to vote with iden3 census proofs.
+func CreateBabyJubJubRandomKeysBatch(n int, chainID string) []*babyjubjub.SignKeys {
+	s := make([]*babyjubjub.SignKeys, n)
+	for i := 0; i < n; i++ {
+		s[i] = babyjubjub.NewSignKeys()
+		if err := s[i].Generate(); err != nil {
+			log.Fatal(err)
+		}
+		s[i].VocdoniChainID = chainID
+	}
+	return s
+}
+
 type keysBatch struct {
 	Keys      []signKey      `json:"keys"`
 	CensusID  types.HexBytes `json:"censusId"`
@@ -158,3 +174,25 @@ func genVote(encrypted bool, keys []string) ([]byte, error) {
 	}
 	return vpBytes, nil
 }
+
+// genThresholdVote generates a vote package encrypted with the hex encoded
+// shared public key of a process with threshold keys.
+func genThresholdVote(key string) ([]byte, error) {
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := threshold.DecodePoint(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode threshold key: (%s)", err)
+	}
+	vp := &vochain.VotePackage{
+		Nonce: RandomHex(rand.Intn(16) + 16),
+		Votes: []int{1, 2, 3, 4, 5, 6},
+	}
+	vpBytes, err := json.Marshal(vp)
+	if err != nil {
+		return nil, err
+	}
+	return threshold.Encrypt(vpBytes, pub)
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"go.vocdoni.io/dvote/api"
+	"go.vocdoni.io/dvote/client"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
+)
+
+var envelopeCmd = &cobra.Command{
+	Use:   "envelope proof|verify",
+	Short: "envelope subcommands",
+}
+
+var envelopeProofCmd = &cobra.Command{
+	Use:   "proof [processId] [nullifier] [height] [appHash]",
+	Short: "get and verify the inclusion proof of a vote against a trusted block header app hash",
+	RunE:  envelopeProof,
+}
+
+var envelopeVerifyCmd = &cobra.Command{
+	Use:   "verify [proofFile] [appHash]",
+	Short: "verify offline a vote inclusion proof against a block header app hash",
+	RunE:  envelopeVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(envelopeCmd)
+	envelopeCmd.AddCommand(envelopeProofCmd)
+	envelopeCmd.AddCommand(envelopeVerifyCmd)
+	envelopeProofCmd.Flags().StringP("output", "o", "", "write the proof to <file>")
+}
+
+func envelopeProof(cmd *cobra.Command, args []string) error {
+	if len(args) < 4 {
+		return fmt.Errorf("you must provide a process id, a nullifier, " +
+			"and the height and app hash of a trusted block header")
+	}
+	pid, err := hex.DecodeString(util.TrimHex(args[0]))
+	if err != nil {
+		return err
+	}
+	nullifier, err := hex.DecodeString(util.TrimHex(args[1]))
+	if err != nil {
+		return err
+	}
+	// The app hash is not fetched from the gateway, since it is the one
+	// serving the proof.
+	height, err := strconv.ParseUint(args[2], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid height: %w", err)
+	}
+	appHash, err := hex.DecodeString(util.TrimHex(args[3]))
+	if err != nil {
+		return err
+	}
+
+	cl, err := client.New(opt.host)
+	if err != nil {
+		return err
+	}
+	defer cl.CheckClose(&err)
+
+	vote, proof, err := cl.VerifyEnvelopeProof(pid, nullifier, uint32(height), appHash)
+	if err != nil {
+		return err
+	}
+	proofJSON, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		return err
+	}
+	if output, _ := cmd.Flags().GetString("output"); output != "" {
+		if err := os.WriteFile(output, proofJSON, 0o644); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println(string(proofJSON))
+	}
+	fmt.Printf("Valid proof at height %d for vote hash %x\n", proof.Height, vote.VoteHash)
+	return err
+}
+
+func envelopeVerify(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("you must provide a proof file and an app hash")
+	}
+	proofJSON, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	appHash, err := hex.DecodeString(util.TrimHex(args[1]))
+	if err != nil {
+		return err
+	}
+	var proof api.VoteProof
+	if err := json.Unmarshal(proofJSON, &proof); err != nil {
+		return fmt.Errorf("cannot decode proof: %w", err)
+	}
+	vote, err := vochain.VerifyVoteProof(proof.ProofOps, proof.ProcessID,
+		proof.Nullifier, proof.Vote, appHash)
+	if err != nil {
+		return fmt.Errorf("invalid proof: %w", err)
+	}
+	fmt.Printf("Valid proof at height %d for vote hash %x\n", proof.Height, vote.VoteHash)
+	return nil
+}
@@ -160,6 +160,26 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 		"enables the process archiver component")
 	globalCfg.VochainConfig.ProcessArchiveKey = *flag.String("processArchiveKey", "",
 		"IPFS base64 encoded private key for process archive IPNS")
+	globalCfg.VochainConfig.Scrutinizer.Reindex = *flag.Bool("vochainScrutinizerReindex", false,
+		"rebuild the scrutinizer indexes from the stored blocks, replacing the current ones once done")
+	globalCfg.VochainConfig.SnapshotInterval = *flag.Uint32("vochainSnapshotInterval", 0,
+		"number of blocks between state snapshots served to other nodes via state sync (0 disables them)")
+	globalCfg.VochainConfig.SnapshotKeepRecent = *flag.Int("vochainSnapshotKeepRecent", 2,
+		"number of recent state snapshots to keep (0 keeps all)")
+	globalCfg.VochainConfig.StateSync = *flag.Bool("vochainStateSync", false,
+		"bootstrap the vochain state from a snapshot provided by peers instead of replaying all blocks")
+	globalCfg.VochainConfig.StateSyncRPCServers = *flag.StringSlice("vochainStateSyncRPCServers", []string{},
+		"comma-separated list of at least two tendermint RPC servers used to verify state sync")
+	globalCfg.VochainConfig.StateSyncTrustHeight = *flag.Int64("vochainStateSyncTrustHeight", 0,
+		"trusted block height for state sync")
+	globalCfg.VochainConfig.StateSyncTrustHash = *flag.String("vochainStateSyncTrustHash", "",
+		"trusted block hash (hex) for state sync")
+	globalCfg.VochainConfig.PruneKeepRecent = *flag.Uint32("vochainPruneKeepRecent", 0,
+		"if greater than 0, prune the old state versions keeping only the given number of recent ones")
+	globalCfg.VochainConfig.PruneKeepEvery = *flag.Uint32("vochainPruneKeepEvery", 0,
+		"if greater than 0, prune the old state versions keeping every Nth one")
+	globalCfg.VochainConfig.PruneInterval = *flag.Uint32("vochainPruneInterval", 1000,
+		"number of blocks between state prunings")
 
 	// metrics
 	globalCfg.Metrics.Enabled = *flag.Bool("metricsEnabled", false, "enable prometheus metrics")
@@ -256,6 +276,16 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	viper.Set("vochainConfig.ProcessArchiveDataDir", globalCfg.DataDir+"/archive")
 	viper.BindPFlag("vochainConfig.ProcessArchive", flag.Lookup("processArchive"))
 	viper.BindPFlag("vochainConfig.ProcessArchiveKey", flag.Lookup("processArchiveKey"))
+	viper.BindPFlag("vochainConfig.Scrutinizer.Reindex", flag.Lookup("vochainScrutinizerReindex"))
+	viper.BindPFlag("vochainConfig.SnapshotInterval", flag.Lookup("vochainSnapshotInterval"))
+	viper.BindPFlag("vochainConfig.SnapshotKeepRecent", flag.Lookup("vochainSnapshotKeepRecent"))
+	viper.BindPFlag("vochainConfig.StateSync", flag.Lookup("vochainStateSync"))
+	viper.BindPFlag("vochainConfig.StateSyncRPCServers", flag.Lookup("vochainStateSyncRPCServers"))
+	viper.BindPFlag("vochainConfig.StateSyncTrustHeight", flag.Lookup("vochainStateSyncTrustHeight"))
+	viper.BindPFlag("vochainConfig.StateSyncTrustHash", flag.Lookup("vochainStateSyncTrustHash"))
+	viper.BindPFlag("vochainConfig.PruneKeepRecent", flag.Lookup("vochainPruneKeepRecent"))
+	viper.BindPFlag("vochainConfig.PruneKeepEvery", flag.Lookup("vochainPruneKeepEvery"))
+	viper.BindPFlag("vochainConfig.PruneInterval", flag.Lookup("vochainPruneInterval"))
 
 	// metrics
 	viper.BindPFlag("metrics.Enabled", flag.Lookup("metricsEnabled"))
@@ -630,6 +660,9 @@ func main() {
 			if err := apior.EnableERC20(globalCfg.W3Config.ChainType, globalCfg.W3Config.W3External); err != nil {
 				log.Fatal(err)
 			}
+			if err := apior.EnableNFT(globalCfg.W3Config.ChainType, globalCfg.W3Config.W3External); err != nil {
+				log.Fatal(err)
+			}
 		}
 	}
 
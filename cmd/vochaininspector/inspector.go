@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -26,6 +27,7 @@ import (
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/scrutinizer"
 	"go.vocdoni.io/dvote/vochain/vochaininfo"
 	"go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
@@ -49,7 +51,8 @@ func main() {
 	listProcess = list voting processes from the state at specific height
 	listVotes = list votes from the state at specific height
 	listBlockVotes = list existing votes from a block (with nullifier)
-	stateGraph = prints the graphViz of the state main tree`)
+	stateGraph = prints the graphViz of the state main tree
+	reindex = rebuild the scrutinizer indexes from the blockstore`)
 	flag.IntVar(&blockHeight, "height", 0, "height block to inspect")
 	flag.StringVar(&pid, "processId", "", "processId as hexadecimal string")
 
@@ -124,6 +127,32 @@ func main() {
 			fmt.Printf("block %d AppHash:%s BlkHash:%s txs:%d\n", i, blk.AppHash, blk.Hash(), len(blk.Txs))
 		}
 
+	case "reindex":
+		vnode := newVochain(chain, dataDir)
+		vnode.Node.Stop()
+		height, err := vnode.State.LastHeight()
+		if err != nil {
+			log.Fatal(err)
+		}
+		sc, err := scrutinizer.NewScrutinizer(filepath.Join(dataDir, "scrutinizer"), vnode, true)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Use the same directory as the node reindex, so each one can
+		// continue the other
+		r, err := scrutinizer.NewReindexer(filepath.Join(dataDir, "scrutinizer-reindex"),
+			vnode, db.TypePebble, vnode.Node.GenesisDoc().AppState)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("reindexing blocks %d to %d", r.Height()+1, height)
+		if err := r.Replay(context.Background(), height); err != nil {
+			log.Fatal(err)
+		}
+		if err := sc.ApplyReindex(r); err != nil {
+			log.Fatal(err)
+		}
+
 	case "block":
 		vnode := newVochain(chain, dataDir)
 		vnode.Node.Stop()
@@ -198,6 +198,29 @@ type VochainCfg struct {
 	Scrutinizer ScrutinizerCfg
 	// IsSeedNode specifies if the node is configured to act as a seed node
 	IsSeedNode bool
+	// SnapshotInterval is the number of blocks between state snapshots served
+	// to other nodes via state sync (0 disables snapshot creation)
+	SnapshotInterval uint32
+	// SnapshotKeepRecent is the number of state snapshots to keep (0 keeps all)
+	SnapshotKeepRecent int
+	// StateSync if enabled the node bootstraps its state from a snapshot
+	// provided by its peers instead of replaying all the blocks
+	StateSync bool
+	// StateSyncRPCServers is the list of tendermint RPC servers (at least two)
+	// used to verify the light client headers during state sync
+	StateSyncRPCServers []string
+	// StateSyncTrustHeight is the trusted block height for state sync
+	StateSyncTrustHeight int64
+	// StateSyncTrustHash is the hash of the trusted block for state sync
+	StateSyncTrustHash string
+	// PruneKeepRecent if greater than 0 enables the pruning of the old state
+	// versions, keeping only the given number of recent versions
+	PruneKeepRecent uint32
+	// PruneKeepEvery if greater than 0 enables the pruning of the old state
+	// versions, keeping every Nth version (along with the recent ones)
+	PruneKeepEvery uint32
+	// PruneInterval is the number of blocks between state prunings
+	PruneInterval uint32
 }
 
 // ScrutinizerCfg handles the configuration options of the scrutinizer
@@ -206,6 +229,8 @@ type ScrutinizerCfg struct {
 	Enabled bool
 	// Disables live results computation on scrutinizer
 	IgnoreLiveResults bool
+	// Reindex rebuilds the scrutinizer indexes from the stored blocks
+	Reindex bool
 }
 
 // OracleCfg includes all possible config params needed by the Oracle
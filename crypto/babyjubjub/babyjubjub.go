@@ -0,0 +1,177 @@
+// Package babyjubjub implements EdDSA signatures over the BabyJubJub curve,
+// using the Poseidon hash, as used by the iden3 identity keys.
+//
+// EdDSA public keys cannot be recovered from signatures, so the signatures of
+// Vocdoni transactions carry the compressed public key of the signer followed
+// by the compressed signature.
+package babyjubjub
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/util"
+)
+
+// PubKeyLength is the length of a compressed BabyJubJub public key.
+const PubKeyLength = 32
+
+// SignatureLength is the length of a compressed BabyJubJub EdDSA signature.
+const SignatureLength = 64
+
+// VocdoniSignatureLength is the length of a signed Vocdoni transaction
+// signature, which contains the signer public key and the signature.
+const VocdoniSignatureLength = PubKeyLength + SignatureLength
+
+// SignKeys represents a BabyJubJub key pair.
+type SignKeys struct {
+	Private babyjub.PrivateKey
+	Public  *babyjub.PublicKey
+	// VocdoniChainID is the chain ID used to sign Vocdoni transactions.
+	VocdoniChainID string
+}
+
+// NewSignKeys returns an empty SignKeys struct.
+func NewSignKeys() *SignKeys {
+	return &SignKeys{}
+}
+
+// Generate generates a new random key pair.
+func (k *SignKeys) Generate() error {
+	k.Private = babyjub.NewRandPrivKey()
+	k.Public = k.Private.Public()
+	return nil
+}
+
+// AddHexKey imports a private key from its hex representation.
+func (k *SignKeys) AddHexKey(privHex string) error {
+	priv, err := hex.DecodeString(util.TrimHex(privHex))
+	if err != nil {
+		return err
+	}
+	if len(priv) != len(k.Private) {
+		return fmt.Errorf("wrong private key length %d", len(priv))
+	}
+	copy(k.Private[:], priv)
+	k.Public = k.Private.Public()
+	return nil
+}
+
+// PublicKey returns the compressed public key.
+func (k *SignKeys) PublicKey() []byte {
+	pub := k.Public.Compress()
+	return pub[:]
+}
+
+// Address returns the address derived from the public key.
+func (k *SignKeys) Address() ethcommon.Address {
+	return AddrFromPublicKey(k.PublicKey())
+}
+
+// Sign signs a message, returning the compressed signature.
+func (k *SignKeys) Sign(message []byte) ([]byte, error) {
+	if k.Public == nil {
+		return nil, errors.New("no private key available")
+	}
+	msg, err := hashMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	sig := k.Private.SignPoseidon(msg).Compress()
+	return sig[:], nil
+}
+
+// SignVocdoniTx signs a vocdoni transaction. TxData is the full transaction
+// payload (no HexString nor a Hash).  The returned signature contains the
+// public key followed by the signature.
+func (k *SignKeys) SignVocdoniTx(txData []byte) ([]byte, error) {
+	sig, err := k.Sign(ethereum.BuildVocdoniTransaction(txData, k.VocdoniChainID))
+	if err != nil {
+		return nil, err
+	}
+	return append(k.PublicKey(), sig...), nil
+}
+
+// Verify checks that signature is a valid signature of message by the
+// compressed public key pubKey.
+func Verify(pubKey, message, signature []byte) error {
+	if len(pubKey) != PubKeyLength {
+		return fmt.Errorf("wrong public key length %d", len(pubKey))
+	}
+	if len(signature) != SignatureLength {
+		return fmt.Errorf("wrong signature length %d", len(signature))
+	}
+	var pubComp babyjub.PublicKeyComp
+	copy(pubComp[:], pubKey)
+	pub, err := pubComp.Decompress()
+	if err != nil {
+		return fmt.Errorf("cannot decompress public key: %w", err)
+	}
+	var sigComp babyjub.SignatureComp
+	copy(sigComp[:], signature)
+	sig, err := sigComp.Decompress()
+	if err != nil {
+		return fmt.Errorf("cannot decompress signature: %w", err)
+	}
+	msg, err := hashMessage(message)
+	if err != nil {
+		return err
+	}
+	if !pub.VerifyPoseidon(msg, sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// PubKeyFromSignature verifies a signature of a Vocdoni transaction, as
+// returned by SignVocdoniTx, and returns the compressed public key of the
+// signer.  Message is the signed body built with
+// ethereum.BuildVocdoniTransaction.
+func PubKeyFromSignature(message, signature []byte) ([]byte, error) {
+	if len(signature) != VocdoniSignatureLength {
+		return nil, fmt.Errorf("wrong signature length %d", len(signature))
+	}
+	pubKey := signature[:PubKeyLength]
+	if err := Verify(pubKey, message, signature[PubKeyLength:]); err != nil {
+		return nil, err
+	}
+	return pubKey, nil
+}
+
+// AddrFromPublicKey returns the address derived from a compressed BabyJubJub
+// public key, computed as the last 20 bytes of its keccak256 hash.
+func AddrFromPublicKey(pubKey []byte) ethcommon.Address {
+	return ethcommon.BytesToAddress(ethereum.HashRaw(pubKey)[12:])
+}
+
+// CensusKey returns the key of a compressed BabyJubJub public key in a
+// Poseidon census tree, which is the Poseidon hash of the public key point
+// coordinates.
+func CensusKey(pubKey []byte) (*big.Int, error) {
+	if len(pubKey) != PubKeyLength {
+		return nil, fmt.Errorf("wrong public key length %d", len(pubKey))
+	}
+	var pubComp babyjub.PublicKeyComp
+	copy(pubComp[:], pubKey)
+	pub, err := pubComp.Decompress()
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress public key: %w", err)
+	}
+	return poseidon.Hash([]*big.Int{pub.X, pub.Y})
+}
+
+// hashMessage returns the Poseidon hash of message, which is the value
+// actually signed.
+func hashMessage(message []byte) (*big.Int, error) {
+	msg, err := poseidon.HashBytes(message)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash message: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,46 @@
+package babyjubjub
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+)
+
+func TestSignature(t *testing.T) {
+	t.Parallel()
+
+	s := NewSignKeys()
+	qt.Assert(t, s.Generate(), qt.IsNil)
+	message := []byte("hello")
+	signature, err := s.Sign(message)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, signature, qt.HasLen, SignatureLength)
+	qt.Assert(t, Verify(s.PublicKey(), message, signature), qt.IsNil)
+	qt.Assert(t, Verify(s.PublicKey(), []byte("bye"), signature), qt.Not(qt.IsNil))
+
+	s2 := NewSignKeys()
+	qt.Assert(t, s2.Generate(), qt.IsNil)
+	qt.Assert(t, Verify(s2.PublicKey(), message, signature), qt.Not(qt.IsNil))
+}
+
+func TestVocdoniTx(t *testing.T) {
+	t.Parallel()
+
+	s := NewSignKeys()
+	qt.Assert(t, s.Generate(), qt.IsNil)
+	s.VocdoniChainID = "test"
+	tx := []byte("transaction")
+	signature, err := s.SignVocdoniTx(tx)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, signature, qt.HasLen, VocdoniSignatureLength)
+
+	pubKey, err := PubKeyFromSignature(ethereum.BuildVocdoniTransaction(tx, "test"), signature)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, pubKey, qt.DeepEquals, s.PublicKey())
+	qt.Assert(t, AddrFromPublicKey(pubKey), qt.Equals, s.Address())
+
+	// a different chain id invalidates the signature
+	_, err = PubKeyFromSignature(ethereum.BuildVocdoniTransaction(tx, "other"), signature)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
@@ -0,0 +1,452 @@
+package threshold
+
+import (
+	"bytes"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-iden3-crypto/constants"
+)
+
+// Homomorphic tally
+//
+// Values are encrypted with exponential ElGamal under the shared public key
+// Y: Enc(m) = (r·G, m·G + r·Y).  Ciphertexts can be added and multiplied by
+// a scalar, so the sum of the encrypted values can be decrypted without
+// decrypting any of them.  Decryption is done jointly by the participants,
+// each one publishing a partial decryption s_i·C1 along with a proof that it
+// was computed with its share s_i, and requires solving a bounded discrete
+// logarithm.
+
+const (
+	// CiphertextLength is the length of a marshaled Ciphertext.
+	CiphertextLength = 2 * PointLength
+	// BitProofLength is the length of the proof that a ciphertext encrypts
+	// either 0 or 1.
+	BitProofLength = 4 * ScalarLength
+	// DLEQProofLength is the length of a discrete logarithm equality proof.
+	DLEQProofLength = 2 * ScalarLength
+	// DecryptionShareLength is the length of a marshaled partial decryption
+	// with its proof.
+	DecryptionShareLength = PointLength + DLEQProofLength
+)
+
+// Ciphertext is an exponential ElGamal ciphertext.
+type Ciphertext struct {
+	C1 *babyjub.Point
+	C2 *babyjub.Point
+}
+
+// NewCiphertext returns the encryption of zero with no randomness, which is
+// the neutral element of Add.
+func NewCiphertext() *Ciphertext {
+	return &Ciphertext{C1: babyjub.NewPoint(), C2: babyjub.NewPoint()}
+}
+
+// EncryptValue encrypts the value m for the shared public key pubKey,
+// returning the ciphertext and the randomness used.
+func EncryptValue(m *big.Int, pubKey *babyjub.Point) (*Ciphertext, *big.Int, error) {
+	r, err := randomScalar(cryptorand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encryptValue(m, r, pubKey), r, nil
+}
+
+func encryptValue(m, r *big.Int, pubKey *babyjub.Point) *Ciphertext {
+	return &Ciphertext{
+		C1: mulPoint(r, babyjub.B8),
+		C2: addPoints(mulPoint(m, babyjub.B8), mulPoint(r, pubKey)),
+	}
+}
+
+// Add returns the ciphertext of the sum of the values of c and o.
+func (c *Ciphertext) Add(o *Ciphertext) *Ciphertext {
+	return &Ciphertext{C1: addPoints(c.C1, o.C1), C2: addPoints(c.C2, o.C2)}
+}
+
+// Mul returns the ciphertext of the value of c multiplied by k.
+func (c *Ciphertext) Mul(k *big.Int) *Ciphertext {
+	return &Ciphertext{C1: mulPoint(k, c.C1), C2: mulPoint(k, c.C2)}
+}
+
+// Marshal encodes the ciphertext as its two compressed points.
+func (c *Ciphertext) Marshal() []byte {
+	return append(EncodePoint(c.C1), EncodePoint(c.C2)...)
+}
+
+// UnmarshalCiphertext decodes a ciphertext encoded with Marshal.
+func UnmarshalCiphertext(data []byte) (*Ciphertext, error) {
+	if len(data) != CiphertextLength {
+		return nil, fmt.Errorf("wrong ciphertext length %d", len(data))
+	}
+	c1, err := decodePoint(data[:PointLength])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	c2, err := decodePoint(data[PointLength:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	return &Ciphertext{C1: c1, C2: c2}, nil
+}
+
+// EncryptBallot encrypts a ballot for the shared public key pubKey.  For each
+// question, choices contains the index of the chosen option out of options,
+// which is encrypted as one ciphertext per option: 1 for the chosen option
+// and 0 for the rest.  Each ciphertext includes a proof that it encrypts
+// either 0 or 1, and each question a proof that its ciphertexts add up to 1,
+// so the ballot can be verified without decrypting it.  The proofs are bound
+// to context, such as the process and voter of the ballot, so they cannot be
+// replayed on another one.
+func EncryptBallot(choices []int, options int, pubKey *babyjub.Point,
+	context []byte) ([]byte, error) {
+	var b bytes.Buffer
+	for q, choice := range choices {
+		if choice < 0 || choice >= options {
+			return nil, fmt.Errorf("invalid choice %d for question %d", choice, q)
+		}
+		sum := new(big.Int)
+		for o := 0; o < options; o++ {
+			m := 0
+			if o == choice {
+				m = 1
+			}
+			ct, r, err := EncryptValue(big.NewInt(int64(m)), pubKey)
+			if err != nil {
+				return nil, err
+			}
+			proof, err := proveBit(ct, m, r, pubKey, context)
+			if err != nil {
+				return nil, err
+			}
+			b.Write(ct.Marshal())
+			b.Write(proof)
+			sum.Add(sum, r)
+		}
+		sum.Mod(sum, babyjub.SubOrder)
+		ct := encryptValue(big.NewInt(1), sum, pubKey)
+		proof, err := proveDLEQ(context, sum, babyjub.B8, pubKey, ct.C1,
+			subPoints(ct.C2, babyjub.B8))
+		if err != nil {
+			return nil, err
+		}
+		b.Write(proof)
+	}
+	return b.Bytes(), nil
+}
+
+// BallotLength returns the length of an encrypted ballot with the given
+// number of questions and options.
+func BallotLength(questions, options int) int {
+	return questions * (options*(CiphertextLength+BitProofLength) + DLEQProofLength)
+}
+
+// UnmarshalBallot returns the ciphertexts of a ballot encrypted with
+// EncryptBallot, indexed by question and option, without verifying its proofs.
+func UnmarshalBallot(data []byte, questions, options int) ([][]*Ciphertext, error) {
+	if questions < 1 || options < 1 || len(data) != BallotLength(questions, options) {
+		return nil, fmt.Errorf("wrong ballot length %d", len(data))
+	}
+	ballot := make([][]*Ciphertext, questions)
+	for q := range ballot {
+		for o := 0; o < options; o++ {
+			ct, err := UnmarshalCiphertext(data[:CiphertextLength])
+			if err != nil {
+				return nil, fmt.Errorf("question %d option %d: %w", q, o, err)
+			}
+			data = data[CiphertextLength+BitProofLength:]
+			ballot[q] = append(ballot[q], ct)
+		}
+		data = data[DLEQProofLength:]
+	}
+	return ballot, nil
+}
+
+// VerifyBallot verifies a ballot encrypted with EncryptBallot for context and
+// returns its ciphertexts, indexed by question and option.
+func VerifyBallot(data []byte, questions, options int,
+	pubKey *babyjub.Point, context []byte) ([][]*Ciphertext, error) {
+	ballot, err := UnmarshalBallot(data, questions, options)
+	if err != nil {
+		return nil, err
+	}
+	for q := range ballot {
+		sum := NewCiphertext()
+		for o, ct := range ballot[q] {
+			data = data[CiphertextLength:]
+			if !verifyBit(ct, data[:BitProofLength], pubKey, context) {
+				return nil, fmt.Errorf("question %d option %d: invalid proof", q, o)
+			}
+			data = data[BitProofLength:]
+			sum = sum.Add(ct)
+		}
+		if !verifyDLEQ(context, data[:DLEQProofLength], babyjub.B8, pubKey, sum.C1,
+			subPoints(sum.C2, babyjub.B8)) {
+			return nil, fmt.Errorf("question %d: invalid sum proof", q)
+		}
+		data = data[DLEQProofLength:]
+	}
+	return ballot, nil
+}
+
+// NewDecryptionShare returns the partial decryption of ct with the share of
+// a participant, along with the proof that it was computed with the share.
+func NewDecryptionShare(ct *Ciphertext, share *big.Int) ([]byte, error) {
+	d := mulPoint(share, ct.C1)
+	proof, err := proveDLEQ(nil, share, babyjub.B8, ct.C1, mulPoint(share, babyjub.B8), d)
+	if err != nil {
+		return nil, err
+	}
+	return append(EncodePoint(d), proof...), nil
+}
+
+// VerifyDecryptionShare verifies a partial decryption of ct created with
+// NewDecryptionShare, being publicShare the public share of the participant,
+// and returns the partial decryption.
+func VerifyDecryptionShare(ct *Ciphertext, publicShare *babyjub.Point,
+	data []byte) (*babyjub.Point, error) {
+	if len(data) != DecryptionShareLength {
+		return nil, fmt.Errorf("wrong decryption share length %d", len(data))
+	}
+	d, err := decodePoint(data[:PointLength])
+	if err != nil {
+		return nil, fmt.Errorf("invalid decryption share: %w", err)
+	}
+	if !verifyDLEQ(nil, data[PointLength:], babyjub.B8, ct.C1, publicShare, d) {
+		return nil, fmt.Errorf("invalid decryption share proof")
+	}
+	return d, nil
+}
+
+// CombineDecryption combines the partial decryptions of ct of at least
+// threshold participants, indexed by the participant index, and returns the
+// encrypted value as a point m·G.
+func CombineDecryption(ct *Ciphertext, shares map[int]*babyjub.Point) (*babyjub.Point, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no decryption shares provided")
+	}
+	indexes := make([]int, 0, len(shares))
+	for i := range shares {
+		indexes = append(indexes, i)
+	}
+	acc := babyjub.NewPoint().Projective()
+	for i, d := range shares {
+		coef, err := lagrangeCoefficient(i, indexes)
+		if err != nil {
+			return nil, err
+		}
+		acc = acc.Add(acc, mulPoint(coef, d).Projective())
+	}
+	return subPoints(ct.C2, acc.Affine()), nil
+}
+
+// ValuePoint returns m·G, which can be compared with the result of
+// CombineDecryption to verify a decrypted value.
+func ValuePoint(m *big.Int) *babyjub.Point {
+	return mulPoint(m, babyjub.B8)
+}
+
+// DiscreteLogTable solves discrete logarithms up to a maximum value with the
+// baby-step giant-step algorithm.  Building the table costs the square root
+// of the maximum, so a table should be reused to solve several values with
+// the same bound.
+type DiscreteLogTable struct {
+	max   uint64
+	steps uint64
+	baby  map[[PointLength]byte]uint64
+	// giant is -steps·G
+	giant *babyjub.Point
+}
+
+// NewDiscreteLogTable returns the table to solve discrete logarithms up to
+// max.
+func NewDiscreteLogTable(max uint64) *DiscreteLogTable {
+	steps := uint64(math.Sqrt(float64(max))) + 1
+	baby := make(map[[PointLength]byte]uint64, steps)
+	acc := babyjub.NewPoint().Projective()
+	g := babyjub.B8.Projective()
+	for j := uint64(0); j < steps; j++ {
+		baby[acc.Affine().Compress()] = j
+		acc = acc.Add(acc, g)
+	}
+	// acc is now steps·G
+	return &DiscreteLogTable{
+		max:   max,
+		steps: steps,
+		baby:  baby,
+		giant: negPoint(acc.Affine()),
+	}
+}
+
+// Solve returns the value m such that p = m·G, being m at most the maximum
+// of the table.
+func (t *DiscreteLogTable) Solve(p *babyjub.Point) (uint64, error) {
+	giant := t.giant.Projective()
+	cur := p.Projective()
+	for i := uint64(0); i <= t.steps; i++ {
+		if j, ok := t.baby[cur.Affine().Compress()]; ok {
+			if m := i*t.steps + j; m <= t.max {
+				return m, nil
+			}
+		}
+		cur = cur.Add(cur, giant)
+	}
+	return 0, fmt.Errorf("discrete logarithm not found up to %d", t.max)
+}
+
+// DiscreteLog returns the value m such that p = m·G, being m at most max.
+// To solve several values with the same bound, use a DiscreteLogTable.
+func DiscreteLog(p *babyjub.Point, max uint64) (uint64, error) {
+	return NewDiscreteLogTable(max).Solve(p)
+}
+
+// ParticipantPublicShare returns the public share s_i·G of the participant
+// index on the shared key generated by the dealings.
+func ParticipantPublicShare(dealings []*Dealing, index int) *babyjub.Point {
+	acc := babyjub.NewPoint().Projective()
+	for _, d := range dealings {
+		acc = acc.Add(acc, d.PublicShare(index).Projective())
+	}
+	return acc.Affine()
+}
+
+// proveBit proves that ct, encrypted with randomness r, encrypts m, being m
+// either 0 or 1, without revealing which one.  It is a disjunction of two
+// Chaum-Pedersen proofs where the branch of the other value is simulated.
+func proveBit(ct *Ciphertext, m int, r *big.Int, pubKey *babyjub.Point,
+	context []byte) ([]byte, error) {
+	var c, z [2]*big.Int
+	var a, b [2]*babyjub.Point
+	w, err := randomScalar(cryptorand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	a[m], b[m] = mulPoint(w, babyjub.B8), mulPoint(w, pubKey)
+	s := 1 - m
+	if c[s], err = randomScalar(cryptorand.Reader); err != nil {
+		return nil, err
+	}
+	if z[s], err = randomScalar(cryptorand.Reader); err != nil {
+		return nil, err
+	}
+	a[s], b[s] = simulateDLEQ(c[s], z[s], babyjub.B8, pubKey, ct.C1,
+		subPoints(ct.C2, mulPoint(big.NewInt(int64(s)), babyjub.B8)))
+	ch := challenge(context, pubKey, ct.C1, ct.C2, a[0], b[0], a[1], b[1])
+	c[m] = new(big.Int).Sub(ch, c[s])
+	c[m].Mod(c[m], babyjub.SubOrder)
+	z[m] = new(big.Int).Mul(c[m], r)
+	z[m].Add(z[m], w).Mod(z[m], babyjub.SubOrder)
+	proof := append(scalarBytes(c[0]), scalarBytes(c[1])...)
+	proof = append(proof, scalarBytes(z[0])...)
+	return append(proof, scalarBytes(z[1])...), nil
+}
+
+func verifyBit(ct *Ciphertext, proof []byte, pubKey *babyjub.Point, context []byte) bool {
+	var c, z [2]*big.Int
+	var a, b [2]*babyjub.Point
+	for k := 0; k < 2; k++ {
+		var err error
+		if c[k], err = DecodeScalar(proof[k*ScalarLength : (k+1)*ScalarLength]); err != nil {
+			return false
+		}
+		if z[k], err = DecodeScalar(proof[(k+2)*ScalarLength : (k+3)*ScalarLength]); err != nil {
+			return false
+		}
+		a[k], b[k] = simulateDLEQ(c[k], z[k], babyjub.B8, pubKey, ct.C1,
+			subPoints(ct.C2, mulPoint(big.NewInt(int64(k)), babyjub.B8)))
+	}
+	ch := challenge(context, pubKey, ct.C1, ct.C2, a[0], b[0], a[1], b[1])
+	sum := new(big.Int).Add(c[0], c[1])
+	return sum.Mod(sum, babyjub.SubOrder).Cmp(ch) == 0
+}
+
+// proveDLEQ proves that p = x·g and q = x·h, without revealing x.  The proof
+// is bound to context.
+func proveDLEQ(context []byte, x *big.Int, g, h, p, q *babyjub.Point) ([]byte, error) {
+	k, err := randomScalar(cryptorand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	c := challenge(context, g, h, p, q, mulPoint(k, g), mulPoint(k, h))
+	z := new(big.Int).Mul(c, x)
+	z.Add(z, k).Mod(z, babyjub.SubOrder)
+	return append(scalarBytes(c), scalarBytes(z)...), nil
+}
+
+func verifyDLEQ(context, proof []byte, g, h, p, q *babyjub.Point) bool {
+	if len(proof) != DLEQProofLength {
+		return false
+	}
+	c, err := DecodeScalar(proof[:ScalarLength])
+	if err != nil {
+		return false
+	}
+	z, err := DecodeScalar(proof[ScalarLength:])
+	if err != nil {
+		return false
+	}
+	a, b := simulateDLEQ(c, z, g, h, p, q)
+	return challenge(context, g, h, p, q, a, b).Cmp(c) == 0
+}
+
+// simulateDLEQ returns the commitments z·g - c·p and z·h - c·q of a discrete
+// logarithm equality proof with challenge c and response z.
+func simulateDLEQ(c, z *big.Int, g, h, p, q *babyjub.Point) (*babyjub.Point, *babyjub.Point) {
+	return subPoints(mulPoint(z, g), mulPoint(c, p)), subPoints(mulPoint(z, h), mulPoint(c, q))
+}
+
+// challenge derives a Fiat-Shamir challenge from the context and the points.
+func challenge(context []byte, points ...*babyjub.Point) *big.Int {
+	h := sha256.New()
+	h.Write([]byte("vocdoni/threshold"))
+	// the context is length prefixed so it cannot be confused with a point
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(context)))
+	h.Write(l[:])
+	h.Write(context)
+	for _, p := range points {
+		h.Write(EncodePoint(p))
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(h.Sum(nil)), babyjub.SubOrder)
+}
+
+// lagrangeCoefficient returns the lagrange coefficient of i evaluated at 0
+// for the participants of indexes: prod(j / (j - i)).
+func lagrangeCoefficient(i int, indexes []int) (*big.Int, error) {
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, j := range indexes {
+		if j == i {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		den.Mul(den, big.NewInt(int64(j-i)))
+	}
+	den.Mod(den, babyjub.SubOrder)
+	if den.ModInverse(den, babyjub.SubOrder) == nil {
+		return nil, fmt.Errorf("invalid share index %d", i)
+	}
+	return num.Mul(num, den).Mod(num, babyjub.SubOrder), nil
+}
+
+func mulPoint(k *big.Int, p *babyjub.Point) *babyjub.Point {
+	return babyjub.NewPoint().Mul(new(big.Int).Mod(k, babyjub.SubOrder), p)
+}
+
+func addPoints(a, b *babyjub.Point) *babyjub.Point {
+	return a.Projective().Add(a.Projective(), b.Projective()).Affine()
+}
+
+func negPoint(p *babyjub.Point) *babyjub.Point {
+	x := new(big.Int).Neg(p.X)
+	return &babyjub.Point{X: x.Mod(x, constants.Q), Y: new(big.Int).Set(p.Y)}
+}
+
+func subPoints(a, b *babyjub.Point) *babyjub.Point {
+	return addPoints(a, negPoint(b))
+}
@@ -0,0 +1,115 @@
+package threshold
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+
+	"go.vocdoni.io/dvote/crypto"
+	"go.vocdoni.io/dvote/crypto/nacl"
+)
+
+func TestHomomorphicTally(t *testing.T) {
+	t.Parallel()
+
+	const threshold, participants = 2, 3
+	const questions, options = 2, 3
+	transport := make([]crypto.Cipher, participants)
+	transportKeys := make([][]byte, participants)
+	for i := range transport {
+		var err error
+		transport[i], err = nacl.Generate(rand.Reader)
+		qt.Assert(t, err, qt.IsNil)
+		transportKeys[i] = transport[i].Public().Bytes()
+	}
+	dealings := make([]*Dealing, participants)
+	for i := range dealings {
+		var err error
+		dealings[i], err = NewDealing([]byte(fmt.Sprintf("seed%d", i)), threshold,
+			transportKeys[i], transportKeys)
+		qt.Assert(t, err, qt.IsNil)
+	}
+	pubKey := SharedPublicKey(dealings)
+	context := []byte("process and voter")
+
+	// weighted ballots are added up without decrypting them
+	tally := make([][]*Ciphertext, questions)
+	for q := range tally {
+		for o := 0; o < options; o++ {
+			tally[q] = append(tally[q], NewCiphertext())
+		}
+	}
+	for _, b := range []struct {
+		choices []int
+		weight  int64
+	}{
+		{[]int{0, 2}, 1},
+		{[]int{1, 2}, 3},
+		{[]int{0, 0}, 2},
+	} {
+		data, err := EncryptBallot(b.choices, options, pubKey, context)
+		qt.Assert(t, err, qt.IsNil)
+		ballot, err := VerifyBallot(data, questions, options, pubKey, context)
+		qt.Assert(t, err, qt.IsNil)
+		for q := range ballot {
+			for o, ct := range ballot[q] {
+				tally[q][o] = tally[q][o].Add(ct.Mul(big.NewInt(b.weight)))
+			}
+		}
+	}
+
+	// ballots with values other than a single 1 per question are rejected
+	_, err := EncryptBallot([]int{options}, options, pubKey, context)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	data, err := EncryptBallot([]int{0, 1}, options, pubKey, context)
+	qt.Assert(t, err, qt.IsNil)
+	_, err = VerifyBallot(data, questions, options+1, pubKey, context)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	forged := append([]byte{}, data...)
+	ct, _, err := EncryptValue(big.NewInt(2), pubKey)
+	qt.Assert(t, err, qt.IsNil)
+	copy(forged, ct.Marshal())
+	_, err = VerifyBallot(forged, questions, options, pubKey, context)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	other := babyjub.NewPoint().Mul(big.NewInt(7), babyjub.B8)
+	_, err = VerifyBallot(data, questions, options, other, context)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	// the proofs cannot be replayed on another process or voter
+	_, err = VerifyBallot(data, questions, options, pubKey, []byte("another voter"))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// the participants 1 and 3 decrypt the tally
+	expected := [][]uint64{{3, 3, 0}, {2, 0, 4}}
+	table := NewDiscreteLogTable(6)
+	for q := range tally {
+		for o, ct := range tally[q] {
+			shares := make(map[int]*babyjub.Point)
+			for _, i := range []int{1, 3} {
+				share, err := ParticipantShare(dealings, i, transport[i-1])
+				qt.Assert(t, err, qt.IsNil)
+				data, err := NewDecryptionShare(ct, share)
+				qt.Assert(t, err, qt.IsNil)
+				shares[i], err = VerifyDecryptionShare(ct, ParticipantPublicShare(dealings, i), data)
+				qt.Assert(t, err, qt.IsNil)
+				// the proof does not hold for another participant
+				_, err = VerifyDecryptionShare(ct, ParticipantPublicShare(dealings, 2), data)
+				qt.Assert(t, err, qt.Not(qt.IsNil))
+			}
+			p, err := CombineDecryption(ct, shares)
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, pointEqual(p, ValuePoint(new(big.Int).SetUint64(expected[q][o]))), qt.IsTrue)
+			m, err := table.Solve(p)
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, m, qt.Equals, expected[q][o])
+		}
+	}
+	_, err = table.Solve(ValuePoint(big.NewInt(7)))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	m, err := DiscreteLog(ValuePoint(big.NewInt(12345)), 20000)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, m, qt.Equals, uint64(12345))
+}
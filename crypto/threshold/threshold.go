@@ -0,0 +1,358 @@
+// Package threshold implements a t-of-n threshold encryption scheme over the
+// BabyJubJub curve.
+//
+// The shared key is generated without a trusted dealer using a Feldman
+// verifiable secret sharing based distributed key generation: each of the n
+// participants (identified by the indexes 1 to n) publishes a Dealing with the
+// commitments to a random polynomial of degree t-1 and the evaluation of the
+// polynomial for every participant, encrypted to its transport key.  The
+// shared public key is the sum of the constant term commitments, and the
+// shared private key can be recovered from any t shares, where the share of a
+// participant is the sum of the evaluations received from all the dealings.
+//
+// Messages are encrypted with an ElGamal based hybrid scheme: a random point
+// R = r·G is sent along with the message encrypted with a secretbox whose key
+// is derived from r·Y, being Y the shared public key.
+package threshold
+
+import (
+	"bytes"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"go.vocdoni.io/dvote/crypto"
+	"go.vocdoni.io/dvote/crypto/nacl"
+)
+
+const (
+	// PointLength is the length of a compressed point.
+	PointLength = 32
+	// ScalarLength is the length of an encoded scalar, such as a share.
+	ScalarLength = 32
+	// EncryptedShareLength is the length of a share encrypted to the
+	// transport key of its recipient.
+	EncryptedShareLength = ScalarLength + box.AnonymousOverhead
+
+	nonceLength = 24
+)
+
+// Dealing is the contribution of a participant to the distributed key
+// generation.
+type Dealing struct {
+	// TransportKey is the nacl public key of the dealer, used by the other
+	// participants to encrypt its shares.
+	TransportKey []byte
+	// Commitments are the commitments to the coefficients of the dealer
+	// polynomial.  Its length is the threshold.
+	Commitments []*babyjub.Point
+	// Shares are the evaluations of the dealer polynomial for the
+	// participants 1 to n, encrypted to their transport keys.
+	Shares [][]byte
+}
+
+// NewDealing creates the dealing of a participant whose transport public key
+// is transportKey, for the participants of transportKeys (the participant
+// with index i is transportKeys[i-1]).  The polynomial coefficients are
+// derived from seed, so the same dealing secret is obtained for the same seed.
+func NewDealing(seed []byte, threshold int, transportKey []byte,
+	transportKeys [][]byte) (*Dealing, error) {
+	if threshold < 1 || threshold > len(transportKeys) {
+		return nil, fmt.Errorf("invalid threshold %d for %d participants", threshold, len(transportKeys))
+	}
+	if len(transportKeys) > 255 {
+		return nil, fmt.Errorf("too many participants")
+	}
+	coefs := polynomial(seed, threshold)
+	d := &Dealing{TransportKey: transportKey}
+	for _, c := range coefs {
+		d.Commitments = append(d.Commitments, babyjub.NewPoint().Mul(c, babyjub.B8))
+	}
+	for i, k := range transportKeys {
+		pub, err := nacl.DecodePublic(fmt.Sprintf("%x", k))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode transport key %d: %w", i+1, err)
+		}
+		share := evalPolynomial(coefs, big.NewInt(int64(i+1)))
+		encShare, err := nacl.Anonymous.Encrypt(scalarBytes(share), pub)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encrypt share %d: %w", i+1, err)
+		}
+		d.Shares = append(d.Shares, encShare)
+	}
+	return d, nil
+}
+
+// Threshold returns the number of shares needed to recover the secret.
+func (d *Dealing) Threshold() int {
+	return len(d.Commitments)
+}
+
+// Marshal encodes the dealing as the transport key, the threshold and the
+// number of participants (one byte each), the commitments and the encrypted
+// shares.
+func (d *Dealing) Marshal() []byte {
+	var b bytes.Buffer
+	b.Write(d.TransportKey)
+	b.WriteByte(byte(len(d.Commitments)))
+	b.WriteByte(byte(len(d.Shares)))
+	for _, c := range d.Commitments {
+		comp := c.Compress()
+		b.Write(comp[:])
+	}
+	for _, s := range d.Shares {
+		b.Write(s)
+	}
+	return b.Bytes()
+}
+
+// UnmarshalDealing decodes a dealing encoded with Marshal, checking that the
+// commitments are valid points.
+func UnmarshalDealing(data []byte) (*Dealing, error) {
+	if len(data) < nacl.KeyLength+2 {
+		return nil, fmt.Errorf("dealing too short")
+	}
+	d := &Dealing{TransportKey: append([]byte(nil), data[:nacl.KeyLength]...)}
+	t, n := int(data[nacl.KeyLength]), int(data[nacl.KeyLength+1])
+	data = data[nacl.KeyLength+2:]
+	if t < 1 || t > n {
+		return nil, fmt.Errorf("invalid threshold %d for %d participants", t, n)
+	}
+	if len(data) != t*PointLength+n*EncryptedShareLength {
+		return nil, fmt.Errorf("invalid dealing length")
+	}
+	for i := 0; i < t; i++ {
+		c, err := decodePoint(data[:PointLength])
+		if err != nil {
+			return nil, fmt.Errorf("invalid commitment %d: %w", i, err)
+		}
+		d.Commitments = append(d.Commitments, c)
+		data = data[PointLength:]
+	}
+	for i := 0; i < n; i++ {
+		d.Shares = append(d.Shares, append([]byte(nil), data[:EncryptedShareLength]...))
+		data = data[EncryptedShareLength:]
+	}
+	return d, nil
+}
+
+// PublicShare returns the commitment to the share of the participant index,
+// computed from the dealing commitments.
+func (d *Dealing) PublicShare(index int) *babyjub.Point {
+	x := big.NewInt(int64(index))
+	xk := big.NewInt(1)
+	acc := babyjub.NewPoint().Projective()
+	for _, c := range d.Commitments {
+		acc = acc.Add(acc, babyjub.NewPoint().Mul(xk, c).Projective())
+		xk = new(big.Int).Mod(new(big.Int).Mul(xk, x), babyjub.SubOrder)
+	}
+	return acc.Affine()
+}
+
+// DecryptShare decrypts the share of the participant index with its transport
+// private key, and checks it against the dealing commitments.
+func (d *Dealing) DecryptShare(index int, transport crypto.Cipher) (*big.Int, error) {
+	if index < 1 || index > len(d.Shares) {
+		return nil, fmt.Errorf("invalid share index %d", index)
+	}
+	data, err := transport.Decrypt(d.Shares[index-1])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt share: %w", err)
+	}
+	share, err := DecodeScalar(data)
+	if err != nil {
+		return nil, err
+	}
+	if !pointEqual(babyjub.NewPoint().Mul(share, babyjub.B8), d.PublicShare(index)) {
+		return nil, fmt.Errorf("share %d does not match the dealing commitments", index)
+	}
+	return share, nil
+}
+
+// ParticipantShare returns the share of the participant index on the shared
+// key generated by the dealings, decrypting them with its transport key.
+func ParticipantShare(dealings []*Dealing, index int, transport crypto.Cipher) (*big.Int, error) {
+	share := new(big.Int)
+	for i, d := range dealings {
+		s, err := d.DecryptShare(index, transport)
+		if err != nil {
+			return nil, fmt.Errorf("dealing %d: %w", i+1, err)
+		}
+		share.Add(share, s)
+	}
+	return share.Mod(share, babyjub.SubOrder), nil
+}
+
+// SharedPublicKey returns the public key shared by the participants of the
+// dealings.
+func SharedPublicKey(dealings []*Dealing) *babyjub.Point {
+	acc := babyjub.NewPoint().Projective()
+	for _, d := range dealings {
+		acc = acc.Add(acc, d.Commitments[0].Projective())
+	}
+	return acc.Affine()
+}
+
+// VerifyShare checks that share is the share of the participant index on the
+// shared key generated by the dealings.
+func VerifyShare(dealings []*Dealing, index int, share *big.Int) error {
+	if !pointEqual(babyjub.NewPoint().Mul(share, babyjub.B8), ParticipantPublicShare(dealings, index)) {
+		return fmt.Errorf("invalid share for participant %d", index)
+	}
+	return nil
+}
+
+// Combine recovers the shared private key from the shares of at least
+// threshold participants, indexed by the participant index.
+func Combine(shares map[int]*big.Int) (*big.Int, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no shares provided")
+	}
+	indexes := make([]int, 0, len(shares))
+	for i := range shares {
+		indexes = append(indexes, i)
+	}
+	secret := new(big.Int)
+	for i, share := range shares {
+		coef, err := lagrangeCoefficient(i, indexes)
+		if err != nil {
+			return nil, err
+		}
+		secret.Add(secret, coef.Mul(coef, share))
+	}
+	return secret.Mod(secret, babyjub.SubOrder), nil
+}
+
+// Encrypt encrypts message for the shared public key pubKey.
+func Encrypt(message []byte, pubKey *babyjub.Point) ([]byte, error) {
+	r, err := randomScalar(cryptorand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [nonceLength]byte
+	if _, err := io.ReadFull(cryptorand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+	ephemeral := babyjub.NewPoint().Mul(r, babyjub.B8).Compress()
+	key := secretKey(babyjub.NewPoint().Mul(r, pubKey))
+	out := append(ephemeral[:], nonce[:]...)
+	return secretbox.Seal(out, message, &nonce, &key), nil
+}
+
+// Decrypt decrypts a message encrypted with Encrypt using the shared private
+// key privKey.
+func Decrypt(ciphertext []byte, privKey *big.Int) ([]byte, error) {
+	if len(ciphertext) < PointLength+nonceLength+secretbox.Overhead {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	ephemeral, err := decodePoint(ciphertext[:PointLength])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	var nonce [nonceLength]byte
+	copy(nonce[:], ciphertext[PointLength:])
+	key := secretKey(babyjub.NewPoint().Mul(privKey, ephemeral))
+	message, ok := secretbox.Open(nil, ciphertext[PointLength+nonceLength:], &nonce, &key)
+	if !ok {
+		return nil, errors.New("cannot decrypt message")
+	}
+	return message, nil
+}
+
+// EncodePoint returns the compressed representation of p.
+func EncodePoint(p *babyjub.Point) []byte {
+	comp := p.Compress()
+	return comp[:]
+}
+
+// DecodePoint decodes a compressed point, which must be in the BabyJubJub
+// prime order subgroup.
+func DecodePoint(data []byte) (*babyjub.Point, error) {
+	if len(data) != PointLength {
+		return nil, fmt.Errorf("wrong point length %d", len(data))
+	}
+	return decodePoint(data)
+}
+
+// EncodeScalar returns the fixed length big-endian representation of s.
+func EncodeScalar(s *big.Int) []byte {
+	return scalarBytes(s)
+}
+
+// DecodeScalar decodes a scalar encoded with EncodeScalar.
+func DecodeScalar(data []byte) (*big.Int, error) {
+	if len(data) != ScalarLength {
+		return nil, fmt.Errorf("wrong scalar length %d", len(data))
+	}
+	s := new(big.Int).SetBytes(data)
+	if s.Cmp(babyjub.SubOrder) >= 0 {
+		return nil, fmt.Errorf("scalar out of range")
+	}
+	return s, nil
+}
+
+func decodePoint(data []byte) (*babyjub.Point, error) {
+	var comp [PointLength]byte
+	copy(comp[:], data)
+	p, err := babyjub.NewPoint().Decompress(comp)
+	if err != nil {
+		return nil, err
+	}
+	if !p.InSubGroup() {
+		return nil, fmt.Errorf("point not in subgroup")
+	}
+	return p, nil
+}
+
+func pointEqual(a, b *babyjub.Point) bool {
+	return a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+}
+
+func scalarBytes(s *big.Int) []byte {
+	b := make([]byte, ScalarLength)
+	return s.FillBytes(b)
+}
+
+// secretKey derives the secretbox key from the shared point.
+func secretKey(p *babyjub.Point) [32]byte {
+	return sha256.Sum256(EncodePoint(p))
+}
+
+// polynomial derives threshold coefficients from seed.
+func polynomial(seed []byte, threshold int) []*big.Int {
+	coefs := make([]*big.Int, threshold)
+	for i := range coefs {
+		var idx [4]byte
+		binary.BigEndian.PutUint32(idx[:], uint32(i))
+		h := sha256.Sum256(append(append([]byte{}, seed...), idx[:]...))
+		coefs[i] = new(big.Int).Mod(new(big.Int).SetBytes(h[:]), babyjub.SubOrder)
+	}
+	return coefs
+}
+
+func evalPolynomial(coefs []*big.Int, x *big.Int) *big.Int {
+	// horner's method
+	y := new(big.Int)
+	for i := len(coefs) - 1; i >= 0; i-- {
+		y.Mul(y, x)
+		y.Add(y, coefs[i])
+		y.Mod(y, babyjub.SubOrder)
+	}
+	return y
+}
+
+func randomScalar(rand io.Reader) (*big.Int, error) {
+	var b [ScalarLength + 16]byte
+	if _, err := io.ReadFull(rand, b[:]); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(b[:]), babyjub.SubOrder), nil
+}
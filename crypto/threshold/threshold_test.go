@@ -0,0 +1,75 @@
+package threshold
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"go.vocdoni.io/dvote/crypto"
+	"go.vocdoni.io/dvote/crypto/nacl"
+)
+
+func TestThresholdEncryption(t *testing.T) {
+	t.Parallel()
+
+	const threshold, participants = 3, 5
+	transport := make([]crypto.Cipher, participants)
+	transportKeys := make([][]byte, participants)
+	for i := range transport {
+		var err error
+		transport[i], err = nacl.Generate(rand.Reader)
+		qt.Assert(t, err, qt.IsNil)
+		transportKeys[i] = transport[i].Public().Bytes()
+	}
+
+	// every participant publishes its dealing
+	dealings := make([]*Dealing, participants)
+	for i := range dealings {
+		d, err := NewDealing([]byte(fmt.Sprintf("seed%d", i)), threshold,
+			transportKeys[i], transportKeys)
+		qt.Assert(t, err, qt.IsNil)
+		dealings[i], err = UnmarshalDealing(d.Marshal())
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, dealings[i].Threshold(), qt.Equals, threshold)
+	}
+	pubKey := SharedPublicKey(dealings)
+	pubKey2, err := DecodePoint(EncodePoint(pubKey))
+	qt.Assert(t, err, qt.IsNil)
+	ciphertext, err := Encrypt([]byte("hello"), pubKey2)
+	qt.Assert(t, err, qt.IsNil)
+
+	// every participant computes its share from the dealings
+	shares := make(map[int]*big.Int)
+	for i := 1; i <= participants; i++ {
+		share, err := ParticipantShare(dealings, i, transport[i-1])
+		qt.Assert(t, err, qt.IsNil)
+		shares[i] = share
+		qt.Assert(t, VerifyShare(dealings, i, share), qt.IsNil)
+		qt.Assert(t, VerifyShare(dealings, i, new(big.Int).Add(share, big.NewInt(1))), qt.Not(qt.IsNil))
+	}
+	// a participant cannot decrypt the shares of another one
+	_, err = dealings[0].DecryptShare(2, transport[0])
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// any threshold shares decrypt the message
+	for _, idx := range [][]int{{1, 2, 3}, {2, 4, 5}, {1, 3, 4, 5}} {
+		subset := make(map[int]*big.Int)
+		for _, i := range idx {
+			subset[i] = shares[i]
+		}
+		privKey, err := Combine(subset)
+		qt.Assert(t, err, qt.IsNil)
+		message, err := Decrypt(ciphertext, privKey)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, string(message), qt.Equals, "hello")
+	}
+
+	// less than threshold shares do not
+	privKey, err := Combine(map[int]*big.Int{1: shares[1], 5: shares[5]})
+	qt.Assert(t, err, qt.IsNil)
+	_, err = Decrypt(ciphertext, privKey)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
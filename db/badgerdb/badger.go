@@ -120,8 +120,11 @@ type BadgerDB struct {
 	db *badger.DB
 }
 
-// check that BadgerDB implements the db.Database interface
-var _ db.Database = (*BadgerDB)(nil)
+// check that BadgerDB implements the db.Database and db.Snapshotter interfaces
+var (
+	_ db.Database    = (*BadgerDB)(nil)
+	_ db.Snapshotter = (*BadgerDB)(nil)
+)
 
 // New returns a BadgerDB using the given Options, which implements the
 // db.Database interface
@@ -214,28 +217,58 @@ func (db *BadgerDB) Close() error {
 // Iterate implements the db.Database.Iterate interface method
 func (db *BadgerDB) Iterate(prefix []byte, callback func(k, v []byte) bool) error {
 	return db.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		if prefix != nil {
-			opts.Prefix = prefix
-		}
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			stopIter := false
-			err := item.Value(func(v []byte) error {
-				if cont := callback(item.Key(), v); !cont {
-					stopIter = true
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-			if stopIter {
-				break
+		return iterate(txn, prefix, callback)
+	})
+}
+
+// Snapshot implements the db.Snapshotter.Snapshot interface method
+func (db *BadgerDB) Snapshot() db.Snapshot {
+	// Read-only transactions always see the data committed before they
+	// were created.
+	return Snapshot{tx: db.db.NewTransaction(false)}
+}
+
+// Snapshot implements the interface db.Snapshot
+type Snapshot struct {
+	tx *badger.Txn
+}
+
+// check that Snapshot implements the db.Snapshot interface
+var _ db.Snapshot = (*Snapshot)(nil)
+
+// Iterate implements the db.Snapshot.Iterate interface method
+func (s Snapshot) Iterate(prefix []byte, callback func(k, v []byte) bool) error {
+	return iterate(s.tx, prefix, callback)
+}
+
+// Close implements the db.Snapshot.Close interface method
+func (s Snapshot) Close() error {
+	s.tx.Discard()
+	return nil
+}
+
+func iterate(txn *badger.Txn, prefix []byte, callback func(k, v []byte) bool) error {
+	opts := badger.DefaultIteratorOptions
+	if prefix != nil {
+		opts.Prefix = prefix
+	}
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		stopIter := false
+		err := item.Value(func(v []byte) error {
+			if cont := callback(item.Key(), v); !cont {
+				stopIter = true
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-		return nil
-	})
+		if stopIter {
+			break
+		}
+	}
+	return nil
 }
@@ -31,6 +31,13 @@ func TestConcurrentWriteTx(t *testing.T) {
 	dbtest.TestConcurrentWriteTx(t, database)
 }
 
+func TestSnapshot(t *testing.T) {
+	database, err := New(db.Options{Path: t.TempDir()})
+	qt.Assert(t, err, qt.IsNil)
+
+	dbtest.TestSnapshot(t, database)
+}
+
 func TestWriteTxApply(t *testing.T) {
 	database, err := New(db.Options{Path: t.TempDir()})
 	qt.Assert(t, err, qt.IsNil)
@@ -48,6 +48,23 @@ type Database interface {
 	Iterate(prefix []byte, callback func(key, value []byte) bool) error
 }
 
+// Snapshot is a read-only view of the contents of a Database at the time it
+// was created, which is not affected by the later writes.
+type Snapshot interface {
+	io.Closer
+
+	// Iterate calls callback with all key-value pairs in the snapshot whose
+	// key starts with prefix, like Database.Iterate.
+	Iterate(prefix []byte, callback func(key, value []byte) bool) error
+}
+
+// Snapshotter is implemented by the databases that can create snapshots.
+type Snapshotter interface {
+	// Snapshot returns a read-only view of the current contents of the
+	// database.  It must be closed once it is no longer used.
+	Snapshot() Snapshot
+}
+
 type ReadTx interface {
 	// Get retreives the value for the given key. If the key does not
 	// exist, returns the error ErrKeyNotFound
@@ -94,6 +94,31 @@ func TestIterate(t *testing.T, d db.Database) {
 	qt.Assert(t, prefix1KeysFound, qt.Equals, prefix1NumKeys)
 }
 
+// TestSnapshot checks that a snapshot keeps the contents of the database at
+// the time it was created.
+func TestSnapshot(t *testing.T, d db.Snapshotter) {
+	database := d.(db.Database)
+	wTx := database.WriteTx()
+	qt.Assert(t, wTx.Set([]byte("a"), []byte("1")), qt.IsNil)
+	qt.Assert(t, wTx.Set([]byte("b"), []byte("2")), qt.IsNil)
+	qt.Assert(t, wTx.Commit(), qt.IsNil)
+
+	snap := d.Snapshot()
+	defer snap.Close()
+	wTx = database.WriteTx()
+	qt.Assert(t, wTx.Set([]byte("a"), []byte("3")), qt.IsNil)
+	qt.Assert(t, wTx.Delete([]byte("b")), qt.IsNil)
+	qt.Assert(t, wTx.Set([]byte("c"), []byte("4")), qt.IsNil)
+	qt.Assert(t, wTx.Commit(), qt.IsNil)
+
+	contents := map[string]string{}
+	qt.Assert(t, snap.Iterate(nil, func(k, v []byte) bool {
+		contents[string(k)] = string(v)
+		return true
+	}), qt.IsNil)
+	qt.Assert(t, contents, qt.DeepEquals, map[string]string{"a": "1", "b": "2"})
+}
+
 // TestConcurrentWriteTx validates the behaviour of badgerdb when multiple
 // write transactions modify the same key.
 func TestConcurrentWriteTx(t *testing.T, database db.Database) {
@@ -31,3 +31,8 @@ func (l *Cache) Get(key interface{}) interface{} {
 	}
 	return value
 }
+
+// Purge removes all the elements from the cache
+func (l *Cache) Purge() {
+	l.lru.Purge()
+}
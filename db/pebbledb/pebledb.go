@@ -88,8 +88,11 @@ type PebbleDB struct {
 	db *pebble.DB
 }
 
-// check that PebbleDB implements the db.Database interface
-var _ db.Database = (*PebbleDB)(nil)
+// check that PebbleDB implements the db.Database and db.Snapshotter interfaces
+var (
+	_ db.Database    = (*PebbleDB)(nil)
+	_ db.Snapshotter = (*PebbleDB)(nil)
+)
 
 // New returns a PebbleDB using the given Options, which implements the
 // db.Database interface
@@ -140,13 +143,42 @@ func keyUpperBound(b []byte) []byte {
 	return nil // no upper-bound
 }
 
-// Iterate implements the db.Database.Iterate interface method
-func (db *PebbleDB) Iterate(prefix []byte, callback func(k, v []byte) bool) (err error) {
-	iterOptions := &pebble.IterOptions{
+func iterOptions(prefix []byte) *pebble.IterOptions {
+	return &pebble.IterOptions{
 		LowerBound: prefix,
 		UpperBound: keyUpperBound(prefix),
 	}
-	iter := db.db.NewIter(iterOptions)
+}
+
+// Iterate implements the db.Database.Iterate interface method
+func (db *PebbleDB) Iterate(prefix []byte, callback func(k, v []byte) bool) (err error) {
+	return iterate(db.db.NewIter(iterOptions(prefix)), prefix, callback)
+}
+
+// Snapshot implements the db.Snapshotter.Snapshot interface method
+func (db *PebbleDB) Snapshot() db.Snapshot {
+	return Snapshot{snap: db.db.NewSnapshot()}
+}
+
+// Snapshot implements the interface db.Snapshot
+type Snapshot struct {
+	snap *pebble.Snapshot
+}
+
+// check that Snapshot implements the db.Snapshot interface
+var _ db.Snapshot = (*Snapshot)(nil)
+
+// Iterate implements the db.Snapshot.Iterate interface method
+func (s Snapshot) Iterate(prefix []byte, callback func(k, v []byte) bool) error {
+	return iterate(s.snap.NewIter(iterOptions(prefix)), prefix, callback)
+}
+
+// Close implements the db.Snapshot.Close interface method
+func (s Snapshot) Close() error {
+	return s.snap.Close()
+}
+
+func iterate(iter *pebble.Iterator, prefix []byte, callback func(k, v []byte) bool) (err error) {
 	defer func() {
 		errC := iter.Close()
 		if err != nil {
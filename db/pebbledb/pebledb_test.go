@@ -23,6 +23,13 @@ func TestIterate(t *testing.T) {
 	dbtest.TestIterate(t, database)
 }
 
+func TestSnapshot(t *testing.T) {
+	database, err := New(db.Options{Path: t.TempDir()})
+	qt.Assert(t, err, qt.IsNil)
+
+	dbtest.TestSnapshot(t, database)
+}
+
 func TestWriteTxApply(t *testing.T) {
 	database, err := New(db.Options{Path: t.TempDir()})
 	qt.Assert(t, err, qt.IsNil)
@@ -2,8 +2,12 @@ module go.vocdoni.io/dvote
 
 go 1.17
 
-// For testing purposes while dvote-protobuf becomes stable
-//replace go.vocdoni.io/proto => ../dvote-protobuf
+// The vochain protobuf changes not yet published upstream are kept in-tree,
+// with only the sources of the models package and its generated code.
+// Modules depending on this one ignore the replace directive, so they cannot
+// build until the changes are released in go.vocdoni.io/proto, its version is
+// required here and ./dvote-protobuf is removed.
+replace go.vocdoni.io/proto => ./dvote-protobuf
 
 replace github.com/timshannon/badgerhold/v3 => github.com/vocdoni/badgerhold/v3 v3.0.0-20210514115050-2d704df3456f
 
@@ -96,6 +100,7 @@ require (
 	github.com/crackcomm/go-gitignore v0.0.0-20170627025303-887ab5e44cc3 // indirect
 	github.com/cskr/pubsub v1.0.2 // indirect
 	github.com/davidlazar/go-crypto v0.0.0-20200604182044-b73af7476f6c // indirect
+	github.com/dchest/blake512 v1.0.0 // indirect
 	github.com/deckarep/golang-set v1.8.0 // indirect
 	github.com/dgraph-io/badger v1.6.2 // indirect
 	github.com/dgraph-io/badger/v2 v2.2007.4 // indirect
@@ -17,6 +17,7 @@ import (
 	"go.vocdoni.io/dvote/oracle"
 	"go.vocdoni.io/dvote/rpcapi"
 	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/proto/build/go/models"
 )
 
@@ -50,6 +51,37 @@ func NewAPIoracle(o *oracle.Oracle, r *rpcapi.RPCAPI) (*APIoracle, error) {
 }
 
 func (a *APIoracle) EnableERC20(chainName string, web3Endpoints []string) error {
+	if err := a.connect(chainName, web3Endpoints); err != nil {
+		return err
+	}
+	a.router.RegisterPublic("newERC20process", true, a.handleNewEthProcess)
+	a.router.APIs = append(a.router.APIs, "oracle")
+	return nil
+}
+
+// EnableNFT enables the creation of ERC721 and ERC1155 census processes,
+// whose index slot is discovered from the token contract storage.
+func (a *APIoracle) EnableNFT(chainName string, web3Endpoints []string) error {
+	if err := a.connect(chainName, web3Endpoints); err != nil {
+		return err
+	}
+	a.router.RegisterPublic("newERC721process", true, a.handleNewERC721Process)
+	a.router.RegisterPublic("newERC1155process", true, a.handleNewERC1155Process)
+	for _, api := range a.router.APIs {
+		if api == "oracle" {
+			return nil
+		}
+	}
+	a.router.APIs = append(a.router.APIs, "oracle")
+	return nil
+}
+
+// connect connects the API oracle to the web3 endpoint of chainName, if it is
+// not connected yet.
+func (a *APIoracle) connect(chainName string, web3Endpoints []string) error {
+	if a.eh != nil {
+		return nil
+	}
 	if chainName == "" || len(web3Endpoints) == 0 {
 		return fmt.Errorf("no web3 endpoint or chain name provided")
 	}
@@ -73,12 +105,25 @@ func (a *APIoracle) EnableERC20(chainName string, web3Endpoints []string) error
 			a.chainNames[k] = true
 		}
 	}
-	a.router.RegisterPublic("newERC20process", true, a.handleNewEthProcess)
-	a.router.APIs = append(a.router.APIs, "oracle")
 	return nil
 }
 
 func (a *APIoracle) handleNewEthProcess(req *api.APIrequest) (*api.APIresponse, error) {
+	return a.newEthProcess(req, models.CensusOrigin_ERC20)
+}
+
+func (a *APIoracle) handleNewERC721Process(req *api.APIrequest) (*api.APIresponse, error) {
+	return a.newEthProcess(req, models.CensusOrigin_ERC721)
+}
+
+func (a *APIoracle) handleNewERC1155Process(req *api.APIrequest) (*api.APIresponse, error) {
+	return a.newEthProcess(req, models.CensusOrigin_ERC1155)
+}
+
+// newEthProcess creates a new process whose census is the token holders of an
+// Ethereum token contract, being the process entity the contract address.
+func (a *APIoracle) newEthProcess(req *api.APIrequest,
+	censusOrigin models.CensusOrigin) (*api.APIresponse, error) {
 	if req.NewProcess == nil {
 		return nil, fmt.Errorf("newProcess is empty")
 	}
@@ -116,15 +161,42 @@ func (a *APIoracle) handleNewEthProcess(req *api.APIrequest) (*api.APIresponse,
 		ProcessId:         ethereum.HashRaw([]byte(pidseed)),
 		Status:            models.ProcessStatus_READY,
 		Namespace:         a.Namespace,
-		CensusOrigin:      models.CensusOrigin_ERC20,
+		CensusOrigin:      censusOrigin,
 		Mode:              &models.ProcessMode{AutoStart: true},
 		SourceNetworkId:   a.eh.SrcNetworkId,
 		Owner:             req.Address().Bytes(),
 	}
+	var tokenID *big.Int
+	switch censusOrigin {
+	case models.CensusOrigin_ERC1155:
+		if req.NewProcess.TokenID == nil {
+			return nil, fmt.Errorf("token id not provided")
+		}
+		tokenID = req.NewProcess.TokenID.ToInt()
+		p.TokenId = tokenID.Bytes()
+	case models.CensusOrigin_ERC721:
+		// the token is only used to find the owners mapping slot
+		if req.NewProcess.TokenID == nil {
+			return nil, fmt.Errorf("token id not provided")
+		}
+		if req.NewProcess.EthOwnersIndexSlot == nil {
+			return nil, fmt.Errorf("owners index slot not provided")
+		}
+		tokenID = req.NewProcess.TokenID.ToInt()
+		p.EthOwnersIndexSlot = req.NewProcess.EthOwnersIndexSlot
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), ethQueryTimeOut)
 	defer cancel()
-	index, err := a.getIndexSlot(ctx, p.EntityId, p.GetSourceBlockHeight(), p.CensusRoot)
+	var index uint32
+	var err error
+	switch censusOrigin {
+	case models.CensusOrigin_ERC20:
+		index, err = a.getIndexSlot(ctx, p.EntityId, p.GetSourceBlockHeight(), p.CensusRoot)
+	default:
+		index, err = a.discoverIndexSlot(ctx, censusOrigin, p.EntityId, *req.Address(),
+			tokenID, p.GetSourceBlockHeight(), p.CensusRoot)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -132,12 +204,28 @@ func (a *APIoracle) handleNewEthProcess(req *api.APIrequest) (*api.APIresponse,
 	if index != p.GetEthIndexSlot() {
 		return nil, fmt.Errorf("index slot does not match")
 	}
+	if censusOrigin == models.CensusOrigin_ERC721 {
+		ownersIndex, err := a.discoverOwnersIndexSlot(ctx, p.EntityId, *req.Address(),
+			tokenID, p.GetSourceBlockHeight(), p.CensusRoot)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("fetched owners index slot %d for contract %x", ownersIndex, p.EntityId)
+		if ownersIndex != p.GetEthOwnersIndexSlot() {
+			return nil, fmt.Errorf("owners index slot does not match")
+		}
+	}
 
 	if req.EthProof == nil {
 		return nil, fmt.Errorf("storage proof is nil")
 	}
-	err = mapbased.VerifyProof(*req.Address(), common.BytesToHash(p.CensusRoot),
-		*req.EthProof, int(index), new(big.Int).SetBytes(req.EthProof.Value), nil)
+	if censusOrigin == models.CensusOrigin_ERC1155 {
+		err = vochain.VerifyERC1155StorageProof(*req.Address(), common.BytesToHash(p.CensusRoot),
+			*req.EthProof, tokenID, int(index), new(big.Int).SetBytes(req.EthProof.Value))
+	} else {
+		err = mapbased.VerifyProof(*req.Address(), common.BytesToHash(p.CensusRoot),
+			*req.EthProof, int(index), new(big.Int).SetBytes(req.EthProof.Value), nil)
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("proof is not valid: %v", err)
@@ -156,29 +244,38 @@ func (a *APIoracle) handleNewEthProcess(req *api.APIrequest) (*api.APIresponse,
 
 func (a *APIoracle) getIndexSlot(ctx context.Context, contractAddr []byte,
 	evmBlockHeight uint64, rootHash []byte) (uint32, error) {
-	// check valid storage root provided
+	addr, err := a.checkStorageRoot(ctx, contractAddr, evmBlockHeight, rootHash)
+	if err != nil {
+		return 0, err
+	}
+	// get index slot from the token storage proof contract
+	islot, err := a.eh.GetTokenBalanceMappingPosition(ctx, addr)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get balance mapping position from the contract: %w", err)
+	}
+	return uint32(islot.Uint64()), nil
+}
+
+// checkStorageRoot checks that rootHash is the storage root of the contract
+// contractAddr at the block evmBlockHeight, and returns the contract address.
+func (a *APIoracle) checkStorageRoot(ctx context.Context, contractAddr []byte,
+	evmBlockHeight uint64, rootHash []byte) (common.Address, error) {
 	if len(contractAddr) != common.AddressLength {
-		return 0, fmt.Errorf("contractAddress length is not correct")
+		return common.Address{}, fmt.Errorf("contractAddress length is not correct")
 	}
-	addr := common.Address{}
-	copy(addr[:], contractAddr[:])
+	addr := common.BytesToAddress(contractAddr)
 	fetchedRoot, err := a.getStorageRoot(ctx, addr, evmBlockHeight)
 	if err != nil {
-		return 0, fmt.Errorf("cannot check EVM storage root: %w", err)
+		return common.Address{}, fmt.Errorf("cannot check EVM storage root: %w", err)
 	}
 	if bytes.Equal(fetchedRoot.Bytes(), common.Hash{}.Bytes()) {
-		return 0, fmt.Errorf("invalid storage root obtained from Ethereum: %x", fetchedRoot)
+		return common.Address{}, fmt.Errorf("invalid storage root obtained from Ethereum: %x", fetchedRoot)
 	}
 	if !bytes.Equal(fetchedRoot.Bytes(), rootHash) {
-		return 0, fmt.Errorf("invalid storage root, got: %x expected: %x",
+		return common.Address{}, fmt.Errorf("invalid storage root, got: %x expected: %x",
 			fetchedRoot, rootHash)
 	}
-	// get index slot from the token storage proof contract
-	islot, err := a.eh.GetTokenBalanceMappingPosition(ctx, addr)
-	if err != nil {
-		return 0, fmt.Errorf("cannot get balance mapping position from the contract: %w", err)
-	}
-	return uint32(islot.Uint64()), nil
+	return addr, nil
 }
 
 // getStorageRoot returns the storage Root Hash of the Ethereum Patricia trie for
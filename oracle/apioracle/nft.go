@@ -0,0 +1,114 @@
+package apioracle
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	goethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/vocdoni/storage-proofs-eth-go/helpers"
+	"github.com/vocdoni/storage-proofs-eth-go/token/mapbased"
+	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+var (
+	// erc721BalanceOf is the selector of the ERC721 balanceOf(address) method
+	erc721BalanceOf = ethcrypto.Keccak256([]byte("balanceOf(address)"))[:4]
+	// erc1155BalanceOf is the selector of the ERC1155 balanceOf(address,uint256) method
+	erc1155BalanceOf = ethcrypto.Keccak256([]byte("balanceOf(address,uint256)"))[:4]
+)
+
+// discoverIndexSlot finds the index slot of the balances mapping of an ERC721
+// or ERC1155 token contract, by looking for the storage slot whose value
+// matches the balance of holder at the block evmBlockHeight.  The holder must
+// own some tokens (of the token tokenID for ERC1155).
+func (a *APIoracle) discoverIndexSlot(ctx context.Context, censusOrigin models.CensusOrigin,
+	contractAddr []byte, holder common.Address, tokenID *big.Int,
+	evmBlockHeight uint64, rootHash []byte) (uint32, error) {
+	addr, err := a.checkStorageRoot(ctx, contractAddr, evmBlockHeight, rootHash)
+	if err != nil {
+		return 0, err
+	}
+	block := new(big.Int).SetUint64(evmBlockHeight)
+	balance, err := a.tokenBalance(ctx, censusOrigin, addr, holder, tokenID, block)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get holder balance: %w", err)
+	}
+	if balance.Sign() == 0 {
+		return 0, fmt.Errorf("holder %s has no tokens", holder.Hex())
+	}
+	for i := 0; i < mapbased.DiscoveryIterations; i++ {
+		var slot [32]byte
+		switch censusOrigin {
+		case models.CensusOrigin_ERC721:
+			slot = helpers.GetMapSlot(holder, i)
+		case models.CensusOrigin_ERC1155:
+			slot = vochain.ERC1155BalanceSlot(holder, tokenID, i)
+		default:
+			return 0, fmt.Errorf("census origin %s not supported", censusOrigin)
+		}
+		value, err := a.eh.EthereumClient.StorageAt(ctx, addr, slot, block)
+		if err != nil {
+			return 0, fmt.Errorf("cannot get storage: %w", err)
+		}
+		if new(big.Int).SetBytes(value).Cmp(balance) == 0 {
+			return uint32(i), nil
+		}
+	}
+	return 0, mapbased.ErrSlotNotFound
+}
+
+// discoverOwnersIndexSlot finds the index slot of the owners mapping
+// (uint256 => address) of an ERC721 token contract, by looking for the storage
+// slot of the token tokenID whose value is holder at the block evmBlockHeight.
+// The holder must own the token.
+func (a *APIoracle) discoverOwnersIndexSlot(ctx context.Context, contractAddr []byte,
+	holder common.Address, tokenID *big.Int, evmBlockHeight uint64,
+	rootHash []byte) (uint32, error) {
+	addr, err := a.checkStorageRoot(ctx, contractAddr, evmBlockHeight, rootHash)
+	if err != nil {
+		return 0, err
+	}
+	block := new(big.Int).SetUint64(evmBlockHeight)
+	for i := 0; i < mapbased.DiscoveryIterations; i++ {
+		value, err := a.eh.EthereumClient.StorageAt(ctx, addr,
+			common.Hash(vochain.ERC721OwnerSlot(tokenID, i)), block)
+		if err != nil {
+			return 0, fmt.Errorf("cannot get storage: %w", err)
+		}
+		if common.BytesToAddress(value) == holder {
+			return uint32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("token %s is not owned by %s", tokenID, holder.Hex())
+}
+
+// tokenBalance returns the balance of holder at the block by calling the
+// balanceOf method of the ERC721 or ERC1155 token contract.
+func (a *APIoracle) tokenBalance(ctx context.Context, censusOrigin models.CensusOrigin,
+	contractAddr, holder common.Address, tokenID *big.Int, block *big.Int) (*big.Int, error) {
+	var data []byte
+	switch censusOrigin {
+	case models.CensusOrigin_ERC721:
+		data = append(data, erc721BalanceOf...)
+		data = append(data, common.LeftPadBytes(holder.Bytes(), 32)...)
+	case models.CensusOrigin_ERC1155:
+		data = append(data, erc1155BalanceOf...)
+		data = append(data, common.LeftPadBytes(holder.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(tokenID.Bytes(), 32)...)
+	default:
+		return nil, fmt.Errorf("census origin %s not supported", censusOrigin)
+	}
+	result, err := a.eh.EthereumClient.CallContract(ctx,
+		goethereum.CallMsg{To: &contractAddr, Data: data}, block)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != 32 {
+		return nil, fmt.Errorf("unexpected balanceOf result length %d", len(result))
+	}
+	return new(big.Int).SetBytes(result), nil
+}
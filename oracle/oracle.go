@@ -1,6 +1,7 @@
 package oracle
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -27,6 +28,8 @@ type OracleResults struct {
 	OracleAddress common.Address `json:"oracleAddress"`
 	ProcessID     types.HexBytes `json:"processId"`
 	Results       [][]string     `json:"results"`
+	// Ranked is only set on ranked choice processes
+	Ranked *indexertypes.RankedResults `json:"ranked,omitempty"`
 }
 
 func NewOracle(app *vochain.BaseApplication, signer *ethereum.SignKeys) (*Oracle, error) {
@@ -65,6 +68,13 @@ func (o *Oracle) NewProcess(process *models.Process) error {
 	if vochain.CensusOrigins[process.CensusOrigin].NeedsIndexSlot && process.EthIndexSlot == nil {
 		return fmt.Errorf("censusOrigin needs index slot (not provided)")
 	}
+	if vochain.CensusOrigins[process.CensusOrigin].NeedsOwnersIndexSlot &&
+		process.EthOwnersIndexSlot == nil {
+		return fmt.Errorf("censusOrigin needs owners index slot (not provided)")
+	}
+	if vochain.CensusOrigins[process.CensusOrigin].NeedsTokenID && process.TokenId == nil {
+		return fmt.Errorf("censusOrigin needs token id (not provided)")
+	}
 
 	// Check if process already exist
 	if _, err := o.VochainApp.State.Process(process.ProcessId, true); err != nil {
@@ -138,6 +148,16 @@ func (o *Oracle) OnComputeResults(results *indexertypes.Results, proc *indexerty
 		return
 	}
 
+	// each oracle submits the results once, the process moves to RESULTS
+	// once the oracle quorum agree
+	for _, r := range vocProcessData.Results {
+		if bytes.Equal(r.GetOracleAddress(), o.signer.Address().Bytes()) {
+			log.Infof("process %x: results already submitted by this oracle, skipping",
+				results.ProcessID)
+			return
+		}
+	}
+
 	// create setProcessTx
 	setprocessTxArgs := &models.SetProcessTx{
 		ProcessId: results.ProcessID,
@@ -154,6 +174,7 @@ func (o *Oracle) OnComputeResults(results *indexertypes.Results, proc *indexerty
 		EntityID:  vocProcessData.EntityId,
 		ProcessID: results.ProcessID,
 		Results:   vochain.GetFriendlyResults(setprocessTxArgs.Results.GetVotes()),
+		Ranked:    indexertypes.RankedResultsFromProto(setprocessTxArgs.Results.GetRanked()),
 	}
 	resultsPayload, err := json.Marshal(signedResultsPayload)
 	if err != nil {
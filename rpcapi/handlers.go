@@ -75,18 +75,24 @@ func (r *RPCAPI) EnableVoteAPI(vocapp *vochain.BaseApplication, vocinfo *vochain
 	}
 	r.APIs = append(r.APIs, "vote")
 	r.RegisterPublic("submitRawTx", false, r.submitRawTx)
+	r.RegisterPublic("simulateTx", false, r.simulateTx)
 	r.RegisterPublic("submitEnvelope", false, r.submitEnvelope)
 	r.RegisterPublic("getEnvelopeStatus", false, r.getEnvelopeStatus)
 	r.RegisterPublic("getEnvelopeHeight", false, r.getEnvelopeHeight)
+	r.RegisterPublic("getEnvelopeProof", false, r.getEnvelopeProof)
 	r.RegisterPublic("getBlockHeight", false, r.getBlockHeight)
 	r.RegisterPublic("getProcessKeys", false, r.getProcessKeys)
 	r.RegisterPublic("getProcessCircuitConfig", false, r.getProcessCircuitConfig)
 	r.RegisterPublic("getProcessRollingCensusSize", false, r.getProcessRollingCensusSize)
 	r.RegisterPublic("getBlockStatus", false, r.getBlockStatus)
+	r.RegisterPublic("estimateBlockAtDateTime", false, r.estimateBlockAtDateTime)
+	r.RegisterPublic("estimateDateAtBlock", false, r.estimateDateAtBlock)
 	r.RegisterPublic("getOracleResults", false, r.getOracleResults)
 	r.RegisterPublic("getProcessCircuitConfig", false, r.getProcessCircuitConfig)
 	r.RegisterPublic("getProcessRollingCensusSize", false, r.getProcessRollingCensusSize)
 	r.RegisterPublic("getPreregisterVoterWeight", false, r.getPreRegisterWeight)
+	r.RegisterPublic("getRollingCensusProof", false, r.getRollingCensusProof)
+	r.RegisterPublic("getAccountsCensusProof", false, r.getAccountsCensusProof)
 
 	return nil
 }
@@ -153,5 +159,11 @@ func (r *RPCAPI) EnableIndexerAPI(vocapp *vochain.BaseApplication,
 	r.RegisterPublic("getTxByHash", false, r.getTxByHash)
 	r.RegisterPublic("getValidatorList", false, r.getValidatorList)
 	r.RegisterPublic("getTxListForBlock", false, r.getTxListForBlock)
+	r.RegisterPublic("getAccountTxList", false, r.getAccountTxList)
+	r.RegisterPublic("getTokenTransfers", false, r.getTokenTransfers)
+	r.RegisterPublic("getAccountBalanceHistory", false, r.getAccountBalanceHistory)
+	r.RegisterPublic("getTokenStats", false, r.getTokenStats)
+	r.RegisterPublic("searchProcesses", false, r.searchProcesses)
+	r.RegisterPublic("searchEntities", false, r.searchEntities)
 	return nil
 }
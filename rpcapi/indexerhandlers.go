@@ -203,6 +203,114 @@ func (r *RPCAPI) getTxListForBlock(request *api.APIrequest) (*api.APIresponse, e
 	return &response, nil
 }
 
+func (r *RPCAPI) getAccountTxList(request *api.APIrequest) (*api.APIresponse, error) {
+	var response api.APIresponse
+	if len(request.EntityId) != types.EntityIDsize {
+		return nil, fmt.Errorf("cannot get account tx list: (malformed entityId)")
+	}
+	max := request.ListSize
+	if max > MaxListSize || max <= 0 {
+		max = MaxListSize
+	}
+	var err error
+	if response.AccountTxList, err = r.scrutinizer.GetAccountTransactions(
+		request.EntityId, request.From, max); err != nil {
+		return nil, fmt.Errorf("cannot get account tx list: %w", err)
+	}
+	return &response, nil
+}
+
+func (r *RPCAPI) getTokenTransfers(request *api.APIrequest) (*api.APIresponse, error) {
+	var response api.APIresponse
+	// without entityId, the transfers of all the accounts are listed
+	if len(request.EntityId) != 0 && len(request.EntityId) != types.EntityIDsize {
+		return nil, fmt.Errorf("cannot get token transfers: (malformed entityId)")
+	}
+	max := request.ListSize
+	if max > MaxListSize || max <= 0 {
+		max = MaxListSize
+	}
+	var err error
+	if response.TokenTransfers, err = r.scrutinizer.GetTokenTransfers(
+		request.EntityId, request.From, max); err != nil {
+		return nil, fmt.Errorf("cannot get token transfers: %w", err)
+	}
+	return &response, nil
+}
+
+func (r *RPCAPI) getAccountBalanceHistory(request *api.APIrequest) (*api.APIresponse, error) {
+	var response api.APIresponse
+	if len(request.EntityId) != types.EntityIDsize {
+		return nil, fmt.Errorf("cannot get account balance history: (malformed entityId)")
+	}
+	max := request.ListSize
+	if max > MaxListSize || max <= 0 {
+		max = MaxListSize
+	}
+	var err error
+	if response.BalanceHistory, err = r.scrutinizer.GetAccountBalances(
+		request.EntityId, request.From, max); err != nil {
+		return nil, fmt.Errorf("cannot get account balance history: %w", err)
+	}
+	return &response, nil
+}
+
+func (r *RPCAPI) getTokenStats(request *api.APIrequest) (*api.APIresponse, error) {
+	var response api.APIresponse
+	var err error
+	if response.TokenStats, err = r.scrutinizer.GetTokenStats(); err != nil {
+		return nil, fmt.Errorf("cannot get token stats: %w", err)
+	}
+	return &response, nil
+}
+
+func (r *RPCAPI) searchProcesses(request *api.APIrequest) (*api.APIresponse, error) {
+	var response api.APIresponse
+	max := request.ListSize
+	if max > MaxListSize || max <= 0 {
+		max = MaxListSize
+	}
+	processList, err := r.scrutinizer.SearchProcesses(request.SearchTerm,
+		request.DateFrom, request.DateTo, request.SortBy, request.From, max)
+	if err != nil {
+		return nil, fmt.Errorf("cannot search processes: %w", err)
+	}
+	for _, p := range processList {
+		response.ProcessList = append(response.ProcessList, fmt.Sprintf("%x", p))
+	}
+	if len(response.ProcessList) == 0 {
+		response.Message = "no processes found for the query"
+		return &response, nil
+	}
+
+	response.Size = new(int64)
+	*response.Size = int64(len(response.ProcessList))
+	return &response, nil
+}
+
+func (r *RPCAPI) searchEntities(request *api.APIrequest) (*api.APIresponse, error) {
+	var response api.APIresponse
+	max := request.ListSize
+	if max > MaxListSize || max <= 0 {
+		max = MaxListSize
+	}
+	entityList, err := r.scrutinizer.SearchEntities(request.SearchTerm, request.From, max)
+	if err != nil {
+		return nil, fmt.Errorf("cannot search entities: %w", err)
+	}
+	for _, e := range entityList {
+		response.EntityIDs = append(response.EntityIDs, fmt.Sprintf("%x", e))
+	}
+	if len(response.EntityIDs) == 0 {
+		response.Message = "no entities found for the query"
+		return &response, nil
+	}
+
+	response.Size = new(int64)
+	*response.Size = int64(len(response.EntityIDs))
+	return &response, nil
+}
+
 func blockMetadataFromBlockModel(
 	block *tmtypes.Block, includeHeight, includeHash bool) *indexertypes.BlockMetadata {
 	if block == nil {
@@ -219,5 +327,6 @@ func blockMetadataFromBlockModel(
 	b.NumTxs = uint64(len(block.Txs))
 	b.LastBlockHash = block.LastBlockID.Hash.Bytes()
 	b.ProposerAddress = block.ProposerAddress.Bytes()
+	b.AppHash = block.AppHash.Bytes()
 	return b
 }
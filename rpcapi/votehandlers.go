@@ -4,6 +4,9 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	api "go.vocdoni.io/dvote/api"
@@ -12,6 +15,7 @@ import (
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/dvote/vochain/scrutinizer"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 	models "go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
 )
@@ -32,6 +36,24 @@ func (r *RPCAPI) submitRawTx(request *api.APIrequest) (*api.APIresponse, error)
 	return &api.APIresponse{Payload: fmt.Sprintf("%x", res.Data)}, nil
 }
 
+func (r *RPCAPI) simulateTx(request *api.APIrequest) (*api.APIresponse, error) {
+	if request.Payload == nil {
+		return nil, fmt.Errorf("payload is empty")
+	}
+	sim, err := r.vocapp.SimulateTx(request.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("cannot simulate transaction: %w", err)
+	}
+	return &api.APIresponse{TxSimulation: &api.TxSimulation{
+		TxHash:    sim.TxID,
+		Valid:     sim.Valid,
+		Error:     sim.Error,
+		Nullifier: sim.Nullifier,
+		Cost:      sim.Cost,
+		Nonce:     sim.Nonce,
+	}}, nil
+}
+
 func (a *RPCAPI) submitEnvelope(request *api.APIrequest) (*api.APIresponse, error) {
 	var err error
 	if request.Payload == nil {
@@ -83,6 +105,8 @@ func (r *RPCAPI) getEnvelopeStatus(request *api.APIrequest) (*api.APIresponse, e
 	response.Height = &vr.Height
 	response.BlockTimestamp = int32(vr.CreationTime.Unix())
 	response.ProcessID = vr.ProcessID
+	overwrites := uint32(len(vr.OverwriteHistory))
+	response.OverwriteCount = &overwrites
 	return &response, nil
 }
 
@@ -116,6 +140,29 @@ func (r *RPCAPI) getEnvelopeHeight(request *api.APIrequest) (*api.APIresponse, e
 	return &response, nil
 }
 
+func (r *RPCAPI) getEnvelopeProof(request *api.APIrequest) (*api.APIresponse, error) {
+	// check pid and nullifier
+	if len(request.ProcessID) != types.ProcessIDsize {
+		return nil, fmt.Errorf("cannot get envelope proof: (malformed processId)")
+	}
+	if len(request.Nullifier) != types.VoteNullifierSize {
+		return nil, fmt.Errorf("cannot get envelope proof: (malformed nullifier)")
+	}
+	vote, ops, height, err := r.vocapp.VoteProof(request.ProcessID, request.Nullifier, request.Height)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get envelope proof: %w", err)
+	}
+	var response api.APIresponse
+	response.VoteProof = &api.VoteProof{
+		ProcessID: request.ProcessID,
+		Nullifier: request.Nullifier,
+		Height:    height,
+		Vote:      vote,
+		ProofOps:  ops,
+	}
+	return &response, nil
+}
+
 func (r *RPCAPI) getBlockHeight(request *api.APIrequest) (*api.APIresponse, error) {
 	var response api.APIresponse
 	h := r.vocapp.Height()
@@ -196,6 +243,9 @@ func (r *RPCAPI) getProcessSummary(request *api.APIrequest) (*api.APIresponse, e
 		StartBlock:      procInfo.StartBlock,
 		State:           models.ProcessStatus(procInfo.Status).String(),
 		EnvelopeType:    procInfo.Envelope,
+		RegistrationPhase: procInfo.Mode.GetPreRegister() &&
+			procInfo.Status == int32(models.ProcessStatus_READY) &&
+			r.vocapp.Height() < procInfo.StartBlock,
 	}
 	return &response, nil
 }
@@ -295,6 +345,64 @@ func (r *RPCAPI) getProcessRollingCensusSize(request *api.APIrequest) (*api.APIr
 	return &response, nil
 }
 
+// getRollingCensusProof returns the proof of a voter in the non-anonymous
+// rolling census of a process.  The rolling census is purged once the process
+// is over, so there are no proofs for the ended, canceled or finished
+// processes; their final census root is kept in the process
+// FinalRollingCensusRoot.
+func (r *RPCAPI) getRollingCensusProof(request *api.APIrequest) (*api.APIresponse, error) {
+	if len(request.ProcessID) != types.ProcessIDsize {
+		return nil, fmt.Errorf("malformed processId")
+	}
+	if len(request.VoterAddress) != common.AddressLength {
+		return nil, fmt.Errorf("voterAddress invalid length (%d)", len(request.VoterAddress))
+	}
+	process, err := r.vocapp.State.Process(request.ProcessID, true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get process %x: %w", request.ProcessID, err)
+	}
+	if !process.GetMode().GetPreRegister() || process.GetEnvelopeType().GetAnonymous() {
+		return nil, fmt.Errorf("process %x has no non-anonymous rolling census", request.ProcessID)
+	}
+	if process.FinalRollingCensusRoot != nil {
+		return nil, fmt.Errorf("the rolling census of process %x is purged, its final root is %x",
+			request.ProcessID, process.FinalRollingCensusRoot)
+	}
+	value, siblings, err := r.vocapp.State.RollingCensusProof(request.ProcessID,
+		request.VoterAddress, true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get rolling census proof: %w", err)
+	}
+	var response api.APIresponse
+	response.Root = process.RollingCensusRoot
+	response.Siblings = siblings
+	response.Weight = new(types.BigInt).SetBytes(value)
+	return &response, nil
+}
+
+func (r *RPCAPI) getAccountsCensusProof(request *api.APIrequest) (*api.APIresponse, error) {
+	if len(request.ProcessID) != types.ProcessIDsize {
+		return nil, fmt.Errorf("malformed processId")
+	}
+	if len(request.VoterAddress) != common.AddressLength {
+		return nil, fmt.Errorf("voterAddress invalid length (%d)", len(request.VoterAddress))
+	}
+	value, siblings, err := r.vocapp.State.AccountsCensusProof(request.ProcessID,
+		common.BytesToAddress(request.VoterAddress))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get accounts census proof: %w", err)
+	}
+	var acc vochain.Account
+	if err := acc.Unmarshal(value); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal account: %w", err)
+	}
+	var response api.APIresponse
+	response.CensusValue = value
+	response.Siblings = siblings
+	response.Weight = (*types.BigInt)(new(big.Int).SetUint64(acc.Balance))
+	return &response, nil
+}
+
 func (r *RPCAPI) getResultsWeight(request *api.APIrequest) (*api.APIresponse, error) {
 	var response api.APIresponse
 	w, err := r.scrutinizer.GetResultsWeight(request.ProcessID)
@@ -311,11 +419,28 @@ func (r *RPCAPI) getOracleResults(request *api.APIrequest) (*api.APIresponse, er
 	if len(request.ProcessID) != types.ProcessIDsize {
 		return nil, fmt.Errorf("cannot get oracle results: (malformed processId)")
 	}
-	var err error
-	response.Results, err = r.vocapp.State.GetProcessResults(request.ProcessID)
+	results, agreed, err := r.vocapp.State.ProcessOracleResults(request.ProcessID)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get oracle results: %w", err)
 	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("cannot get oracle results: no results for process %x",
+			request.ProcessID)
+	}
+	// the submissions that conflict with the agreed results are included for auditing
+	if agreed != nil {
+		response.Results = vochain.GetFriendlyResults(agreed.GetVotes())
+		response.RankedResults = indexertypes.RankedResultsFromProto(agreed.GetRanked())
+	}
+	for _, res := range results {
+		response.OracleResults = append(response.OracleResults, &api.OracleResults{
+			Oracle:    res.OracleAddress,
+			Results:   vochain.GetFriendlyResults(res.GetVotes()),
+			Ranked:    indexertypes.RankedResultsFromProto(res.GetRanked()),
+			Signature: res.Signature,
+			Agreed:    agreed != nil && vochain.SameResults(res, agreed),
+		})
+	}
 	return &response, nil
 }
 
@@ -361,6 +486,7 @@ func (r *RPCAPI) getResults(request *api.APIrequest) (*api.APIresponse, error) {
 		return nil, fmt.Errorf("cannot get results: (unknown error fetching results)")
 	}
 	response.Results = scrutinizer.GetFriendlyResults(vr.Votes)
+	response.RankedResults = vr.Ranked
 	response.Final = &vr.Final
 	h := uint32(vr.EnvelopeHeight)
 	response.Height = &h
@@ -395,6 +521,38 @@ func (r *RPCAPI) getBlockStatus(request *api.APIrequest) (*api.APIresponse, erro
 	return &response, nil
 }
 
+// estimateBlockAtDateTime returns the estimated height of the block at the
+// request unix timestamp date, or the height of the last block started before
+// it if it is in the past.
+func (r *RPCAPI) estimateBlockAtDateTime(request *api.APIrequest) (*api.APIresponse, error) {
+	if request.Date <= 0 {
+		return nil, fmt.Errorf("cannot estimate block: invalid date %d", request.Date)
+	}
+	var response api.APIresponse
+	h := r.vocinfo.HeightAtTime(time.Unix(request.Date, 0))
+	if h <= 0 || h > math.MaxUint32 {
+		return nil, fmt.Errorf("cannot estimate block at date %d", request.Date)
+	}
+	height := uint32(h)
+	response.Height = &height
+	return &response, nil
+}
+
+// estimateDateAtBlock returns the estimated unix timestamp of the block at the
+// request height, or its timestamp if it is in the past.
+func (r *RPCAPI) estimateDateAtBlock(request *api.APIrequest) (*api.APIresponse, error) {
+	if request.Height == 0 {
+		return nil, fmt.Errorf("cannot estimate date: missing height")
+	}
+	var response api.APIresponse
+	t := r.vocinfo.HeightTime(int64(request.Height))
+	if t.IsZero() {
+		return nil, fmt.Errorf("cannot estimate date at block %d", request.Height)
+	}
+	response.BlockTimestamp = int32(t.Unix())
+	return &response, nil
+}
+
 func (r *RPCAPI) getPreRegisterWeight(request *api.APIrequest) (*api.APIresponse, error) {
 	if len(request.ProcessID) != types.ProcessIDsize {
 		return nil, fmt.Errorf("malformed processId")
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -138,6 +139,17 @@ func Vochain(vs *VochainService) (
 			return nil, nil, nil, err
 		}
 		go sc.AfterSyncBootstrap()
+		go sc.CollectMetrics(vs.MetricsAgent)
+		go sc.FetchMetadata(vs.Storage)
+		if vs.Config.Scrutinizer.Reindex {
+			go func() {
+				log.Info("starting the scrutinizer reindex")
+				if err := sc.Reindex(context.Background(), vs.Config.DBType,
+					vnode.Node.GenesisDoc().AppState); err != nil {
+					log.Errorf("cannot reindex the scrutinizer: %v", err)
+				}
+			}()
+		}
 	}
 
 	// Census Downloader
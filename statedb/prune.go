@@ -0,0 +1,334 @@
+package statedb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+// pruneDefaultBatchSize is the default maximum number of keys deleted in a
+// single database transaction while pruning.
+const pruneDefaultBatchSize = 1000
+
+// LeafSubTreesFn returns the configurations of the subTrees that hang from the
+// leaf with key and value, found in the tree opened from the mainTree
+// following path (which is empty for the mainTree).
+type LeafSubTreesFn func(path []TreeConfig, key, value []byte) ([]TreeConfig, error)
+
+// PruneOptions are the parameters of a StateDB pruning.
+type PruneOptions struct {
+	// KeepRecent is the number of most recent versions to keep.  The last
+	// version is always kept.
+	KeepRecent uint32
+	// KeepEvery, if not 0, keeps every version that is a multiple of
+	// KeepEvery.
+	KeepEvery uint32
+	// SubTrees is used to discover the subTrees hanging from each leaf, so
+	// that their nodes are also marked as reachable.  If nil, only the
+	// mainTree nodes are considered.
+	SubTrees LeafSubTreesFn
+	// BatchSize is the maximum number of keys deleted in a single database
+	// transaction.  If 0, pruneDefaultBatchSize is used.
+	BatchSize int
+	// Pinned, if not nil, returns the versions that must be kept besides
+	// the ones selected by KeepRecent and KeepEvery.
+	Pinned func() ([]uint32, error)
+}
+
+// PruneStats summarises the result of a StateDB pruning.
+type PruneStats struct {
+	// Versions is the number of pruned versions.
+	Versions int
+	// Nodes is the number of deleted tree nodes.
+	Nodes int
+}
+
+// keep returns true if version must be kept when last is the last version,
+// being pinned the versions returned by Pinned.
+func (o *PruneOptions) keep(version, last uint32, pinned map[uint32]bool) bool {
+	if version == last || uint64(version)+uint64(o.KeepRecent) > uint64(last) {
+		return true
+	}
+	return (o.KeepEvery > 0 && version%o.KeepEvery == 0) || pinned[version]
+}
+
+// pruneMarker keeps track of the tree nodes reachable from the versions that
+// are kept during a pruning.  Nodes are identified by the full database key
+// (the tree database prefix followed by the node key).
+type pruneMarker struct {
+	db       db.Database
+	subTrees LeafSubTreesFn
+	marks    map[string]struct{}
+	// trees contains the database prefix of the arbo.Tree of every
+	// visited tree, mapped to its hash length.
+	trees map[string]int
+	// pending contains the roots of the versions committed while the
+	// pruning is in progress, which must be marked before deleting nodes.
+	// It is protected by StateDB.pruneLock.
+	pending [][]byte
+}
+
+// markTree marks all the nodes reachable from root in the tree stored at the
+// database prefix, including the nodes of its subTrees.
+func (m *pruneMarker) markTree(ctx context.Context, tx db.ReadTx, prefix string,
+	hashFunc arbo.HashFunction, root []byte, treePath []TreeConfig) error {
+	treePrefix := prefix + subKeyTree + "/"
+	m.trees[treePrefix] = hashFunc.Len()
+	return m.markNode(ctx, tx, prefix, treePrefix, hashFunc.Len(), root, treePath)
+}
+
+// markNode marks the node at key and its descendants.  Nodes already marked
+// are skipped, as all their descendants are marked too.
+func (m *pruneMarker) markNode(ctx context.Context, tx db.ReadTx, prefix, treePrefix string,
+	hashLen int, key []byte, treePath []TreeConfig) error {
+	if len(key) != hashLen || bytes.Equal(key, make([]byte, hashLen)) {
+		return nil
+	}
+	id := treePrefix + string(key)
+	if _, ok := m.marks[id]; ok {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := tx.Get([]byte(id))
+	if err != nil {
+		return fmt.Errorf("cannot get node %x at %q: %w", key, treePrefix, err)
+	}
+	if len(value) == 0 {
+		return fmt.Errorf("empty node %x at %q", key, treePrefix)
+	}
+	switch value[0] {
+	case arbo.PrefixValueIntermediate:
+		l, r := arbo.ReadIntermediateChilds(value)
+		if err := m.markNode(ctx, tx, prefix, treePrefix, hashLen, l, treePath); err != nil {
+			return err
+		}
+		if err := m.markNode(ctx, tx, prefix, treePrefix, hashLen, r, treePath); err != nil {
+			return err
+		}
+	case arbo.PrefixValueLeaf:
+		if m.subTrees == nil {
+			break
+		}
+		leafKey, leafValue := arbo.ReadLeafValue(value)
+		cfgs, err := m.subTrees(treePath, leafKey, leafValue)
+		if err != nil {
+			return err
+		}
+		for _, cfg := range cfgs {
+			root, err := cfg.parentLeafGetRoot(leafValue)
+			if err != nil {
+				return fmt.Errorf("cannot get root of subTree %s: %w", cfg.kindID, err)
+			}
+			subPrefix := prefix + path.Join(subKeySubTree, cfg.prefix) + "/"
+			subPath := append(treePath[:len(treePath):len(treePath)], cfg)
+			if err := m.markTree(ctx, tx, subPrefix, cfg.hashFunc, root, subPath); err != nil {
+				return err
+			}
+		}
+	}
+	// The node is marked once all its descendants have been marked, so
+	// that a marked node always implies a fully marked subtree.
+	m.marks[id] = struct{}{}
+	return nil
+}
+
+// markVersion marks all the nodes reachable from the mainTree root.
+func (m *pruneMarker) markVersion(ctx context.Context, root []byte) error {
+	tx := m.db.ReadTx()
+	defer tx.Discard()
+	return m.markTree(ctx, tx, "", mainTreeCfg.hashFunc, root, nil)
+}
+
+// markPending marks the versions committed since the last call.  Must be
+// called with StateDB.pruneLock held.
+func (m *pruneMarker) markPending(ctx context.Context) error {
+	for _, root := range m.pending {
+		if err := m.markVersion(ctx, root); err != nil {
+			return err
+		}
+	}
+	m.pending = nil
+	return nil
+}
+
+// Prune deletes the versions that don't need to be kept according to opts,
+// and garbage-collects the tree nodes (of the mainTree and all its subTrees)
+// that are no longer reachable from the kept versions.  Prune works
+// incrementally: nodes are deleted in small batches, and TreeTx commits can
+// happen concurrently.  The versions committed while pruning are always kept.
+// Only one Prune can run at a time, concurrent calls wait for the previous
+// one to finish.
+//
+// The nodes of a subTree that is not reachable from any kept version are not
+// deleted, as the subTree can't be discovered.
+func (s *StateDB) Prune(ctx context.Context, opts PruneOptions) (*PruneStats, error) {
+	s.pruneRunLock.Lock()
+	defer s.pruneRunLock.Unlock()
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = pruneDefaultBatchSize
+	}
+
+	marker := &pruneMarker{
+		db:       s.db,
+		subTrees: opts.SubTrees,
+		marks:    make(map[string]struct{}),
+		trees:    make(map[string]int),
+	}
+	pinned := make(map[uint32]bool)
+	if opts.Pinned != nil {
+		versions, err := opts.Pinned()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get pinned versions: %w", err)
+		}
+		for _, version := range versions {
+			pinned[version] = true
+		}
+	}
+	// Get the versions to prune and start tracking new commits atomically.
+	s.pruneLock.Lock()
+	keep, prune, err := s.pruneVersions(&opts, pinned)
+	if err == nil {
+		s.pruneMarker = marker
+	}
+	s.pruneLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		s.pruneLock.Lock()
+		s.pruneMarker = nil
+		s.pruneLock.Unlock()
+	}()
+	stats := &PruneStats{}
+	if len(prune) == 0 {
+		return stats, nil
+	}
+
+	for _, root := range keep {
+		if err := marker.markVersion(ctx, root); err != nil {
+			return stats, fmt.Errorf("cannot mark nodes: %w", err)
+		}
+	}
+
+	// Delete the pruned versions
+	verPrefix := path.Join(subKeyMeta, pathVersion) + "/"
+	var keys [][]byte
+	for _, version := range prune {
+		keys = append(keys, []byte(verPrefix+string(uint32ToBytes(version))))
+	}
+	if _, err := s.pruneDelete(ctx, marker, keys, opts.BatchSize); err != nil {
+		return stats, err
+	}
+	stats.Versions = len(prune)
+
+	// Sweep the unreachable nodes of all the visited trees
+	treePrefixes := make([]string, 0, len(marker.trees))
+	for treePrefix := range marker.trees {
+		treePrefixes = append(treePrefixes, treePrefix)
+	}
+	sort.Strings(treePrefixes)
+	for _, treePrefix := range treePrefixes {
+		hashLen := marker.trees[treePrefix]
+		var candidates [][]byte
+		if err := s.db.Iterate([]byte(treePrefix), func(key, _ []byte) bool {
+			// Skip arbo metadata (such as the root and the number
+			// of leafs), which is not a node.
+			if len(key) != hashLen {
+				return true
+			}
+			id := treePrefix + string(key)
+			if _, ok := marker.marks[id]; !ok {
+				candidates = append(candidates, []byte(id))
+			}
+			return true
+		}); err != nil {
+			return stats, err
+		}
+		n, err := s.pruneDelete(ctx, marker, candidates, opts.BatchSize)
+		stats.Nodes += n
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// pruneVersions returns the roots of the versions to keep and the versions to
+// prune.  Must be called with pruneLock held.
+func (s *StateDB) pruneVersions(opts *PruneOptions,
+	pinned map[uint32]bool) ([][]byte, []uint32, error) {
+	tx := s.db.ReadTx()
+	defer tx.Discard()
+	last, err := getVersion(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var keep [][]byte
+	var prune []uint32
+	verPrefix := path.Join(subKeyMeta, pathVersion) + "/"
+	if err := s.db.Iterate([]byte(verPrefix), func(key, value []byte) bool {
+		if len(key) != 4 {
+			// keyCurVersion
+			return true
+		}
+		version := binary.LittleEndian.Uint32(key)
+		if opts.keep(version, last, pinned) {
+			keep = append(keep, append([]byte{}, value...))
+		} else {
+			prune = append(prune, version)
+		}
+		return true
+	}); err != nil {
+		return nil, nil, err
+	}
+	return keep, prune, nil
+}
+
+// pruneDelete deletes keys in batches of batchSize.  Before each batch, the
+// versions committed in the meantime are marked, and the keys that became
+// reachable are skipped.  Returns the number of deleted keys.
+func (s *StateDB) pruneDelete(ctx context.Context, marker *pruneMarker,
+	keys [][]byte, batchSize int) (int, error) {
+	deleted := 0
+	for len(keys) > 0 {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+		n := batchSize
+		if n > len(keys) {
+			n = len(keys)
+		}
+		batch := keys[:n]
+		keys = keys[n:]
+		if err := func() error {
+			s.pruneLock.Lock()
+			defer s.pruneLock.Unlock()
+			if err := marker.markPending(ctx); err != nil {
+				return fmt.Errorf("cannot mark nodes: %w", err)
+			}
+			tx := s.db.WriteTx()
+			defer tx.Discard()
+			for _, key := range batch {
+				if _, ok := marker.marks[string(key)]; ok {
+					continue
+				}
+				if err := tx.Delete(key); err != nil {
+					return err
+				}
+				deleted++
+			}
+			return tx.Commit()
+		}(); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
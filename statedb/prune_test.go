@@ -0,0 +1,196 @@
+package statedb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/metadb"
+)
+
+// pruneTestSubTrees implements LeafSubTreesFn for the trees used in the
+// pruning tests.
+func pruneTestSubTrees(path []TreeConfig, key, value []byte) ([]TreeConfig, error) {
+	if len(path) != 0 {
+		return nil, nil
+	}
+	switch string(key) {
+	case string(singleCfg.Key()):
+		return []TreeConfig{singleCfg}, nil
+	case "01234567":
+		return []TreeConfig{multiACfg.WithKey(key), multiBCfg.WithKey(key)}, nil
+	}
+	return nil, nil
+}
+
+// pruneTestCommit commits a new version that updates the mainTree and all
+// its subTrees.
+func pruneTestCommit(t *testing.T, sdb *StateDB, version uint32) {
+	id := []byte("01234567")
+	mainTree, err := sdb.BeginTx()
+	qt.Assert(t, err, qt.IsNil)
+	defer mainTree.Discard()
+	value := []byte(fmt.Sprintf("value%d", version))
+	if version == 1 {
+		qt.Assert(t, mainTree.Add(singleCfg.Key(), emptyHash), qt.IsNil)
+		qt.Assert(t, mainTree.Add(id, make([]byte, 32*2)), qt.IsNil)
+		qt.Assert(t, mainTree.Add([]byte("main"), value), qt.IsNil)
+	} else {
+		qt.Assert(t, mainTree.Set([]byte("main"), value), qt.IsNil)
+	}
+	single, err := mainTree.SubTree(singleCfg)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, single.Add([]byte(fmt.Sprintf("key%d", version)), value), qt.IsNil)
+	for _, cfg := range []*TreeNonSingletonConfig{multiACfg, multiBCfg} {
+		multi, err := mainTree.SubTree(cfg.WithKey(id))
+		qt.Assert(t, err, qt.IsNil)
+		if version == 1 {
+			qt.Assert(t, multi.Add([]byte("key"), value), qt.IsNil)
+		} else {
+			qt.Assert(t, multi.Set([]byte("key"), value), qt.IsNil)
+		}
+	}
+	qt.Assert(t, mainTree.Commit(version), qt.IsNil)
+}
+
+// pruneTestCheck checks that all the values of version can be read.
+func pruneTestCheck(t *testing.T, sdb *StateDB, version uint32) {
+	id := []byte("01234567")
+	value := []byte(fmt.Sprintf("value%d", version))
+	root, err := sdb.VersionRoot(version)
+	qt.Assert(t, err, qt.IsNil)
+	mainTree, err := sdb.TreeView(root)
+	qt.Assert(t, err, qt.IsNil)
+	v, err := mainTree.Get([]byte("main"))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, v, qt.DeepEquals, value)
+	single, err := mainTree.SubTree(singleCfg)
+	qt.Assert(t, err, qt.IsNil)
+	for i := uint32(1); i <= version; i++ {
+		v, err := single.Get([]byte(fmt.Sprintf("key%d", i)))
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, v, qt.DeepEquals, []byte(fmt.Sprintf("value%d", i)))
+	}
+	for _, cfg := range []*TreeNonSingletonConfig{multiACfg, multiBCfg} {
+		multi, err := mainTree.SubTree(cfg.WithKey(id))
+		qt.Assert(t, err, qt.IsNil)
+		v, err := multi.Get([]byte("key"))
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, v, qt.DeepEquals, value)
+	}
+}
+
+// countKeys returns the number of keys in database.
+func countKeys(t *testing.T, database db.Database) int {
+	n := 0
+	qt.Assert(t, database.Iterate(nil, func(_, _ []byte) bool {
+		n++
+		return true
+	}), qt.IsNil)
+	return n
+}
+
+func TestPrune(t *testing.T) {
+	sdb := NewStateDB(metadb.NewTest(t))
+	for version := uint32(1); version <= 10; version++ {
+		pruneTestCommit(t, sdb, version)
+	}
+	keysBefore := countKeys(t, sdb.db)
+
+	opts := PruneOptions{
+		KeepRecent: 3,
+		KeepEvery:  4,
+		SubTrees:   pruneTestSubTrees,
+		BatchSize:  7,
+	}
+	stats, err := sdb.Prune(context.Background(), opts)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, stats.Versions, qt.Equals, 6)
+	qt.Assert(t, stats.Nodes > 0, qt.IsTrue)
+	qt.Assert(t, countKeys(t, sdb.db), qt.Equals, keysBefore-stats.Versions-stats.Nodes)
+
+	for version := uint32(1); version <= 10; version++ {
+		switch version {
+		case 4, 8, 9, 10:
+			pruneTestCheck(t, sdb, version)
+		default:
+			_, err := sdb.VersionRoot(version)
+			qt.Assert(t, err, qt.ErrorIs, db.ErrKeyNotFound)
+		}
+	}
+
+	// A second pruning has nothing to do
+	stats, err = sdb.Prune(context.Background(), opts)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, *stats, qt.Equals, PruneStats{})
+
+	// New versions can be committed after pruning
+	pruneTestCommit(t, sdb, 11)
+	pruneTestCheck(t, sdb, 11)
+}
+
+func TestPruneKeepEvery(t *testing.T) {
+	sdb := NewStateDB(metadb.NewTest(t))
+	for version := uint32(1); version <= 10; version++ {
+		pruneTestCommit(t, sdb, version)
+	}
+	stats, err := sdb.Prune(context.Background(), PruneOptions{
+		KeepEvery: 4,
+		SubTrees:  pruneTestSubTrees,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, stats.Versions, qt.Equals, 7)
+	for version := uint32(1); version <= 10; version++ {
+		switch version {
+		case 4, 8, 10:
+			pruneTestCheck(t, sdb, version)
+		default:
+			_, err := sdb.VersionRoot(version)
+			qt.Assert(t, err, qt.ErrorIs, db.ErrKeyNotFound)
+		}
+	}
+}
+
+func TestPruneConcurrentCommits(t *testing.T) {
+	sdb := NewStateDB(metadb.NewTest(t))
+	for version := uint32(1); version <= 10; version++ {
+		pruneTestCommit(t, sdb, version)
+	}
+	opts := PruneOptions{
+		KeepRecent: 2,
+		SubTrees:   pruneTestSubTrees,
+		BatchSize:  1,
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			if _, err := sdb.Prune(context.Background(), opts); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for version := uint32(11); version <= 30; version++ {
+		pruneTestCommit(t, sdb, version)
+	}
+	wg.Wait()
+
+	_, err := sdb.Prune(context.Background(), opts)
+	qt.Assert(t, err, qt.IsNil)
+	pruneTestCheck(t, sdb, 29)
+	pruneTestCheck(t, sdb, 30)
+	_, err = sdb.VersionRoot(28)
+	qt.Assert(t, err, qt.ErrorIs, db.ErrKeyNotFound)
+
+	// A canceled pruning returns an error
+	pruneTestCommit(t, sdb, 31)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sdb.Prune(ctx, opts)
+	qt.Assert(t, err, qt.ErrorIs, context.Canceled)
+	pruneTestCheck(t, sdb, 31)
+}
@@ -0,0 +1,144 @@
+package statedb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.vocdoni.io/dvote/db"
+)
+
+// snapshotMaxEntrySize is the maximum size of a key or value accepted while
+// importing a snapshot.  It protects against allocating huge buffers when
+// reading a malformed snapshot.
+const snapshotMaxEntrySize = 64 << 20 // 64 MiB
+
+// Export writes all the key-values stored in the StateDB database into w.  This
+// includes the mainTree, all the subTrees (with their NoState databases) and
+// the versions metadata, so that the resulting stream can be loaded into an
+// empty database with Import to obtain an identical StateDB.  Each entry is
+// encoded as `uvarint(len(key)) | key | uvarint(len(value)) | value`.
+//
+// Export must not be called while a TreeTx is being committed, otherwise the
+// exported state could mix two different versions.  Use Snapshot to export
+// while new versions are committed.
+func (s *StateDB) Export(w io.Writer) error {
+	return export(w, s.db.Iterate)
+}
+
+// Snapshot is a read-only view of the StateDB database at the time it was
+// created, which can be exported while new versions are committed.
+type Snapshot struct {
+	snap db.Snapshot
+}
+
+// Snapshot returns a read-only view of the current StateDB database.  It must
+// not be called while a TreeTx is being committed, and must be closed once it
+// is no longer used.
+func (s *StateDB) Snapshot() (*Snapshot, error) {
+	snapshotter, ok := s.db.(db.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("database %T does not support snapshots", s.db)
+	}
+	return &Snapshot{snap: snapshotter.Snapshot()}, nil
+}
+
+// Export writes the snapshot contents into w, like StateDB.Export.
+func (sn *Snapshot) Export(w io.Writer) error {
+	return export(w, sn.snap.Iterate)
+}
+
+// Close releases the snapshot.
+func (sn *Snapshot) Close() error {
+	return sn.snap.Close()
+}
+
+func export(w io.Writer, iterate func(prefix []byte, callback func(key, value []byte) bool) error) error {
+	bw := bufio.NewWriter(w)
+	var werr error
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	writeEntry := func(b []byte) error {
+		n := binary.PutUvarint(lenBuf, uint64(len(b)))
+		if _, err := bw.Write(lenBuf[:n]); err != nil {
+			return err
+		}
+		_, err := bw.Write(b)
+		return err
+	}
+	if err := iterate(nil, func(key, value []byte) bool {
+		if werr = writeEntry(key); werr != nil {
+			return false
+		}
+		if werr = writeEntry(value); werr != nil {
+			return false
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+	if werr != nil {
+		return fmt.Errorf("cannot write snapshot entry: %w", werr)
+	}
+	return bw.Flush()
+}
+
+// Import replaces the contents of the StateDB database by the key-values read
+// from r, which must have been generated by Export.  All the existing
+// key-values are deleted before the import.  No TreeTx must be open while
+// calling Import.
+func (s *StateDB) Import(r io.Reader) error {
+	// Collect the keys first, so that we don't delete while iterating.
+	var keys [][]byte
+	if err := s.db.Iterate(nil, func(key, _ []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		return true
+	}); err != nil {
+		return err
+	}
+	batch := db.NewBatch(s.db)
+	defer batch.Discard()
+	for _, key := range keys {
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	br := bufio.NewReader(r)
+	readEntry := func() ([]byte, error) {
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		if size > snapshotMaxEntrySize {
+			return nil, fmt.Errorf("snapshot entry too big: %d bytes", size)
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	for {
+		key, err := readEntry()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("cannot read snapshot key: %w", err)
+		}
+		value, err := readEntry()
+		if err != nil {
+			// An EOF here means the stream was cut between a key
+			// and its value.
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("cannot read snapshot value: %w", err)
+		}
+		if err := batch.Set(key, value); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
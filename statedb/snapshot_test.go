@@ -0,0 +1,83 @@
+package statedb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/db/metadb"
+)
+
+func TestExportImport(t *testing.T) {
+	sdb := NewStateDB(metadb.NewTest(t))
+	mainTree, err := sdb.BeginTx()
+	qt.Assert(t, err, qt.IsNil)
+	for i := 0; i < 100; i++ {
+		qt.Assert(t, mainTree.Add([]byte(fmt.Sprintf("key%d", i)),
+			[]byte(fmt.Sprintf("val%d", i))), qt.IsNil)
+	}
+	qt.Assert(t, mainTree.Commit(1), qt.IsNil)
+	root1, err := sdb.Hash()
+	qt.Assert(t, err, qt.IsNil)
+
+	var buf bytes.Buffer
+	qt.Assert(t, sdb.Export(&buf), qt.IsNil)
+	exported := buf.Bytes()
+
+	// Import into a StateDB with different contents, which must be replaced
+	sdb2 := NewStateDB(metadb.NewTest(t))
+	mainTree2, err := sdb2.BeginTx()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, mainTree2.Add([]byte("other"), []byte("value")), qt.IsNil)
+	qt.Assert(t, mainTree2.Commit(5), qt.IsNil)
+
+	qt.Assert(t, sdb2.Import(bytes.NewReader(exported)), qt.IsNil)
+	version, err := sdb2.Version()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, version, qt.Equals, uint32(1))
+	root2, err := sdb2.Hash()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, root2, qt.DeepEquals, root1)
+
+	view, err := sdb2.TreeView(nil)
+	qt.Assert(t, err, qt.IsNil)
+	v, err := view.Get([]byte("key42"))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, v, qt.DeepEquals, []byte("val42"))
+	_, err = view.Get([]byte("other"))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// Re-exporting the imported StateDB gives the same stream
+	buf.Reset()
+	qt.Assert(t, sdb2.Export(&buf), qt.IsNil)
+	qt.Assert(t, buf.Bytes(), qt.DeepEquals, exported)
+
+	// A truncated stream must fail
+	sdb3 := NewStateDB(metadb.NewTest(t))
+	err = sdb3.Import(bytes.NewReader(exported[:len(exported)-1]))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
+
+func TestSnapshotExport(t *testing.T) {
+	sdb := NewStateDB(metadb.NewTest(t))
+	mainTree, err := sdb.BeginTx()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, mainTree.Add([]byte("key1"), []byte("val1")), qt.IsNil)
+	qt.Assert(t, mainTree.Commit(1), qt.IsNil)
+	var buf bytes.Buffer
+	qt.Assert(t, sdb.Export(&buf), qt.IsNil)
+
+	// The versions committed after the snapshot is taken are not exported
+	snap, err := sdb.Snapshot()
+	qt.Assert(t, err, qt.IsNil)
+	defer snap.Close()
+	mainTree, err = sdb.BeginTx()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, mainTree.Add([]byte("key2"), []byte("val2")), qt.IsNil)
+	qt.Assert(t, mainTree.Commit(2), qt.IsNil)
+
+	var snapBuf bytes.Buffer
+	qt.Assert(t, snap.Export(&snapBuf), qt.IsNil)
+	qt.Assert(t, snapBuf.Bytes(), qt.DeepEquals, buf.Bytes())
+}
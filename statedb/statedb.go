@@ -149,6 +149,17 @@ func (c *TreeNonSingletonConfig) HashFunc() arbo.HashFunction {
 	return c.hashFunc
 }
 
+// KindID returns the identifier of the kind of tree set for this SubTreeConfig
+func (c *TreeNonSingletonConfig) KindID() string {
+	return c.kindID
+}
+
+// ParentLeafGetRoot returns the function used to get the root of this subTree
+// from the value of its parent leaf.
+func (c *TreeNonSingletonConfig) ParentLeafGetRoot() GetRootFn {
+	return c.parentLeafGetRoot
+}
+
 // WithKey returns a unified subTree configuration type for opening a singleton
 // subTree that is identified by `key`.  `key` is the path in the parent tree
 // to the leaf that contains the subTree root.
@@ -179,7 +190,7 @@ func NewTreeSingletonConfig(params TreeParams) TreeConfig {
 // Key returns the key used in the parent tree in which the value that contains
 // the subTree root is stored.  The key is the path of the parent leaf with the root.
 func (c *TreeConfig) Key() []byte {
-	return []byte(c.kindID)
+	return c.parentLeafKey
 }
 
 // HashFunc returns the hashFunc set for this SubTreeSingleConfig
@@ -198,6 +209,12 @@ var mainTreeCfg = NewTreeSingletonConfig(TreeParams{
 	ParentLeafSetRoot: nil,
 })
 
+// MainTreeHashFunc returns the hash function used by the mainTree, which is
+// required to verify proofs against the StateDB root.
+func MainTreeHashFunc() arbo.HashFunction {
+	return mainTreeCfg.hashFunc
+}
+
 // StateDB is a database backed structure that holds a dynamic hierarchy of
 // linked merkle trees with the property that the keys and values of all merkle
 // trees can be cryptographically represented by a single hash, the
@@ -205,6 +222,14 @@ var mainTreeCfg = NewTreeSingletonConfig(TreeParams{
 type StateDB struct {
 	hashLen int
 	db      db.Database
+	// pruneLock serializes the TreeTx commits with the deletions done
+	// while pruning.
+	pruneLock sync.Mutex
+	// pruneMarker is set while a pruning is in progress.  It is protected
+	// by pruneLock.
+	pruneMarker *pruneMarker
+	// pruneRunLock ensures that only one pruning runs at a time.
+	pruneRunLock sync.Mutex
 }
 
 // NewStateDB returns an instance of the StateDB.
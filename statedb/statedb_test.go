@@ -531,3 +531,60 @@ func TestBigUpdateDiscard(t *testing.T) {
 	qt.Assert(t, singleRoot, qt.DeepEquals, emptyHash)
 	mainTree.Discard()
 }
+
+func TestDel(t *testing.T) {
+	id := []byte("01234567")
+	// newStateDB returns a StateDB with a singleton and two non-singleton
+	// subTrees, where the key-values at index skip are not added.
+	newStateDB := func(skip map[int]bool) *StateDB {
+		sdb := NewStateDB(metadb.NewTest(t))
+		mainTree, err := sdb.BeginTx()
+		qt.Assert(t, err, qt.IsNil)
+		defer mainTree.Discard()
+		qt.Assert(t, mainTree.Add(singleCfg.Key(), emptyHash), qt.IsNil)
+		qt.Assert(t, mainTree.Add(id, make([]byte, 32*2)), qt.IsNil)
+		for i := 0; i < 8; i++ {
+			if skip[i] {
+				continue
+			}
+			k := []byte(fmt.Sprintf("key%d", i))
+			v := []byte(fmt.Sprintf("value%d", i))
+			qt.Assert(t, mainTree.DeepAdd(k, v, singleCfg), qt.IsNil)
+			qt.Assert(t, mainTree.DeepAdd(k, v, multiACfg.WithKey(id)), qt.IsNil)
+			qt.Assert(t, mainTree.DeepAdd(k, v, multiBCfg.WithKey(id)), qt.IsNil)
+		}
+		qt.Assert(t, mainTree.Commit(0), qt.IsNil)
+		return sdb
+	}
+
+	sdb := newStateDB(nil)
+	skip := map[int]bool{1: true, 4: true, 5: true}
+	mainTree, err := sdb.BeginTx()
+	qt.Assert(t, err, qt.IsNil)
+	defer mainTree.Discard()
+	for i := range skip {
+		k := []byte(fmt.Sprintf("key%d", i))
+		qt.Assert(t, mainTree.DeepDel(k, singleCfg), qt.IsNil)
+		qt.Assert(t, mainTree.DeepDel(k, multiACfg.WithKey(id)), qt.IsNil)
+		qt.Assert(t, mainTree.DeepDel(k, multiBCfg.WithKey(id)), qt.IsNil)
+	}
+	err = mainTree.DeepDel([]byte("key1"), singleCfg)
+	qt.Assert(t, err, qt.Equals, arbo.ErrKeyNotFound)
+	qt.Assert(t, mainTree.Commit(1), qt.IsNil)
+
+	mainTreeView, err := sdb.TreeView(nil)
+	qt.Assert(t, err, qt.IsNil)
+	_, err = mainTreeView.DeepGet([]byte("key4"), multiACfg.WithKey(id))
+	qt.Assert(t, err, qt.Equals, arbo.ErrKeyNotFound)
+	v, err := mainTreeView.DeepGet([]byte("key3"), multiBCfg.WithKey(id))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, v, qt.DeepEquals, []byte("value3"))
+
+	// The deletions are propagated up to the mainTree, whose root must be
+	// the same as if the deleted keys had never been added.
+	hash, err := sdb.Hash()
+	qt.Assert(t, err, qt.IsNil)
+	expectedHash, err := newStateDB(skip).Hash()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, hash, qt.DeepEquals, expectedHash)
+}
@@ -120,6 +120,13 @@ func (u *TreeUpdate) Set(key, value []byte) error {
 	return u.tree.Set(u.tree.tx, key, value)
 }
 
+// Del removes a key-value from this tree.  `key` is the path of the leaf.
+// Returns arbo.ErrKeyNotFound if the key doesn't exist.
+func (u *TreeUpdate) Del(key []byte) error {
+	u.dirtyTree = true
+	return u.tree.Del(u.tree.tx, key)
+}
+
 // SubTree is used to open the subTree (singleton and non-singleton) as a
 // TreeUpdate.  The treeUpdate.tx is created from u.tx appending the prefix
 // `subKeySubTree | cfg.prefix`.  In turn the treeUpdate.tree uses the
@@ -207,6 +214,16 @@ func (u *TreeUpdate) DeepSet(key, value []byte, cfgs ...TreeConfig) error {
 	return tree.Set(key, value)
 }
 
+// DeepDel allows performing a Del on a nested subTree by passing the list
+// of tree configurations and the key to delete on the last subTree.
+func (u *TreeUpdate) DeepDel(key []byte, cfgs ...TreeConfig) error {
+	tree, err := u.DeepSubTree(cfgs...)
+	if err != nil {
+		return err
+	}
+	return tree.Del(key)
+}
+
 // TreeTx is a wrapper over TreeUpdate that includes the Commit and Discard
 // methods to control the transaction used to update the StateDB.  It contains
 // the mainTree opened in the wrapped TreeUpdate.  The TreeTx is not safe for
@@ -325,7 +342,17 @@ func (t *TreeTx) Commit(version uint32) error {
 	if err := setVersionRoot(t.tx, version, root); err != nil {
 		return err
 	}
-	return t.tx.Commit()
+	t.sdb.pruneLock.Lock()
+	defer t.sdb.pruneLock.Unlock()
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	// If a pruning is in progress, the nodes of the new version must be
+	// marked as reachable before deleting any node.
+	if m := t.sdb.pruneMarker; m != nil {
+		m.pending = append(m.pending, root)
+	}
+	return nil
 }
 
 // Discard all the changes that have been made from the TreeTx.  After calling
@@ -1,6 +1,8 @@
 package tree
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -11,8 +13,9 @@ import (
 
 // Tree defines the struct that implements the MerkleTree functionalities
 type Tree struct {
-	tree *arbo.Tree
-	db   db.Database
+	tree      *arbo.Tree
+	db        db.Database
+	maxLevels int
 }
 
 // Options is used to pass the parameters to load a new Tree
@@ -51,8 +54,9 @@ func New(wTx db.WriteTx, opts Options) (*Tree, error) {
 	}
 
 	return &Tree{
-		tree: tree,
-		db:   opts.DB,
+		tree:      tree,
+		db:        opts.DB,
+		maxLevels: opts.MaxLevels,
 	}, nil
 }
 
@@ -120,6 +124,142 @@ func (t *Tree) Add(wTx db.WriteTx, key, value []byte) error {
 	return nil
 }
 
+// Del removes the leaf of the given key.  The tree is rebalanced so that the
+// resulting root is the same as if the key had never been added.  If the key
+// does not exist, arbo.ErrKeyNotFound is returned.
+func (t *Tree) Del(wTx db.WriteTx, key []byte) error {
+	givenTx := wTx != nil
+	if !givenTx {
+		wTx = t.DB().WriteTx()
+		defer wTx.Discard()
+	}
+	if err := t.del(wTx, key); err != nil {
+		return err
+	}
+	if !givenTx {
+		if err := wTx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// del implements Del on top of the arbo database layout, as arbo doesn't
+// support deletions.  The tree is walked down to the leaf collecting the
+// siblings, and then walked up replacing the leaf by an empty node.  While the
+// subtree being walked up contains a single leaf (or none), no intermediate
+// nodes are created, so that the remaining leaf is moved up to the level where
+// arbo would have placed it.
+func (t *Tree) del(wTx db.WriteTx, key []byte) error {
+	hashFunc := t.tree.HashFunction()
+	emptyHash := make([]byte, hashFunc.Len())
+	keyPath := make([]byte, (t.maxLevels+7)/8)
+	if len(key) > len(keyPath) {
+		return fmt.Errorf("key length %d exceeds the maximum of %d bytes", len(key), len(keyPath))
+	}
+	copy(keyPath, key)
+	right := func(lvl int) bool {
+		return keyPath[lvl/8]&(1<<(lvl%8)) != 0
+	}
+	isLeaf := func(node []byte) (bool, error) {
+		value, err := wTx.Get(node)
+		if err != nil {
+			return false, fmt.Errorf("cannot get node %x: %w", node, err)
+		}
+		return len(value) > 0 && value[0] == arbo.PrefixValueLeaf, nil
+	}
+
+	root, err := t.tree.RootWithTx(wTx)
+	if err != nil {
+		return err
+	}
+	// go down to the leaf
+	var siblings [][]byte
+	for node := root; ; {
+		if bytes.Equal(node, emptyHash) {
+			return arbo.ErrKeyNotFound
+		}
+		value, err := wTx.Get(node)
+		if err != nil {
+			return fmt.Errorf("cannot get node %x: %w", node, err)
+		}
+		if len(value) > 0 && value[0] == arbo.PrefixValueLeaf {
+			leafKey, _ := arbo.ReadLeafValue(value)
+			if !bytes.Equal(leafKey, key) {
+				return arbo.ErrKeyNotFound
+			}
+			break
+		}
+		if len(value) == 0 || value[0] != arbo.PrefixValueIntermediate {
+			return arbo.ErrInvalidValuePrefix
+		}
+		if len(siblings) >= t.maxLevels {
+			return arbo.ErrMaxLevel
+		}
+		l, r := arbo.ReadIntermediateChilds(value)
+		if right(len(siblings)) {
+			siblings = append(siblings, l)
+			node = r
+		} else {
+			siblings = append(siblings, r)
+			node = l
+		}
+	}
+
+	// go up to the root
+	node := emptyHash
+	collapsing := true
+	for lvl := len(siblings) - 1; lvl >= 0; lvl-- {
+		sibling := siblings[lvl]
+		if collapsing {
+			if bytes.Equal(sibling, emptyHash) {
+				continue
+			}
+			if bytes.Equal(node, emptyHash) {
+				leaf, err := isLeaf(sibling)
+				if err != nil {
+					return err
+				}
+				if leaf {
+					node = sibling
+					continue
+				}
+			}
+			collapsing = false
+		}
+		l, r := node, sibling
+		if right(lvl) {
+			l, r = sibling, node
+		}
+		value := make([]byte, arbo.PrefixValueLen+2*hashFunc.Len())
+		value[0] = arbo.PrefixValueIntermediate
+		value[1] = byte(len(l))
+		copy(value[arbo.PrefixValueLen:], l)
+		copy(value[arbo.PrefixValueLen+hashFunc.Len():], r)
+		if node, err = hashFunc.Hash(l, r); err != nil {
+			return err
+		}
+		if err := wTx.Set(node, value); err != nil {
+			return err
+		}
+	}
+	if err := t.tree.SetRootWithTx(wTx, node); err != nil {
+		return err
+	}
+
+	nLeafs, err := t.tree.GetNLeafsWithTx(wTx)
+	if err != nil {
+		return err
+	}
+	if nLeafs > 0 {
+		nLeafs--
+	}
+	// same encoding as the arbo "nleafs" key
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(nLeafs))
+	return wTx.Set([]byte("nleafs"), b)
+}
+
 // AddBatch adds a batch of key-values to the Tree. Returns an array containing
 // the indexes of the keys failed to add. Supports empty values as input
 // parameters, which is equivalent to 0 valued byte array.
@@ -231,8 +371,9 @@ func (t *Tree) FromRoot(root []byte) (*Tree, error) {
 		return nil, err
 	}
 	return &Tree{
-		tree: tree,
-		db:   t.db,
+		tree:      tree,
+		db:        t.db,
+		maxLevels: t.maxLevels,
 	}, nil
 }
 
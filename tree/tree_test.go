@@ -1,6 +1,7 @@
 package tree
 
 import (
+	"math/rand"
 	"strconv"
 	"testing"
 
@@ -138,3 +139,62 @@ func TestFromRoot(t *testing.T) {
 	err = wTx.Commit()
 	qt.Assert(t, err, qt.IsNil)
 }
+
+func TestDel(t *testing.T) {
+	// Use short keys so that many of them share a long path prefix, which
+	// forces the leafs to be moved up after the deletions.
+	var keys [][]byte
+	for i := 0; i < 64; i++ {
+		keys = append(keys, []byte{byte(i * 37), byte(i % 3)})
+	}
+	newTree := func() *Tree {
+		tree, err := New(nil, Options{DB: metadb.NewTest(t), MaxLevels: 16,
+			HashFunc: arbo.HashFunctionBlake2b})
+		qt.Assert(t, err, qt.IsNil)
+		return tree
+	}
+
+	tree := newTree()
+	for i, k := range keys {
+		qt.Assert(t, tree.Add(nil, k, []byte("value"+strconv.Itoa(i))), qt.IsNil)
+	}
+	err := tree.Del(nil, []byte{0xff, 0xff})
+	qt.Assert(t, err, qt.Equals, arbo.ErrKeyNotFound)
+
+	rnd := rand.New(rand.NewSource(1))
+	deleted := make(map[int]bool)
+	for _, i := range rnd.Perm(len(keys)) {
+		qt.Assert(t, tree.Del(nil, keys[i]), qt.IsNil)
+		deleted[i] = true
+		_, err := tree.Get(nil, keys[i])
+		qt.Assert(t, err, qt.Equals, arbo.ErrKeyNotFound)
+		qt.Assert(t, tree.Del(nil, keys[i]), qt.Equals, arbo.ErrKeyNotFound)
+
+		// The root must match a tree where the deleted keys were
+		// never added.
+		expected := newTree()
+		for j, k := range keys {
+			if !deleted[j] {
+				qt.Assert(t, expected.Add(nil, k, []byte("value"+strconv.Itoa(j))), qt.IsNil)
+			}
+		}
+		root, err := tree.Root(nil)
+		qt.Assert(t, err, qt.IsNil)
+		expectedRoot, err := expected.Root(nil)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, root, qt.DeepEquals, expectedRoot)
+
+		size, err := tree.Size(nil)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, size, qt.Equals, uint64(len(keys)-len(deleted)))
+	}
+	root, err := tree.Root(nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, root, qt.DeepEquals, make([]byte, arbo.HashFunctionBlake2b.Len()))
+
+	// The tree can be used normally after the deletions
+	qt.Assert(t, tree.Add(nil, keys[0], []byte("value0")), qt.IsNil)
+	v, err := tree.Get(nil, keys[0])
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, v, qt.DeepEquals, []byte("value0"))
+}
@@ -40,6 +40,15 @@ func (u *URLAPI) EnableVotingHandlers(vocdoniAPP *vochain.BaseApplication, vocdo
 		return err
 	}
 
+	if err := u.api.RegisterMethod(
+		"/transactions/simulate",
+		"POST",
+		bearerstdapi.MethodAccessTypePublic,
+		u.simulateTxHandler,
+	); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -124,3 +133,33 @@ func (u *URLAPI) processHandler(msg *bearerstdapi.BearerStandardAPIdata, ctx *ht
 	}
 	return nil
 }
+
+// https://server/v1/pub/transactions/simulate
+func (u *URLAPI) simulateTxHandler(msg *bearerstdapi.BearerStandardAPIdata, ctx *httprouter.HTTPContext) error {
+	var req TransactionMsg
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		return fmt.Errorf("cannot decode request: %w", err)
+	}
+	if len(req.Payload) == 0 {
+		return fmt.Errorf("payload is empty")
+	}
+	sim, err := u.vocapp.SimulateTx(req.Payload)
+	if err != nil {
+		return fmt.Errorf("cannot simulate transaction: %w", err)
+	}
+	data, err := json.Marshal(&TransactionSimulation{
+		TxHash:    sim.TxID,
+		Valid:     sim.Valid,
+		Error:     sim.Error,
+		Nullifier: sim.Nullifier,
+		Cost:      sim.Cost,
+		Nonce:     sim.Nonce,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON: %w", err)
+	}
+	if err = ctx.Send(data, bearerstdapi.HTTPstatusCodeOK); err != nil {
+		log.Warn(err)
+	}
+	return nil
+}
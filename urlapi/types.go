@@ -44,3 +44,16 @@ type Question struct {
 	Description string   `json:"description"`
 	Choices     []string `json:"choices"`
 }
+
+type TransactionMsg struct {
+	Payload types.HexBytes `json:"payload"`
+}
+
+type TransactionSimulation struct {
+	TxHash    types.HexBytes `json:"txHash"`
+	Valid     bool           `json:"valid"`
+	Error     string         `json:"error,omitempty"`
+	Nullifier types.HexBytes `json:"nullifier,omitempty"`
+	Cost      uint64         `json:"cost"`
+	Nonce     *uint32        `json:"nonce,omitempty"`
+}
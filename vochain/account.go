@@ -445,6 +445,17 @@ func (v *State) SetAccount(accountAddress common.Address, account *Account) erro
 
 // SubstractCostIncrementNonce
 func (v *State) SubstractCostIncrementNonce(accountAddress common.Address, txType models.TxType) error {
+	// get tx cost
+	cost, err := v.TxCost(txType, false)
+	if err != nil {
+		return fmt.Errorf("substractCostIncrementNonce: %w", err)
+	}
+	return v.SubstractAmountIncrementNonce(accountAddress, cost)
+}
+
+// SubstractAmountIncrementNonce sends cost from the account to the burn
+// address and increments the account nonce
+func (v *State) SubstractAmountIncrementNonce(accountAddress common.Address, cost uint64) error {
 	// get account
 	acc, err := v.GetAccount(accountAddress, false)
 	if err != nil {
@@ -453,11 +464,6 @@ func (v *State) SubstractCostIncrementNonce(accountAddress common.Address, txTyp
 	if acc == nil {
 		return ErrAccountNotExist
 	}
-	// get tx cost
-	cost, err := v.TxCost(txType, false)
-	if err != nil {
-		return fmt.Errorf("substractCostIncrementNonce: %w", err)
-	}
 	// increment nonce
 	acc.Nonce++
 	// send cost to burn address
@@ -1,12 +1,25 @@
 package vochain
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	"github.com/tendermint/tendermint/privval"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"go.vocdoni.io/dvote/config"
 	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
 	"go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
 )
@@ -157,3 +170,296 @@ func testRemoveOracle(t *testing.T, oracle *ethereum.SignKeys,
 	app.Commit()
 	return nil
 }
+
+func TestSetOracleQuorum(t *testing.T) {
+	app := TestBaseApplication(t)
+	oracles := make([]*ethereum.SignKeys, 3)
+	for i := range oracles {
+		oracles[i] = ethereum.NewSignKeys()
+		qt.Assert(t, oracles[i].Generate(), qt.IsNil)
+		qt.Assert(t, app.State.AddOracle(oracles[i].Address()), qt.IsNil)
+	}
+	quorum := func() uint32 {
+		quorum, err := app.State.OracleQuorum(true)
+		qt.Assert(t, err, qt.IsNil)
+		return quorum
+	}
+
+	// the quorum must be between one and the number of oracles
+	qt.Assert(t, app.State.SetOracleQuorum(4), qt.ErrorMatches, "invalid oracle quorum.*")
+	qt.Assert(t, testSetOracleQuorum(t, oracles[0], app, 0), qt.ErrorMatches, ".*invalid oracle quorum.*")
+	qt.Assert(t, testSetOracleQuorum(t, oracles[0], app, 4), qt.ErrorMatches, ".*invalid oracle quorum.*")
+	qt.Assert(t, quorum(), qt.Equals, uint32(1))
+
+	qt.Assert(t, testSetOracleQuorum(t, oracles[0], app, 3), qt.IsNil)
+	qt.Assert(t, quorum(), qt.Equals, uint32(3))
+
+	// the oracles needed to reach the quorum cannot be removed
+	qt.Assert(t, testRemoveOracle(t, oracles[0], app, oracles[2].Address()),
+		qt.ErrorMatches, ".*cannot remove oracle.*")
+	qt.Assert(t, testSetOracleQuorum(t, oracles[1], app, 2), qt.IsNil)
+	qt.Assert(t, quorum(), qt.Equals, uint32(2))
+	qt.Assert(t, testRemoveOracle(t, oracles[0], app, oracles[2].Address()), qt.IsNil)
+}
+
+func testSetOracleQuorum(t *testing.T, oracle *ethereum.SignKeys,
+	app *BaseApplication, quorum uint32) error {
+	var cktx abcitypes.RequestCheckTx
+	var detx abcitypes.RequestDeliverTx
+	var cktxresp abcitypes.ResponseCheckTx
+	var detxresp abcitypes.ResponseDeliverTx
+	var stx models.SignedTx
+	var err error
+
+	tx := &models.AdminTx{
+		Txtype:       models.TxType_SET_ORACLE_QUORUM,
+		OracleQuorum: &quorum,
+	}
+
+	if stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: tx}}); err != nil {
+		t.Fatal(err)
+	}
+	if stx.Signature, err = oracle.SignVocdoniTx(stx.Tx); err != nil {
+		t.Fatal(err)
+	}
+	if cktx.Tx, err = proto.Marshal(&stx); err != nil {
+		t.Fatal(err)
+	}
+	cktxresp = app.CheckTx(cktx)
+	if cktxresp.Code != 0 {
+		return fmt.Errorf("checkTx failed: %s", cktxresp.Data)
+	}
+	if detx.Tx, err = proto.Marshal(&stx); err != nil {
+		t.Fatal(err)
+	}
+	detxresp = app.DeliverTx(detx)
+	if detxresp.Code != 0 {
+		return fmt.Errorf("deliverTx failed: %s", detxresp.Data)
+	}
+	app.Commit()
+	return nil
+}
+
+func TestValidatorUpdates(t *testing.T) {
+	app := TestBaseApplication(t)
+	oracle := ethereum.SignKeys{}
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.AddOracle(oracle.Address()), qt.IsNil)
+
+	rng := testutil.NewRandom(0)
+	pubKeyA := ed25519.PubKey(rng.RandomBytes(ed25519.PubKeySize))
+	pubKeyB := ed25519.PubKey(rng.RandomBytes(ed25519.PubKeySize))
+	addValidator := func(pubKey ed25519.PubKey, power uint64) *models.AdminTx {
+		return &models.AdminTx{
+			Txtype:    models.TxType_ADD_VALIDATOR,
+			PublicKey: pubKey,
+			Power:     &power,
+		}
+	}
+	removeValidator := func(pubKey ed25519.PubKey) *models.AdminTx {
+		return &models.AdminTx{
+			Txtype:  models.TxType_REMOVE_VALIDATOR,
+			Address: pubKey.Address(),
+		}
+	}
+	height := int64(0)
+	// doBlock delivers the txs and returns the EndBlock validator updates
+	doBlock := func(txs ...*models.AdminTx) []abcitypes.ValidatorUpdate {
+		height++
+		for _, tx := range txs {
+			qt.Assert(t, testAdminTx(t, &oracle, app, tx), qt.IsNil)
+		}
+		resp := app.EndBlock(abcitypes.RequestEndBlock{Height: height})
+		app.Commit()
+		return resp.ValidatorUpdates
+	}
+
+	updates := doBlock(addValidator(pubKeyA, 10))
+	qt.Assert(t, updates, qt.DeepEquals, []abcitypes.ValidatorUpdate{
+		abcitypes.Ed25519ValidatorUpdate(pubKeyA, 10),
+	})
+	qt.Assert(t, doBlock(), qt.HasLen, 0)
+
+	// re-power A and add B
+	expected := []abcitypes.ValidatorUpdate{
+		abcitypes.Ed25519ValidatorUpdate(pubKeyA, 20),
+		abcitypes.Ed25519ValidatorUpdate(pubKeyB, 5),
+	}
+	if bytes.Compare(pubKeyA.Address(), pubKeyB.Address()) > 0 {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	qt.Assert(t, doBlock(addValidator(pubKeyA, 20), addValidator(pubKeyB, 5)),
+		qt.DeepEquals, expected)
+
+	// setting the same power is not a change
+	qt.Assert(t, doBlock(addValidator(pubKeyB, 5)), qt.HasLen, 0)
+
+	// removing a validator sets its power to 0
+	updates = doBlock(removeValidator(pubKeyA))
+	qt.Assert(t, updates, qt.DeepEquals, []abcitypes.ValidatorUpdate{
+		abcitypes.Ed25519ValidatorUpdate(pubKeyA, 0),
+	})
+	validators, err := app.State.Validators(true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, validators, qt.HasLen, 1)
+	qt.Assert(t, validators[0].PubKey, qt.DeepEquals, []byte(pubKeyB))
+
+	// invalid validator changes are rejected
+	qt.Assert(t, testAdminTx(t, &oracle, app, removeValidator(pubKeyA)), qt.IsNotNil)
+	qt.Assert(t, testAdminTx(t, &oracle, app, removeValidator(pubKeyB)), qt.IsNotNil)
+	qt.Assert(t, testAdminTx(t, &oracle, app, addValidator(pubKeyA, 0)), qt.IsNotNil)
+}
+
+// testAdminTx signs the admin tx with the oracle key, and sends it to CheckTx
+// and DeliverTx.
+func testAdminTx(t *testing.T, oracle *ethereum.SignKeys,
+	app *BaseApplication, tx *models.AdminTx) error {
+	var stx models.SignedTx
+	var err error
+	if stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: tx}}); err != nil {
+		t.Fatal(err)
+	}
+	if stx.Signature, err = oracle.SignVocdoniTx(stx.Tx); err != nil {
+		t.Fatal(err)
+	}
+	txBytes, err := proto.Marshal(&stx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: txBytes}); resp.Code != 0 {
+		return fmt.Errorf("checkTx failed: %s", resp.Data)
+	}
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: txBytes}); resp.Code != 0 {
+		return fmt.Errorf("deliverTx failed: %s", resp.Data)
+	}
+	return nil
+}
+
+// TestValidatorUpdatesNode runs a single validator tendermint node, and checks
+// that the validator changes sent as admin txs take effect on the consensus
+// validator set.
+func TestValidatorUpdatesNode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping tendermint node test in short mode")
+	}
+	oracle := ethereum.SignKeys{}
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	oracle.VocdoniChainID = "test-validators"
+
+	// the node may still be writing its address book while t.TempDir is
+	// removed, so the data dir is removed once the node is stopped
+	dataDir, err := os.MkdirTemp("", "TestValidatorUpdatesNode")
+	qt.Assert(t, err, qt.IsNil)
+	t.Cleanup(func() { os.RemoveAll(dataDir) })
+	cfg := &config.VochainCfg{
+		DataDir:                     dataDir,
+		DBType:                      db.TypePebble,
+		LogLevel:                    "none",
+		P2PListen:                   fmt.Sprintf("127.0.0.1:%d", freePort(t)),
+		RPCListen:                   fmt.Sprintf("127.0.0.1:%d", freePort(t)),
+		MempoolSize:                 1000,
+		MinerTargetBlockTimeSeconds: 1,
+		NoWaitSync:                  true,
+		Dev:                         true,
+		CreateGenesis:               true,
+	}
+	qt.Assert(t, os.MkdirAll(filepath.Join(cfg.DataDir, "config"), 0o755), qt.IsNil)
+	qt.Assert(t, os.MkdirAll(filepath.Join(cfg.DataDir, "data"), 0o755), qt.IsNil)
+	validator := privval.GenFilePV(
+		filepath.Join(cfg.DataDir, "config", "priv_validator_key.json"),
+		filepath.Join(cfg.DataDir, "data", "priv_validator_state.json"),
+	)
+	cfg.MinerKey = fmt.Sprintf("%x", validator.Key.PrivKey)
+	tmParams := tmtypes.DefaultConsensusParams()
+	genesis, err := NewGenesis(cfg, oracle.VocdoniChainID, &ConsensusParams{
+		Block:     BlockParams(tmParams.Block),
+		Evidence:  EvidenceParams{MaxAgeNumBlocks: 1, MaxAgeDuration: 1},
+		Validator: ValidatorParams(tmParams.Validator),
+	}, []privval.FilePV{*validator}, []string{oracle.AddressString()}, oracle.AddressString())
+	qt.Assert(t, err, qt.IsNil)
+
+	app, err := NewBaseApplication(cfg.DBType, filepath.Join(cfg.DataDir, "data"))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, app.SetNode(cfg, genesis), qt.IsNil)
+	app.SetDefaultMethods()
+	qt.Assert(t, app.Node.Start(), qt.IsNil)
+	t.Cleanup(func() {
+		if err := app.Node.Stop(); err != nil {
+			t.Error(err)
+		}
+		app.Node.Wait()
+		app.State.Close()
+	})
+
+	// sendAdminTx signs the admin tx with the oracle key and sends it to the
+	// node mempool.
+	sendAdminTx := func(tx *models.AdminTx) {
+		stx := &models.SignedTx{}
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: tx}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = oracle.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		txBytes, err := proto.Marshal(stx)
+		qt.Assert(t, err, qt.IsNil)
+		resp, err := app.SendTx(txBytes)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Data))
+	}
+	// waitValidators waits until the consensus validator set has the
+	// expected powers, by validator address.
+	waitValidators := func(expected map[string]int64) {
+		t.Helper()
+		var current map[string]int64
+		for i := 0; i < 30; i++ {
+			_, validators := app.Node.ConsensusState().GetValidators()
+			current = make(map[string]int64, len(validators))
+			for _, v := range validators {
+				current[v.Address.String()] = v.VotingPower
+			}
+			if reflect.DeepEqual(current, expected) {
+				return
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+		t.Fatalf("validator set is %v, expected %v", current, expected)
+	}
+	power := func(p uint64) *uint64 { return &p }
+
+	pubKeyA, err := validator.GetPubKey()
+	qt.Assert(t, err, qt.IsNil)
+	addressA := pubKeyA.Address().String()
+	waitValidators(map[string]int64{addressA: 10})
+
+	// re-power the genesis validator
+	sendAdminTx(&models.AdminTx{
+		Txtype:    models.TxType_ADD_VALIDATOR,
+		PublicKey: pubKeyA.Bytes(),
+		Power:     power(20),
+	})
+	waitValidators(map[string]int64{addressA: 20})
+
+	// add a validator with a power low enough to keep the chain live
+	pubKeyB := ed25519.GenPrivKey().PubKey()
+	addressB := pubKeyB.Address().String()
+	sendAdminTx(&models.AdminTx{
+		Txtype:    models.TxType_ADD_VALIDATOR,
+		PublicKey: pubKeyB.Bytes(),
+		Power:     power(1),
+	})
+	waitValidators(map[string]int64{addressA: 20, addressB: 1})
+
+	// and remove it
+	sendAdminTx(&models.AdminTx{
+		Txtype:  models.TxType_REMOVE_VALIDATOR,
+		Address: pubKeyB.Address(),
+	})
+	waitValidators(map[string]int64{addressA: 20})
+}
+
+// freePort returns a free TCP port on the loopback interface.
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	qt.Assert(t, err, qt.IsNil)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -1,11 +1,13 @@
 package vochain
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -65,6 +67,9 @@ type BaseApplication struct {
 	chainId             string
 	// ZkVKs contains the VerificationKey for each circuit parameters index
 	ZkVKs []*snarkTypes.Vk
+	// snapshots manages the state snapshots used by state sync, it is nil
+	// if snapshots are not enabled.
+	snapshots *snapshotManager
 }
 
 var _ abcitypes.Application = (*BaseApplication)(nil)
@@ -346,8 +351,9 @@ func (app *BaseApplication) GetBlockByHeight(height int64) *tmtypes.Block {
 		return nil
 	}
 	cachedBlock := app.blockCache.GetAndUpdate(height, func(prev interface{}) interface{} {
-		if prev != nil {
+		if prev != nil && prev.(*tmtypes.Block) != nil {
 			// If it's already in the cache, use it as-is.
+			// The blocks not stored yet are retrieved again.
 			return prev
 		}
 		return app.fnGetBlockByHeight(height)
@@ -488,6 +494,22 @@ func (app *BaseApplication) InitChain(req abcitypes.RequestInitChain) abcitypes.
 		}
 	}
 
+	// set keykeepers threshold
+	if kk := genesisAppState.KeyKeepers; kk != nil {
+		log.Infof("setting genesis keykeepers threshold %d of %d", kk.Threshold, kk.Count)
+		if err := app.State.SetKeyKeepersThreshold(kk.Threshold, kk.Count); err != nil {
+			log.Fatalf("could not set keykeepers threshold from genesis file: %s", err)
+		}
+	}
+
+	// set oracle results quorum
+	if genesisAppState.OracleQuorum > 0 {
+		log.Infof("setting genesis oracle quorum %d", genesisAppState.OracleQuorum)
+		if err := app.State.SetOracleQuorum(genesisAppState.OracleQuorum); err != nil {
+			log.Fatalf("could not set oracle quorum from genesis file: %s", err)
+		}
+	}
+
 	// create burn account
 	if err := app.State.SetAccount(BurnAddress, &Account{}); err != nil {
 		log.Fatal("unable to set burn address")
@@ -512,6 +534,9 @@ func (app *BaseApplication) BeginBlock(
 	atomic.StoreInt64(&app.startBlockTimestamp, req.Header.GetTime().Unix())
 	height := uint32(req.Header.GetHeight())
 	app.State.SetHeight(height)
+	if err := app.State.ScheduleProcesses(req.Header.GetTime()); err != nil {
+		log.Fatalf("cannot schedule timed processes: %v", err)
+	}
 	go app.State.CachePurge(height)
 
 	return abcitypes.ResponseBeginBlock{}
@@ -576,7 +601,7 @@ func (app *BaseApplication) DeliverTx(req abcitypes.RequestDeliverTx) abcitypes.
 			return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
 		}
 		for _, e := range app.State.eventListeners {
-			e.OnNewTx(tmtypes.Tx(req.Tx).Hash(), app.Height()+1, app.State.TxCounter())
+			e.OnNewTx(tx, app.Height()+1, app.State.TxCounter())
 		}
 	} else {
 		return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
@@ -590,46 +615,76 @@ func (app *BaseApplication) Commit() abcitypes.ResponseCommit {
 	if err != nil {
 		log.Fatalf("cannot save state: %s", err)
 	}
+	if height := app.State.CurrentHeight(); app.snapshots != nil && app.snapshots.mustSnapshot(height) {
+		if err := app.snapshots.create(app.State, height); err != nil {
+			log.Errorf("cannot create state snapshot at height %d: %v", height, err)
+		}
+	}
 	return abcitypes.ResponseCommit{
 		Data: data,
 	}
 }
 
-// Query does nothing
-func (app *BaseApplication) Query(req abcitypes.RequestQuery) abcitypes.ResponseQuery {
-	return abcitypes.ResponseQuery{}
-}
-
-// EndBlock updates the app height and timestamp at the end of the current block
+// EndBlock updates the app height and timestamp at the end of the current block,
+// and returns the changes made to the validator set during the block so that
+// Tendermint applies them.
 func (app *BaseApplication) EndBlock(req abcitypes.RequestEndBlock) abcitypes.ResponseEndBlock {
-	app.endBlock(req.Height, time.Now())
-	return abcitypes.ResponseEndBlock{}
+	return app.endBlock(req.Height, time.Now())
 }
 
 func (app *BaseApplication) endBlock(height int64, timestamp time.Time) abcitypes.ResponseEndBlock {
 	atomic.StoreUint32(&app.height, uint32(height))
 	atomic.StoreInt64(&app.endBlockTimestamp, timestamp.Unix())
-	return abcitypes.ResponseEndBlock{}
-}
-
-func (app *BaseApplication) ApplySnapshotChunk(
-	req abcitypes.RequestApplySnapshotChunk) abcitypes.ResponseApplySnapshotChunk {
-	return abcitypes.ResponseApplySnapshotChunk{}
-}
-
-func (app *BaseApplication) ListSnapshots(
-	req abcitypes.RequestListSnapshots) abcitypes.ResponseListSnapshots {
-	return abcitypes.ResponseListSnapshots{}
-}
-
-func (app *BaseApplication) LoadSnapshotChunk(
-	req abcitypes.RequestLoadSnapshotChunk) abcitypes.ResponseLoadSnapshotChunk {
-	return abcitypes.ResponseLoadSnapshotChunk{}
+	updates, err := app.validatorUpdates()
+	if err != nil {
+		log.Fatalf("cannot get validator updates: %v", err)
+	}
+	for _, u := range updates {
+		log.Infof("validator update at height %d: %x with power %d",
+			height, u.PubKey.GetEd25519(), u.Power)
+	}
+	return abcitypes.ResponseEndBlock{ValidatorUpdates: updates}
 }
 
-func (app *BaseApplication) OfferSnapshot(
-	req abcitypes.RequestOfferSnapshot) abcitypes.ResponseOfferSnapshot {
-	return abcitypes.ResponseOfferSnapshot{}
+// validatorUpdates returns the validators added, removed or whose power has
+// changed in the current block, compared to the last committed state, sorted
+// by address.  Removed validators have power 0.
+func (app *BaseApplication) validatorUpdates() ([]abcitypes.ValidatorUpdate, error) {
+	committed, err := app.State.Validators(true)
+	if err != nil {
+		return nil, err
+	}
+	current, err := app.State.Validators(false)
+	if err != nil {
+		return nil, err
+	}
+	// Start with all the committed validators removed, and then undo the
+	// removal of the ones that are left untouched.
+	changes := make(map[string]*models.Validator)
+	previous := make(map[string]*models.Validator)
+	for _, v := range committed {
+		changes[string(v.Address)] = &models.Validator{Address: v.Address, PubKey: v.PubKey}
+		previous[string(v.Address)] = v
+	}
+	for _, v := range current {
+		if old, ok := previous[string(v.Address)]; ok &&
+			bytes.Equal(old.PubKey, v.PubKey) && old.Power == v.Power {
+			delete(changes, string(v.Address))
+			continue
+		}
+		changes[string(v.Address)] = v
+	}
+	addrs := make([]string, 0, len(changes))
+	for addr := range changes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	updates := make([]abcitypes.ValidatorUpdate, 0, len(addrs))
+	for _, addr := range addrs {
+		v := changes[addr]
+		updates = append(updates, abcitypes.Ed25519ValidatorUpdate(v.PubKey, int64(v.Power)))
+	}
+	return updates, nil
 }
 
 // SetFnGetBlockByHash sets the getter for blocks by hash
@@ -2,6 +2,7 @@ package vochain
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -48,6 +49,20 @@ func GenerateNullifier(address ethcommon.Address, processID []byte) []byte {
 	return ethereum.HashRaw(nullifier.Bytes())
 }
 
+// GenerateSerialNullifier generates the nullifier of a vote for the question
+// questionIndex of a serial process (hash(address+processId+questionIndex)),
+// so that each question can be voted once.  The questionIndex is encoded as
+// big-endian uint32.
+func GenerateSerialNullifier(address ethcommon.Address, processID []byte, questionIndex uint32) []byte {
+	nullifier := bytes.Buffer{}
+	nullifier.Write(address.Bytes())
+	nullifier.Write(processID)
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, questionIndex)
+	nullifier.Write(index)
+	return ethereum.HashRaw(nullifier.Bytes())
+}
+
 /* TENDERMINT 0.35
 // NewPrivateValidator returns a tendermint file private validator (key and state)
 // if tmPrivKey not specified, uses the existing one or generates a new one
@@ -183,7 +198,9 @@ func NewGenesis(cfg *config.VochainCfg, chainID string, consensusParams *Consens
 	}
 	appState.Oracles = oracles
 	appState.Treasurer = treasurer
-	appStateBytes, err := tmjson.Marshal(appState)
+	// The app state is decoded with encoding/json by InitChain, which
+	// expects the tx costs as numbers rather than amino-flavored strings.
+	appStateBytes, err := json.Marshal(appState)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -10,9 +10,11 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vocdoni/arbo"
 	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/statedb"
 	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
 )
 
 // keyCensusLen is the census.NoState key used to store the census size.
@@ -27,8 +29,19 @@ var keyCensusLen = []byte("len")
 // 	return []byte(path.Join(pathCensusKeyIndex, string(key)))
 // }
 
+// rollingCensusCfg returns the rolling census subTree configuration of a
+// pre-register process.  Anonymous processes use a Poseidon census indexed by
+// registration order, while non-anonymous processes use a sha256 census
+// indexed by the voter address.
+func rollingCensusCfg(p *models.Process) *statedb.TreeNonSingletonConfig {
+	if p.EnvelopeType != nil && p.EnvelopeType.Anonymous {
+		return CensusPoseidonCfg
+	}
+	return CensusCfg
+}
+
 // AddToRollingCensus adds a new key to an existing rolling census.
-// NOTE: weight value is not used.
+// NOTE: weight value is only used on non-anonymous rolling census.
 func (v *State) AddToRollingCensus(pid []byte, key []byte, weight *big.Int) error {
 	v.Tx.Lock()
 	defer v.Tx.Unlock()
@@ -36,7 +49,7 @@ func (v *State) AddToRollingCensus(pid []byte, key []byte, weight *big.Int) erro
 	if err != nil {
 		return fmt.Errorf("cannot open process with pid %x: %w", pid, err)
 	}
-	census, err := v.Tx.DeepSubTree(ProcessesCfg, CensusPoseidonCfg.WithKey(pid))
+	census, err := v.Tx.DeepSubTree(ProcessesCfg, rollingCensusCfg(process).WithKey(pid))
 	if err != nil {
 		return fmt.Errorf("cannot open rolling census with pid %x: %w", pid, err)
 	}
@@ -50,13 +63,21 @@ func (v *State) AddToRollingCensus(pid []byte, key []byte, weight *big.Int) erro
 	if censusLen >= *process.MaxCensusSize {
 		return fmt.Errorf("maxCensusSize already reached")
 	}
-	// Add key to census
-	index := [8]byte{}
-	binary.LittleEndian.PutUint64(index[:], censusLen)
-	if err := census.Add(index[:], key); err != nil {
-		return fmt.Errorf("cannot add (%x) to rolling census: %w", key, err)
+	if process.EnvelopeType.Anonymous {
+		// Add key to census
+		index := [8]byte{}
+		binary.LittleEndian.PutUint64(index[:], censusLen)
+		if err := census.Add(index[:], key); err != nil {
+			return fmt.Errorf("cannot add (%x) to rolling census: %w", key, err)
+		}
+		log.Debugf("added key %x with index %d to rolling census", key, censusLen)
+	} else {
+		// Add key to census with its weight as value
+		if err := census.Add(key, weight.Bytes()); err != nil {
+			return fmt.Errorf("cannot add (%x) to rolling census: %w", key, err)
+		}
+		log.Debugf("added key %x with weight %s to rolling census", key, weight)
 	}
-	log.Debugf("added key %x with index %d to rolling census", key, censusLen)
 	// // Store mapping between key -> key index
 	// if err := noState.Set(keyCensusKeyIndex(key), censusLenLE); err != nil {
 	// 	return err
@@ -68,8 +89,9 @@ func (v *State) AddToRollingCensus(pid []byte, key []byte, weight *big.Int) erro
 	return nil
 }
 
-func getRollingCensusSize(mainTreeView statedb.TreeViewer, pid []byte) (uint64, error) {
-	census, err := mainTreeView.DeepSubTree(ProcessesCfg, CensusPoseidonCfg.WithKey(pid))
+func getRollingCensusSize(mainTreeView statedb.TreeViewer, process *models.Process) (uint64, error) {
+	pid := process.ProcessId
+	census, err := mainTreeView.DeepSubTree(ProcessesCfg, rollingCensusCfg(process).WithKey(pid))
 	if err != nil {
 		return 0, fmt.Errorf("cannot open rolling census with pid %x: %w", pid, err)
 	}
@@ -86,13 +108,174 @@ func (v *State) GetRollingCensusSize(pid []byte, committed bool) (uint64, error)
 		v.Tx.RLock()
 		defer v.Tx.RUnlock()
 	}
-	return getRollingCensusSize(v.mainTreeViewer(committed), pid)
+	mainTreeView := v.mainTreeViewer(committed)
+	process, err := getProcess(mainTreeView, pid)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open process with pid %x: %w", pid, err)
+	}
+	return getRollingCensusSize(mainTreeView, process)
+}
+
+// RollingCensusProof returns the value (the weight) of key in the
+// non-anonymous rolling census of a process, and the merkle proof of its
+// inclusion, that can be verified against the process RollingCensusRoot.
+func (v *State) RollingCensusProof(pid, key []byte, committed bool) ([]byte, []byte, error) {
+	if !committed {
+		v.Tx.RLock()
+		defer v.Tx.RUnlock()
+	}
+	census, err := v.mainTreeViewer(committed).DeepSubTree(ProcessesCfg, CensusCfg.WithKey(pid))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open rolling census with pid %x: %w", pid, err)
+	}
+	return census.GenProof(key)
 }
 
-// PurgeRollingCensus removes a rolling census from the permanent store
-// If the census does not exist, it does nothing.
-func (s *State) PurgeRollingCensus(pid []byte) error {
-	return fmt.Errorf("TODO")
+// keyRollingCensusPurgeQueue is the NoState key used to store the ProcessIDs
+// whose rolling census is pending to be purged, in order.
+var keyRollingCensusPurgeQueue = []byte("rollingCensusPurgeQueue")
+
+// rollingCensusPurgeBatch is the maximum number of keys removed from the
+// purged rolling censuses on each block.
+var rollingCensusPurgeBatch = 1000
+
+// PurgeRollingCensus schedules the removal of all the keys of the rolling
+// census of a process, and the pre-register nullifiers.  The process
+// RollingCensusRoot is first copied to its FinalRollingCensusRoot, since the
+// roots stored in the process become empty once purged.  The keys are removed
+// by purgeRollingCensuses, at most rollingCensusPurgeBatch on each block.  If
+// the process has no rolling census, it does nothing.
+func (v *State) PurgeRollingCensus(pid []byte) error {
+	v.Tx.Lock()
+	defer v.Tx.Unlock()
+	return v.purgeRollingCensus(pid)
+}
+
+// purgeRollingCensus implements PurgeRollingCensus.  v.Tx must be locked.
+func (v *State) purgeRollingCensus(pid []byte) error {
+	process, err := getProcess(v.mainTreeViewer(false), pid)
+	if err != nil {
+		return fmt.Errorf("cannot open process with pid %x: %w", pid, err)
+	}
+	if process.Mode == nil || !process.Mode.PreRegister || process.FinalRollingCensusRoot != nil {
+		return nil
+	}
+	process.FinalRollingCensusRoot = process.RollingCensusRoot
+	if err := updateProcess(&v.Tx, process, pid); err != nil {
+		return err
+	}
+	queue, err := v.rollingCensusPurgeQueue()
+	if err != nil {
+		return err
+	}
+	return v.setRollingCensusPurgeQueue(append(queue, pid))
+}
+
+// rollingCensusPurgeQueue returns the ProcessIDs whose rolling census is
+// pending to be purged.
+func (v *State) rollingCensusPurgeQueue() ([][]byte, error) {
+	pidsBytes, err := v.Tx.NoState().Get(keyRollingCensusPurgeQueue)
+	if errors.Is(err, db.ErrKeyNotFound) {
+		return [][]byte{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pids models.ProcessIdList
+	if err := proto.Unmarshal(pidsBytes, &pids); err != nil {
+		return nil, fmt.Errorf("cannot proto.Unmarshal pids: %w", err)
+	}
+	return pids.ProcessIds, nil
+}
+
+// setRollingCensusPurgeQueue stores the ProcessIDs whose rolling census is
+// pending to be purged.
+func (v *State) setRollingCensusPurgeQueue(pids [][]byte) error {
+	pidsBytes, err := proto.Marshal(&models.ProcessIdList{ProcessIds: pids})
+	if err != nil {
+		return err
+	}
+	return v.Tx.NoState().Set(keyRollingCensusPurgeQueue, pidsBytes)
+}
+
+// purgeRollingCensuses removes at most rollingCensusPurgeBatch keys from the
+// rolling censuses and pre-register nullifiers of the processes scheduled by
+// PurgeRollingCensus, in order, and unschedules the processes that are fully
+// purged.  v.Tx must be locked.
+func (v *State) purgeRollingCensuses() error {
+	queue, err := v.rollingCensusPurgeQueue()
+	if err != nil || len(queue) == 0 {
+		return err
+	}
+	left := rollingCensusPurgeBatch
+	purged := 0
+	for _, pid := range queue {
+		if left == 0 {
+			break
+		}
+		process, err := getProcess(v.mainTreeViewer(false), pid)
+		if err != nil {
+			return fmt.Errorf("cannot open process with pid %x: %w", pid, err)
+		}
+		censusCfg := rollingCensusCfg(process).WithKey(pid)
+		done := true
+		for _, cfg := range []statedb.TreeConfig{censusCfg, PreRegisterNullifiersCfg.WithKey(pid)} {
+			tree, err := v.Tx.DeepSubTree(ProcessesCfg, cfg)
+			if err != nil {
+				return fmt.Errorf("cannot open %s tree of process %x: %w", cfg.KindID(), pid, err)
+			}
+			keys, err := firstKeys(tree, left+1)
+			if err != nil {
+				return err
+			}
+			if len(keys) > left {
+				keys, done = keys[:left], false
+			}
+			for _, key := range keys {
+				if err := tree.Del(key); err != nil {
+					return fmt.Errorf("cannot delete %x from %s tree of process %x: %w",
+						key, cfg.KindID(), pid, err)
+				}
+			}
+			left -= len(keys)
+			if !done {
+				break
+			}
+			if cfg.KindID() == censusCfg.KindID() {
+				if err := statedb.SetUint64(tree.NoState(), keyCensusLen, 0); err != nil {
+					return err
+				}
+			}
+		}
+		if !done {
+			break
+		}
+		purged++
+		log.Debugf("purged rolling census of process %x", pid)
+	}
+	if purged == 0 {
+		return nil
+	}
+	return v.setRollingCensusPurgeQueue(queue[purged:])
+}
+
+// firstKeys returns at most n keys of the leafs of tree, without visiting the
+// rest of the tree.
+func firstKeys(tree *statedb.TreeUpdate, n int) ([][]byte, error) {
+	keys := [][]byte{}
+	if err := tree.IterateNodes(func(_, value []byte) bool {
+		if len(keys) >= n {
+			// stop descending into the remaining branches
+			return true
+		}
+		if value[0] == arbo.PrefixValueLeaf {
+			key, _ := arbo.ReadLeafValue(value)
+			keys = append(keys, append([]byte{}, key...))
+		}
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	return keys, nil
 }
 
 // GetRollingCensusRoot returns the last rolling census root for a process id
@@ -171,9 +354,8 @@ func (v *State) RegisterKeyTxCheck(vtx *models.Tx, txBytes, signature []byte, st
 	if state.CurrentHeight() >= process.StartBlock {
 		return fmt.Errorf("process %x already started", tx.ProcessId)
 	}
-	if !(process.Mode.PreRegister && process.EnvelopeType.Anonymous) {
-		return fmt.Errorf("RegisterKeyTx only supported with " +
-			"Mode.PreRegister and EnvelopeType.Anonymous")
+	if !process.Mode.PreRegister {
+		return fmt.Errorf("RegisterKeyTx only supported with Mode.PreRegister")
 	}
 	if process.Status != models.ProcessStatus_READY {
 		return fmt.Errorf("process %x not in READY state", tx.ProcessId)
@@ -184,8 +366,22 @@ func (v *State) RegisterKeyTxCheck(vtx *models.Tx, txBytes, signature []byte, st
 	if signature == nil {
 		return fmt.Errorf("signature missing on voteTx")
 	}
-	if len(tx.NewKey) != 32 {
-		return fmt.Errorf("newKey wrong size")
+	// On anonymous processes the new key is a BabyJubJub public key
+	// hash, otherwise it is the ethereum address that will sign the votes.
+	if process.EnvelopeType.Anonymous {
+		if len(tx.NewKey) != 32 {
+			return fmt.Errorf("newKey wrong size")
+		}
+	} else {
+		if len(tx.NewKey) != common.AddressLength {
+			return fmt.Errorf("newKey wrong size")
+		}
+		if _, err := v.Tx.DeepGet(tx.NewKey, ProcessesCfg,
+			CensusCfg.WithKey(tx.ProcessId)); err == nil {
+			return fmt.Errorf("newKey %x already registered", tx.NewKey)
+		} else if !errors.Is(err, arbo.ErrKeyNotFound) {
+			return fmt.Errorf("cannot check if newKey is registered: %w", err)
+		}
 	}
 	// Verify that we are not over maxCensusSize
 	censusSize, err := v.GetRollingCensusSize(tx.ProcessId, false)
@@ -239,9 +435,12 @@ func (v *State) RegisterKeyTxCheck(vtx *models.Tx, txBytes, signature []byte, st
 	// also add the weight as a public input in the circuit to verify it anonymously.
 	// The following check ensures that weight != 1 is not used, once the above is
 	// implemented we can remove it
-	if usedWeight.Cmp(bigOne) != 0 {
+	if process.EnvelopeType.Anonymous && usedWeight.Cmp(bigOne) != 0 {
 		return fmt.Errorf("weight != 1 is not yet supported, received %s", tx.Weight)
 	}
+	if txWeight.Sign() <= 0 {
+		return fmt.Errorf("weight must be positive, received %s", tx.Weight)
+	}
 
 	if usedWeight.Cmp(weight) > 0 {
 		return fmt.Errorf("cannot register more keys: "+
@@ -274,3 +473,71 @@ func (v *State) GetPreRegisterAddrUsedWeight(pid []byte, addr common.Address) (*
 	}
 	return new(big.Int).SetBytes(weightBytes), nil
 }
+
+// getAccountsRoot returns the root of the Accounts subTree.
+func getAccountsRoot(mainTreeView statedb.TreeViewer) ([]byte, error) {
+	accounts, err := mainTreeView.SubTree(AccountsCfg)
+	if err != nil {
+		return nil, err
+	}
+	return accounts.Root()
+}
+
+// setAccountsCensusRoot sets the census root of the processes of pids with
+// census origin VOCHAIN_TOKENS to the current Accounts tree root, which is the
+// census snapshot of the processes that start in the next block.
+func (v *State) setAccountsCensusRoot(pids [][]byte) error {
+	mainTreeView := v.mainTreeViewer(false)
+	var accountsRoot []byte
+	for _, pid := range pids {
+		process, err := getProcess(mainTreeView, pid)
+		if err != nil {
+			return err
+		}
+		if process.CensusOrigin != models.CensusOrigin_VOCHAIN_TOKENS {
+			continue
+		}
+		if accountsRoot == nil {
+			if accountsRoot, err = getAccountsRoot(mainTreeView); err != nil {
+				return fmt.Errorf("cannot get accounts root: %w", err)
+			}
+		}
+		process.CensusRoot = accountsRoot
+		if err := updateProcess(&v.Tx, process, pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AccountsCensusProof returns the marshaled Account of address and the
+// siblings of its proof in the accounts census of the process pid, whose
+// census origin must be VOCHAIN_TOKENS.  The proof is generated from the state
+// committed in the block before the process start block, so it can only be
+// obtained once the process has started.  That state version is kept by the
+// pruning while the process accepts votes (see accountsCensusVersions).
+func (v *State) AccountsCensusProof(pid []byte, address common.Address) ([]byte, []byte, error) {
+	process, err := v.Process(pid, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if process.CensusOrigin != models.CensusOrigin_VOCHAIN_TOKENS {
+		return nil, nil, fmt.Errorf("process %x census origin is not vochain tokens", pid)
+	}
+	if len(process.CensusRoot) == 0 {
+		return nil, nil, fmt.Errorf("process %x has not started yet", pid)
+	}
+	root, err := v.Store.VersionRoot(process.StartBlock - 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("state at height %d not found: %w", process.StartBlock-1, err)
+	}
+	mainTreeView, err := v.Store.TreeView(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	accounts, err := mainTreeView.SubTree(AccountsCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return accounts.GenProof(address.Bytes())
+}
@@ -80,14 +80,18 @@ func (c *CensusDownloader) OnProcess(pid, eid []byte, censusRoot, censusURI stri
 }
 
 // NOT USED but required for implementing the vochain.EventListener interface
-func (c *CensusDownloader) OnCancel(pid []byte, txindex int32)                     {}
-func (c *CensusDownloader) OnVote(v *models.Vote, txindex int32)                   {}
-func (c *CensusDownloader) OnNewTx(hash []byte, blockHeight uint32, txIndex int32) {}
-func (c *CensusDownloader) OnProcessKeys(pid []byte, pub string, txindex int32)    {}
-func (c *CensusDownloader) OnRevealKeys(pid []byte, priv string, txindex int32)    {}
+func (c *CensusDownloader) OnCancel(pid []byte, txindex int32)                               {}
+func (c *CensusDownloader) OnVote(v *models.Vote, txindex int32)                             {}
+func (c *CensusDownloader) OnNewTx(tx *vochain.VochainTx, blockHeight uint32, txIndex int32) {}
+func (c *CensusDownloader) OnProcessKeys(pid []byte, pub string, txindex int32)              {}
+func (c *CensusDownloader) OnRevealKeys(pid []byte, priv string, txindex int32)              {}
 func (c *CensusDownloader) OnProcessStatusChange(pid []byte,
 	status models.ProcessStatus, txindex int32) {
 }
+func (c *CensusDownloader) OnProcessQuestionIndex(pid []byte,
+	questionIndex uint32, txindex int32) {
+}
+func (c *CensusDownloader) OnProcessDurationChange(pid []byte, blockCount uint32) {}
 
 func (c *CensusDownloader) OnProcessResults(pid []byte,
 	results *models.ProcessResult, txindex int32) error {
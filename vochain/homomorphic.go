@@ -0,0 +1,423 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/crypto/threshold"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/statedb"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// Homomorphic tally
+//
+// Processes with the homomorphic tally flag on their envelope type are voted
+// with ballots encrypted with the threshold shared key, which are never
+// decrypted one by one:
+//
+//  1. The vote package is a threshold.EncryptBallot ballot with one choice
+//     for each of the MaxCount questions out of MaxValue+1 options, whose
+//     proofs are bound to the process and voter with HomomorphicBallotContext
+//     and verified on the vote transaction.
+//  2. Each vote adds its ballot ciphertexts, multiplied by the vote weight,
+//     to the encrypted tally of the process.  The total weight of the votes
+//     is capped to MaxHomomorphicTallyWeight, so the tally can always be
+//     decrypted.
+//  3. Once the process finishes, each keykeeper publishes the partial
+//     decryption of the encrypted tally with its share, along with the proofs
+//     of correct decryption, with a REVEAL_PROCESS_KEYS transaction
+//     (EncryptionPrivateKeys[index]).  The share itself is never revealed.
+//  4. Once threshold keykeepers have revealed their partial decryptions,
+//     the results are the decrypted tally, and the SET_PROCESS_RESULTS
+//     transactions are verified against it.
+
+// MaxHomomorphicOptions is the maximum number of encrypted values of a
+// homomorphic tally ballot, that is MaxCount*(MaxValue+1).
+const MaxHomomorphicOptions = 64
+
+// MaxHomomorphicTallyWeight is the maximum total weight of the votes of a
+// homomorphic tally process.  Decrypting each tally value requires solving a
+// discrete logarithm up to the total weight, whose cost grows with its square
+// root.
+const MaxHomomorphicTallyWeight = 1 << 32
+
+var (
+	// keyTally is the process votes NoState key used to store the encrypted
+	// tally of a homomorphic tally process.
+	keyTally = []byte("tally")
+	// keyTallyWeight is the process votes NoState key used to store the
+	// total weight of the votes added to the encrypted tally.
+	keyTallyWeight = []byte("tallyWeight")
+)
+
+// homomorphicBallotSize returns the number of questions and options of the
+// ballots of a homomorphic tally process.
+func homomorphicBallotSize(process *models.Process) (questions, options int) {
+	return int(process.VoteOptions.GetMaxCount()), int(process.VoteOptions.GetMaxValue()) + 1
+}
+
+// checkHomomorphicProcess checks that a new process can use the homomorphic
+// tally.
+func checkHomomorphicProcess(process *models.Process, state *State) error {
+	et := process.EnvelopeType
+	if !et.EncryptedVotes {
+		return fmt.Errorf("homomorphic tally requires encrypted votes")
+	}
+	if et.Anonymous || et.Serial || et.UniqueValues || et.CostFromWeight {
+		return fmt.Errorf("homomorphic tally not supported with anonymous, serial, " +
+			"unique values or cost from weight envelope types")
+	}
+	// the encrypted tally cannot subtract an overwritten ballot
+	if process.GetVoteOptions().GetMaxVoteOverwrites() > 0 {
+		return fmt.Errorf("homomorphic tally not supported with vote overwrites")
+	}
+	_, count, err := state.KeyKeepersThreshold(false)
+	if err != nil {
+		return fmt.Errorf("cannot get keykeepers threshold: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("homomorphic tally requires threshold encryption keys")
+	}
+	if questions, options := homomorphicBallotSize(process); questions*options > MaxHomomorphicOptions {
+		return fmt.Errorf("homomorphic tally supports up to %d encrypted values per ballot, got %d",
+			MaxHomomorphicOptions, questions*options)
+	}
+	// the votes of a census of MaxCensusSize voters of weight 1 must fit in
+	// the tally
+	if process.GetMaxCensusSize() == 0 || process.GetMaxCensusSize() > MaxHomomorphicTallyWeight {
+		return fmt.Errorf("homomorphic tally requires a max census size from 1 to %d",
+			uint64(MaxHomomorphicTallyWeight))
+	}
+	return nil
+}
+
+// HomomorphicBallotContext returns the context which the proofs of a
+// homomorphic tally ballot are bound to, given the process and the voter
+// address, so a ballot cannot be replayed by another voter or on another
+// process.  On vochain tokens census processes the voter is the account.
+func HomomorphicBallotContext(processID []byte, voter common.Address) []byte {
+	return append(append([]byte{}, processID...), voter.Bytes()...)
+}
+
+// checkHomomorphicVote verifies the encrypted ballot of a homomorphic tally
+// process vote package cast by voter, and that its weight fits in the tally.
+func checkHomomorphicVote(state *State, process *models.Process, votePackage []byte,
+	voter common.Address, weight *big.Int) error {
+	key, err := hex.DecodeString(process.EncryptionPublicKeys[ThresholdKeyIndex])
+	if err != nil {
+		return fmt.Errorf("cannot decode threshold key: %w", err)
+	}
+	pubKey, err := threshold.DecodePoint(key)
+	if err != nil {
+		return fmt.Errorf("cannot decode threshold key: %w", err)
+	}
+	questions, options := homomorphicBallotSize(process)
+	if _, err := threshold.VerifyBallot(votePackage, questions, options, pubKey,
+		HomomorphicBallotContext(process.ProcessId, voter)); err != nil {
+		return fmt.Errorf("invalid encrypted ballot: %w", err)
+	}
+	total, err := state.EncryptedTallyWeight(process, false)
+	if err != nil {
+		return fmt.Errorf("cannot get tally weight: %w", err)
+	}
+	if total.Add(total, weight).Cmp(big.NewInt(MaxHomomorphicTallyWeight)) > 0 {
+		return fmt.Errorf("the vote weight exceeds the homomorphic tally maximum weight %d",
+			uint64(MaxHomomorphicTallyWeight))
+	}
+	return nil
+}
+
+// EncryptedTally returns the encrypted tally of a homomorphic tally process,
+// indexed by question and option, and the total weight of its votes.
+// When committed is false, the operation is executed also on not yet commited
+// data from the currently open StateDB transaction.
+// When committed is true, the operation is executed on the last commited version.
+func (v *State) EncryptedTally(process *models.Process,
+	committed bool) ([][]*threshold.Ciphertext, *big.Int, error) {
+	if !committed {
+		v.Tx.RLock()
+		defer v.Tx.RUnlock()
+	}
+	votes, err := v.mainTreeViewer(committed).DeepSubTree(
+		ProcessesCfg, VotesCfg.WithKey(process.ProcessId))
+	if err != nil {
+		return nil, nil, err
+	}
+	return getEncryptedTally(votes.NoState(), process)
+}
+
+// EncryptedTallyWeight returns the total weight of the votes added to the
+// encrypted tally of a homomorphic tally process.
+// When committed is false, the operation is executed also on not yet commited
+// data from the currently open StateDB transaction.
+// When committed is true, the operation is executed on the last commited version.
+func (v *State) EncryptedTallyWeight(process *models.Process, committed bool) (*big.Int, error) {
+	if !committed {
+		v.Tx.RLock()
+		defer v.Tx.RUnlock()
+	}
+	votes, err := v.mainTreeViewer(committed).DeepSubTree(
+		ProcessesCfg, VotesCfg.WithKey(process.ProcessId))
+	if err != nil {
+		return nil, err
+	}
+	weight, err := votes.NoState().Get(keyTallyWeight)
+	if errors.Is(err, db.ErrKeyNotFound) {
+		return new(big.Int), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(weight), nil
+}
+
+func getEncryptedTally(noState statedb.Viewer, process *models.Process) ([][]*threshold.Ciphertext, *big.Int, error) {
+	questions, options := homomorphicBallotSize(process)
+	tally := make([][]*threshold.Ciphertext, questions)
+	data, err := noState.Get(keyTally)
+	if errors.Is(err, db.ErrKeyNotFound) {
+		for q := range tally {
+			for o := 0; o < options; o++ {
+				tally[q] = append(tally[q], threshold.NewCiphertext())
+			}
+		}
+		return tally, new(big.Int), nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if len(data) != questions*options*threshold.CiphertextLength {
+		return nil, nil, fmt.Errorf("invalid encrypted tally length %d", len(data))
+	}
+	for q := range tally {
+		for o := 0; o < options; o++ {
+			ct, err := threshold.UnmarshalCiphertext(data[:threshold.CiphertextLength])
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid encrypted tally: %w", err)
+			}
+			tally[q] = append(tally[q], ct)
+			data = data[threshold.CiphertextLength:]
+		}
+	}
+	weight, err := noState.Get(keyTallyWeight)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get tally weight: %w", err)
+	}
+	return tally, new(big.Int).SetBytes(weight), nil
+}
+
+// addToEncryptedTally adds the ballot of a vote, multiplied by the vote
+// weight, to the encrypted tally of its process, if it is a homomorphic tally
+// process.  The ballot must have been verified with checkHomomorphicVote.
+// v.Tx must be locked.
+func (v *State) addToEncryptedTally(vote *models.Vote) error {
+	process, err := getProcess(v.mainTreeViewer(false), vote.ProcessId)
+	if err != nil {
+		return fmt.Errorf("cannot open process with pid %x: %w", vote.ProcessId, err)
+	}
+	if !process.EnvelopeType.GetHomomorphic() {
+		return nil
+	}
+	questions, options := homomorphicBallotSize(process)
+	ballot, err := threshold.UnmarshalBallot(vote.VotePackage, questions, options)
+	if err != nil {
+		return fmt.Errorf("invalid encrypted ballot: %w", err)
+	}
+	weight := big.NewInt(1)
+	if len(vote.Weight) > 0 {
+		weight.SetBytes(vote.Weight)
+	}
+	votes, err := v.Tx.DeepSubTree(ProcessesCfg, VotesCfg.WithKey(vote.ProcessId))
+	if err != nil {
+		return err
+	}
+	noState := votes.NoState()
+	tally, total, err := getEncryptedTally(noState, process)
+	if err != nil {
+		return err
+	}
+	// votes delivered from the cache were checked against an older tally
+	if total.Add(total, weight).Cmp(big.NewInt(MaxHomomorphicTallyWeight)) > 0 {
+		return fmt.Errorf("the vote weight exceeds the homomorphic tally maximum weight %d",
+			uint64(MaxHomomorphicTallyWeight))
+	}
+	var data []byte
+	for q := range tally {
+		for o := range tally[q] {
+			tally[q][o] = tally[q][o].Add(ballot[q][o].Mul(weight))
+			data = append(data, tally[q][o].Marshal()...)
+		}
+	}
+	if err := noState.Set(keyTally, data); err != nil {
+		return err
+	}
+	return noState.Set(keyTallyWeight, total.Bytes())
+}
+
+// TallyDecryptions returns the partial decryptions of the encrypted tally
+// revealed by the keykeepers 1 to count of a homomorphic tally process,
+// indexed by keykeeper index.
+func TallyDecryptions(privateKeys []string, count uint32) (map[int][]byte, error) {
+	decryptions := make(map[int][]byte)
+	for i := 1; i <= int(count) && i < len(privateKeys); i++ {
+		if privateKeys[i] == "" {
+			continue
+		}
+		b, err := hex.DecodeString(privateKeys[i])
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode tally decryption %d: %w", i, err)
+		}
+		decryptions[i] = b
+	}
+	return decryptions, nil
+}
+
+// NewTallyDecryption returns the partial decryption of the encrypted tally of
+// a homomorphic tally process with the share of a keykeeper, to be revealed
+// with a REVEAL_PROCESS_KEYS transaction.
+func NewTallyDecryption(tally [][]*threshold.Ciphertext, share *big.Int) ([]byte, error) {
+	var data []byte
+	for q := range tally {
+		for _, ct := range tally[q] {
+			d, err := threshold.NewDecryptionShare(ct, share)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, d...)
+		}
+	}
+	return data, nil
+}
+
+// checkTallyDecryption verifies the partial decryption of the encrypted
+// tally of a homomorphic tally process revealed by the keykeeper index.
+func checkTallyDecryption(state *State, process *models.Process,
+	dealings []*threshold.Dealing, index int, data []byte) error {
+	// the tally must not change once the decryption is revealed
+	if state.CurrentHeight() <= process.StartBlock+process.BlockCount &&
+		process.Status != models.ProcessStatus_ENDED &&
+		process.Status != models.ProcessStatus_CANCELED {
+		return fmt.Errorf("cannot reveal the tally decryption while the process accepts votes")
+	}
+	tally, _, err := state.EncryptedTally(process, false)
+	if err != nil {
+		return fmt.Errorf("cannot get encrypted tally: %w", err)
+	}
+	questions, options := homomorphicBallotSize(process)
+	if len(data) != questions*options*threshold.DecryptionShareLength {
+		return fmt.Errorf("wrong tally decryption length %d", len(data))
+	}
+	publicShare := threshold.ParticipantPublicShare(dealings, index)
+	for q := range tally {
+		for o, ct := range tally[q] {
+			if _, err := threshold.VerifyDecryptionShare(ct, publicShare,
+				data[:threshold.DecryptionShareLength]); err != nil {
+				return fmt.Errorf("question %d option %d: %w", q, o, err)
+			}
+			data = data[threshold.DecryptionShareLength:]
+		}
+	}
+	return nil
+}
+
+// tallyDecryption combines the partial decryptions of the encrypted tally of
+// a homomorphic tally process, returning each value m as the point m·G, and
+// the total weight of the votes.  The partial decryptions of at least
+// threshold keykeepers must have been revealed.
+func (v *State) tallyDecryption(process *models.Process,
+	committed bool) ([][]*babyjub.Point, *big.Int, error) {
+	required, count, err := v.KeyKeepersThreshold(committed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get keykeepers threshold: %w", err)
+	}
+	decryptions, err := TallyDecryptions(process.EncryptionPrivateKeys, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(decryptions) < int(required) {
+		return nil, nil, fmt.Errorf("tally decryption requires %d keykeepers, got %d",
+			required, len(decryptions))
+	}
+	tally, weight, err := v.EncryptedTally(process, committed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get encrypted tally: %w", err)
+	}
+	values := make([][]*babyjub.Point, len(tally))
+	for q := range tally {
+		for o, ct := range tally[q] {
+			shares := make(map[int]*babyjub.Point)
+			offset := (q*len(tally[q]) + o) * threshold.DecryptionShareLength
+			for i, d := range decryptions {
+				// the decryptions were verified when revealed
+				if shares[i], err = threshold.DecodePoint(
+					d[offset : offset+threshold.PointLength]); err != nil {
+					return nil, nil, fmt.Errorf("invalid tally decryption %d: %w", i, err)
+				}
+			}
+			m, err := threshold.CombineDecryption(ct, shares)
+			if err != nil {
+				return nil, nil, err
+			}
+			values[q] = append(values[q], m)
+		}
+	}
+	return values, weight, nil
+}
+
+// HomomorphicResults returns the decrypted tally of a homomorphic tally
+// process, indexed by question and option.
+// When committed is false, the operation is executed also on not yet commited
+// data from the currently open StateDB transaction.
+// When committed is true, the operation is executed on the last commited version.
+func (v *State) HomomorphicResults(process *models.Process, committed bool) ([][]*big.Int, error) {
+	values, weight, err := v.tallyDecryption(process, committed)
+	if err != nil {
+		return nil, err
+	}
+	if weight.Cmp(big.NewInt(MaxHomomorphicTallyWeight)) > 0 {
+		return nil, fmt.Errorf("tally weight %s too big to be decrypted", weight)
+	}
+	// every value is at most the total weight
+	table := threshold.NewDiscreteLogTable(weight.Uint64())
+	results := make([][]*big.Int, len(values))
+	for q := range values {
+		for o, p := range values[q] {
+			m, err := table.Solve(p)
+			if err != nil {
+				return nil, fmt.Errorf("question %d option %d: %w", q, o, err)
+			}
+			results[q] = append(results[q], new(big.Int).SetUint64(m))
+		}
+	}
+	return results, nil
+}
+
+// checkHomomorphicResults checks that the results of a homomorphic tally
+// process match the decryption of its encrypted tally.
+func checkHomomorphicResults(state *State, process *models.Process,
+	result *models.ProcessResult) error {
+	values, _, err := state.tallyDecryption(process, false)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt tally: %w", err)
+	}
+	if len(result.Votes) != len(values) {
+		return fmt.Errorf("results must have %d questions, got %d", len(values), len(result.Votes))
+	}
+	for q := range values {
+		if len(result.Votes[q].GetQuestion()) != len(values[q]) {
+			return fmt.Errorf("results question %d must have %d options", q, len(values[q]))
+		}
+		for o, p := range values[q] {
+			value := new(big.Int).SetBytes(result.Votes[q].Question[o])
+			if !bytes.Equal(threshold.EncodePoint(threshold.ValuePoint(value)),
+				threshold.EncodePoint(p)) {
+				return fmt.Errorf("results question %d option %d do not match the tally", q, o)
+			}
+		}
+	}
+	return nil
+}
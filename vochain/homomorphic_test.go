@@ -0,0 +1,209 @@
+package vochain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/crypto"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/threshold"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHomomorphicTally(t *testing.T) {
+	app := TestBaseApplication(t)
+	const required, count = 2, 3
+	const questions, options = 2, 3
+
+	envelope := &models.EnvelopeType{EncryptedVotes: true}
+	envelope.Homomorphic = true
+	pid := util.RandomBytes(types.ProcessIDsize)
+	censusURI := ipfsUrl
+	censusSize := uint64(10)
+	process := &models.Process{
+		ProcessId:             pid,
+		StartBlock:            10,
+		BlockCount:            10,
+		EnvelopeType:          envelope,
+		Mode:                  &models.ProcessMode{Interruptible: true},
+		VoteOptions:           &models.ProcessVoteOptions{MaxCount: questions, MaxValue: options - 1},
+		Status:                models.ProcessStatus_READY,
+		EntityId:              util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:            util.RandomBytes(32),
+		CensusURI:             &censusURI,
+		MaxCensusSize:         &censusSize,
+		CensusOrigin:          models.CensusOrigin_OFF_CHAIN_TREE,
+		EncryptionPublicKeys:  make([]string, types.KeyKeeperMaxKeyIndex),
+		EncryptionPrivateKeys: make([]string, types.KeyKeeperMaxKeyIndex),
+	}
+
+	// the homomorphic tally requires threshold keys and a single choice
+	qt.Assert(t, checkHomomorphicProcess(process, app.State), qt.Not(qt.IsNil))
+	qt.Assert(t, app.State.SetKeyKeepersThreshold(required, count), qt.IsNil)
+	qt.Assert(t, checkHomomorphicProcess(process, app.State), qt.IsNil)
+	process.EnvelopeType.UniqueValues = true
+	qt.Assert(t, checkHomomorphicProcess(process, app.State), qt.Not(qt.IsNil))
+	process.EnvelopeType.UniqueValues = false
+	process.MaxCensusSize = nil
+	qt.Assert(t, checkHomomorphicProcess(process, app.State), qt.Not(qt.IsNil))
+	process.MaxCensusSize = &censusSize
+	qt.Assert(t, app.State.AddProcess(process), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	keykeepers := util.CreateEthRandomKeysBatch(count)
+	transport := make([]crypto.Cipher, count)
+	transportKeys := make([][]byte, count)
+	for i, k := range keykeepers {
+		qt.Assert(t, app.State.AddOracle(k.Address()), qt.IsNil)
+		var err error
+		transport[i], err = nacl.Generate(rand.Reader)
+		qt.Assert(t, err, qt.IsNil)
+		transportKeys[i] = transport[i].Public().Bytes()
+	}
+	sendAdminTx := func(signer *ethereum.SignKeys, tx *models.AdminTx) error {
+		tx.ProcessId = pid
+		tx.Nonce = util.RandomBytes(32)
+		var stx models.SignedTx
+		var err error
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: tx}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return nil
+	}
+	sendKey := func(txType models.TxType, index int, key []byte) error {
+		kindex := uint32(index)
+		tx := &models.AdminTx{Txtype: txType, KeyIndex: &kindex}
+		if txType == models.TxType_ADD_PROCESS_KEYS {
+			tx.EncryptionPublicKey = key
+		} else {
+			tx.EncryptionPrivateKey = key
+		}
+		return sendAdminTx(keykeepers[index-1], tx)
+	}
+
+	// threshold keys setup
+	for i := 1; i <= count; i++ {
+		qt.Assert(t, sendKey(models.TxType_ADD_PROCESS_KEYS, i, transportKeys[i-1]), qt.IsNil)
+	}
+	app.AdvanceTestBlock()
+	for i := 1; i <= count; i++ {
+		d, err := threshold.NewDealing([]byte{byte(i)}, required, transportKeys[i-1], transportKeys)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, sendKey(models.TxType_ADD_PROCESS_KEYS, i, d.Marshal()), qt.IsNil)
+	}
+	app.AdvanceTestBlock()
+	process, err := app.State.Process(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	dealings, err := ThresholdDealings(process, count)
+	qt.Assert(t, err, qt.IsNil)
+	pubKey := threshold.SharedPublicKey(dealings)
+	qt.Assert(t, process.EncryptionPublicKeys[ThresholdKeyIndex], qt.Equals,
+		hex.EncodeToString(threshold.EncodePoint(pubKey)))
+
+	// weighted votes are added to the encrypted tally
+	for _, v := range []struct {
+		choices []int
+		weight  int64
+	}{
+		{[]int{0, 2}, 1},
+		{[]int{1, 2}, 3},
+		{[]int{0, 0}, 2},
+	} {
+		voter := ethereum.NewSignKeys()
+		qt.Assert(t, voter.Generate(), qt.IsNil)
+		ballot, err := threshold.EncryptBallot(v.choices, options, pubKey,
+			HomomorphicBallotContext(pid, voter.Address()))
+		qt.Assert(t, err, qt.IsNil)
+		weight := big.NewInt(v.weight)
+		qt.Assert(t, checkHomomorphicVote(app.State, process, ballot,
+			voter.Address(), weight), qt.IsNil)
+		// the ballot proofs are bound to the voter
+		qt.Assert(t, checkHomomorphicVote(app.State, process, ballot,
+			keykeepers[0].Address(), weight), qt.Not(qt.IsNil))
+		qt.Assert(t, app.State.AddVote(&models.Vote{
+			ProcessId:   pid,
+			Nullifier:   util.RandomBytes(32),
+			VotePackage: ballot,
+			Weight:      weight.Bytes(),
+		}), qt.IsNil)
+	}
+	voter := keykeepers[0].Address()
+	ballot, err := threshold.EncryptBallot([]int{1}, options, pubKey,
+		HomomorphicBallotContext(pid, voter))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, checkHomomorphicVote(app.State, process, ballot, voter, big.NewInt(1)),
+		qt.Not(qt.IsNil))
+	// the total weight of the votes is capped
+	ballot, err = threshold.EncryptBallot([]int{0, 1}, options, pubKey,
+		HomomorphicBallotContext(pid, voter))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, checkHomomorphicVote(app.State, process, ballot, voter,
+		big.NewInt(MaxHomomorphicTallyWeight-6)), qt.IsNil)
+	qt.Assert(t, checkHomomorphicVote(app.State, process, ballot, voter,
+		big.NewInt(MaxHomomorphicTallyWeight-5)), qt.Not(qt.IsNil))
+	app.AdvanceTestBlock()
+
+	tally, weight, err := app.State.EncryptedTally(process, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, weight.Int64(), qt.Equals, int64(6))
+	qt.Assert(t, tally, qt.HasLen, questions)
+
+	// keykeepers reveal the tally decryption, never their shares
+	shares := make([]*big.Int, count)
+	decryptions := make([][]byte, count)
+	for i := range shares {
+		shares[i], err = threshold.ParticipantShare(dealings, i+1, transport[i])
+		qt.Assert(t, err, qt.IsNil)
+		decryptions[i], err = NewTallyDecryption(tally, shares[i])
+		qt.Assert(t, err, qt.IsNil)
+	}
+	qt.Assert(t, sendKey(models.TxType_REVEAL_PROCESS_KEYS, 1, decryptions[0]), qt.Not(qt.IsNil))
+	qt.Assert(t, app.State.SetProcessStatus(pid, models.ProcessStatus_ENDED, true), qt.IsNil)
+	qt.Assert(t, sendKey(models.TxType_REVEAL_PROCESS_KEYS, 1,
+		threshold.EncodeScalar(shares[0])), qt.Not(qt.IsNil))
+	qt.Assert(t, sendKey(models.TxType_REVEAL_PROCESS_KEYS, 1, decryptions[1]), qt.Not(qt.IsNil))
+	qt.Assert(t, sendKey(models.TxType_REVEAL_PROCESS_KEYS, 1, decryptions[0]), qt.IsNil)
+	qt.Assert(t, sendKey(models.TxType_REVEAL_PROCESS_KEYS, 3, decryptions[2]), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	// the results are the decrypted tally
+	process, err = app.State.Process(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	results, err := app.State.HomomorphicResults(process, true)
+	qt.Assert(t, err, qt.IsNil)
+	expected := [][]int64{{3, 3, 0}, {2, 0, 4}}
+	result := &models.ProcessResult{
+		ProcessId:     pid,
+		EntityId:      process.EntityId,
+		OracleAddress: keykeepers[0].Address().Bytes(),
+	}
+	for q := range expected {
+		result.Votes = append(result.Votes, &models.QuestionResult{})
+		for o := range expected[q] {
+			qt.Assert(t, results[q][o].Int64(), qt.Equals, expected[q][o])
+			result.Votes[q].Question = append(result.Votes[q].Question,
+				big.NewInt(expected[q][o]).Bytes())
+		}
+	}
+	qt.Assert(t, app.State.SetProcessResults(pid, result, false), qt.IsNil)
+	result.Votes[0].Question[0] = big.NewInt(4).Bytes()
+	qt.Assert(t, app.State.SetProcessResults(pid, result, false), qt.Not(qt.IsNil))
+}
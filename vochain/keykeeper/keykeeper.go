@@ -8,6 +8,7 @@ import (
 
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/threshold"
 	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/db/badgerdb"
 	"go.vocdoni.io/dvote/log"
@@ -36,6 +37,8 @@ type KeyKeeper struct {
 	vochain   *vochain.BaseApplication
 	storage   db.Database
 	keyPool   map[string]*processKeys
+	dealPool  map[string]bool
+	checkPool map[string]bool
 	blockPool map[string]int64
 	signer    *ethereum.SignKeys
 	lock      sync.Mutex
@@ -183,6 +186,8 @@ func (k *KeyKeeper) Rollback() {
 	k.lock.Lock()
 	defer k.lock.Unlock()
 	k.keyPool = make(map[string]*processKeys)
+	k.dealPool = make(map[string]bool)
+	k.checkPool = make(map[string]bool)
 	k.blockPool = make(map[string]int64)
 }
 
@@ -196,6 +201,13 @@ func (k *KeyKeeper) OnProcess(pid, eid []byte, censusRoot, censusURI string, txi
 	if !(p.EnvelopeType.Anonymous || p.EnvelopeType.EncryptedVotes) {
 		return
 	}
+	// On threshold keys, only the keykeepers 1 to count take part
+	if _, count, err := k.vochain.State.KeyKeepersThreshold(false); err != nil {
+		log.Errorf("cannot get keykeepers threshold: (%s)", err)
+		return
+	} else if count > 0 && int(k.myIndex) > int(count) {
+		return
+	}
 	// If keys already exist, do nothing (this happens on the start-up block replay)
 	if len(p.EncryptionPublicKeys[k.myIndex]) > 0 {
 		return
@@ -213,8 +225,12 @@ func (k *KeyKeeper) OnProcess(pid, eid []byte, censusRoot, censusURI string, txi
 		return
 	}
 
-	// Add keys to the pool queue
+	// Add keys to the pool queue, the tally of homomorphic tally processes
+	// can still change on its last block
 	k.blockPool[string(pid)] = int64(p.StartBlock + p.BlockCount)
+	if p.EnvelopeType.GetHomomorphic() {
+		k.blockPool[string(pid)]++
+	}
 }
 
 // OnCancel will publish the private and reveal keys of the canceled process, if required
@@ -239,6 +255,8 @@ func (k *KeyKeeper) Commit(height uint32) error {
 	k.scheduleRevealKeys()
 	go k.checkRevealProcess(height)
 	go k.publishPendingKeys()
+	go k.publishPendingDealings()
+	go k.checkPendingDealings()
 	return nil
 }
 
@@ -248,7 +266,7 @@ func (k *KeyKeeper) OnVote(v *models.Vote, txindex int32) {
 }
 
 // OnNewTx is not used by the KeyKeeper
-func (k *KeyKeeper) OnNewTx(hash []byte, blockHeight uint32, txIndex int32) {
+func (k *KeyKeeper) OnNewTx(tx *vochain.VochainTx, blockHeight uint32, txIndex int32) {
 	// do nothing
 }
 
@@ -271,9 +289,37 @@ func (k *KeyKeeper) OnProcessStatusChange(pid []byte, status models.ProcessStatu
 	}
 }
 
-// OnProcessKeys does nothing
+// OnProcessKeys adds the process to the dealing pool queue if the process has
+// threshold keys and all the keykeepers transport keys have been published,
+// or to the check pool queue once all the dealings have been published
 func (k *KeyKeeper) OnProcessKeys(pid []byte, pub string, txindex int32) {
-	// do nothing
+	_, count, err := k.vochain.State.KeyKeepersThreshold(false)
+	if err != nil {
+		log.Errorf("cannot get keykeepers threshold: (%s)", err)
+		return
+	}
+	if count == 0 || int(k.myIndex) > int(count) {
+		return
+	}
+	p, err := k.vochain.State.Process(pid, false)
+	if err != nil {
+		log.Errorf("cannot get process from state: (%s)", err)
+		return
+	}
+	// if the dealing has not been published yet
+	if len(p.EncryptionPublicKeys[k.myIndex]) == 2*encryptionKeySize {
+		if keys, err := vochain.ThresholdTransportKeys(p, count); err != nil {
+			log.Errorf("cannot get transport keys: (%s)", err)
+		} else if keys != nil {
+			k.dealPool[string(pid)] = true
+		}
+		return
+	}
+	if dealings, err := vochain.ThresholdDealings(p, count); err != nil {
+		log.Errorf("cannot get dealings: (%s)", err)
+	} else if dealings != nil {
+		k.checkPool[string(pid)] = true
+	}
 }
 
 // OnRevealKeys does nothing
@@ -281,6 +327,11 @@ func (k *KeyKeeper) OnRevealKeys(pid []byte, priv string, txindex int32) {
 	// do nothing
 }
 
+// OnProcessQuestionIndex does nothing
+func (k *KeyKeeper) OnProcessQuestionIndex(pid []byte, questionIndex uint32, txindex int32) {
+	// do nothing
+}
+
 // OnProcessResults does nothing
 func (k *KeyKeeper) OnProcessResults(pid []byte,
 	results *models.ProcessResult, txindex int32) error {
@@ -291,6 +342,31 @@ func (k *KeyKeeper) OnProcessResults(pid []byte,
 // OnProcessesStart does nothing
 func (k *KeyKeeper) OnProcessesStart(pids [][]byte) {}
 
+// OnProcessDurationChange reschedules the reveal keys of a timed process whose
+// end block has changed
+func (k *KeyKeeper) OnProcessDurationChange(pid []byte, blockCount uint32) {
+	p, err := k.vochain.State.Process(pid, false)
+	if err != nil {
+		log.Errorf("cannot get process from state: (%s)", err)
+		return
+	}
+	if !(p.EnvelopeType.Anonymous || p.EnvelopeType.EncryptedVotes) {
+		return
+	}
+	if p.EncryptionPublicKeys[k.myIndex] == "" {
+		return
+	}
+	height := int64(p.StartBlock + blockCount)
+	if p.EnvelopeType.GetHomomorphic() {
+		height++
+	}
+	if next := int64(k.vochain.State.CurrentHeight()) + 1; height < next {
+		height = next
+	}
+	log.Infof("process duration changed, scheduling reveal keys for block %d", height)
+	k.blockPool[string(pid)] = height
+}
+
 // Generate Keys generates a set of encryption/commitment keys for a process.
 // Encryption private key = hash(signer.privKey + processId + keyIndex).
 func (k *KeyKeeper) generateKeys(pid []byte) (*processKeys, error) {
@@ -311,6 +387,22 @@ func (k *KeyKeeper) generateKeys(pid []byte) (*processKeys, error) {
 	return pk, nil
 }
 
+// generateDealing generates the threshold keys dealing for a process, whose
+// transport key is the process encryption key.  The dealing polynomial is
+// derived from hash(signer.privKey + processId + keyIndex + "dealing"), so
+// the keykeeper share can be re-created at any time.
+func (k *KeyKeeper) generateDealing(pid []byte, required int,
+	transportKeys [][]byte) (*threshold.Dealing, error) {
+	pk, err := k.generateKeys(pid)
+	if err != nil {
+		return nil, err
+	}
+	seed := append(append([]byte{}, pid...), byte(k.myIndex))
+	seed = append(seed, []byte("dealing")...)
+	return threshold.NewDealing(ethereum.HashRaw(append(k.signer.Private.D.Bytes(), seed...)),
+		required, pk.pubKey, transportKeys)
+}
+
 // scheduleRevealKeys takes the pids from the blockPool and add them to the schedule storage
 func (k *KeyKeeper) scheduleRevealKeys() {
 	k.lock.Lock()
@@ -414,6 +506,119 @@ func (k *KeyKeeper) publishPendingKeys() {
 	}
 }
 
+// publishPendingDealings publishes the threshold keys dealing of each process
+// in the dealPool
+func (k *KeyKeeper) publishPendingDealings() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	required, count, err := k.vochain.State.KeyKeepersThreshold(true)
+	if err != nil {
+		log.Errorf("cannot get keykeepers threshold: (%s)", err)
+		return
+	}
+	for pid := range k.dealPool {
+		if err := k.publishDealing([]byte(pid), int(required), count); err != nil {
+			log.Errorf("cannot publish dealing for process %x: (%s)", pid, err)
+		}
+	}
+}
+
+func (k *KeyKeeper) publishDealing(pid []byte, required int, count uint32) error {
+	p, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		return err
+	}
+	transportKeys, err := vochain.ThresholdTransportKeys(p, count)
+	if err != nil {
+		return err
+	}
+	if transportKeys == nil {
+		return fmt.Errorf("transport keys not available")
+	}
+	dealing, err := k.generateDealing(pid, required, transportKeys)
+	if err != nil {
+		return err
+	}
+	log.Infof("publishing threshold keys dealing for process %x", pid)
+	kindex := new(uint32)
+	*kindex = uint32(k.myIndex)
+	return k.signAndSendTx(&models.AdminTx{
+		Txtype:              models.TxType_ADD_PROCESS_KEYS,
+		KeyIndex:            kindex,
+		Nonce:               util.RandomBytes(32),
+		ProcessId:           pid,
+		EncryptionPublicKey: dealing.Marshal(),
+	})
+}
+
+// checkPendingDealings checks the shares received from the threshold keys
+// dealings of each process in the checkPool
+func (k *KeyKeeper) checkPendingDealings() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	_, count, err := k.vochain.State.KeyKeepersThreshold(true)
+	if err != nil {
+		log.Errorf("cannot get keykeepers threshold: (%s)", err)
+		return
+	}
+	for pid := range k.checkPool {
+		if err := k.checkDealings([]byte(pid), count); err != nil {
+			log.Errorf("cannot check dealings for process %x: (%s)", pid, err)
+		}
+	}
+}
+
+// checkDealings checks the shares received from the dealings of a process
+// against their commitments, and complains about the dealers whose share is
+// not valid.  The complaint reveals the keykeeper transport key, so its
+// shares of the process become public.
+func (k *KeyKeeper) checkDealings(pid []byte, count uint32) error {
+	p, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		return err
+	}
+	dealings, err := vochain.ThresholdDealings(p, count)
+	if err != nil {
+		return err
+	}
+	if dealings == nil {
+		return fmt.Errorf("dealings not available")
+	}
+	pk, err := k.generateKeys(pid)
+	if err != nil {
+		return err
+	}
+	transport, err := nacl.DecodePrivate(fmt.Sprintf("%x", pk.privKey))
+	if err != nil {
+		return err
+	}
+	for i, d := range dealings {
+		dealer := uint32(i + 1)
+		if dealer == uint32(k.myIndex) || vochain.IsExcludedDealer(p, dealer) {
+			continue
+		}
+		_, err := d.DecryptShare(int(k.myIndex), transport)
+		if err == nil {
+			continue
+		}
+		log.Warnf("invalid share from dealer %d for process %x, complaining: (%s)",
+			dealer, pid, err)
+		kindex := new(uint32)
+		*kindex = uint32(k.myIndex)
+		if err := k.signAndSendTx(&models.AdminTx{
+			Txtype:               models.TxType_COMPLAIN_PROCESS_DEALING,
+			KeyIndex:             kindex,
+			DealerIndex:          &dealer,
+			Nonce:                util.RandomBytes(32),
+			ProcessId:            pid,
+			EncryptionPrivateKey: pk.privKey,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (k *KeyKeeper) publishKeys(pk *processKeys, pid string) error {
 	log.Infof("publishing keys for process %x", []byte(pid))
 	kindex := new(uint32)
@@ -453,6 +658,19 @@ func (k *KeyKeeper) revealKeys(pid string) error {
 	if err != nil {
 		return err
 	}
+	privKey := pk.privKey
+	// on threshold keys, the share of the keykeeper is revealed, or the
+	// partial decryption of the tally on homomorphic tally processes
+	if _, count, err := k.vochain.State.KeyKeepersThreshold(true); err != nil {
+		return err
+	} else if count > 0 {
+		if privKey, err = k.thresholdShare([]byte(pid), pk, count); err != nil {
+			return err
+		}
+		if privKey, err = k.tallyDecryption([]byte(pid), privKey); err != nil {
+			return err
+		}
+	}
 	kindex := new(uint32)
 	*kindex = uint32(pk.index)
 	tx := &models.AdminTx{
@@ -460,7 +678,7 @@ func (k *KeyKeeper) revealKeys(pid string) error {
 		KeyIndex:             kindex,
 		Nonce:                util.RandomBytes(32),
 		ProcessId:            []byte(pid),
-		EncryptionPrivateKey: pk.privKey,
+		EncryptionPrivateKey: privKey,
 	}
 	if err := k.signAndSendTx(tx); err != nil {
 		return err
@@ -477,6 +695,53 @@ func (k *KeyKeeper) revealKeys(pid string) error {
 	return wTx.Commit()
 }
 
+// thresholdShare returns the keykeeper share of the threshold keys of a
+// process, decrypted from the dealings with its transport key
+func (k *KeyKeeper) thresholdShare(pid []byte, pk *processKeys, count uint32) ([]byte, error) {
+	p, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		return nil, err
+	}
+	dealings, err := vochain.QualifiedDealings(p, count)
+	if err != nil {
+		return nil, err
+	}
+	if dealings == nil {
+		return nil, fmt.Errorf("process %x has no threshold key", pid)
+	}
+	transport, err := nacl.DecodePrivate(fmt.Sprintf("%x", pk.privKey))
+	if err != nil {
+		return nil, err
+	}
+	share, err := threshold.ParticipantShare(dealings, int(k.myIndex), transport)
+	if err != nil {
+		return nil, err
+	}
+	return threshold.EncodeScalar(share), nil
+}
+
+// tallyDecryption returns the partial decryption of the encrypted tally of a
+// homomorphic tally process with the keykeeper share, or the share itself if
+// the process is not a homomorphic tally process
+func (k *KeyKeeper) tallyDecryption(pid, share []byte) ([]byte, error) {
+	p, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		return nil, err
+	}
+	if !p.EnvelopeType.GetHomomorphic() {
+		return share, nil
+	}
+	tally, _, err := k.vochain.State.EncryptedTally(p, true)
+	if err != nil {
+		return nil, err
+	}
+	s, err := threshold.DecodeScalar(share)
+	if err != nil {
+		return nil, err
+	}
+	return vochain.NewTallyDecryption(tally, s)
+}
+
 func (k *KeyKeeper) signAndSendTx(tx *models.AdminTx) error {
 	var err error
 	stx := &models.SignedTx{}
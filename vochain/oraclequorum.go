@@ -0,0 +1,138 @@
+package vochain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/vocdoni/arbo"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// Oracle results quorum
+//
+// Each oracle submits the results of a process once with a
+// SET_PROCESS_RESULTS transaction, and all the submissions are stored in the
+// process Results.  The process moves to RESULTS once the oracle quorum have
+// submitted identical results (the same votes and ranked results), which
+// become the agreed results of the process.  The submissions that differ from
+// the agreed results are kept for auditing.  If no quorum is set, the first submitted
+// results are agreed.  The quorum is set on the genesis and updated with the
+// SET_ORACLE_QUORUM admin transaction, and it can never be greater than the
+// number of oracles.
+
+// SetOracleQuorum sets the number of oracles that must submit identical
+// results for a process to move to RESULTS.  The quorum must be between one
+// and the number of oracles.
+func (v *State) SetOracleQuorum(quorum uint32) error {
+	oracles, err := v.Oracles(false)
+	if err != nil {
+		return fmt.Errorf("cannot get oracles: %w", err)
+	}
+	if err := checkOracleQuorum(quorum, len(oracles)); err != nil {
+		return err
+	}
+	v.Tx.Lock()
+	defer v.Tx.Unlock()
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, quorum)
+	return v.Tx.DeepSet([]byte(OracleQuorumKey), value, ExtraCfg)
+}
+
+// checkOracleQuorum checks that quorum can be reached by the number of oracles.
+func checkOracleQuorum(quorum uint32, oracles int) error {
+	if quorum < 1 || uint64(quorum) > uint64(oracles) {
+		return fmt.Errorf("invalid oracle quorum %d for %d oracles", quorum, oracles)
+	}
+	return nil
+}
+
+// OracleQuorum returns the oracle quorum set with SetOracleQuorum, or one if
+// not set.
+// When committed is false, the operation is executed also on not yet commited
+// data from the currently open StateDB transaction.
+// When committed is true, the operation is executed on the last commited version.
+func (v *State) OracleQuorum(committed bool) (uint32, error) {
+	if !committed {
+		v.Tx.RLock()
+		defer v.Tx.RUnlock()
+	}
+	extraTree, err := v.mainTreeViewer(committed).SubTree(ExtraCfg)
+	if err != nil {
+		return 0, err
+	}
+	value, err := extraTree.Get([]byte(OracleQuorumKey))
+	if errors.Is(err, arbo.ErrKeyNotFound) {
+		return 1, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(value) != 4 {
+		return 0, fmt.Errorf("invalid oracle quorum value")
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+// SameResults returns true if both results have the same votes and ranked
+// results.
+func SameResults(a, b *models.ProcessResult) bool {
+	if !proto.Equal(a.GetRanked(), b.GetRanked()) {
+		return false
+	}
+	if len(a.GetVotes()) != len(b.GetVotes()) {
+		return false
+	}
+	for i := range a.GetVotes() {
+		if !proto.Equal(a.Votes[i], b.Votes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// AgreedResults returns the first results submitted by quorum oracles, in
+// submission order, or nil if the quorum has not been reached.
+func AgreedResults(results []*models.ProcessResult, quorum uint32) *models.ProcessResult {
+	for i, r := range results {
+		if r == nil {
+			continue
+		}
+		var first *models.ProcessResult
+		count := uint32(0)
+		for _, pr := range results[:i+1] {
+			if pr != nil && SameResults(pr, r) {
+				if first == nil {
+					first = pr
+				}
+				count++
+			}
+		}
+		if count >= quorum {
+			return first
+		}
+	}
+	return nil
+}
+
+// ProcessOracleResults returns all the results submitted by the oracles for a
+// process, and the agreed results, which are nil if the quorum has not been
+// reached yet.
+func (v *State) ProcessOracleResults(pid []byte) ([]*models.ProcessResult,
+	*models.ProcessResult, error) {
+	process, err := v.Process(pid, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	quorum, err := v.OracleQuorum(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := []*models.ProcessResult{}
+	for _, r := range process.Results {
+		if r != nil {
+			results = append(results, r)
+		}
+	}
+	return results, AgreedResults(results, quorum), nil
+}
@@ -0,0 +1,49 @@
+package vochain
+
+import (
+	"errors"
+	"fmt"
+
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// Vote overwrites
+//
+// On processes with MaxVoteOverwrites greater than zero, a voter can cast a
+// new envelope with the same nullifier up to MaxVoteOverwrites times while the
+// process is READY.  The new vote replaces the previous one in the state, and
+// only the last envelope is counted.  The state keeps the number of times each
+// vote has been overwritten.
+
+// EnvelopeOverwrites returns the number of times the vote identified by the
+// processID and nullifier has been overwritten, or ErrVoteDoesNotExist if
+// there is no such vote.
+// When committed is false, the operation is executed also on not yet commited
+// data from the currently open StateDB transaction.
+// When committed is true, the operation is executed on the last commited version.
+func (v *State) EnvelopeOverwrites(processID, nullifier []byte, committed bool) (uint32, error) {
+	sdbVote, err := v.stateDBVote(processID, nullifier, committed)
+	if err != nil {
+		return 0, err
+	}
+	return sdbVote.GetOverwrites(), nil
+}
+
+// checkVoteOverwrite returns an error if a vote with the nullifier already
+// exists and it cannot be overwritten anymore.
+func checkVoteOverwrite(state *State, process *models.Process, nullifier []byte) error {
+	overwrites, err := state.EnvelopeOverwrites(process.ProcessId, nullifier, false)
+	if errors.Is(err, ErrVoteDoesNotExist) || errors.Is(err, ErrProcessNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	max := process.GetVoteOptions().GetMaxVoteOverwrites()
+	if max == 0 {
+		return fmt.Errorf("vote %x already exists", nullifier)
+	}
+	if overwrites >= max {
+		return fmt.Errorf("vote %x cannot be overwritten more than %d times", nullifier, max)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package vochain
+
+import (
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/censustree"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/db/metadb"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestVoteOverwrite(t *testing.T) {
+	app := TestBaseApplication(t)
+
+	tr, err := censustree.New(censustree.Options{Name: "testoverwrite",
+		ParentDB: metadb.NewTest(t), MaxLevels: 256, CensusType: models.Census_ARBO_BLAKE2B})
+	qt.Assert(t, err, qt.IsNil)
+	voter := ethereum.NewSignKeys()
+	qt.Assert(t, voter.Generate(), qt.IsNil)
+	key, err := tr.Hash(voter.PublicKey())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tr.Add(key, nil), qt.IsNil)
+	_, siblings, err := tr.GenProof(key)
+	qt.Assert(t, err, qt.IsNil)
+	root, err := tr.Root()
+	qt.Assert(t, err, qt.IsNil)
+
+	censusURI := ipfsUrl
+	pid := util.RandomBytes(types.ProcessIDsize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 3, MaxVoteOverwrites: 2},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:   root,
+		CensusURI:    &censusURI,
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}), qt.IsNil)
+
+	sendVote := func(value int) error {
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{Vote: &models.VoteEnvelope{
+			Nonce:     util.RandomBytes(32),
+			ProcessId: pid,
+			Proof: &models.Proof{Payload: &models.Proof_Arbo{Arbo: &models.ProofArbo{
+				Type:     models.ProofArbo_BLAKE2B,
+				Siblings: siblings,
+			}}},
+			VotePackage: []byte(fmt.Sprintf("[%d]", value)),
+		}}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = voter.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return nil
+	}
+
+	// the vote can be cast and then overwritten twice
+	nullifier := GenerateNullifier(voter.Address(), pid)
+	hashes := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		qt.Assert(t, sendVote(i), qt.IsNil)
+		app.AdvanceTestBlock()
+		overwrites, err := app.State.EnvelopeOverwrites(pid, nullifier, true)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, overwrites, qt.Equals, uint32(i))
+		hash, err := app.State.Envelope(pid, nullifier, true)
+		qt.Assert(t, err, qt.IsNil)
+		hashes[string(hash)] = true
+	}
+	qt.Assert(t, sendVote(3), qt.ErrorMatches, ".*cannot be overwritten more than 2 times.*")
+
+	// each vote replaces the previous one
+	qt.Assert(t, hashes, qt.HasLen, 3)
+	qt.Assert(t, app.State.CountVotes(pid, true), qt.Equals, uint32(1))
+	count, err := app.State.VoteCount(true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, count, qt.Equals, uint64(1))
+}
@@ -21,8 +21,15 @@ var emptyPreRegisterNullifiersRoot = make([]byte, PreRegisterNullifiersCfg.HashF
 // AddProcess adds a new process to the vochain.  Adding a process with a
 // ProcessId that already exists will return an error.
 func (v *State) AddProcess(p *models.Process) error {
+	if p.CensusOrigin == models.CensusOrigin_VOCHAIN_TOKENS {
+		// the census root is set on Save, once the accounts snapshot
+		// of the process is committed
+		if p.StartBlock <= v.CurrentHeight() {
+			return fmt.Errorf("vochain tokens census process must start after the current height")
+		}
+		p.CensusRoot = nil
+	}
 	preRegister := p.Mode != nil && p.Mode.PreRegister
-	anonymous := p.EnvelopeType != nil && p.EnvelopeType.Anonymous
 	if preRegister {
 		p.RollingCensusRoot = emptyCensusRoot
 		p.NullifiersRoot = emptyPreRegisterNullifiersRoot
@@ -38,11 +45,11 @@ func (v *State) AddProcess(p *models.Process) error {
 		if err := v.Tx.DeepAdd(p.ProcessId, newProcessBytes, ProcessesCfg); err != nil {
 			return err
 		}
-		// If Mode.PreRegister && EnvelopeType.Anonymous we create (by
-		// opening) a new empty poseidon census tree and nullifier tree
-		// at p.ProcessId.
-		if preRegister && anonymous {
-			census, err := v.Tx.DeepSubTree(ProcessesCfg, CensusPoseidonCfg.WithKey(p.ProcessId))
+		// If Mode.PreRegister we create (by opening) a new empty rolling
+		// census tree (poseidon if EnvelopeType.Anonymous, sha256
+		// otherwise) and nullifier tree at p.ProcessId.
+		if preRegister {
+			census, err := v.Tx.DeepSubTree(ProcessesCfg, rollingCensusCfg(p).WithKey(p.ProcessId))
 			if err != nil {
 				return err
 			}
@@ -55,6 +62,18 @@ func (v *State) AddProcess(p *models.Process) error {
 				return err
 			}
 		}
+		if p.GetEndTime() > 0 {
+			pids, err := v.timedProcesses()
+			if err != nil {
+				return err
+			}
+			if err := v.setTimedProcesses(append(pids, p.ProcessId)); err != nil {
+				return err
+			}
+		}
+		if err := v.setProcessIDByEndBlock(p.ProcessId, p.StartBlock+p.BlockCount); err != nil {
+			return err
+		}
 		return v.setProcessIDByStartBlock(p.ProcessId, p.StartBlock)
 	}()
 	v.Tx.Unlock()
@@ -247,6 +266,25 @@ func (v *State) SetProcessStatus(pid []byte, newstatus models.ProcessStatus, com
 		if err := v.updateProcess(process, process.ProcessId); err != nil {
 			return err
 		}
+		// a process resumed once its last block is over is ended at the
+		// end of this block, since it was not ended while paused
+		if endBlock := process.StartBlock + process.BlockCount; newstatus == models.ProcessStatus_READY &&
+			endBlock < v.CurrentHeight() {
+			v.Tx.Lock()
+			err := v.moveProcessIDByEndBlock(pid, endBlock, v.CurrentHeight())
+			v.Tx.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		// Once the process is finished, the rolling census is no longer
+		// needed.
+		switch newstatus {
+		case models.ProcessStatus_ENDED, models.ProcessStatus_CANCELED, models.ProcessStatus_RESULTS:
+			if err := v.PurgeRollingCensus(process.ProcessId); err != nil {
+				return err
+			}
+		}
 		for _, l := range v.eventListeners {
 			l.OnProcessStatusChange(process.ProcessId, process.Status, v.TxCounter())
 		}
@@ -284,6 +322,19 @@ func (v *State) SetProcessResults(pid []byte, result *models.ProcessResult, comm
 	if result.OracleAddress == nil {
 		return fmt.Errorf("cannot set results, oracle address is nil")
 	}
+	// check the oracle has not already sent a results
+	for _, pr := range process.Results {
+		if bytes.Equal(pr.GetOracleAddress(), result.OracleAddress) {
+			return fmt.Errorf("results already set for this oracle address")
+		}
+	}
+	// on homomorphic tally processes, the results must match the decrypted
+	// tally
+	if process.EnvelopeType.GetHomomorphic() {
+		if err := checkHomomorphicResults(v, process, result); err != nil {
+			return fmt.Errorf("cannot set results: %w", err)
+		}
+	}
 
 	if commit {
 		// Warning: if we don't set a maximum block number on which results can be
@@ -297,43 +348,90 @@ func (v *State) SetProcessResults(pid []byte, result *models.ProcessResult, comm
 			}
 			// use len*2 for security, a new oracle could be registered during
 			// the time window from the first results tx to the last one
-			process.Results = make([]*models.ProcessResult, len(n)*2)
-		}
-		// check the oracle has not already sent a results
-		for _, pr := range process.Results {
-			if bytes.Equal(pr.GetOracleAddress(), result.OracleAddress) {
-				return fmt.Errorf("results already set for this oracle address")
-			}
+			process.Results = make([]*models.ProcessResult, 0, len(n)*2)
 		}
 		process.Results = append(process.Results, result)
-		process.Status = models.ProcessStatus_RESULTS
+		// the process moves to RESULTS once the oracle quorum agree
+		quorum, err := v.OracleQuorum(false)
+		if err != nil {
+			return fmt.Errorf("cannot set results: %w", err)
+		}
+		agreed := AgreedResults(process.Results, quorum)
+		if agreed != nil && !SameResults(agreed, result) {
+			log.Warnf("oracle %x results for process %x conflict with the agreed results",
+				result.OracleAddress, process.ProcessId)
+		}
+		quorumReached := agreed != nil && process.Status != models.ProcessStatus_RESULTS
+		if quorumReached {
+			process.Status = models.ProcessStatus_RESULTS
+		}
 		if err := v.updateProcess(process, process.ProcessId); err != nil {
 			return fmt.Errorf("cannot set results: %w", err)
 		}
 		// Call event listeners
-		for _, l := range v.eventListeners {
-			if err := l.OnProcessResults(process.ProcessId, result, v.TxCounter()); err != nil {
-				log.Warnf("onProcessResults callback error: %v", err)
+		if quorumReached {
+			for _, l := range v.eventListeners {
+				if err := l.OnProcessResults(process.ProcessId, agreed, v.TxCounter()); err != nil {
+					log.Warnf("onProcessResults callback error: %v", err)
+				}
 			}
 		}
 	}
 	return nil
 }
 
-// GetProcessResults returns a friendly representation of the results stored in the State (if any).
+// GetProcessResults returns a friendly representation of the agreed results
+// stored in the State (if any).
 func (v *State) GetProcessResults(pid []byte) ([][]string, error) {
 	// TO-DO (pau): use a LRU cache for results
-	process, err := v.Process(pid, true)
+	_, agreed, err := v.ProcessOracleResults(pid)
 	if err != nil {
 		return nil, err
 	}
-	if len(process.Results) > 0 {
-		// TO-DO (pau): return the whole list of oracle results
-		return GetFriendlyResults(process.Results[0].GetVotes()), nil
+	if agreed != nil {
+		return GetFriendlyResults(agreed.GetVotes()), nil
 	}
 	return nil, fmt.Errorf("no results for process %x", pid)
 }
 
+// SetProcessQuestionIndex advances the current question of a serial process to
+// questionIndex, which must be the next question.  Once advanced, new votes are
+// cast for the new question.
+func (v *State) SetProcessQuestionIndex(pid []byte, questionIndex uint32, commit bool) error {
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	if process.EnvelopeType == nil || !process.EnvelopeType.Serial {
+		return fmt.Errorf("cannot set question index, process %x is not serial", pid)
+	}
+	if process.Status != models.ProcessStatus_READY &&
+		process.Status != models.ProcessStatus_PAUSED {
+		return fmt.Errorf(
+			"cannot set question index, process status must be READY or PAUSED and is: %s",
+			process.Status.String())
+	}
+	if questionIndex != process.GetQuestionIndex()+1 {
+		return fmt.Errorf("cannot set question index to %d, current question index is %d",
+			questionIndex, process.GetQuestionIndex())
+	}
+	if questionIndex >= process.GetQuestionCount() {
+		return fmt.Errorf("cannot set question index to %d, process has %d questions",
+			questionIndex, process.GetQuestionCount())
+	}
+
+	if commit {
+		process.QuestionIndex = &questionIndex
+		if err := v.updateProcess(process, process.ProcessId); err != nil {
+			return err
+		}
+		for _, l := range v.eventListeners {
+			l.OnProcessQuestionIndex(process.ProcessId, questionIndex, v.TxCounter())
+		}
+	}
+	return nil
+}
+
 // SetProcessCensus sets the census for a given process, only if that process enables dynamic census
 func (v *State) SetProcessCensus(pid, censusRoot []byte, censusURI string, commit bool) error {
 	process, err := v.Process(pid, false)
@@ -380,71 +478,88 @@ func (v *State) SetProcessCensus(pid, censusRoot []byte, censusURI string, commi
 
 // NewProcessTxCheck is an abstraction of ABCI checkTx for creating a new process
 func (app *BaseApplication) NewProcessTxCheck(vtx *models.Tx, txBytes,
-	signature []byte, state *State) (*models.Process, error) {
+	signature []byte, state *State) (*models.Process, *processTxCheckValues, error) {
 	tx := vtx.GetNewProcess()
 	if tx.Process == nil {
-		return nil, fmt.Errorf("process data is empty")
+		return nil, nil, fmt.Errorf("process data is empty")
 	}
 	// basic required fields check
 	if tx.Process.VoteOptions == nil || tx.Process.EnvelopeType == nil || tx.Process.Mode == nil {
-		return nil, fmt.Errorf("missing required fields (voteOptions, envelopeType or processMode)")
+		return nil, nil, fmt.Errorf("missing required fields (voteOptions, envelopeType or processMode)")
 	}
 	if tx.Process.VoteOptions.MaxCount == 0 || tx.Process.VoteOptions.MaxValue == 0 {
-		return nil, fmt.Errorf("missing vote options parameters (maxCount or maxValue)")
+		return nil, nil, fmt.Errorf("missing vote options parameters (maxCount or maxValue)")
 	}
 	// check signature available
 	if signature == nil || tx == nil || txBytes == nil {
-		return nil, fmt.Errorf("missing signature or new process transaction")
+		return nil, nil, fmt.Errorf("missing signature or new process transaction")
+	}
+	// timed processes get their start block and block count from their dates
+	if err := scheduleTimedProcess(tx.Process, state.CurrentHeight(),
+		app.TimestampStartBlock()); err != nil {
+		return nil, nil, err
 	}
 	// start and block count sanity check
 	// if startBlock is zero or one, the process will be enabled on the next block
 	if tx.Process.StartBlock == 0 || tx.Process.StartBlock == 1 {
 		tx.Process.StartBlock = state.CurrentHeight() + 1
 	} else if tx.Process.StartBlock < state.CurrentHeight() {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"cannot add process with start block lower than or equal to the current height")
 	}
 	if tx.Process.BlockCount <= 0 {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"cannot add process with duration lower than or equal to the current height")
 	}
-	// Check if transaction owner has enough funds to create a process
-	authorized, addr, err := state.VerifyAccountBalance(txBytes, signature, NewProcessCost)
+	// the accounts census snapshot is taken at the end of the block
+	// previous to the process start block, so it cannot be in the past
+	if tx.Process.CensusOrigin == models.CensusOrigin_VOCHAIN_TOKENS {
+		if tx.Process.StartBlock <= state.CurrentHeight() {
+			return nil, nil, fmt.Errorf("vochain tokens census process must start after the current height")
+		}
+		if tx.Process.Mode.PreRegister {
+			return nil, nil, fmt.Errorf("pre-register mode not supported with vochain tokens census")
+		}
+	}
+	// Oracles can create processes with any entityID free of charge,
+	// otherwise the entity must pay the process cost from its account
+	addr, isOracle, err := processTxSender(state, txBytes, signature)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if authorized {
-		// If owner authorized, check entityId matches with owner address
+	txValues := &processTxCheckValues{}
+	if !isOracle {
 		if !bytes.Equal(tx.Process.EntityId, addr.Bytes()) {
-			return nil, fmt.Errorf("process entityID and transaction owner do not match")
+			return nil, nil, fmt.Errorf("process entityID and transaction owner do not match")
 		}
-	} else {
-		// Check if the transaction comes from an oracle
-		// Oracles can create processes with any entityID
-		if authorized, err = state.IsOracle(addr); err != nil {
-			return nil, err
+		txCost, err := state.TxCost(models.TxType_NEW_PROCESS, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot get %s tx cost: %w", models.TxType_NEW_PROCESS, err)
 		}
-	}
-	// If owner without balance and not an oracle, fail
-	if !authorized {
-		return nil, fmt.Errorf("unauthorized to create a process, recovered addr is %s", addr.Hex())
+		cost, err := NewProcessCost(txCost, tx.Process)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkProcessTxAccount(state, addr, tx.AccountNonce, cost); err != nil {
+			return nil, nil, fmt.Errorf("unauthorized to create a process: %w", err)
+		}
+		txValues.Account, txValues.Cost = addr, cost
 	}
 	// get process
 	_, err = state.Process(tx.Process.ProcessId, false)
 	if err == nil {
-		return nil, fmt.Errorf("process with id (%x) already exists", tx.Process.ProcessId)
+		return nil, nil, fmt.Errorf("process with id (%x) already exists", tx.Process.ProcessId)
 	}
 
 	// check valid/implemented process types
-	// pre-regiser and anonymous must be either both enabled or disabled, as
-	// we only support a single scenario of pre-register + anonymous.
-	if tx.Process.Mode.PreRegister != tx.Process.EnvelopeType.Anonymous {
-		return nil, fmt.Errorf("pre-register mode only supported " +
-			"with anonymous envelope type and viceversa")
+	// anonymous voting requires pre-register, as the anonymous census is
+	// built during the registration phase.
+	if tx.Process.EnvelopeType.Anonymous && !tx.Process.Mode.PreRegister {
+		return nil, nil, fmt.Errorf("anonymous envelope type requires pre-register mode")
 	}
 	if tx.Process.Mode.PreRegister &&
 		(tx.Process.MaxCensusSize == nil || *tx.Process.MaxCensusSize <= 0) {
-		return nil, fmt.Errorf("pre-register mode requires setting " +
+		return nil, nil, fmt.Errorf("pre-register mode requires setting " +
 			"maxCensusSize to be > 0")
 	}
 	if tx.Process.Mode.PreRegister && tx.Process.EnvelopeType.Anonymous {
@@ -456,23 +571,46 @@ func (app *BaseApplication) NewProcessTxCheck(vtx *models.Tx, txBytes,
 			circuits = Genesis["dev"].CircuitsConfig
 		}
 		if len(circuits) == 0 {
-			return nil, fmt.Errorf("no circuit configs in the %v genesis", app.chainId)
+			return nil, nil, fmt.Errorf("no circuit configs in the %v genesis", app.chainId)
 		}
 		if tx.Process.MaxCensusSize == nil {
-			return nil, fmt.Errorf("maxCensusSize is not provided")
+			return nil, nil, fmt.Errorf("maxCensusSize is not provided")
 		}
 		if *tx.Process.MaxCensusSize > uint64(circuits[len(circuits)-1].Parameters[0]) {
-			return nil, fmt.Errorf("maxCensusSize for anonymous envelope "+
+			return nil, nil, fmt.Errorf("maxCensusSize for anonymous envelope "+
 				"cannot be bigger than the parameter for the biggest circuit (%v)",
 				circuits[len(circuits)-1].Parameters[0])
 		}
 	}
 
-	// TODO: Enable support for PreRegiser without Anonymous.  Figure out
-	// all the required changes to support a process with a rolling census
-	// that is not Anonymous.
+	// Serial processes are voted one question at a time, starting from
+	// question index 0.  Anonymous votes are not supported, as the
+	// nullifier must be different for each question.
 	if tx.Process.EnvelopeType.Serial {
-		return nil, fmt.Errorf("serial process not yet implemented")
+		if tx.Process.EnvelopeType.Anonymous {
+			return nil, nil, fmt.Errorf("serial process not supported with anonymous envelope type")
+		}
+		if tx.Process.GetQuestionCount() == 0 ||
+			tx.Process.GetQuestionCount() > tx.Process.VoteOptions.MaxCount {
+			return nil, nil, fmt.Errorf("serial process requires a question count " +
+				"between 1 and the vote options maxCount")
+		}
+		tx.Process.QuestionIndex = new(uint32)
+	}
+
+	// Homomorphic tally processes are voted with encrypted ballots that are
+	// added up without decrypting them, see homomorphic.go.
+	if tx.Process.EnvelopeType.GetHomomorphic() {
+		if err := checkHomomorphicProcess(tx.Process, state); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Ranked choice processes are voted with an ordering of the options,
+	// see ranked.go.
+	if tx.Process.VoteOptions.GetRankedSeats() > 0 {
+		if err := checkRankedProcess(tx.Process); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if tx.Process.EnvelopeType.EncryptedVotes || tx.Process.EnvelopeType.Anonymous {
@@ -480,60 +618,66 @@ func (app *BaseApplication) NewProcessTxCheck(vtx *models.Tx, txBytes,
 		tx.Process.EncryptionPublicKeys = make([]string, types.KeyKeeperMaxKeyIndex)
 		tx.Process.EncryptionPrivateKeys = make([]string, types.KeyKeeperMaxKeyIndex)
 	}
-	return tx.Process, nil
+	return tx.Process, txValues, nil
 }
 
 // SetProcessTxCheck is an abstraction of ABCI checkTx for canceling an existing process
-func SetProcessTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error {
+func SetProcessTxCheck(vtx *models.Tx, txBytes, signature []byte,
+	state *State) (*processTxCheckValues, error) {
 	tx := vtx.GetSetProcess()
 	// check signature available
 	if signature == nil || tx == nil || txBytes == nil {
-		return fmt.Errorf("missing signature on setProcess transaction")
-	}
-
-	// Check if transaction owner has enough funds to create a process
-	authorized, addr, err := state.VerifyAccountBalance(txBytes, signature, SetProcessCost)
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("missing signature on setProcess transaction")
 	}
 	// get process
 	process, err := state.Process(tx.ProcessId, false)
 	if err != nil {
-		return fmt.Errorf("cannot get process %x: %w", tx.ProcessId, err)
+		return nil, fmt.Errorf("cannot get process %x: %w", tx.ProcessId, err)
 	}
-	isOracle := false
-	if authorized {
-		// If owner authorized, check entityId matches with owner address
+	// Oracles can update processes of any entityID free of charge,
+	// otherwise the entity must pay the transaction cost from its account
+	addr, isOracle, err := processTxSender(state, txBytes, signature)
+	if err != nil {
+		return nil, err
+	}
+	txValues := &processTxCheckValues{}
+	if !isOracle {
+		if tx.Txtype == models.TxType_SET_PROCESS_RESULTS {
+			return nil, fmt.Errorf("only oracles can execute set process results transaction")
+		}
 		if !bytes.Equal(process.EntityId, addr.Bytes()) {
-			return fmt.Errorf("process entityID and transaction owner do not match")
+			return nil, fmt.Errorf("process entityID and transaction owner do not match")
 		}
-	} else {
-		// Check if the transaction comes from an oracle
-		// Oracles can create processes with any entityID
-		if isOracle, err = state.IsOracle(addr); err != nil {
-			return err
+		cost, err := state.TxCost(tx.Txtype, false)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get %s tx cost: %w", tx.Txtype, err)
 		}
-	}
-
-	// If owner without balance and not an oracle, fail
-	if !authorized && !isOracle {
-		return fmt.Errorf("unauthorized to set process status, recovered addr is %s", addr.Hex())
+		if err := checkProcessTxAccount(state, addr, tx.AccountNonce, cost); err != nil {
+			return nil, fmt.Errorf("unauthorized to set process: %w", err)
+		}
+		txValues.Account, txValues.Cost = addr, cost
 	}
 	switch tx.Txtype {
 	case models.TxType_SET_PROCESS_RESULTS:
-		if !isOracle {
-			return fmt.Errorf("only oracles can execute set process results transaction")
-		}
 		results := tx.GetResults()
 		if !bytes.Equal(results.OracleAddress, addr.Bytes()) {
-			return fmt.Errorf("cannot set results, oracle address provided in results does not match")
+			return nil, fmt.Errorf("cannot set results, oracle address provided in results does not match")
 		}
-		return state.SetProcessResults(process.ProcessId, results, false)
+		err = state.SetProcessResults(process.ProcessId, results, false)
 	case models.TxType_SET_PROCESS_STATUS:
-		return state.SetProcessStatus(process.ProcessId, tx.GetStatus(), false)
+		err = state.SetProcessStatus(process.ProcessId, tx.GetStatus(), false)
 	case models.TxType_SET_PROCESS_CENSUS:
-		return state.SetProcessCensus(process.ProcessId, tx.GetCensusRoot(), tx.GetCensusURI(), false)
+		err = state.SetProcessCensus(process.ProcessId, tx.GetCensusRoot(), tx.GetCensusURI(), false)
+	case models.TxType_SET_PROCESS_QUESTION_INDEX:
+		if tx.QuestionIndex == nil {
+			return nil, fmt.Errorf("question index is nil")
+		}
+		err = state.SetProcessQuestionIndex(process.ProcessId, *tx.QuestionIndex, false)
 	default:
-		return fmt.Errorf("unknown setProcess tx type: %s", tx.Txtype)
+		return nil, fmt.Errorf("unknown setProcess tx type: %s", tx.Txtype)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return txValues, nil
 }
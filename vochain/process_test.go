@@ -7,7 +7,9 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	qt "github.com/frankban/quicktest"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/censustree"
 	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/db/metadb"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	models "go.vocdoni.io/proto/build/go/models"
@@ -315,6 +317,69 @@ func TestProcessSetResultsTransition(t *testing.T) {
 	}
 }
 
+func TestProcessResultsOracleQuorum(t *testing.T) {
+	app := TestBaseApplication(t)
+	oracles := make([]*ethereum.SignKeys, 3)
+	for i := range oracles {
+		oracles[i] = ethereum.NewSignKeys()
+		qt.Assert(t, oracles[i].Generate(), qt.IsNil)
+		qt.Assert(t, app.State.AddOracle(oracles[i].Address()), qt.IsNil)
+	}
+	qt.Assert(t, app.State.SetOracleQuorum(2), qt.IsNil)
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	eid := util.RandomBytes(types.EthereumAddressSize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{Interruptible: true},
+		Status:       models.ProcessStatus_ENDED,
+		EntityId:     eid,
+		CensusRoot:   util.RandomBytes(32),
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}), qt.IsNil)
+
+	setResults := func(oracle *ethereum.SignKeys, value byte) error {
+		return testSetProcessResults(t, pid, oracle, app, &models.ProcessResult{
+			ProcessId:     pid,
+			EntityId:      eid,
+			Votes:         []*models.QuestionResult{{Question: [][]byte{{value}}}},
+			OracleAddress: oracle.Address().Bytes(),
+		})
+	}
+	status := func() models.ProcessStatus {
+		p, err := app.State.Process(pid, true)
+		qt.Assert(t, err, qt.IsNil)
+		return p.Status
+	}
+
+	// two conflicting results do not reach the quorum
+	qt.Assert(t, setResults(oracles[0], 1), qt.IsNil)
+	qt.Assert(t, setResults(oracles[0], 1), qt.ErrorMatches, ".*already set.*")
+	qt.Assert(t, status(), qt.Equals, models.ProcessStatus_ENDED)
+	_, err := app.State.GetProcessResults(pid)
+	qt.Assert(t, err, qt.ErrorMatches, "no results.*")
+
+	qt.Assert(t, setResults(oracles[1], 2), qt.IsNil)
+	qt.Assert(t, status(), qt.Equals, models.ProcessStatus_ENDED)
+
+	// the third oracle agrees with the first one
+	qt.Assert(t, setResults(oracles[2], 1), qt.IsNil)
+	qt.Assert(t, status(), qt.Equals, models.ProcessStatus_RESULTS)
+	friendly, err := app.State.GetProcessResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, friendly, qt.DeepEquals, [][]string{{"1"}})
+
+	// the conflicting results are kept
+	results, agreed, err := app.State.ProcessOracleResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, results, qt.HasLen, 3)
+	qt.Assert(t, agreed.OracleAddress, qt.DeepEquals, oracles[0].Address().Bytes())
+	qt.Assert(t, SameResults(results[1], agreed), qt.IsFalse)
+	qt.Assert(t, SameResults(results[2], agreed), qt.IsTrue)
+}
+
 func testSetProcessResults(t *testing.T, pid []byte, oracle *ethereum.SignKeys,
 	app *BaseApplication, results *models.ProcessResult) error {
 	var cktx abcitypes.RequestCheckTx
@@ -487,6 +552,195 @@ func testSetProcessCensus(t *testing.T, pid []byte, oracle *ethereum.SignKeys,
 	return nil
 }
 
+func TestProcessSetQuestionIndexTransition(t *testing.T) {
+	app := TestBaseApplication(t)
+	oracle := ethereum.SignKeys{}
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.AddOracle(common.HexToAddress(oracle.AddressString())), qt.IsNil)
+
+	// Add a serial process with 3 questions and a non serial one
+	censusURI := ipfsUrl
+	questionCount := uint32(3)
+	pid := util.RandomBytes(types.ProcessIDsize)
+	pid2 := util.RandomBytes(types.ProcessIDsize)
+	process := &models.Process{
+		ProcessId:     pid,
+		StartBlock:    0,
+		EnvelopeType:  &models.EnvelopeType{Serial: true},
+		Mode:          &models.ProcessMode{Interruptible: true},
+		VoteOptions:   &models.ProcessVoteOptions{MaxCount: 3, MaxValue: 2},
+		Status:        models.ProcessStatus_READY,
+		EntityId:      util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:    util.RandomBytes(32),
+		CensusURI:     &censusURI,
+		CensusOrigin:  models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:    1024,
+		QuestionIndex: new(uint32),
+		QuestionCount: &questionCount,
+	}
+	process2 := proto.Clone(process).(*models.Process)
+	process2.ProcessId = pid2
+	process2.EnvelopeType = &models.EnvelopeType{}
+	qt.Assert(t, app.State.AddProcess(process), qt.IsNil)
+	qt.Assert(t, app.State.AddProcess(process2), qt.IsNil)
+
+	// Advance to the next question (should work)
+	qt.Assert(t, testSetProcessQuestionIndex(t, pid, &oracle, app, 1), qt.IsNil)
+	// Set the same question index again (should not work)
+	qt.Assert(t, testSetProcessQuestionIndex(t, pid, &oracle, app, 1), qt.Not(qt.IsNil))
+	// Skip a question (should not work)
+	qt.Assert(t, testSetProcessQuestionIndex(t, pid, &oracle, app, 3), qt.Not(qt.IsNil))
+	// Advance to the last question (should work)
+	qt.Assert(t, testSetProcessQuestionIndex(t, pid, &oracle, app, 2), qt.IsNil)
+	// Go beyond the last question (should not work)
+	qt.Assert(t, testSetProcessQuestionIndex(t, pid, &oracle, app, 3), qt.Not(qt.IsNil))
+
+	p, err := app.State.Process(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, p.GetQuestionIndex(), qt.Equals, uint32(2))
+
+	// Set question index on a non serial process (should not work)
+	qt.Assert(t, testSetProcessQuestionIndex(t, pid2, &oracle, app, 1), qt.Not(qt.IsNil))
+
+	// Set question index on an ended process (should not work)
+	status := models.ProcessStatus_ENDED
+	qt.Assert(t, testSetProcessStatus(t, pid, &oracle, app, &status), qt.IsNil)
+	qt.Assert(t, testSetProcessQuestionIndex(t, pid, &oracle, app, 2), qt.Not(qt.IsNil))
+
+	// The nullifier of a serial vote depends on the question index
+	addr := common.HexToAddress(randomEthAccount)
+	qt.Assert(t, GenerateSerialNullifier(addr, pid, 0), qt.Not(qt.DeepEquals),
+		GenerateSerialNullifier(addr, pid, 1))
+}
+
+func TestSerialVoteQuestionIndex(t *testing.T) {
+	app := TestBaseApplication(t)
+
+	tr, err := censustree.New(censustree.Options{Name: "testserial",
+		ParentDB: metadb.NewTest(t), MaxLevels: 256, CensusType: models.Census_ARBO_BLAKE2B})
+	qt.Assert(t, err, qt.IsNil)
+	voter := ethereum.NewSignKeys()
+	qt.Assert(t, voter.Generate(), qt.IsNil)
+	key, err := tr.Hash(voter.PublicKey())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tr.Add(key, nil), qt.IsNil)
+	_, siblings, err := tr.GenProof(key)
+	qt.Assert(t, err, qt.IsNil)
+	root, err := tr.Root()
+	qt.Assert(t, err, qt.IsNil)
+
+	censusURI := ipfsUrl
+	questionCount := uint32(2)
+	pid := util.RandomBytes(types.ProcessIDsize)
+	process := &models.Process{
+		ProcessId:     pid,
+		EnvelopeType:  &models.EnvelopeType{Serial: true},
+		Mode:          &models.ProcessMode{Interruptible: true},
+		VoteOptions:   &models.ProcessVoteOptions{MaxCount: 2, MaxValue: 2},
+		Status:        models.ProcessStatus_READY,
+		EntityId:      util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:    root,
+		CensusURI:     &censusURI,
+		CensusOrigin:  models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:    1024,
+		QuestionIndex: new(uint32),
+		QuestionCount: &questionCount,
+	}
+	pid2 := util.RandomBytes(types.ProcessIDsize)
+	process2 := proto.Clone(process).(*models.Process)
+	process2.ProcessId = pid2
+	process2.EnvelopeType = &models.EnvelopeType{}
+	qt.Assert(t, app.State.AddProcess(process), qt.IsNil)
+	qt.Assert(t, app.State.AddProcess(process2), qt.IsNil)
+
+	sendVote := func(pid []byte, questionIndex uint32) error {
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{Vote: &models.VoteEnvelope{
+			Nonce:     util.RandomBytes(32),
+			ProcessId: pid,
+			Proof: &models.Proof{Payload: &models.Proof_Arbo{Arbo: &models.ProofArbo{
+				Type:     models.ProofArbo_BLAKE2B,
+				Siblings: siblings,
+			}}},
+			VotePackage:   []byte("[1]"),
+			QuestionIndex: questionIndex,
+		}}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = voter.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return nil
+	}
+
+	// the signed question index must be the current one
+	qt.Assert(t, sendVote(pid, 1), qt.ErrorMatches, ".*process .* is on question 0.*")
+	qt.Assert(t, sendVote(pid, 0), qt.IsNil)
+	app.AdvanceTestBlock()
+	qt.Assert(t, app.State.SetProcessQuestionIndex(pid, 1, true), qt.IsNil)
+	qt.Assert(t, sendVote(pid, 0), qt.ErrorMatches, ".*process .* is on question 1.*")
+	qt.Assert(t, sendVote(pid, 1), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	// each question has its own nullifier
+	for i := uint32(0); i < questionCount; i++ {
+		exists, err := app.State.EnvelopeExists(pid,
+			GenerateSerialNullifier(voter.Address(), pid, i), true)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, exists, qt.IsTrue)
+	}
+
+	// non serial processes do not take a question index
+	qt.Assert(t, sendVote(pid2, 1), qt.ErrorMatches, ".*only allowed on serial processes.*")
+}
+
+func testSetProcessQuestionIndex(t *testing.T, pid []byte, oracle *ethereum.SignKeys,
+	app *BaseApplication, questionIndex uint32) error {
+	var cktx abcitypes.RequestCheckTx
+	var detx abcitypes.RequestDeliverTx
+	var cktxresp abcitypes.ResponseCheckTx
+	var detxresp abcitypes.ResponseDeliverTx
+	var stx models.SignedTx
+	var err error
+
+	tx := &models.SetProcessTx{
+		Txtype:        models.TxType_SET_PROCESS_QUESTION_INDEX,
+		Nonce:         util.RandomBytes(32),
+		ProcessId:     pid,
+		QuestionIndex: &questionIndex,
+	}
+
+	if stx.Tx, err = proto.Marshal(&models.Tx{
+		Payload: &models.Tx_SetProcess{SetProcess: tx}}); err != nil {
+		t.Fatal(err)
+	}
+	if stx.Signature, err = oracle.SignVocdoniTx(stx.Tx); err != nil {
+		t.Fatal(err)
+	}
+	if cktx.Tx, err = proto.Marshal(&stx); err != nil {
+		t.Fatal(err)
+	}
+	cktxresp = app.CheckTx(cktx)
+	if cktxresp.Code != 0 {
+		return fmt.Errorf("checkTx failed: %s", cktxresp.Data)
+	}
+	if detx.Tx, err = proto.Marshal(&stx); err != nil {
+		t.Fatal(err)
+	}
+	detxresp = app.DeliverTx(detx)
+	if detxresp.Code != 0 {
+		return fmt.Errorf("deliverTx failed: %s", detxresp.Data)
+	}
+	app.Commit()
+	return nil
+}
+
 func TestCount(t *testing.T) {
 	app := TestBaseApplication(t)
 	count, err := app.State.CountProcesses(false)
@@ -497,3 +751,103 @@ func TestCount(t *testing.T) {
 	qt.Assert(t, err, qt.IsNil)
 	qt.Assert(t, count, qt.Equals, uint64(0))
 }
+
+func TestProcessCost(t *testing.T) {
+	app := TestBaseApplication(t)
+	entity := ethereum.SignKeys{}
+	qt.Assert(t, entity.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.SetAccount(BurnAddress, &Account{}), qt.IsNil)
+	qt.Assert(t, app.State.CreateAccount(entity.Address(), "ipfs://", nil, 0), qt.IsNil)
+	qt.Assert(t, app.State.MintBalance(entity.Address(), 50), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_NEW_PROCESS, 10), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_SET_PROCESS_STATUS, 5), qt.IsNil)
+
+	// the cost is charged per started census and block units
+	maxCensusSize := uint64(2500)
+	process := &models.Process{
+		ProcessId:     util.RandomBytes(types.ProcessIDsize),
+		EnvelopeType:  &models.EnvelopeType{},
+		Mode:          &models.ProcessMode{Interruptible: true},
+		VoteOptions:   &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+		Status:        models.ProcessStatus_READY,
+		EntityId:      entity.Address().Bytes(),
+		CensusRoot:    util.RandomBytes(32),
+		CensusURI:     new(string),
+		CensusOrigin:  models.CensusOrigin_OFF_CHAIN_TREE,
+		MaxCensusSize: &maxCensusSize,
+		BlockCount:    ProcessCostBlockUnit + 1,
+	}
+	cost, err := NewProcessCost(10, process)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, cost, qt.Equals, uint64(60))
+
+	sendTx := func(signer *ethereum.SignKeys, tx *models.Tx) error {
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(tx)
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return nil
+	}
+	newProcess := func(nonce uint32) error {
+		return sendTx(&entity, &models.Tx{Payload: &models.Tx_NewProcess{
+			NewProcess: &models.NewProcessTx{
+				Txtype:       models.TxType_NEW_PROCESS,
+				Nonce:        util.RandomBytes(32),
+				AccountNonce: nonce,
+				Process:      process,
+			}}})
+	}
+	setStatus := func(signer *ethereum.SignKeys, nonce uint32, status models.ProcessStatus) error {
+		return sendTx(signer, &models.Tx{Payload: &models.Tx_SetProcess{
+			SetProcess: &models.SetProcessTx{
+				Txtype:       models.TxType_SET_PROCESS_STATUS,
+				Nonce:        util.RandomBytes(32),
+				AccountNonce: nonce,
+				ProcessId:    process.ProcessId,
+				Status:       &status,
+			}}})
+	}
+	checkBalance := func(balance uint64, nonce uint32, burnt uint64) {
+		acc, err := app.State.GetAccount(entity.Address(), false)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, acc.Balance, qt.Equals, balance)
+		qt.Assert(t, acc.Nonce, qt.Equals, nonce)
+		burnAcc, err := app.State.GetAccount(BurnAddress, false)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, burnAcc.Balance, qt.Equals, burnt)
+	}
+
+	// the entity cannot afford the process
+	qt.Assert(t, newProcess(0), qt.ErrorMatches, ".*not enough balance.*balance is 50, cost is 60.*")
+	checkBalance(50, 0, 0)
+
+	qt.Assert(t, app.State.MintBalance(entity.Address(), 50), qt.IsNil)
+	qt.Assert(t, newProcess(1), qt.ErrorMatches, ".*invalid account nonce.*")
+	qt.Assert(t, newProcess(0), qt.IsNil)
+	checkBalance(40, 1, 60)
+	app.AdvanceTestBlock()
+
+	// the process updates are charged too, and cannot be replayed
+	qt.Assert(t, setStatus(&entity, 1, models.ProcessStatus_PAUSED), qt.IsNil)
+	checkBalance(35, 2, 65)
+	app.AdvanceTestBlock()
+	qt.Assert(t, setStatus(&entity, 1, models.ProcessStatus_PAUSED), qt.ErrorMatches,
+		".*invalid account nonce: expected 2 got 1.*")
+	checkBalance(35, 2, 65)
+
+	// oracles are not charged, and their nonces are ignored
+	oracle := ethereum.SignKeys{}
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.AddOracle(oracle.Address()), qt.IsNil)
+	qt.Assert(t, setStatus(&oracle, 0, models.ProcessStatus_READY), qt.IsNil)
+	checkBalance(35, 2, 65)
+}
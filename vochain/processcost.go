@@ -0,0 +1,115 @@
+package vochain
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// Process fees
+//
+// Entities pay the creation and the updates of their processes from their
+// accounts.  The NEW_PROCESS tx cost is charged once for each started
+// ProcessCostCensusUnit voters of the process MaxCensusSize and for each
+// started ProcessCostBlockUnit blocks of its duration, while the
+// SET_PROCESS_* tx costs are charged as they are.  The charged transactions
+// must have the account nonce in their AccountNonce, the fees are transferred
+// to BurnAddress and the account nonce is incremented.  Oracles are not
+// charged, as they manage processes on behalf of the entities, so their
+// AccountNonce is ignored; their transactions are made unique by the random
+// Nonce instead.
+
+const (
+	// ProcessCostCensusUnit is the number of voters of MaxCensusSize charged
+	// with the NEW_PROCESS tx cost.
+	ProcessCostCensusUnit = 1000
+	// ProcessCostBlockUnit is the number of blocks of duration charged with
+	// the NEW_PROCESS tx cost, about one day with DefaultBlockTime.
+	ProcessCostBlockUnit = 8640
+)
+
+// processTxCheckValues are the values returned by the process transactions
+// checks.
+type processTxCheckValues struct {
+	// Account is the account charged with the transaction, or the zero
+	// address if the transaction was sent by an oracle.
+	Account common.Address
+	Cost    uint64
+}
+
+// costUnits returns the number of started units of size unit in n, or one if
+// n is zero.
+func costUnits(n, unit uint64) uint64 {
+	units := n / unit
+	if n%unit != 0 || units == 0 {
+		units++
+	}
+	return units
+}
+
+// NewProcessCost returns the cost of creating the process p, given the
+// NEW_PROCESS tx cost.
+func NewProcessCost(txCost uint64, p *models.Process) (uint64, error) {
+	hi, cost := bits.Mul64(txCost, costUnits(p.GetMaxCensusSize(), ProcessCostCensusUnit))
+	if hi != 0 {
+		return 0, fmt.Errorf("process cost overflow")
+	}
+	hi, cost = bits.Mul64(cost, costUnits(uint64(p.BlockCount), ProcessCostBlockUnit))
+	if hi != 0 {
+		return 0, fmt.Errorf("process cost overflow")
+	}
+	return cost, nil
+}
+
+// processTxSender returns the address that signed a process transaction and
+// whether it is an oracle.
+func processTxSender(state *State, txBytes, signature []byte) (common.Address, bool, error) {
+	addr, err := ethereum.AddrFromSignature(txBytes, signature)
+	if err != nil {
+		return addr, false, err
+	}
+	oracles, err := state.Oracles(false)
+	if err != nil {
+		return addr, false, fmt.Errorf("cannot get oracles: %w", err)
+	}
+	for _, oracle := range oracles {
+		if oracle == addr {
+			return addr, true, nil
+		}
+	}
+	return addr, false, nil
+}
+
+// checkProcessTxAccount returns an error if the account does not exist, its
+// nonce is not nonce or its balance is lower than cost.
+func checkProcessTxAccount(state *State, address common.Address, nonce uint32, cost uint64) error {
+	acc, err := state.GetAccount(address, false)
+	if err != nil {
+		return fmt.Errorf("cannot get account %s: %w", address.Hex(), err)
+	}
+	if acc == nil {
+		return fmt.Errorf("%w: %s", ErrAccountNotExist, address.Hex())
+	}
+	if nonce != acc.Nonce {
+		return fmt.Errorf("%w: expected %d got %d", ErrAccountNonceInvalid, acc.Nonce, nonce)
+	}
+	if acc.Balance < cost {
+		return fmt.Errorf("%w: account %s balance is %d, cost is %d",
+			ErrNotEnoughBalance, address.Hex(), acc.Balance, cost)
+	}
+	return nil
+}
+
+// chargeProcessTx transfers the cost of a process transaction from the entity
+// account to BurnAddress and increments the account nonce.  Oracles
+// transactions are not charged.
+func (v *State) chargeProcessTx(values *processTxCheckValues) error {
+	if values.Account == types.EthereumZeroAddress {
+		return nil
+	}
+	return v.SubstractAmountIncrementNonce(values.Account, values.Cost)
+}
@@ -0,0 +1,180 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// pathProcessIDsByEndBlock is the db path used to store ProcessIDs indexed
+// by their end block (StartBlock + BlockCount).
+const pathProcessIDsByEndBlock = "pidByEndBlock"
+
+// keyProcessIDsByEndBlockIndexed is the db key set once the processes created
+// before the end block index existed have been indexed.
+var keyProcessIDsByEndBlockIndexed = []byte("pidByEndBlockIndexed")
+
+// keyProcessIDsByEndBlock returns the db key where ProcessesIDs with
+// endBlock are stored.
+func keyProcessIDsByEndBlock(endBlock uint32) []byte {
+	key := make([]byte, 4)
+	binary.LittleEndian.PutUint32(key, endBlock)
+	return []byte(path.Join(pathProcessIDsByEndBlock, string(key)))
+}
+
+// processIDsByEndBlock returns the ProcessIDs of processes with endBlock.
+func (v *State) processIDsByEndBlock(endBlock uint32) ([][]byte, error) {
+	pidsBytes, err := v.Tx.NoState().Get(keyProcessIDsByEndBlock(endBlock))
+	if errors.Is(err, db.ErrKeyNotFound) {
+		return [][]byte{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pids models.ProcessIdList
+	if err := proto.Unmarshal(pidsBytes, &pids); err != nil {
+		return nil, fmt.Errorf("cannot proto.Unmarshal pids: %w", err)
+	}
+	return pids.ProcessIds, nil
+}
+
+// setProcessIDsByEndBlock stores the ProcessIDs of processes with endBlock.
+func (v *State) setProcessIDsByEndBlock(endBlock uint32, pids [][]byte) error {
+	pidsBytes, err := proto.Marshal(&models.ProcessIdList{ProcessIds: pids})
+	if err != nil {
+		return err
+	}
+	return v.Tx.NoState().Set(keyProcessIDsByEndBlock(endBlock), pidsBytes)
+}
+
+// setProcessIDByEndBlock indexes the processID by its process endBlock.
+func (v *State) setProcessIDByEndBlock(processID []byte, endBlock uint32) error {
+	pids, err := v.processIDsByEndBlock(endBlock)
+	if err != nil {
+		return err
+	}
+	return v.setProcessIDsByEndBlock(endBlock, append(pids, processID))
+}
+
+// moveProcessIDByEndBlock moves the processID from the oldEndBlock index to the
+// newEndBlock one.
+func (v *State) moveProcessIDByEndBlock(processID []byte, oldEndBlock, newEndBlock uint32) error {
+	pids, err := v.processIDsByEndBlock(oldEndBlock)
+	if err != nil {
+		return err
+	}
+	for i, pid := range pids {
+		if bytes.Equal(pid, processID) {
+			pids = append(pids[:i], pids[i+1:]...)
+			break
+		}
+	}
+	if err := v.setProcessIDsByEndBlock(oldEndBlock, pids); err != nil {
+		return err
+	}
+	return v.setProcessIDByEndBlock(processID, newEndBlock)
+}
+
+// indexProcessesByEndBlock indexes the READY and PAUSED processes that are
+// not indexed by their end block yet, which were created before the index
+// existed, so they are ended once they finish.  The processes whose end block
+// is before height are indexed on height.  It only scans the processes once.
+// v.Tx must be locked.
+func (v *State) indexProcessesByEndBlock(height uint32) error {
+	if _, err := v.Tx.NoState().Get(keyProcessIDsByEndBlockIndexed); err == nil {
+		return nil
+	} else if !errors.Is(err, db.ErrKeyNotFound) {
+		return err
+	}
+	processesTree, err := v.Tx.SubTree(ProcessesCfg)
+	if err != nil {
+		return err
+	}
+	byEndBlock := make(map[uint32][][]byte)
+	var iterErr error
+	if err := processesTree.Iterate(func(key, value []byte) bool {
+		var sdbProc models.StateDBProcess
+		if err := proto.Unmarshal(value, &sdbProc); err != nil {
+			iterErr = fmt.Errorf("cannot unmarshal StateDBProcess: %w", err)
+			return true
+		}
+		p := sdbProc.Process
+		if p == nil || (p.Status != models.ProcessStatus_READY &&
+			p.Status != models.ProcessStatus_PAUSED) {
+			return false
+		}
+		endBlock := p.StartBlock + p.BlockCount
+		if endBlock < height {
+			endBlock = height
+		}
+		byEndBlock[endBlock] = append(byEndBlock[endBlock], append([]byte{}, key...))
+		return false
+	}); err != nil {
+		return err
+	}
+	if iterErr != nil {
+		return iterErr
+	}
+	count := 0
+	for endBlock, pids := range byEndBlock {
+		indexed, err := v.processIDsByEndBlock(endBlock)
+		if err != nil {
+			return err
+		}
+		for _, pid := range pids {
+			if !containsProcessID(indexed, pid) {
+				indexed = append(indexed, pid)
+				count++
+			}
+		}
+		if err := v.setProcessIDsByEndBlock(endBlock, indexed); err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		log.Infof("indexed %d processes by their end block", count)
+	}
+	return v.Tx.NoState().Set(keyProcessIDsByEndBlockIndexed, []byte{1})
+}
+
+func containsProcessID(pids [][]byte, pid []byte) bool {
+	for _, p := range pids {
+		if bytes.Equal(p, pid) {
+			return true
+		}
+	}
+	return false
+}
+
+// endProcesses sets the status of the READY processes from pids to ENDED,
+// scheduling the purge of their rolling census, and returns the ProcessIDs of the ended
+// processes.  The PAUSED processes are not ended, and are ended on the block
+// they are resumed instead.  v.Tx must be locked.
+func (v *State) endProcesses(pids [][]byte) ([][]byte, error) {
+	ended := [][]byte{}
+	for _, pid := range pids {
+		p, err := getProcess(v.Tx.AsTreeView(), pid)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get process %x: %w", pid, err)
+		}
+		if p.Status != models.ProcessStatus_READY {
+			continue
+		}
+		p.Status = models.ProcessStatus_ENDED
+		if err := updateProcess(&v.Tx, p, pid); err != nil {
+			return nil, err
+		}
+		// as when the process is ended with a transaction
+		if err := v.purgeRollingCensus(pid); err != nil {
+			return nil, err
+		}
+		ended = append(ended, pid)
+	}
+	return ended, nil
+}
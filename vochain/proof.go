@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 
+	"go.vocdoni.io/dvote/crypto/babyjubjub"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/saltedkey"
 	"go.vocdoni.io/dvote/log"
@@ -47,8 +48,14 @@ func VerifyProof(process *models.Process, proof *models.Proof,
 		verifyProof = VerifyProofOffChainCSP
 	case models.CensusOrigin_ERC20:
 		verifyProof = VerifyProofERC20
+	case models.CensusOrigin_ERC721:
+		verifyProof = VerifyProofERC721
+	case models.CensusOrigin_ERC1155:
+		verifyProof = VerifyProofERC1155
 	case models.CensusOrigin_MINI_ME:
 		verifyProof = VerifyProofMiniMe
+	case models.CensusOrigin_VOCHAIN_TOKENS:
+		verifyProof = VerifyProofVochainTokens
 	default:
 		return false, nil, fmt.Errorf("census origin not compatible")
 	}
@@ -71,8 +78,23 @@ func VerifyProofOffChainTree(process *models.Process, proof *models.Proof,
 	case *models.Proof_Graviton:
 		return false, nil, fmt.Errorf("graviton proof no longer supported")
 	case *models.Proof_Iden3:
-		// NOT IMPLEMENTED
-		return false, nil, fmt.Errorf("iden3 proof not implemented")
+		// the census is a Poseidon tree whose leaf keys are the BabyJubJub
+		// census keys of the voters (see babyjubjub.CensusKey) and whose
+		// values are all 1, so pubKey must be the signer BabyJubJub key
+		p := proof.GetIden3()
+		if p == nil {
+			return false, nil, fmt.Errorf("iden3 proof is empty")
+		}
+		censusKey, err := babyjubjub.CensusKey(pubKey)
+		if err != nil {
+			return false, nil, fmt.Errorf("cannot compute census key: %w", err)
+		}
+		hashFunc := arbo.HashFunctionPoseidon
+		valid, err := tree.VerifyProof(hashFunc,
+			arbo.BigIntToBytes(hashFunc.Len(), censusKey),
+			arbo.BigIntToBytes(hashFunc.Len(), bigOne),
+			p.Siblings, censusRoot)
+		return valid, bigOne, err
 	case *models.Proof_Arbo:
 		p := proof.GetArbo()
 		if p == nil {
@@ -103,6 +125,25 @@ func VerifyProofOffChainTree(process *models.Process, proof *models.Proof,
 	}
 }
 
+// VerifyProofRollingCensus verifies a proof of a key registered in the
+// non-anonymous rolling census of a pre-register process, being censusRoot the
+// process RollingCensusRoot.  The proof must be an arbo proof whose key is the
+// voter address and its value the registered weight.
+// Returns verification result and weight.
+func VerifyProofRollingCensus(process *models.Process, proof *models.Proof,
+	censusOrigin models.CensusOrigin,
+	censusRoot, processID, pubKey []byte, addr ethcommon.Address) (bool, *big.Int, error) {
+	p := proof.GetArbo()
+	if p == nil {
+		return false, nil, fmt.Errorf("rolling census proof must be an arbo proof")
+	}
+	valid, err := tree.VerifyProof(CensusCfg.HashFunc(), addr.Bytes(), p.Value, p.Siblings, censusRoot)
+	if err != nil || !valid {
+		return false, nil, err
+	}
+	return true, new(big.Int).SetBytes(p.Value), nil
+}
+
 // VerifyProofOffChainCA verifies a proof with census origin OFF_CHAIN_CA.
 // Returns verification result and weight.
 func VerifyProofOffChainCSP(process *models.Process, proof *models.Proof,
@@ -218,6 +259,183 @@ func VerifyProofERC20(process *models.Process, proof *models.Proof,
 	return err == nil, balance, err
 }
 
+// VerifyProofERC721 verifies a proof with census origin ERC721.  The proof
+// must contain a storage proof of the voter balance in the token balances
+// mapping (address => uint256), which has the same layout as the ERC20
+// mapbased tokens, and a storage proof of the voter being the owner of one of
+// its tokens in the token owners mapping (uint256 => address).  The weight is
+// the number of tokens owned by the voter.
+// Returns verification result and weight.
+func VerifyProofERC721(process *models.Process, proof *models.Proof,
+	censusOrigin models.CensusOrigin,
+	censusRoot, processID, pubKey []byte, addr ethcommon.Address) (bool, *big.Int, error) {
+	if process.EthIndexSlot == nil || process.EthOwnersIndexSlot == nil {
+		return false, nil, fmt.Errorf("index slots not found for process %x", process.ProcessId)
+	}
+	p := proof.GetErc721Storage()
+	if p == nil || p.Balance == nil || p.Owner == nil {
+		return false, nil, fmt.Errorf("erc721 proof is empty")
+	}
+
+	balance := new(big.Int).SetBytes(p.Balance.Value)
+	if balance.Cmp(bigZero) == 0 {
+		return false, nil, fmt.Errorf("balance at proof is 0")
+	}
+	tokenID := new(big.Int).SetBytes(p.TokenId)
+	log.Debugf("validating erc721 storage proofs for key %x, token %s and balance %v",
+		p.Balance.Key, tokenID, balance)
+	if err := mapbased.VerifyProof(addr, ethcommon.BytesToHash(censusRoot),
+		ethstorageproof.StorageResult{
+			Key:   p.Balance.Key,
+			Proof: p.Balance.Siblings,
+			Value: p.Balance.Value,
+		},
+		int(*process.EthIndexSlot),
+		balance,
+		nil); err != nil {
+		return false, nil, err
+	}
+	if err := VerifyERC721OwnerProof(addr, ethcommon.BytesToHash(censusRoot),
+		ethstorageproof.StorageResult{
+			Key:   p.Owner.Key,
+			Proof: p.Owner.Siblings,
+			Value: p.Owner.Value,
+		},
+		tokenID,
+		int(*process.EthOwnersIndexSlot)); err != nil {
+		return false, nil, err
+	}
+	return true, balance, nil
+}
+
+// ERC721OwnerSlot returns the storage key of the owner of the token tokenID,
+// being position the index slot of the ERC721 owners mapping
+// (uint256 => address).
+func ERC721OwnerSlot(tokenID *big.Int, position int) [32]byte {
+	return ethcrypto.Keccak256Hash(
+		ethcommon.LeftPadBytes(tokenID.Bytes(), 32),
+		ethcommon.LeftPadBytes(big.NewInt(int64(position)).Bytes(), 32),
+	)
+}
+
+// VerifyERC721OwnerProof verifies an ERC721 storage proof of owner being the
+// owner of the token tokenID against the contract storage root, being
+// indexSlot the index slot of the owners mapping.
+func VerifyERC721OwnerProof(owner ethcommon.Address, storageRoot ethcommon.Hash,
+	proof ethstorageproof.StorageResult, tokenID *big.Int, indexSlot int) error {
+	if proof.Value == nil {
+		return fmt.Errorf("value is nil")
+	}
+	keySlot := ERC721OwnerSlot(tokenID, indexSlot)
+	if !bytes.Equal(keySlot[:], proof.Key) {
+		return fmt.Errorf("proof key and leafData do not match (%x != %x)", keySlot, proof.Key)
+	}
+	if proofOwner := ethcommon.BytesToAddress(proof.Value); len(proof.Value) > 32 ||
+		proofOwner != owner {
+		return fmt.Errorf("token %s is not owned by %s", tokenID, owner.Hex())
+	}
+	valid, err := ethstorageproof.VerifyEthStorageProof(&proof, storageRoot)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("proof is not valid")
+	}
+	return nil
+}
+
+// VerifyProofERC1155 verifies a proof with census origin ERC1155.  The proof
+// must be a storage proof of the balance of the voter for the process token
+// (see ERC1155TokenID) in the token balances nested mapping
+// (uint256 => (address => uint256)).
+// Returns verification result and weight.
+func VerifyProofERC1155(process *models.Process, proof *models.Proof,
+	censusOrigin models.CensusOrigin,
+	censusRoot, processID, pubKey []byte, addr ethcommon.Address) (bool, *big.Int, error) {
+	if process.EthIndexSlot == nil {
+		return false, nil, fmt.Errorf("index slot not found for process %x", process.ProcessId)
+	}
+	tokenID, err := ERC1155TokenID(process)
+	if err != nil {
+		return false, nil, err
+	}
+	p := proof.GetEthereumStorage()
+	if p == nil {
+		return false, nil, fmt.Errorf("ethereum proof is empty")
+	}
+
+	balance := new(big.Int).SetBytes(p.Value)
+	if balance.Cmp(bigZero) == 0 {
+		return false, nil, fmt.Errorf("balance at proof is 0")
+	}
+	log.Debugf("validating erc1155 storage proof for key %x, token %s and balance %v",
+		p.Key, tokenID, balance)
+	err = VerifyERC1155StorageProof(addr, ethcommon.BytesToHash(censusRoot),
+		ethstorageproof.StorageResult{
+			Key:   p.Key,
+			Proof: p.Siblings,
+			Value: p.Value,
+		},
+		tokenID,
+		int(*process.EthIndexSlot),
+		balance)
+	return err == nil, balance, err
+}
+
+// ERC1155TokenID returns the token ID of an ERC1155 census process.
+func ERC1155TokenID(process *models.Process) (*big.Int, error) {
+	if process.TokenId == nil {
+		return nil, fmt.Errorf("token id not found for process %x", process.ProcessId)
+	}
+	if len(process.TokenId) > 32 {
+		return nil, fmt.Errorf("invalid token id %x for process %x",
+			process.TokenId, process.ProcessId)
+	}
+	return new(big.Int).SetBytes(process.TokenId), nil
+}
+
+// ERC1155BalanceSlot returns the storage key of the balance of holder for the
+// token tokenID, being position the index slot of the ERC1155 balances nested
+// mapping (uint256 => (address => uint256)).
+func ERC1155BalanceSlot(holder ethcommon.Address, tokenID *big.Int, position int) [32]byte {
+	tokenSlot := ethcrypto.Keccak256(
+		ethcommon.LeftPadBytes(tokenID.Bytes(), 32),
+		ethcommon.LeftPadBytes(big.NewInt(int64(position)).Bytes(), 32),
+	)
+	return ethcrypto.Keccak256Hash(ethcommon.LeftPadBytes(holder[:], 32), tokenSlot)
+}
+
+// VerifyERC1155StorageProof verifies an ERC1155 balance storage proof of
+// holder for the token tokenID against the contract storage root, being
+// indexSlot the index slot of the balances nested mapping.  The proof value
+// must match targetBalance.
+func VerifyERC1155StorageProof(holder ethcommon.Address, storageRoot ethcommon.Hash,
+	proof ethstorageproof.StorageResult, tokenID *big.Int, indexSlot int,
+	targetBalance *big.Int) error {
+	if proof.Value == nil {
+		return fmt.Errorf("value is nil")
+	}
+	if targetBalance == nil {
+		return fmt.Errorf("target balance is nil")
+	}
+	keySlot := ERC1155BalanceSlot(holder, tokenID, indexSlot)
+	if !bytes.Equal(keySlot[:], proof.Key) {
+		return fmt.Errorf("proof key and leafData do not match (%x != %x)", keySlot, proof.Key)
+	}
+	if proofBalance := new(big.Int).SetBytes(proof.Value); targetBalance.Cmp(proofBalance) != 0 {
+		return fmt.Errorf("proof balance and provided balance mismatch (%v != %v)",
+			proofBalance, targetBalance)
+	}
+	valid, err := ethstorageproof.VerifyEthStorageProof(&proof, storageRoot)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("proof is not valid")
+	}
+	return nil
+}
+
 // VerifyProofMiniMe verifies a proof with census origin MiniMe.
 // Returns verification result and weight.
 func VerifyProofMiniMe(process *models.Process, proof *models.Proof,
@@ -259,3 +477,52 @@ func VerifyProofMiniMe(process *models.Process, proof *models.Proof,
 		new(big.Int).SetUint64(*process.SourceBlockHeight))
 	return err == nil, proof0Balance, err
 }
+
+// VerifyProofVochainTokens verifies a proof with census origin
+// VOCHAIN_TOKENS.  The proof must be a Vochain account proof of the marshaled
+// Account in the Accounts tree, being censusRoot the Accounts tree root.  The vote can be signed by the account
+// owner or by any of its delegates, being addr the account address (see
+// AccountsCensusVoter) and pubKey the public key of the signer.
+// Returns verification result and the account balance as weight.
+func VerifyProofVochainTokens(process *models.Process, proof *models.Proof,
+	censusOrigin models.CensusOrigin,
+	censusRoot, processID, pubKey []byte, addr ethcommon.Address) (bool, *big.Int, error) {
+	p := proof.GetVochainAccount()
+	if p == nil || p.Account == nil {
+		return false, nil, fmt.Errorf("accounts census proof is empty")
+	}
+	if !bytes.Equal(p.Address, addr.Bytes()) {
+		return false, nil, fmt.Errorf("proof address and account do not match (%x != %x)",
+			p.Address, addr)
+	}
+	valid, err := tree.VerifyProof(AccountsCfg.HashFunc(), p.Address, p.Account, p.Siblings, censusRoot)
+	if err != nil || !valid {
+		return false, nil, err
+	}
+	var acc Account
+	if err := acc.Unmarshal(p.Account); err != nil {
+		return false, nil, fmt.Errorf("cannot unmarshal account: %w", err)
+	}
+	signer, err := ethereum.AddrFromPublicKey(pubKey)
+	if err != nil {
+		return false, nil, fmt.Errorf("cannot extract address from public key: %w", err)
+	}
+	if signer != addr && !acc.IsDelegate(signer) {
+		return false, nil, fmt.Errorf("%s is not a delegate of account %s", signer.Hex(), addr.Hex())
+	}
+	if acc.Balance == 0 {
+		return false, nil, fmt.Errorf("account %s has no balance", addr.Hex())
+	}
+	return true, new(big.Int).SetUint64(acc.Balance), nil
+}
+
+// AccountsCensusVoter returns the address of the account proved by an
+// accounts census proof (see VerifyProofVochainTokens), on whose behalf the
+// vote is cast.
+func AccountsCensusVoter(proof *models.Proof) (ethcommon.Address, error) {
+	p := proof.GetVochainAccount()
+	if p == nil || len(p.Address) != ethcommon.AddressLength {
+		return ethcommon.Address{}, fmt.Errorf("accounts census proof must contain an address")
+	}
+	return ethcommon.BytesToAddress(p.Address), nil
+}
@@ -57,6 +57,20 @@ func TestEthProof(t *testing.T) {
 }
 
 func testEthSendVotes(t *testing.T, s testStorageProof,
+	pid []byte, vp []byte, app *BaseApplication, expectedResult bool) {
+	t.Logf("voting %x", s.StorageProof.Key)
+	proof := &models.Proof{Payload: &models.Proof_EthereumStorage{
+		EthereumStorage: &models.ProofEthereumStorage{
+			Key:      s.StorageProof.Key,
+			Value:    s.StorageProof.Value,
+			Siblings: s.StorageProof.Proof,
+		},
+	}}
+	testEthSendVote(t, s.Address, proof, pid, vp, app, expectedResult)
+}
+
+// testEthSendVote sends a vote with proof, signed by the holder address.
+func testEthSendVote(t *testing.T, address string, proof *models.Proof,
 	pid []byte, vp []byte, app *BaseApplication, expectedResult bool) {
 	var cktx abcitypes.RequestCheckTx
 	var detx abcitypes.RequestDeliverTx
@@ -66,16 +80,10 @@ func testEthSendVotes(t *testing.T, s testStorageProof,
 
 	var stx models.SignedTx
 
-	t.Logf("voting %x", s.StorageProof.Key)
-	proof := models.ProofEthereumStorage{
-		Key:      s.StorageProof.Key,
-		Value:    s.StorageProof.Value,
-		Siblings: s.StorageProof.Proof,
-	}
 	tx := &models.VoteEnvelope{
 		Nonce:       util.RandomBytes(32),
 		ProcessId:   pid,
-		Proof:       &models.Proof{Payload: &models.Proof_EthereumStorage{EthereumStorage: &proof}},
+		Proof:       proof,
 		VotePackage: vp,
 	}
 
@@ -86,14 +94,14 @@ func testEthSendVotes(t *testing.T, s testStorageProof,
 		if err := sk.AddHexKey(key); err != nil {
 			t.Fatal(err)
 		}
-		if util.TrimHex(sk.Address().Hex()) == util.TrimHex(s.Address) {
+		if util.TrimHex(sk.Address().Hex()) == util.TrimHex(address) {
 			signer = sk
 			found = true
 			break
 		}
 	}
 	if !found {
-		t.Fatalf("signer for address %s not found", s.Address)
+		t.Fatalf("signer for address %s not found", address)
 	}
 	pub, _ := signer.HexString()
 	t.Logf("addr: %s pubKey: %s", signer.Address(), pub)
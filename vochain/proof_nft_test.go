@@ -0,0 +1,339 @@
+package vochain
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/storage-proofs-eth-go/ethstorageproof"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestERC721Proof(t *testing.T) {
+	app := TestBaseApplication(t)
+	sp := testERC721Proofs{}
+	qt.Assert(t, json.Unmarshal([]byte(erc721VotingProofs), &sp), qt.IsNil)
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:          pid,
+		StartBlock:         0,
+		EnvelopeType:       &models.EnvelopeType{},
+		Mode:               new(models.ProcessMode),
+		Status:             models.ProcessStatus_READY,
+		EntityId:           util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:         testERC721StorageRoot,
+		CensusOrigin:       models.CensusOrigin_ERC721,
+		BlockCount:         1024,
+		EthIndexSlot:       &testERC721IndexSlot,
+		EthOwnersIndexSlot: &testERC721OwnersIndexSlot,
+	}), qt.IsNil)
+
+	vp := []byte("[1,2,3,4]")
+
+	// Test wrong vote (change the number of tokens)
+	wrongSp := sp.StorageProofs[0]
+	wrongSp.Balance.Value = sp.StorageProofs[1].Balance.Value
+	testERC721SendVote(t, wrongSp, pid, vp, app, false)
+
+	// Test wrong vote (the token is owned by another holder)
+	wrongSp = sp.StorageProofs[0]
+	wrongSp.TokenID, wrongSp.Owner = sp.StorageProofs[1].TokenID, sp.StorageProofs[1].Owner
+	testERC721SendVote(t, wrongSp, pid, vp, app, false)
+
+	// Test wrong vote (no owner proof)
+	wrongSp = sp.StorageProofs[0]
+	wrongSp.Owner = ethstorageproof.StorageResult{}
+	testERC721SendVote(t, wrongSp, pid, vp, app, false)
+
+	// Test valid votes
+	for _, s := range sp.StorageProofs {
+		testERC721SendVote(t, s, pid, vp, app, true)
+	}
+
+	// Test double vote
+	testERC721SendVote(t, sp.StorageProofs[2], pid, vp, app, false)
+}
+
+// testERC721SendVote sends a vote with the ERC721 storage proofs of s.
+func testERC721SendVote(t *testing.T, s testERC721Proof,
+	pid []byte, vp []byte, app *BaseApplication, expectedResult bool) {
+	proof := &models.Proof{Payload: &models.Proof_Erc721Storage{
+		Erc721Storage: &models.ProofERC721{
+			Balance: &models.ProofEthereumStorage{
+				Key:      s.Balance.Key,
+				Value:    s.Balance.Value,
+				Siblings: s.Balance.Proof,
+			},
+			Owner: &models.ProofEthereumStorage{
+				Key:      s.Owner.Key,
+				Value:    s.Owner.Value,
+				Siblings: s.Owner.Proof,
+			},
+			TokenId: s.TokenID,
+		},
+	}}
+	testEthSendVote(t, s.Address, proof, pid, vp, app, expectedResult)
+}
+
+type testERC721Proofs struct {
+	StorageProofs []testERC721Proof `json:"storageProofs"`
+}
+
+type testERC721Proof struct {
+	Address string                        `json:"address"`
+	TokenID types.HexBytes                `json:"tokenId"`
+	Balance ethstorageproof.StorageResult `json:"balanceProof"`
+	Owner   ethstorageproof.StorageResult `json:"ownerProof"`
+}
+
+func TestERC1155Proof(t *testing.T) {
+	app := TestBaseApplication(t)
+	sp := testStorageProofs{}
+	qt.Assert(t, json.Unmarshal([]byte(erc1155VotingProofs), &sp), qt.IsNil)
+
+	addProcess := func(tokenID int64) []byte {
+		pid := util.RandomBytes(types.ProcessIDsize)
+		qt.Assert(t, app.State.AddProcess(&models.Process{
+			ProcessId:    pid,
+			StartBlock:   0,
+			EnvelopeType: &models.EnvelopeType{},
+			Mode:         new(models.ProcessMode),
+			Status:       models.ProcessStatus_READY,
+			EntityId:     util.RandomBytes(types.EthereumAddressSize),
+			CensusRoot:   testERC1155StorageRoot,
+			TokenId:      big.NewInt(tokenID).Bytes(),
+			CensusOrigin: models.CensusOrigin_ERC1155,
+			BlockCount:   1024,
+			EthIndexSlot: &testERC1155IndexSlot,
+		}), qt.IsNil)
+		return pid
+	}
+	pid := addProcess(testERC1155TokenID)
+	process, err := app.State.Process(pid, false)
+	qt.Assert(t, err, qt.IsNil)
+	tokenID, err := ERC1155TokenID(process)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tokenID.Cmp(big.NewInt(7)), qt.Equals, 0)
+
+	vp := []byte("[1,2,3,4]")
+
+	// Test wrong vote (change the balance)
+	wrongSp := sp.StorageProofs[0]
+	wrongSp.StorageProof.Value = sp.StorageProofs[1].StorageProof.Value
+	testEthSendVotes(t, wrongSp, pid, vp, app, false)
+
+	// Test wrong vote (proofs are for another token)
+	otherPid := addProcess(8)
+	testEthSendVotes(t, sp.StorageProofs[0], otherPid, vp, app, false)
+
+	// Test valid votes
+	for _, s := range sp.StorageProofs {
+		testEthSendVotes(t, s, pid, vp, app, true)
+	}
+
+	// Test double vote
+	testEthSendVotes(t, sp.StorageProofs[2], pid, vp, app, false)
+}
+
+var (
+	testERC721IndexSlot       = uint32(3)
+	testERC721OwnersIndexSlot = uint32(2)
+	testERC721StorageRoot     = testutil.Hex2byte(nil,
+		"0x06099753ff2faa0c77a88da222c20a13e29c15afc3d974c878364b4b93e9dde5")
+
+	testERC1155IndexSlot   = uint32(0)
+	testERC1155TokenID     = int64(7)
+	testERC1155StorageRoot = testutil.Hex2byte(nil,
+		"0xf4de1acce599a96a78ce2d15998c8b670cbb0255051014d53fac147c9845c83c")
+)
+
+// erc721VotingProofs are storage proofs of an ERC721 contract, whose owners
+// mapping is at index slot 2 and balances mapping at index slot 3, of the
+// balance and the first owned token of the testSmartContractHolders holders.
+var erc721VotingProofs = string(
+	`
+  {
+    "storageProofs": [
+      {
+        "address": "0xe101391adF348Cd80bb71b97306f3CdDd5d34586",
+        "tokenId": "0x01",
+        "balanceProof": {
+          "key": "0xff06b98d5d01ddf166cbf31a709e3a36a835804bd0b5227dfeaea7900e440547",
+          "value": "0x1",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf8518080808080808080a072f882830dcb7c6b092dd08ac1115e4216ff72b4489bf48550d02f14d51d95c180808080a0a7af2e7b41066c80772f408dd324f496259ea7aa8150107f2caa41217292ddd8808080",
+            "0xe2a020168d84d974ba6c8cd3734a131d75f143b78d82f97d6c75acd3efd0951ebc5e01"
+          ]
+        },
+        "ownerProof": {
+          "key": "0xe90b7bceb6e7df5418fb78d8ee546e97c83a08bbccc01a0644d599ccd2a7c2e0",
+          "value": "0xe101391adf348cd80bb71b97306f3cddd5d34586",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf87180808080a01361fcf781e4723309fc3295b378e25197e245b1c472fbf6b201a83b97b1545480a04c3695fcd964a8b0d0194137d1d6dc784c34db8766b5bc3636d3acc04028a6758080808080808080a0c30047ec3693d0a8cd3c8749a3207ddd73f9249748c1f96d3762384e2c0c23d780",
+            "0xf7a020ef4bf8f63cf9dd467136c679c02b5c17fcf6322d9562512bf5eb952cf7cc539594e101391adf348cd80bb71b97306f3cddd5d34586"
+          ]
+        }
+      },
+      {
+        "address": "0xCfAE1df2458D2B62640E813037CcDfd91c6333e3",
+        "tokenId": "0x02",
+        "balanceProof": {
+          "key": "0x49f1318f025db9bb04f557bc9b511680864fe4514330c237540cdb7f7036f1ae",
+          "value": "0x2",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf85180a0a864476b203677609dae1e2d8fcfd4471be0f2bc882083e660b4b690324e656b808080808080808080a0bf659a12fe1d027d9df0c1612069a2ea03a2d663ffeaae2073275bca8fc65ea48080808080",
+            "0xe2a0206de4f2160867778001a7aaed18ddaea01417b58387717a26b4179d888c57b002"
+          ]
+        },
+        "ownerProof": {
+          "key": "0x679795a0195a1b76cdebb7c51d74e058aee92919b8c3389af86ef24535e8a28c",
+          "value": "0xcfae1df2458d2b62640e813037ccdfd91c6333e3",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf7a0370731c4fc4bf9cd8fc2be4d898bd67fd357eb0135035bf4500364b4c42c4fa59594cfae1df2458d2b62640e813037ccdfd91c6333e3"
+          ]
+        }
+      },
+      {
+        "address": "0x22C0608a1f9858c2335C2BD94364c680DdB268bB",
+        "tokenId": "0x04",
+        "balanceProof": {
+          "key": "0xf1db02af1a9ef040f83209c9d51e849cbfe19dce3c6144bc8e2495c2d77a2e0d",
+          "value": "0x3",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xe2a03fb63657807adc44f8cefb6e52663eb76cd9e23590f0edb19341690e44aee40003"
+          ]
+        },
+        "ownerProof": {
+          "key": "0xee60d0579bcffd98e668647d59fec1ff86a7fb340ce572e844f234ae73a6918f",
+          "value": "0x22c0608a1f9858c2335c2bd94364c680ddb268bb",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf7a03502d3b6b12d4463d21f1670be290f261bc491d2d9b9e976b22f9b055a686fc3959422c0608a1f9858c2335c2bd94364c680ddb268bb"
+          ]
+        }
+      },
+      {
+        "address": "0x4729175D62fAFF7A0C695B38b66D2E60806E595F",
+        "tokenId": "0x07",
+        "balanceProof": {
+          "key": "0xf8dcd7399b3c748fd09f6bba63976d4e02b45f7df478f2684c0b41894db6d002",
+          "value": "0x4",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf8718080a08a762396108a066e5b89aa554ecd70bafaba0da6fc490f64f530c688e0f5c662808080a023230d0cf53b8f77c85cb2f439b88ef80ad330195c4261dc06ee65f9cb2d23588080a0f66697ca0f36b4215c38748b6db225d426e5f9b50fd6208fc4591d867384142680808080808080",
+            "0xe2a020cbf32751b389723b74acb38e62fbf6c7b4faddf57d8b8bed2ab7e8d18fa04d04"
+          ]
+        },
+        "ownerProof": {
+          "key": "0xacd8ef244210bb6898e73c48bf820ed8ecc857a3bab8d79c10e4fa92b1e9ca65",
+          "value": "0x4729175d62faff7a0c695b38b66d2e60806e595f",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf87180808080a01361fcf781e4723309fc3295b378e25197e245b1c472fbf6b201a83b97b1545480a04c3695fcd964a8b0d0194137d1d6dc784c34db8766b5bc3636d3acc04028a6758080808080808080a0c30047ec3693d0a8cd3c8749a3207ddd73f9249748c1f96d3762384e2c0c23d780",
+            "0xf7a02013dffd80ba7541d4ea05a3202a3f515d93c4b983db32843fbe26e885c3d90095944729175d62faff7a0c695b38b66d2e60806e595f"
+          ]
+        }
+      },
+      {
+        "address": "0x7115f6C5c40e4a8B5D17Fb2d77662BD45b868637",
+        "tokenId": "0x0b",
+        "balanceProof": {
+          "key": "0xa8afb3f20ff08dc4b15691630261991490b0b61d45382e4966f21357fd565f6b",
+          "value": "0x5",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf8518080808080a03a4fb170a671f91a12dea86680dfc379d19d1d1acf8cce8096557effa030d5118080808080808080a098a2d3794cd078a36f8185fa774b7fd6fa5042c8ca278ddb998538d672b090f58080",
+            "0xe2a0209c0f1f7f1caef751bc182315381d7cb884d1b863faed3e31be5d55435124c205"
+          ]
+        },
+        "ownerProof": {
+          "key": "0xab9952baf6478d8cfb7253ce86a6c53a7b7549582c76210b1581ae682b7e556f",
+          "value": "0x7115f6c5c40e4a8b5d17fb2d77662bd45b868637",
+          "proof": [
+            "0xf9017180a0853e1cf9ed384e9993f238e674a24d50b1e626be168e917ae33a4a3e9a221664a09a314899c520ede8d0b37d07afaacc238073d7ad0a3f0e05e7494a558e539fd7a0c05cd24a50efb74d23fa90826ff3378e45602bfb1446ee0d6202f6dc1ea61ba580a07c6b2859902d9f0cffd631065f1501c69f235d310ac4033b741af8f597353910a086a405ec76df01739f56985de2142d80f47f3f34ba869b45026f0ec7c98a6180a06d6e298848032636de1f056e2030ca330427f597c0e254391add21b02fcacbe8a04822f13687ff46ad289f1de5a1e085cbe0ee7959fa9dc879dd770b1b67eee00f80a0ced1b74b421b91f324dfc78594e2a13b21805d50bb235dcdb283a18c64c0798c8080a0be89418d038b97140f362ce6eb5dd7c62bb724e4c79ed4a52e81b456840c818fa02ce1ff4b6f27189ae2b458eaa79ff7b267aaafed1b782cc73fd313a2f2826cd2a03da13a382beb8da35e984c249d967eef164e7e54456018ff08b6bd41034ed51680",
+            "0xf8718080a08a762396108a066e5b89aa554ecd70bafaba0da6fc490f64f530c688e0f5c662808080a023230d0cf53b8f77c85cb2f439b88ef80ad330195c4261dc06ee65f9cb2d23588080a0f66697ca0f36b4215c38748b6db225d426e5f9b50fd6208fc4591d867384142680808080808080",
+            "0xf7a02092dab2820266ca9664ab42ef2fc3bfb330178173a94c5b0333385663d1b8c195947115f6c5c40e4a8b5d17fb2d77662bd45b868637"
+          ]
+        }
+      }
+    ]
+  }
+  `)
+
+// erc1155VotingProofs are storage proofs of an ERC1155 contract, whose
+// balances nested mapping is at index slot 0, of the token 7 balance of the
+// testSmartContractHolders holders.
+var erc1155VotingProofs = string(
+	`
+  {
+    "storageProofs": [
+      {
+        "address": "0xe101391adF348Cd80bb71b97306f3CdDd5d34586",
+        "storageProof": {
+          "key": "0x6a708f59091dcea3d061bc606d8d0da5be01725f859bb22d22e9db9606035452",
+          "value": "0xa",
+          "proof": [
+            "0xf8f180a09342b36e97a89d951d55efa2df586a1c853ef34688e77792fb097c9cc4ea4d6180a052f70873dc6c0d62be3e66f752bb8efec8404222c7a0b107f4ec74df656afe3fa0ddedd46c1b8bc378fec77440672e1496f2716597537ca6f5b85c5a565dedddad80808080a0fe4c43a8ea4a8948e698aed7840d2c3c678e6260055591bf1b6d169743e5b2d0a02126ec569689cfdc887fdfd032debb76435b0179f63f3ad18040cf31be5aee25a04c2b4bd9f02208ab5f52fadd54cf014e1fbe982b13c7acf14f12188873bf177480a06ae6c3a6443af37c09405a28b5f02a4531e1d34824748f679d69992bcc8b301b808080",
+            "0xe2a03a92a2fef56436371f128235a6b52b9ec04585be2f65debc3f3611488b10a2e70a"
+          ]
+        }
+      },
+      {
+        "address": "0x22C0608a1f9858c2335C2BD94364c680DdB268bB",
+        "storageProof": {
+          "key": "0x07bcf41af1b2cb0896588231315bb504b021ff7707633b9052cbfa0800374215",
+          "value": "0x14",
+          "proof": [
+            "0xf8f180a09342b36e97a89d951d55efa2df586a1c853ef34688e77792fb097c9cc4ea4d6180a052f70873dc6c0d62be3e66f752bb8efec8404222c7a0b107f4ec74df656afe3fa0ddedd46c1b8bc378fec77440672e1496f2716597537ca6f5b85c5a565dedddad80808080a0fe4c43a8ea4a8948e698aed7840d2c3c678e6260055591bf1b6d169743e5b2d0a02126ec569689cfdc887fdfd032debb76435b0179f63f3ad18040cf31be5aee25a04c2b4bd9f02208ab5f52fadd54cf014e1fbe982b13c7acf14f12188873bf177480a06ae6c3a6443af37c09405a28b5f02a4531e1d34824748f679d69992bcc8b301b808080",
+            "0xf851808080808080808080808080a06d112e8421011a6c59dc823528524a0a496f7578f3878a740a01393d215638998080a01c89f53e0d1937ba122eadcd59516c8e33121103c77e5f146c79a7df28fff93d80",
+            "0xe2a020e7c1b784f75176f1cbcde62f1c36e56185449a67071443262450f3a499859e14"
+          ]
+        }
+      },
+      {
+        "address": "0xCfAE1df2458D2B62640E813037CcDfd91c6333e3",
+        "storageProof": {
+          "key": "0x5ea489f01c157985493016948fd739112d5643a9ddf2cbd2340dcc67c676496a",
+          "value": "0x1e",
+          "proof": [
+            "0xf8f180a09342b36e97a89d951d55efa2df586a1c853ef34688e77792fb097c9cc4ea4d6180a052f70873dc6c0d62be3e66f752bb8efec8404222c7a0b107f4ec74df656afe3fa0ddedd46c1b8bc378fec77440672e1496f2716597537ca6f5b85c5a565dedddad80808080a0fe4c43a8ea4a8948e698aed7840d2c3c678e6260055591bf1b6d169743e5b2d0a02126ec569689cfdc887fdfd032debb76435b0179f63f3ad18040cf31be5aee25a04c2b4bd9f02208ab5f52fadd54cf014e1fbe982b13c7acf14f12188873bf177480a06ae6c3a6443af37c09405a28b5f02a4531e1d34824748f679d69992bcc8b301b808080",
+            "0xf851808080a03e601b60f11d7a87ce9790a144259d854c195bdcf627ed06c28a26e7b3a3bc28808080808080a03e33426270ee5753793d5cd6b57e018c407f5a97154b5eb4acf6a80aed20dbac808080808080",
+            "0xe2a0201c6cf7a00654888b536d90d64ce65a449c718e365611547a513eae249e397f1e"
+          ]
+        }
+      },
+      {
+        "address": "0x4729175D62fAFF7A0C695B38b66D2E60806E595F",
+        "storageProof": {
+          "key": "0xbb5d74856ceb353365ef8ace135096c37f66f0a65189a74ff93b34709d0769ca",
+          "value": "0x28",
+          "proof": [
+            "0xf8f180a09342b36e97a89d951d55efa2df586a1c853ef34688e77792fb097c9cc4ea4d6180a052f70873dc6c0d62be3e66f752bb8efec8404222c7a0b107f4ec74df656afe3fa0ddedd46c1b8bc378fec77440672e1496f2716597537ca6f5b85c5a565dedddad80808080a0fe4c43a8ea4a8948e698aed7840d2c3c678e6260055591bf1b6d169743e5b2d0a02126ec569689cfdc887fdfd032debb76435b0179f63f3ad18040cf31be5aee25a04c2b4bd9f02208ab5f52fadd54cf014e1fbe982b13c7acf14f12188873bf177480a06ae6c3a6443af37c09405a28b5f02a4531e1d34824748f679d69992bcc8b301b808080",
+            "0xe2a0369594b914810e3970f1f78d6d333ce209539a524d758bf932969dcfd3d8f15f28"
+          ]
+        }
+      },
+      {
+        "address": "0x7115f6C5c40e4a8B5D17Fb2d77662BD45b868637",
+        "storageProof": {
+          "key": "0x476a152876f2b51e2e0d28dc9001b913d11e5387faf806fda90b7d95a7456247",
+          "value": "0x32",
+          "proof": [
+            "0xf8f180a09342b36e97a89d951d55efa2df586a1c853ef34688e77792fb097c9cc4ea4d6180a052f70873dc6c0d62be3e66f752bb8efec8404222c7a0b107f4ec74df656afe3fa0ddedd46c1b8bc378fec77440672e1496f2716597537ca6f5b85c5a565dedddad80808080a0fe4c43a8ea4a8948e698aed7840d2c3c678e6260055591bf1b6d169743e5b2d0a02126ec569689cfdc887fdfd032debb76435b0179f63f3ad18040cf31be5aee25a04c2b4bd9f02208ab5f52fadd54cf014e1fbe982b13c7acf14f12188873bf177480a06ae6c3a6443af37c09405a28b5f02a4531e1d34824748f679d69992bcc8b301b808080",
+            "0xe2a03dd1ddcd15bfad26e2fc25ceea5602f0b72daa80a14820f76411ac311723959b32"
+          ]
+        }
+      }
+    ]
+  }
+  `)
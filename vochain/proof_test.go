@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"testing"
 
+	qt "github.com/frankban/quicktest"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
 	"go.vocdoni.io/dvote/censustree"
+	"go.vocdoni.io/dvote/crypto/babyjubjub"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/db/metadb"
 	"go.vocdoni.io/dvote/log"
@@ -411,3 +413,309 @@ func testCASendVotes(t *testing.T, pid []byte, vp []byte, signer *ethereum.SignK
 	}
 	app.Commit()
 }
+
+func TestRollingCensusProof(t *testing.T) {
+	app := TestBaseApplication(t)
+
+	db := metadb.NewTest(t)
+	tr, err := censustree.New(censustree.Options{Name: "testrollingcensus", ParentDB: db,
+		MaxLevels: 256, CensusType: models.Census_ARBO_BLAKE2B})
+	qt.Assert(t, err, qt.IsNil)
+	voter := ethereum.NewSignKeys()
+	qt.Assert(t, voter.Generate(), qt.IsNil)
+	voterKey, err := tr.Hash(voter.PublicKey())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tr.Add(voterKey, nil), qt.IsNil)
+	root, err := tr.Root()
+	qt.Assert(t, err, qt.IsNil)
+
+	// Add a non-anonymous pre-register process
+	censusURI := ipfsUrl
+	maxCensusSize := uint64(10)
+	pid := util.RandomBytes(types.ProcessIDsize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:     pid,
+		StartBlock:    app.Height() + 2,
+		EnvelopeType:  &models.EnvelopeType{},
+		Mode:          &models.ProcessMode{PreRegister: true},
+		Status:        models.ProcessStatus_READY,
+		EntityId:      util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:    root,
+		CensusURI:     &censusURI,
+		CensusOrigin:  models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:    1024,
+		MaxCensusSize: &maxCensusSize,
+	}), qt.IsNil)
+
+	sendTx := func(signer *ethereum.SignKeys, tx *models.Tx) error {
+		var stx models.SignedTx
+		var err error
+		stx.Tx, err = proto.Marshal(tx)
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return nil
+	}
+	registerKey := func(newKey *ethereum.SignKeys) error {
+		_, siblings, err := tr.GenProof(voterKey)
+		qt.Assert(t, err, qt.IsNil)
+		return sendTx(voter, &models.Tx{Payload: &models.Tx_RegisterKey{
+			RegisterKey: &models.RegisterKeyTx{
+				Nonce:     util.RandomBytes(32),
+				ProcessId: pid,
+				Proof: &models.Proof{Payload: &models.Proof_Arbo{
+					Arbo: &models.ProofArbo{
+						Type:     models.ProofArbo_BLAKE2B,
+						Siblings: siblings,
+					},
+				}},
+				NewKey: newKey.Address().Bytes(),
+				Weight: "1",
+			}}})
+	}
+	vote := func(signer *ethereum.SignKeys, value, siblings []byte) error {
+		return sendTx(signer, &models.Tx{Payload: &models.Tx_Vote{
+			Vote: &models.VoteEnvelope{
+				Nonce:     util.RandomBytes(32),
+				ProcessId: pid,
+				Proof: &models.Proof{Payload: &models.Proof_Arbo{
+					Arbo: &models.ProofArbo{Siblings: siblings, Value: value},
+				}},
+				VotePackage: []byte("[1,2,3]"),
+			}}})
+	}
+
+	// Registration phase: the voter registers a new key only once
+	newKey := ethereum.NewSignKeys()
+	qt.Assert(t, newKey.Generate(), qt.IsNil)
+	newKey2 := ethereum.NewSignKeys()
+	qt.Assert(t, newKey2.Generate(), qt.IsNil)
+	qt.Assert(t, registerKey(newKey), qt.IsNil)
+	app.AdvanceTestBlock()
+	qt.Assert(t, registerKey(newKey), qt.Not(qt.IsNil))
+	qt.Assert(t, registerKey(newKey2), qt.Not(qt.IsNil))
+	size, err := app.State.GetRollingCensusSize(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, size, qt.Equals, uint64(1))
+	app.AdvanceTestBlock()
+
+	// Voting phase: the registration is closed and only the registered key
+	// can vote with a proof of the rolling census
+	qt.Assert(t, registerKey(newKey2), qt.Not(qt.IsNil))
+	value, siblings, err := app.State.RollingCensusProof(pid, newKey.Address().Bytes(), true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, vote(voter, value, siblings), qt.Not(qt.IsNil))
+	qt.Assert(t, vote(newKey2, value, siblings), qt.Not(qt.IsNil))
+	qt.Assert(t, vote(newKey, value, siblings), qt.IsNil)
+	qt.Assert(t, vote(newKey, value, siblings), qt.Not(qt.IsNil))
+}
+
+func TestIden3Proof(t *testing.T) {
+	app := TestBaseApplication(t)
+
+	db := metadb.NewTest(t)
+	tr, err := censustree.New(censustree.Options{Name: "testiden3", ParentDB: db,
+		MaxLevels: 256, CensusType: models.Census_ARBO_POSEIDON})
+	qt.Assert(t, err, qt.IsNil)
+	keys := make([]*babyjubjub.SignKeys, 10)
+	censusKeys := make([][]byte, len(keys))
+	for i := range keys {
+		keys[i] = babyjubjub.NewSignKeys()
+		qt.Assert(t, keys[i].Generate(), qt.IsNil)
+		keys[i].VocdoniChainID = app.ChainID()
+		k, err := babyjubjub.CensusKey(keys[i].PublicKey())
+		qt.Assert(t, err, qt.IsNil)
+		censusKeys[i] = tr.BigIntToBytes(k)
+		qt.Assert(t, tr.Add(censusKeys[i], tr.BigIntToBytes(bigOne)), qt.IsNil)
+	}
+	root, err := tr.Root()
+	qt.Assert(t, err, qt.IsNil)
+
+	censusURI := ipfsUrl
+	pid := util.RandomBytes(types.ProcessIDsize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		StartBlock:   0,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:   root,
+		CensusURI:    &censusURI,
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}), qt.IsNil)
+
+	vote := func(signer *babyjubjub.SignKeys, siblings []byte) ([]byte, error) {
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{
+			Vote: &models.VoteEnvelope{
+				Nonce:     util.RandomBytes(32),
+				ProcessId: pid,
+				Proof: &models.Proof{Payload: &models.Proof_Iden3{
+					Iden3: &models.ProofIden3{Siblings: siblings},
+				}},
+				VotePackage: []byte("[1,2,3]"),
+			}}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return nil, fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes})
+		if resp.Code != 0 {
+			return nil, fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return resp.Data, nil
+	}
+
+	for i, k := range keys {
+		_, siblings, err := tr.GenProof(censusKeys[i])
+		qt.Assert(t, err, qt.IsNil)
+		nullifier, err := vote(k, siblings)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, nullifier, qt.DeepEquals, GenerateNullifier(k.Address(), pid))
+		// double vote
+		_, err = vote(k, siblings)
+		qt.Assert(t, err, qt.Not(qt.IsNil))
+		app.Commit()
+	}
+
+	// a key out of the census cannot vote with the proof of another key
+	outsider := babyjubjub.NewSignKeys()
+	qt.Assert(t, outsider.Generate(), qt.IsNil)
+	outsider.VocdoniChainID = app.ChainID()
+	_, siblings, err := tr.GenProof(censusKeys[0])
+	qt.Assert(t, err, qt.IsNil)
+	_, err = vote(outsider, siblings)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// an ethereum signature is not accepted for an iden3 proof
+	ethKey := ethereum.NewSignKeys()
+	qt.Assert(t, ethKey.Generate(), qt.IsNil)
+	var stx models.SignedTx
+	stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{
+		Vote: &models.VoteEnvelope{
+			Nonce:     util.RandomBytes(32),
+			ProcessId: pid,
+			Proof: &models.Proof{Payload: &models.Proof_Iden3{
+				Iden3: &models.ProofIden3{Siblings: siblings},
+			}},
+			VotePackage: []byte("[1,2,3]"),
+		}}})
+	qt.Assert(t, err, qt.IsNil)
+	stx.Signature, err = ethKey.SignVocdoniTx(stx.Tx)
+	qt.Assert(t, err, qt.IsNil)
+	stxBytes, err := proto.Marshal(&stx)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}).Code, qt.Not(qt.Equals), uint32(0))
+}
+
+func TestVochainTokensProof(t *testing.T) {
+	app := TestBaseApplication(t)
+
+	keys := util.CreateEthRandomKeysBatch(4)
+	holder, delegate, empty, outsider := keys[0], keys[1], keys[2], keys[3]
+	for _, k := range keys {
+		k.VocdoniChainID = app.ChainID()
+	}
+	qt.Assert(t, app.State.CreateAccount(holder.Address(), "ipfs://", nil, 0), qt.IsNil)
+	qt.Assert(t, app.State.CreateAccount(empty.Address(), "ipfs://", nil, 0), qt.IsNil)
+	qt.Assert(t, app.State.MintBalance(holder.Address(), 100), qt.IsNil)
+	qt.Assert(t, app.State.SetAccountDelegate(holder.Address(), delegate.Address(),
+		models.TxType_ADD_DELEGATE_FOR_ACCOUNT), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	process := &models.Process{
+		ProcessId:    util.RandomBytes(types.ProcessIDsize),
+		StartBlock:   app.State.CurrentHeight(),
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusOrigin: models.CensusOrigin_VOCHAIN_TOKENS,
+		BlockCount:   1024,
+	}
+	// the accounts snapshot cannot be taken in the past
+	qt.Assert(t, app.State.AddProcess(process), qt.Not(qt.IsNil))
+	process.StartBlock = app.State.CurrentHeight() + 1
+	qt.Assert(t, app.State.AddProcess(process), qt.IsNil)
+	pid := process.ProcessId
+	_, _, err := app.State.AccountsCensusProof(pid, holder.Address())
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	app.AdvanceTestBlock()
+
+	// balance changes after the snapshot do not modify the vote weight
+	qt.Assert(t, app.State.MintBalance(holder.Address(), 50), qt.IsNil)
+	app.AdvanceTestBlock()
+	p, err := app.State.Process(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, p.CensusRoot, qt.HasLen, AccountsCfg.HashFunc().Len())
+
+	vote := func(signer *ethereum.SignKeys, account *ethereum.SignKeys) ([]byte, error) {
+		value, siblings, err := app.State.AccountsCensusProof(pid, account.Address())
+		if err != nil {
+			return nil, err
+		}
+		proof := &models.Proof{Payload: &models.Proof_VochainAccount{
+			VochainAccount: &models.ProofVochainAccount{
+				Address:  account.Address().Bytes(),
+				Account:  value,
+				Siblings: siblings,
+			}}}
+		valid, weight, err := VerifyProof(p, proof, p.CensusOrigin, p.CensusRoot,
+			pid, signer.PublicKey(), account.Address())
+		if err != nil || !valid {
+			return nil, fmt.Errorf("invalid proof: %v", err)
+		}
+		qt.Assert(t, weight.Uint64(), qt.Equals, uint64(100))
+
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{
+			Vote: &models.VoteEnvelope{
+				Nonce:       util.RandomBytes(32),
+				ProcessId:   pid,
+				Proof:       proof,
+				VotePackage: []byte("[1,2,3]"),
+			}}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return nil, fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes})
+		if resp.Code != 0 {
+			return nil, fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return resp.Data, nil
+	}
+
+	// accounts without balance and non delegates cannot vote
+	_, err = vote(empty, empty)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	_, err = vote(outsider, holder)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	_, err = vote(outsider, outsider)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// the delegate votes on behalf of the holder, which cannot vote again
+	nullifier, err := vote(delegate, holder)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, nullifier, qt.DeepEquals, GenerateNullifier(holder.Address(), pid))
+	_, err = vote(holder, holder)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
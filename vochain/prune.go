@@ -0,0 +1,159 @@
+package vochain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/statedb"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// mainSubTrees contains the singleton subTrees hanging from the mainTree,
+// indexed by their parent leaf key.
+var mainSubTrees = map[string]statedb.TreeConfig{
+	string(ExtraCfg.Key()):       ExtraCfg,
+	string(OraclesCfg.Key()):     OraclesCfg,
+	string(ValidatorsCfg.Key()):  ValidatorsCfg,
+	string(ProcessesCfg.Key()):   ProcessesCfg,
+	string(AccountsCfg.Key()):    AccountsCfg,
+	string(FaucetNonceCfg.Key()): FaucetNonceCfg,
+}
+
+// stateLeafSubTrees implements statedb.LeafSubTreesFn for the StateDB tree
+// hierarchy of the Vochain.
+func stateLeafSubTrees(path []statedb.TreeConfig, key, value []byte) ([]statedb.TreeConfig, error) {
+	switch {
+	case len(path) == 0:
+		if cfg, ok := mainSubTrees[string(key)]; ok {
+			return []statedb.TreeConfig{cfg}, nil
+		}
+	case len(path) == 1 && path[0].KindID() == ProcessesCfg.KindID():
+		var sdbProc models.StateDBProcess
+		if err := proto.Unmarshal(value, &sdbProc); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal StateDBProcess: %w", err)
+		}
+		cfgs := []statedb.TreeConfig{VotesCfg.WithKey(key)}
+		p := sdbProc.Process
+		if p != nil && p.Mode != nil && p.Mode.PreRegister {
+			cfgs = append(cfgs, rollingCensusCfg(p).WithKey(key),
+				PreRegisterNullifiersCfg.WithKey(key))
+		}
+		return cfgs, nil
+	}
+	return nil, nil
+}
+
+// statePruner runs the StateDB pruning in the background.
+type statePruner struct {
+	opts     statedb.PruneOptions
+	interval uint32
+	// running is 1 while a pruning is in progress.
+	running int32
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// EnablePruning makes the State delete the old StateDB versions every interval
+// blocks, keeping the last keepRecent versions and every version multiple of
+// keepEvery (if not 0).  The pruning runs in the background and doesn't block
+// the block commits.
+func (v *State) EnablePruning(keepRecent, keepEvery, interval uint32) {
+	if interval == 0 {
+		interval = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	v.pruner = &statePruner{
+		opts: statedb.PruneOptions{
+			KeepRecent: keepRecent,
+			KeepEvery:  keepEvery,
+			SubTrees:   stateLeafSubTrees,
+			Pinned:     v.accountsCensusVersions,
+		},
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	log.Infof("state pruning enabled every %d blocks, keeping the last %d versions and every %d",
+		interval, keepRecent, keepEvery)
+}
+
+// Prune deletes the old StateDB versions according to the State pruning
+// options, and waits for it to finish.  Returns an error if pruning is not
+// enabled.
+func (v *State) Prune() (*statedb.PruneStats, error) {
+	if v.pruner == nil {
+		return nil, fmt.Errorf("pruning is not enabled")
+	}
+	return v.Store.Prune(v.pruner.ctx, v.pruner.opts)
+}
+
+// maybePrune starts a background pruning if it's due at height and no other
+// pruning is running.
+func (v *State) maybePrune(height uint32) {
+	p := v.pruner
+	if p == nil || height%p.interval != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer atomic.StoreInt32(&p.running, 0)
+		start := time.Now()
+		stats, err := v.Store.Prune(p.ctx, p.opts)
+		if err != nil {
+			log.Warnf("cannot prune state at height %d: %v", height, err)
+			return
+		}
+		log.Infof("pruned %d state versions and %d tree nodes in %s",
+			stats.Versions, stats.Nodes, time.Since(start))
+	}()
+}
+
+// accountsCensusVersions returns the state versions of the accounts census
+// snapshots of the started VOCHAIN_TOKENS processes that still accept votes,
+// which are needed to generate their census proofs (see AccountsCensusProof).
+func (v *State) accountsCensusVersions() ([]uint32, error) {
+	processesTree, err := v.mainTreeViewer(true).SubTree(ProcessesCfg)
+	if err != nil {
+		return nil, err
+	}
+	var versions []uint32
+	var iterErr error
+	if err := processesTree.Iterate(func(key, value []byte) bool {
+		var sdbProc models.StateDBProcess
+		if err := proto.Unmarshal(value, &sdbProc); err != nil {
+			iterErr = fmt.Errorf("cannot unmarshal StateDBProcess: %w", err)
+			return true
+		}
+		p := sdbProc.Process
+		if p == nil || p.CensusOrigin != models.CensusOrigin_VOCHAIN_TOKENS ||
+			len(p.CensusRoot) == 0 {
+			return false
+		}
+		if p.Status == models.ProcessStatus_READY || p.Status == models.ProcessStatus_PAUSED {
+			versions = append(versions, p.StartBlock-1)
+		}
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	return versions, iterErr
+}
+
+// stopPruning cancels the running pruning and waits for it to finish.
+func (v *State) stopPruning() {
+	if v.pruner == nil {
+		return
+	}
+	v.pruner.cancel()
+	v.pruner.wg.Wait()
+}
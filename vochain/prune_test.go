@@ -0,0 +1,120 @@
+package vochain
+
+import (
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestStatePrune(t *testing.T) {
+	rng := testutil.NewRandom(0)
+	app := TestBaseApplication(t)
+	app.State.EnablePruning(2, 0, 1000)
+
+	var pids [][]byte
+	votes := make(map[string][]byte) // nullifier -> processId
+	for height := uint32(1); height <= 10; height++ {
+		app.State.SetHeight(height)
+		censusURI := "ipfs://foobar"
+		pid := rng.RandomBytes(32)
+		pids = append(pids, pid)
+		qt.Assert(t, app.State.AddProcess(&models.Process{
+			ProcessId:    pid,
+			EntityId:     rng.RandomBytes(32),
+			CensusURI:    &censusURI,
+			Mode:         &models.ProcessMode{PreRegister: true},
+			EnvelopeType: &models.EnvelopeType{Anonymous: true},
+		}), qt.IsNil)
+		// Vote on all the processes
+		for _, pid := range pids {
+			nullifier := rng.RandomBytes(32)
+			votes[string(nullifier)] = pid
+			qt.Assert(t, app.State.AddVote(&models.Vote{
+				ProcessId:   pid,
+				Nullifier:   nullifier,
+				VotePackage: []byte(fmt.Sprintf("%d", height)),
+			}), qt.IsNil)
+		}
+		app.Commit()
+	}
+	hash, err := app.State.Store.Hash()
+	qt.Assert(t, err, qt.IsNil)
+
+	stats, err := app.State.Prune()
+	qt.Assert(t, err, qt.IsNil)
+	// Versions from 0 to 8 are pruned
+	qt.Assert(t, stats.Versions, qt.Equals, 9)
+	qt.Assert(t, stats.Nodes > 0, qt.IsTrue)
+
+	// The last state is intact
+	hash2, err := app.State.Store.Hash()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, hash2, qt.DeepEquals, hash)
+	for _, pid := range pids {
+		_, err := app.State.Process(pid, true)
+		qt.Assert(t, err, qt.IsNil)
+	}
+	for nullifier, pid := range votes {
+		_, err := app.State.Envelope(pid, []byte(nullifier), true)
+		qt.Assert(t, err, qt.IsNil)
+	}
+	_, err = app.State.Store.VersionRoot(9)
+	qt.Assert(t, err, qt.IsNil)
+	_, err = app.State.Store.VersionRoot(8)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// The state can still be updated
+	app.State.SetHeight(11)
+	qt.Assert(t, app.State.AddVote(&models.Vote{
+		ProcessId:   pids[0],
+		Nullifier:   rng.RandomBytes(32),
+		VotePackage: []byte("11"),
+	}), qt.IsNil)
+	app.Commit()
+}
+
+func TestStatePruneAccountsCensus(t *testing.T) {
+	app := TestBaseApplication(t)
+	app.State.EnablePruning(1, 0, 1000)
+	holder := ethereum.NewSignKeys()
+	qt.Assert(t, holder.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.CreateAccount(holder.Address(), "ipfs://", nil, 0), qt.IsNil)
+	qt.Assert(t, app.State.MintBalance(holder.Address(), 100), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		StartBlock:   app.State.CurrentHeight() + 1,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{Interruptible: true},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusOrigin: models.CensusOrigin_VOCHAIN_TOKENS,
+		BlockCount:   1024,
+	}), qt.IsNil)
+	for i := 0; i < 5; i++ {
+		qt.Assert(t, app.State.MintBalance(holder.Address(), 1), qt.IsNil)
+		app.AdvanceTestBlock()
+	}
+
+	// The accounts census snapshot of the process is kept
+	_, err := app.State.Prune()
+	qt.Assert(t, err, qt.IsNil)
+	_, _, err = app.State.AccountsCensusProof(pid, holder.Address())
+	qt.Assert(t, err, qt.IsNil)
+
+	// and pruned once the process ends
+	qt.Assert(t, app.State.SetProcessStatus(pid, models.ProcessStatus_ENDED, true), qt.IsNil)
+	app.AdvanceTestBlock()
+	_, err = app.State.Prune()
+	qt.Assert(t, err, qt.IsNil)
+	_, _, err = app.State.AccountsCensusProof(pid, holder.Address())
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
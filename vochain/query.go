@@ -0,0 +1,355 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+	"go.vocdoni.io/dvote/statedb"
+	"go.vocdoni.io/dvote/tree"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// QueryMethodProcess returns the models.StateDBProcess of a process.
+	// Requires QueryData.ProcessID.
+	QueryMethodProcess = "process"
+	// QueryMethodVote returns the models.StateDBVote of a vote.  Requires
+	// QueryData.ProcessID and QueryData.Nullifier.
+	QueryMethodVote = "vote"
+	// QueryMethodAccount returns the models.Account of an account.
+	// Requires QueryData.Address.
+	QueryMethodAccount = "account"
+	// QueryMethodOracle returns []byte{1} if the address is an oracle.
+	// Requires QueryData.Address.
+	QueryMethodOracle = "oracle"
+	// QueryMethodTxCost returns the cost of a transaction type encoded as a
+	// little endian uint64.  Requires QueryData.TxType.
+	QueryMethodTxCost = "txCost"
+
+	// ProofOpTypePrefix is the prefix of the tmcrypto.ProofOp types
+	// returned by Query.  It is followed by the KindID of the tree the
+	// proof belongs to, or "main" for the mainTree.
+	ProofOpTypePrefix = "arbo:"
+	proofOpMainTree   = "main"
+)
+
+// queryProofTree holds the information required to verify a proof of a tree
+// of the StateDB hierarchy.
+type queryProofTree struct {
+	cfg *statedb.TreeNonSingletonConfig
+	// singleton is true if the tree hangs from a parent leaf whose key is
+	// the tree KindID.
+	singleton bool
+}
+
+// queryProofTrees contains all the trees which can be part of a query proof,
+// indexed by their KindID.
+var queryProofTrees = map[string]queryProofTree{
+	ExtraCfg.KindID():      {cfg: ExtraCfg.TreeNonSingletonConfig, singleton: true},
+	OraclesCfg.KindID():    {cfg: OraclesCfg.TreeNonSingletonConfig, singleton: true},
+	ValidatorsCfg.KindID(): {cfg: ValidatorsCfg.TreeNonSingletonConfig, singleton: true},
+	ProcessesCfg.KindID():  {cfg: ProcessesCfg.TreeNonSingletonConfig, singleton: true},
+	AccountsCfg.KindID():   {cfg: AccountsCfg.TreeNonSingletonConfig, singleton: true},
+	VotesCfg.KindID():      {cfg: VotesCfg},
+	CensusCfg.KindID():     {cfg: CensusCfg},
+}
+
+// Query implements the ABCI Query method.  The request data must contain a
+// JSON encoded QueryData, whose Method selects the state entry to read (if
+// Method is empty, the request path without the leading slash is used).  The
+// state is read at the version of the requested height, or the last committed
+// one if the height is 0.  If a proof is requested, the response ProofOps
+// contain a chain of arbo Merkle proofs, from the tree containing the entry up
+// to the mainTree, that can be verified with VerifyQueryProof.
+//
+// Note that the state committed at height H is summarized by the app hash
+// found in the header of block H+1.
+func (app *BaseApplication) Query(req abcitypes.RequestQuery) abcitypes.ResponseQuery {
+	resp, err := app.query(req)
+	if err != nil {
+		return abcitypes.ResponseQuery{Code: 1, Log: err.Error(), Height: req.Height}
+	}
+	return resp
+}
+
+func (app *BaseApplication) query(req abcitypes.RequestQuery) (abcitypes.ResponseQuery, error) {
+	var qd QueryData
+	if len(req.Data) > 0 {
+		if err := json.Unmarshal(req.Data, &qd); err != nil {
+			return abcitypes.ResponseQuery{}, fmt.Errorf("cannot unmarshal query data: %w", err)
+		}
+	}
+	if qd.Method == "" {
+		qd.Method = strings.TrimPrefix(req.Path, "/")
+	}
+	key, cfgs, err := app.queryPath(&qd)
+	if err != nil {
+		return abcitypes.ResponseQuery{}, err
+	}
+
+	height := uint32(req.Height)
+	if height == 0 {
+		if height, err = app.State.LastHeight(); err != nil {
+			return abcitypes.ResponseQuery{}, err
+		}
+	}
+	root, err := app.State.Store.VersionRoot(height)
+	if err != nil {
+		return abcitypes.ResponseQuery{}, fmt.Errorf("state at height %d not found: %w", height, err)
+	}
+	mainTree, err := app.State.Store.TreeView(root)
+	if err != nil {
+		return abcitypes.ResponseQuery{}, err
+	}
+
+	resp := abcitypes.ResponseQuery{Key: key, Height: int64(height)}
+	if !req.Prove {
+		if resp.Value, err = mainTree.DeepGet(key, cfgs...); err != nil {
+			return abcitypes.ResponseQuery{}, err
+		}
+		return resp, nil
+	}
+	ops, err := GenQueryProof(mainTree, key, cfgs...)
+	if err != nil {
+		return abcitypes.ResponseQuery{}, err
+	}
+	if resp.Value, _, err = decodeProofOpData(ops[0].Data); err != nil {
+		return abcitypes.ResponseQuery{}, err
+	}
+	resp.ProofOps = &tmcrypto.ProofOps{Ops: ops}
+	return resp, nil
+}
+
+// queryPath returns the key and the subTree path of the state entry requested
+// by qd.
+func (app *BaseApplication) queryPath(qd *QueryData) ([]byte, []statedb.TreeConfig, error) {
+	switch qd.Method {
+	case QueryMethodProcess:
+		pid, err := hex.DecodeString(util.TrimHex(qd.ProcessID))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode processId: %w", err)
+		}
+		return pid, []statedb.TreeConfig{ProcessesCfg}, nil
+	case QueryMethodVote:
+		pid, err := hex.DecodeString(util.TrimHex(qd.ProcessID))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode processId: %w", err)
+		}
+		nullifier, err := hex.DecodeString(util.TrimHex(qd.Nullifier))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode nullifier: %w", err)
+		}
+		vid, err := app.State.voteID(pid, nullifier)
+		if err != nil {
+			return nil, nil, err
+		}
+		return vid, []statedb.TreeConfig{ProcessesCfg, VotesCfg.WithKey(pid)}, nil
+	case QueryMethodAccount, QueryMethodOracle:
+		if !common.IsHexAddress(qd.Address) {
+			return nil, nil, fmt.Errorf("invalid address %q", qd.Address)
+		}
+		cfg := AccountsCfg
+		if qd.Method == QueryMethodOracle {
+			cfg = OraclesCfg
+		}
+		return common.HexToAddress(qd.Address).Bytes(), []statedb.TreeConfig{cfg}, nil
+	case QueryMethodTxCost:
+		txType, ok := models.TxType_value[qd.TxType]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown tx type %q", qd.TxType)
+		}
+		key, ok := TxTypeCostToStateKey[models.TxType(txType)]
+		if !ok {
+			return nil, nil, fmt.Errorf("tx type %s has no cost", qd.TxType)
+		}
+		return []byte(key), []statedb.TreeConfig{ExtraCfg}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown query method %q", qd.Method)
+	}
+}
+
+// GenQueryProof generates the chain of Merkle proofs of the leaf at key found
+// in the subTree reached by following cfgs from mainTree.  The first ProofOp
+// proves the leaf in the deepest subTree, and each following one proves the
+// parent leaf holding the root of the previous subTree, until the mainTree.
+func GenQueryProof(mainTree statedb.TreeViewer, key []byte,
+	cfgs ...statedb.TreeConfig) ([]tmcrypto.ProofOp, error) {
+	trees := []statedb.TreeViewer{mainTree}
+	for _, cfg := range cfgs {
+		subTree, err := trees[len(trees)-1].SubTree(cfg)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, subTree)
+	}
+	ops := make([]tmcrypto.ProofOp, 0, len(trees))
+	for i := len(trees) - 1; i >= 0; i-- {
+		kind := proofOpMainTree
+		if i > 0 {
+			kind = cfgs[i-1].KindID()
+		}
+		value, siblings, err := trees[i].GenProof(key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot generate proof at %s tree: %w", kind, err)
+		}
+		ops = append(ops, tmcrypto.ProofOp{
+			Type: ProofOpTypePrefix + kind,
+			Key:  key,
+			Data: encodeProofOpData(value, siblings),
+		})
+		if i > 0 {
+			key = cfgs[i-1].Key()
+		}
+	}
+	return ops, nil
+}
+
+// encodeProofOpData encodes the leaf value and the packed siblings of an arbo
+// proof as `uvarint(len(value)) | value | siblings`.
+func encodeProofOpData(value, siblings []byte) []byte {
+	data := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(value)+len(siblings))
+	n := binary.PutUvarint(data, uint64(len(value)))
+	data = append(data[:n], value...)
+	return append(data, siblings...)
+}
+
+// decodeProofOpData decodes the leaf value and the packed siblings encoded
+// with encodeProofOpData.
+func decodeProofOpData(data []byte) ([]byte, []byte, error) {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < size {
+		return nil, nil, fmt.Errorf("invalid proof op data")
+	}
+	return data[n : n+int(size)], data[n+int(size):], nil
+}
+
+// VerifyQueryProof verifies that the chain of proofs in ops, as returned by
+// Query, proves that key has value in the state summarized by appHash.  Note
+// that only the key of the first proof is checked, so callers should check the
+// keys of the parent leaves of non-singleton subTrees (such as the processId
+// of the Votes subTree) when needed.
+func VerifyQueryProof(ops []tmcrypto.ProofOp, key, value, appHash []byte) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("empty proof")
+	}
+	if !bytes.Equal(ops[0].Key, key) {
+		return fmt.Errorf("proof key %x does not match %x", ops[0].Key, key)
+	}
+	values := make([][]byte, len(ops))
+	siblings := make([][]byte, len(ops))
+	for i, op := range ops {
+		var err error
+		if values[i], siblings[i], err = decodeProofOpData(op.Data); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(values[0], value) {
+		return fmt.Errorf("proof value does not match")
+	}
+	for i, op := range ops {
+		kind := strings.TrimPrefix(op.Type, ProofOpTypePrefix)
+		if kind == op.Type {
+			return fmt.Errorf("unknown proof op type %q", op.Type)
+		}
+		last := i == len(ops)-1
+		if kind == proofOpMainTree {
+			if !last {
+				return fmt.Errorf("mainTree proof must be the last one")
+			}
+			ok, err := tree.VerifyProof(statedb.MainTreeHashFunc(), op.Key, values[i],
+				siblings[i], appHash)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("invalid mainTree proof")
+			}
+			return nil
+		}
+		if last {
+			return fmt.Errorf("proof does not reach the mainTree")
+		}
+		subTree, ok := queryProofTrees[kind]
+		if !ok {
+			return fmt.Errorf("unknown proof tree kind %q", kind)
+		}
+		if subTree.singleton && !bytes.Equal(ops[i+1].Key, []byte(kind)) {
+			return fmt.Errorf("invalid parent leaf key %x for tree %s", ops[i+1].Key, kind)
+		}
+		root, err := subTree.cfg.ParentLeafGetRoot()(values[i+1])
+		if err != nil {
+			return fmt.Errorf("cannot get %s root from parent leaf: %w", kind, err)
+		}
+		ok, err = tree.VerifyProof(subTree.cfg.HashFunc(), op.Key, values[i], siblings[i], root)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("invalid %s proof", kind)
+		}
+	}
+	return nil
+}
+
+// VoteProof returns the marshaled models.StateDBVote of the vote with nullifier
+// in the process pid, together with its inclusion proof in the state committed
+// at height (or the last committed state if height is 0), and the height of
+// that state.  The proof can be verified with VerifyVoteProof against the app
+// hash found in the header of block height+1.
+func (app *BaseApplication) VoteProof(pid, nullifier []byte,
+	height uint32) ([]byte, []tmcrypto.ProofOp, uint32, error) {
+	data, err := json.Marshal(&QueryData{
+		Method:    QueryMethodVote,
+		ProcessID: hex.EncodeToString(pid),
+		Nullifier: hex.EncodeToString(nullifier),
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	resp, err := app.query(abcitypes.RequestQuery{Data: data, Height: int64(height), Prove: true})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return resp.Value, resp.ProofOps.Ops, uint32(resp.Height), nil
+}
+
+// VerifyVoteProof verifies that ops, as returned by VoteProof, prove that the
+// vote with nullifier in the process pid is the marshaled models.StateDBVote
+// value, in the state summarized by appHash.  On success, the unmarshaled
+// vote is returned.
+func VerifyVoteProof(ops []tmcrypto.ProofOp, pid, nullifier, value,
+	appHash []byte) (*models.StateDBVote, error) {
+	vid, err := VoteID(pid, nullifier)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) != 3 || ops[0].Type != ProofOpTypePrefix+VotesCfg.KindID() ||
+		ops[1].Type != ProofOpTypePrefix+ProcessesCfg.KindID() {
+		return nil, fmt.Errorf("invalid vote proof")
+	}
+	// The Votes subTree is not a singleton, so its parent leaf key must
+	// be checked here.
+	if !bytes.Equal(ops[1].Key, pid) {
+		return nil, fmt.Errorf("vote proof is for process %x", ops[1].Key)
+	}
+	if err := VerifyQueryProof(ops, vid, value, appHash); err != nil {
+		return nil, err
+	}
+	var vote models.StateDBVote
+	if err := proto.Unmarshal(value, &vote); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal vote: %w", err)
+	}
+	if !bytes.Equal(vote.ProcessId, pid) || !bytes.Equal(vote.Nullifier, nullifier) {
+		return nil, fmt.Errorf("vote does not match processId and nullifier")
+	}
+	return &vote, nil
+}
@@ -0,0 +1,178 @@
+package vochain
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestQueryProofs(t *testing.T) {
+	rng := testutil.NewRandom(0)
+	app := TestBaseApplication(t)
+
+	pid := rng.RandomBytes(32)
+	nullifier := rng.RandomBytes(32)
+	oracle := common.HexToAddress(randomEthAccount)
+	censusURI := "ipfs://foobar"
+
+	app.State.SetHeight(1)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId: pid,
+		EntityId:  rng.RandomBytes(32),
+		CensusURI: &censusURI,
+	}), qt.IsNil)
+	qt.Assert(t, app.State.CreateAccount(oracle, "ipfs://", nil, 0), qt.IsNil)
+	qt.Assert(t, app.State.AddOracle(oracle), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_NEW_PROCESS, 10), qt.IsNil)
+	app.Commit()
+	appHash1, err := app.State.Store.Hash()
+	qt.Assert(t, err, qt.IsNil)
+
+	app.State.SetHeight(2)
+	qt.Assert(t, app.State.AddVote(&models.Vote{
+		ProcessId:   pid,
+		Nullifier:   nullifier,
+		VotePackage: []byte("vote"),
+	}), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_NEW_PROCESS, 20), qt.IsNil)
+	app.Commit()
+	appHash2, err := app.State.Store.Hash()
+	qt.Assert(t, err, qt.IsNil)
+
+	query := func(height int64, qd *QueryData) abcitypes.ResponseQuery {
+		data, err := json.Marshal(qd)
+		qt.Assert(t, err, qt.IsNil)
+		return app.Query(abcitypes.RequestQuery{Data: data, Height: height, Prove: true})
+	}
+
+	// Process
+	resp := query(0, &QueryData{Method: QueryMethodProcess, ProcessID: fmt.Sprintf("%x", pid)})
+	qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Log))
+	qt.Assert(t, resp.Height, qt.Equals, int64(2))
+	qt.Assert(t, VerifyQueryProof(resp.ProofOps.Ops, pid, resp.Value, appHash2), qt.IsNil)
+	var sdbProc models.StateDBProcess
+	qt.Assert(t, proto.Unmarshal(resp.Value, &sdbProc), qt.IsNil)
+	qt.Assert(t, sdbProc.Process.ProcessId, qt.DeepEquals, pid)
+
+	// Vote, proven through Votes -> Processes -> mainTree
+	resp = query(0, &QueryData{
+		Method:    QueryMethodVote,
+		ProcessID: fmt.Sprintf("%x", pid),
+		Nullifier: fmt.Sprintf("%x", nullifier),
+	})
+	qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Log))
+	qt.Assert(t, resp.ProofOps.Ops, qt.HasLen, 3)
+	qt.Assert(t, resp.ProofOps.Ops[1].Key, qt.DeepEquals, pid)
+	qt.Assert(t, VerifyQueryProof(resp.ProofOps.Ops, resp.Key, resp.Value, appHash2), qt.IsNil)
+	qt.Assert(t, VerifyQueryProof(resp.ProofOps.Ops, resp.Key, resp.Value, appHash1),
+		qt.Not(qt.IsNil))
+	qt.Assert(t, VerifyQueryProof(resp.ProofOps.Ops, resp.Key, []byte("fake"), appHash2),
+		qt.Not(qt.IsNil))
+
+	// The vote does not exist at height 1
+	resp = query(1, &QueryData{
+		Method:    QueryMethodVote,
+		ProcessID: fmt.Sprintf("%x", pid),
+		Nullifier: fmt.Sprintf("%x", nullifier),
+	})
+	qt.Assert(t, resp.Code, qt.Not(qt.Equals), uint32(0))
+
+	// Account and oracle
+	resp = query(0, &QueryData{Method: QueryMethodAccount, Address: oracle.Hex()})
+	qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Log))
+	qt.Assert(t, VerifyQueryProof(resp.ProofOps.Ops, oracle.Bytes(), resp.Value, appHash2), qt.IsNil)
+	resp = query(0, &QueryData{Method: QueryMethodOracle, Address: oracle.Hex()})
+	qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Log))
+	qt.Assert(t, resp.Value, qt.DeepEquals, []byte{1})
+	qt.Assert(t, VerifyQueryProof(resp.ProofOps.Ops, oracle.Bytes(), resp.Value, appHash2), qt.IsNil)
+
+	// A proof of one subTree can't be passed as a proof of another one
+	ops := resp.ProofOps.Ops
+	ops[0].Type = ProofOpTypePrefix + AccountsCfg.KindID()
+	qt.Assert(t, VerifyQueryProof(ops, oracle.Bytes(), resp.Value, appHash2), qt.Not(qt.IsNil))
+
+	// Tx costs at different heights
+	for height, expected := range map[int64]uint64{1: 10, 2: 20} {
+		resp = query(height, &QueryData{Method: QueryMethodTxCost, TxType: "NEW_PROCESS"})
+		qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Log))
+		qt.Assert(t, binary.LittleEndian.Uint64(resp.Value), qt.Equals, expected)
+		appHash := appHash1
+		if height == 2 {
+			appHash = appHash2
+		}
+		qt.Assert(t, VerifyQueryProof(resp.ProofOps.Ops, resp.Key, resp.Value, appHash), qt.IsNil)
+	}
+
+	// Queries without proof and using the path as method
+	data, err := json.Marshal(&QueryData{ProcessID: fmt.Sprintf("%x", pid)})
+	qt.Assert(t, err, qt.IsNil)
+	resp = app.Query(abcitypes.RequestQuery{Path: "/process", Data: data})
+	qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Log))
+	qt.Assert(t, resp.ProofOps, qt.IsNil)
+	qt.Assert(t, proto.Unmarshal(resp.Value, &sdbProc), qt.IsNil)
+
+	// Unknown method
+	resp = query(0, &QueryData{Method: "foo"})
+	qt.Assert(t, resp.Code, qt.Not(qt.Equals), uint32(0))
+}
+
+func TestVoteProof(t *testing.T) {
+	rng := testutil.NewRandom(0)
+	app := TestBaseApplication(t)
+
+	pid := rng.RandomBytes(32)
+	otherPid := rng.RandomBytes(32)
+	nullifier := rng.RandomBytes(32)
+	censusURI := "ipfs://foobar"
+
+	app.State.SetHeight(1)
+	for _, id := range [][]byte{pid, otherPid} {
+		qt.Assert(t, app.State.AddProcess(&models.Process{
+			ProcessId: id,
+			EntityId:  rng.RandomBytes(32),
+			CensusURI: &censusURI,
+		}), qt.IsNil)
+		qt.Assert(t, app.State.AddVote(&models.Vote{
+			ProcessId:   id,
+			Nullifier:   nullifier,
+			VotePackage: []byte("vote"),
+		}), qt.IsNil)
+	}
+	app.Commit()
+	appHash, err := app.State.Store.Hash()
+	qt.Assert(t, err, qt.IsNil)
+
+	value, ops, height, err := app.VoteProof(pid, nullifier, 0)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, height, qt.Equals, uint32(1))
+	vote, err := VerifyVoteProof(ops, pid, nullifier, value, appHash)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, vote.ProcessId, qt.DeepEquals, pid)
+	qt.Assert(t, vote.Nullifier, qt.DeepEquals, nullifier)
+
+	// The proof is not valid for another process or nullifier
+	_, err = VerifyVoteProof(ops, otherPid, nullifier, value, appHash)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	_, err = VerifyVoteProof(ops, pid, rng.RandomBytes(32), value, appHash)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+	otherValue, otherOps, _, err := app.VoteProof(otherPid, nullifier, 0)
+	qt.Assert(t, err, qt.IsNil)
+	_, err = VerifyVoteProof(otherOps, pid, nullifier, otherValue, appHash)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// Nor for another state
+	_, err = VerifyVoteProof(ops, pid, nullifier, value, rng.RandomBytes(32))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// Unknown votes have no proof
+	_, _, _, err = app.VoteProof(pid, rng.RandomBytes(32), 0)
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
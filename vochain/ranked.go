@@ -0,0 +1,34 @@
+package vochain
+
+import (
+	"fmt"
+
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// Ranked choice
+//
+// On ranked choice processes each vote is an ordering of the options, most
+// preferred first: the vote values are distinct option indexes from 0 to
+// MaxValue, and up to MaxCount of them can be ranked.  The scrutinizer
+// computes the Instant-Runoff, Single Transferable Vote (electing RankedSeats
+// options) and Condorcet/Schulze results from the ballots.
+
+// checkRankedProcess checks the options of a new ranked choice process.
+func checkRankedProcess(process *models.Process) error {
+	vo, et := process.VoteOptions, process.EnvelopeType
+	if !et.UniqueValues {
+		return fmt.Errorf("ranked choice requires unique values")
+	}
+	if et.Serial || et.CostFromWeight || vo.MaxTotalCost > 0 || et.GetHomomorphic() {
+		return fmt.Errorf("ranked choice not supported with serial, cost from weight, " +
+			"max total cost or homomorphic tally")
+	}
+	if vo.MaxCount > vo.MaxValue+1 {
+		return fmt.Errorf("ranked choice maxCount cannot be greater than the number of options")
+	}
+	if vo.GetRankedSeats() > vo.MaxValue {
+		return fmt.Errorf("ranked choice seats must be lower than the number of options")
+	}
+	return nil
+}
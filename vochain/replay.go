@@ -0,0 +1,104 @@
+package vochain
+
+import (
+	"bytes"
+	"fmt"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// Block replay
+//
+// A replay application builds a separate state from the genesis by replaying
+// the blocks already stored by another application, without networking.  The
+// blocks go through the same BeginBlock, DeliverTx, EndBlock and Commit steps
+// as when they were received, so the event listeners of the replay state are
+// notified exactly as the ones of the original state were, which allows to
+// rebuild the data derived from the events, such as the indexes.  The blocks,
+// the transactions and the zk verification keys are read from the original
+// application, and the replay application is always synchronizing.  Since the
+// app hash of a block header is the hash of the state after the previous
+// block, the replay is aborted as soon as the replayed state hash differs
+// from the stored one, as the rebuilt data would not match the chain.
+
+// NewReplayApplication creates an application with a new state at dbpath,
+// which replays the blocks stored by source.  If the state is empty, it is
+// initialized with genesisAppState, else the replay continues from its last
+// height.
+func NewReplayApplication(source *BaseApplication, dbType, dbpath string,
+	genesisAppState []byte) (*BaseApplication, error) {
+	app, err := NewBaseApplication(dbType, dbpath)
+	if err != nil {
+		return nil, err
+	}
+	app.chainId = source.ChainID()
+	app.ZkVKs = source.ZkVKs
+	app.SetFnGetBlockByHash(source.GetBlockByHash)
+	app.SetFnGetBlockByHeight(source.GetBlockByHeight)
+	app.SetFnGetTx(app.getTxTendermint)
+	app.SetFnGetTxHash(app.getTxHashTendermint)
+	app.SetFnSendTx(func(tx []byte) (*ctypes.ResultBroadcastTx, error) {
+		return nil, fmt.Errorf("cannot send transactions to a replay application")
+	})
+	app.SetFnMempoolSize(func() int { return 0 })
+	app.IsSynchronizing = func() bool { return true }
+
+	height, err := app.State.LastHeight()
+	if err != nil {
+		app.State.Close()
+		return nil, fmt.Errorf("cannot get replay state height: %w", err)
+	}
+	if height == 0 {
+		app.InitChain(abcitypes.RequestInitChain{
+			ChainId:       app.chainId,
+			AppStateBytes: genesisAppState,
+		})
+	}
+	app.height = height
+	return app, nil
+}
+
+// ReplayBlock executes the transactions of block on the state, and commits
+// it.  The block must be the one following the last committed height.  It
+// returns an error if the state hash before or after the block differs from
+// the app hash stored on the block header or on the next block header.
+func (app *BaseApplication) ReplayBlock(block *tmtypes.Block) error {
+	height, err := app.State.LastHeight()
+	if err != nil {
+		return err
+	}
+	if block.Height != int64(height)+1 {
+		return fmt.Errorf("cannot replay block %d on state height %d", block.Height, height)
+	}
+	// the first block app hash is the genesis one, not the state hash
+	if height > 0 {
+		if err := checkAppHash(block, app.State.WorkingHash()); err != nil {
+			return err
+		}
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: *block.Header.ToProto()})
+	for _, tx := range block.Txs {
+		app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx})
+	}
+	app.endBlock(block.Height, block.Time)
+	hash, err := app.State.Save()
+	if err != nil {
+		return fmt.Errorf("cannot save state: %w", err)
+	}
+	// the last block is checked once the next one is stored
+	if next := app.GetBlockByHeight(block.Height + 1); next != nil {
+		return checkAppHash(next, hash)
+	}
+	return nil
+}
+
+// checkAppHash returns an error if the app hash of block is not hash.
+func checkAppHash(block *tmtypes.Block, hash []byte) error {
+	if !bytes.Equal(block.AppHash, hash) {
+		return fmt.Errorf("app hash mismatch on block %d: stored %x, replayed %x",
+			block.Height, block.AppHash.Bytes(), hash)
+	}
+	return nil
+}
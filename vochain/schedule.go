@@ -0,0 +1,195 @@
+package vochain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// Timed processes
+//
+// A process can optionally carry start and end dates (unix timestamps in
+// seconds).  On creation, its StartBlock and BlockCount are estimated from the
+// dates, the current block time and DefaultBlockTime.  Then, at the beginning
+// of each block, the block count is adjusted with the block header time: the
+// process is closed on the first block starting at or after the end date, and
+// extended while the next block is estimated to start before the end date.
+// Votes are only accepted on blocks starting between the start date and the
+// end date.
+
+// DefaultBlockTime is the estimated time between two blocks.
+const DefaultBlockTime = 10 * time.Second
+
+// keyTimedProcesses is the NoState key of the list of timed processes whose
+// end date has not been reached yet.
+var keyTimedProcesses = []byte("timedProcesses")
+
+// SetProcessSchedule sets the start and end dates of a process.  A zero start
+// date makes the process start as soon as it is created.
+func SetProcessSchedule(p *models.Process, startTime, endTime time.Time) {
+	start := int64(0)
+	if !startTime.IsZero() {
+		start = startTime.Unix()
+	}
+	p.StartTime = start
+	p.EndTime = endTime.Unix()
+}
+
+// blocksUntil returns the estimated number of blocks to reach the duration d.
+func blocksUntil(d int64) uint32 {
+	bt := int64(DefaultBlockTime / time.Second)
+	if d <= 0 {
+		return 0
+	}
+	return uint32((d + bt - 1) / bt)
+}
+
+// scheduleTimedProcess checks the dates of a new timed process and sets its
+// StartBlock and BlockCount, being height and now the current block height
+// and time.  A start date in the past starts the process on the next block.
+func scheduleTimedProcess(p *models.Process, height uint32, now int64) error {
+	start, end := p.GetStartTime(), p.GetEndTime()
+	if end <= 0 {
+		if start > 0 {
+			return fmt.Errorf("process start date requires an end date")
+		}
+		return nil
+	}
+	if start == 0 {
+		start = now
+		p.StartTime = start
+	}
+	if end <= start || end <= now {
+		return fmt.Errorf("process end date must be after its start date and the current time")
+	}
+	startBlocks := blocksUntil(start - now)
+	if startBlocks == 0 {
+		startBlocks = 1
+	}
+	p.StartBlock = height + startBlocks
+	p.BlockCount = blocksUntil(end - (now + int64(startBlocks)*int64(DefaultBlockTime/time.Second)))
+	if p.BlockCount == 0 {
+		p.BlockCount = 1
+	}
+	return nil
+}
+
+// checkProcessSchedule returns an error if the block time now is not within
+// the dates of a timed process.
+func checkProcessSchedule(p *models.Process, now int64) error {
+	end := p.GetEndTime()
+	if end == 0 {
+		return nil
+	}
+	if now < p.GetStartTime() || now >= end {
+		return fmt.Errorf("process %x not started or finished", p.ProcessId)
+	}
+	return nil
+}
+
+// timedProcesses returns the ProcessIDs of the timed processes still open.
+func (v *State) timedProcesses() ([][]byte, error) {
+	pidsBytes, err := v.Tx.NoState().Get(keyTimedProcesses)
+	if errors.Is(err, db.ErrKeyNotFound) {
+		return [][]byte{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pids models.ProcessIdList
+	if err := proto.Unmarshal(pidsBytes, &pids); err != nil {
+		return nil, fmt.Errorf("cannot proto.Unmarshal pids: %w", err)
+	}
+	return pids.ProcessIds, nil
+}
+
+// setTimedProcesses stores the ProcessIDs of the timed processes still open.
+func (v *State) setTimedProcesses(pids [][]byte) error {
+	pidsBytes, err := proto.Marshal(&models.ProcessIdList{ProcessIds: pids})
+	if err != nil {
+		return err
+	}
+	return v.Tx.NoState().Set(keyTimedProcesses, pidsBytes)
+}
+
+// ScheduleProcesses adjusts the BlockCount of the timed processes to the
+// current block height and time, and must be called at the beginning of each
+// block.  The event listeners are notified with OnProcessDurationChange.
+func (v *State) ScheduleProcesses(now time.Time) error {
+	height := v.CurrentHeight()
+	changed := []*models.Process{}
+	v.Tx.Lock()
+	err := func() error {
+		pids, err := v.timedProcesses()
+		if err != nil || len(pids) == 0 {
+			return err
+		}
+		open := [][]byte{}
+		for _, pid := range pids {
+			p, err := getProcess(v.Tx.AsTreeView(), pid)
+			if err != nil {
+				return fmt.Errorf("cannot get timed process %x: %w", pid, err)
+			}
+			if p.Status != models.ProcessStatus_READY && p.Status != models.ProcessStatus_PAUSED {
+				continue
+			}
+			endBlock := p.StartBlock + p.BlockCount
+			end := p.GetEndTime()
+			var newEndBlock uint32
+			if now.Unix() >= end {
+				// the process is closed on this block
+				if endBlock >= height {
+					newEndBlock = height - 1
+				}
+			} else {
+				open = append(open, pid)
+				// the blocks are faster than estimated, extend the process
+				// if the next block starts before the end date
+				if endBlock <= height && now.Add(DefaultBlockTime).Unix() < end {
+					newEndBlock = height + blocksUntil(end-now.Unix()) - 1
+				}
+			}
+			if newEndBlock == 0 {
+				continue
+			}
+			if newEndBlock <= p.StartBlock {
+				p.BlockCount = 1
+			} else {
+				p.BlockCount = newEndBlock - p.StartBlock
+			}
+			if p.StartBlock+p.BlockCount == endBlock {
+				continue
+			}
+			if err := updateProcess(&v.Tx, p, pid); err != nil {
+				return err
+			}
+			// a process closed on this block is ended at the end of it
+			if newEndBlock < height {
+				newEndBlock = height
+			}
+			if err := v.moveProcessIDByEndBlock(pid, endBlock, newEndBlock); err != nil {
+				return err
+			}
+			changed = append(changed, p)
+		}
+		if len(open) == len(pids) {
+			return nil
+		}
+		return v.setTimedProcesses(open)
+	}()
+	v.Tx.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, p := range changed {
+		log.Debugf("process %x block count set to %d", p.ProcessId, p.BlockCount)
+		for _, l := range v.eventListeners {
+			l.OnProcessDurationChange(p.ProcessId, p.BlockCount)
+		}
+	}
+	return nil
+}
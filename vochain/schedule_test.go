@@ -0,0 +1,107 @@
+package vochain
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestTimedProcess(t *testing.T) {
+	app := TestBaseApplication(t)
+	// advance starts a new block step seconds after the previous one
+	advance := func(step time.Duration) {
+		endingHeight := int64(app.Height()) + 1
+		endingTime := time.Unix(app.TimestampStartBlock(), 0).Add(step)
+		app.endBlock(endingHeight, endingTime)
+		app.Commit()
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{
+			Time:   endingTime,
+			Height: endingHeight + 1,
+		}})
+	}
+	advance(time.Second)
+
+	now := app.TimestampStartBlock()
+	height := app.State.CurrentHeight()
+	p := &models.Process{
+		ProcessId:    util.RandomBytes(types.ProcessIDsize),
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:   util.RandomBytes(32),
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+	}
+
+	// the dates must be consistent
+	SetProcessSchedule(p, time.Unix(now+100, 0), time.Unix(now+50, 0))
+	qt.Assert(t, scheduleTimedProcess(p, height, now), qt.ErrorMatches, ".*must be after.*")
+	SetProcessSchedule(p, time.Time{}, time.Unix(now-10, 0))
+	qt.Assert(t, scheduleTimedProcess(p, height, now), qt.ErrorMatches, ".*must be after.*")
+
+	// the process starts on the next block and is estimated to last 9 blocks
+	SetProcessSchedule(p, time.Time{}, time.Unix(now+100, 0))
+	qt.Assert(t, scheduleTimedProcess(p, height, now), qt.IsNil)
+	qt.Assert(t, p.GetStartTime(), qt.Equals, now)
+	qt.Assert(t, p.StartBlock, qt.Equals, height+1)
+	qt.Assert(t, p.BlockCount, qt.Equals, uint32(9))
+	qt.Assert(t, app.State.AddProcess(p), qt.IsNil)
+
+	endBlock := func() uint32 {
+		p, err := app.State.Process(p.ProcessId, false)
+		qt.Assert(t, err, qt.IsNil)
+		return p.StartBlock + p.BlockCount
+	}
+
+	// blocks are faster than estimated, so the process is extended on its
+	// end block if the next block starts before its end date
+	for i := 0; i < 9; i++ {
+		advance(5 * time.Second)
+	}
+	qt.Assert(t, endBlock(), qt.Equals, height+10)
+	advance(5 * time.Second)
+	qt.Assert(t, endBlock(), qt.Equals, height+14)
+	qt.Assert(t, checkProcessSchedule(p, app.TimestampStartBlock()), qt.IsNil)
+
+	// the last block is the one started 10 seconds before the end date
+	for i := 0; i < 8; i++ {
+		advance(5 * time.Second)
+	}
+	qt.Assert(t, app.TimestampStartBlock(), qt.Equals, now+90)
+	qt.Assert(t, endBlock(), qt.Equals, app.State.CurrentHeight())
+	advance(5 * time.Second)
+	process, err := app.State.Process(p.ProcessId, false)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, process.Status, qt.Equals, models.ProcessStatus_ENDED)
+	qt.Assert(t, endBlock(), qt.Equals, height+18)
+
+	// blocks slower than estimated close the process on the first block
+	// started at its end date
+	p.ProcessId = util.RandomBytes(types.ProcessIDsize)
+	now, height = app.TimestampStartBlock(), app.State.CurrentHeight()
+	SetProcessSchedule(p, time.Time{}, time.Unix(now+100, 0))
+	qt.Assert(t, scheduleTimedProcess(p, height, now), qt.IsNil)
+	qt.Assert(t, app.State.AddProcess(p), qt.IsNil)
+	for i := 0; i < 4; i++ {
+		advance(20 * time.Second)
+	}
+	qt.Assert(t, endBlock(), qt.Equals, height+10)
+	advance(20 * time.Second)
+	qt.Assert(t, endBlock(), qt.Equals, app.State.CurrentHeight()-1)
+	qt.Assert(t, checkProcessSchedule(p, app.TimestampStartBlock()), qt.ErrorMatches,
+		".*not started or finished.*")
+	advance(20 * time.Second)
+	process, err = app.State.Process(p.ProcessId, false)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, process.Status, qt.Equals, models.ProcessStatus_ENDED)
+	pids, err := app.State.timedProcesses()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, pids, qt.HasLen, 0)
+}
@@ -1,57 +1,71 @@
 package scrutinizer
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/timshannon/badgerhold/v3"
+	"github.com/pressly/goose/v3"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
 )
 
 //go:generate go run github.com/kyleconroy/sqlc/cmd/sqlc@v1.12.0 generate
 
-// The string to search on the KV database error to identify a transaction conflict.
-// If the KV (currently badger) returns this error, it is considered non fatal and the
-// transaction will be retried until it works.
-// This check is made comparing string in order to avoid importing a specific KV
-// implementation.
-const kvErrorStringForRetry = "Transaction Conflict"
+// sqliteBusyTimeout is the time a connection waits for the database lock held
+// by another transaction before failing with a busy error, in milliseconds.
+const sqliteBusyTimeout = 10000
 
-// InitDB initializes a badgerhold db at the location given by dataDir
-func InitDB(dataDir string) (*badgerhold.Store, error) {
-	opts := badgerhold.DefaultOptions
-
-	// Note that these "With" options return a modified copy.
-	opts.Options = opts.WithCompression(0)
-	opts.Options = opts.WithBlockCacheSize(0)
-	opts.Options = opts.WithVerifyValueChecksum(false)
-	opts.Options = opts.WithDetectConflicts(true)
-
-	opts.SequenceBandwith = 10000
-	opts.Dir = dataDir
-	opts.ValueDir = dataDir
+// InitDB opens the sqlite database at sqlPath, applies the pending migrations
+// and sets up the full-text indexes.  Write transactions take the database
+// lock when they begin, so concurrent writers wait for each other instead of
+// failing on commit.
+func InitDB(sqlPath string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=wal&_txlock=immediate&_busy_timeout=%d",
+		sqlPath, sqliteBusyTimeout)
+	// sqlDB, err := sql.Open("sqlite", dsn) // modernc
+	sqlDB, err := sql.Open("sqlite3", dsn) // mattn
+	if err != nil {
+		return nil, err
+	}
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		return nil, err
+	}
+	// goose.SetLogger(log.Logger()) // TODO: interfaces aren't compatible
+	goose.SetBaseFS(embedMigrations)
+	if err := goose.Up(sqlDB, "migrations"); err != nil {
+		return nil, fmt.Errorf("goose up: %w", err)
+	}
+	if err := initFullTextSearch(sqlDB); err != nil {
+		return nil, err
+	}
+	return sqlDB, nil
+}
 
-	// TODO: support configurable logging
-	opts.Options = opts.WithLogger(nil)
-	opts.Logger = nil
+// db returns the database of the indexes.
+func (s *Scrutinizer) db() *sql.DB {
+	return s.sqlDB.Load().(*sql.DB)
+}
 
-	return badgerhold.Open(opts)
+func (s *Scrutinizer) timeoutQueries() (*scrutinizerdb.Queries, context.Context, context.CancelFunc) {
+	ctx := context.TODO()
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	queries := scrutinizerdb.New(s.db())
+	return queries, ctx, cancel
 }
 
-func (s *Scrutinizer) queryWithRetries(query func() error) error {
-	maxTries := 1000
-	for {
-		if err := query(); err != nil {
-			if strings.Contains(err.Error(), kvErrorStringForRetry) {
-				maxTries--
-				if maxTries == 0 {
-					return fmt.Errorf("cannot update record: max retires reached")
-				}
-				time.Sleep(time.Millisecond * 5)
-				continue
-			}
-			return fmt.Errorf("cannot update record: %w, ", err)
-		}
-		return nil
+// withTx runs fn with the queries of a new database transaction, which is
+// committed if fn does not return an error and rolled back otherwise.
+func (s *Scrutinizer) withTx(fn func(ctx context.Context, queries *scrutinizerdb.Queries) error) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
+	defer cancel()
+	tx, err := s.db().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(ctx, scrutinizerdb.New(tx)); err != nil {
+		return err
 	}
+	return tx.Commit()
 }
@@ -8,6 +8,26 @@ import (
 	"go.vocdoni.io/dvote/types"
 )
 
+type AccountBalance struct {
+	Account types.HexBytes
+	Height  int64
+	Balance int64
+}
+
+type Entity struct {
+	ID           string
+	CreationTime time.Time
+}
+
+type EntityMetadatum struct {
+	EntityID       types.HexBytes
+	InfoUri        string
+	Uri            string
+	Name           string
+	Description    string
+	FailedAttempts int64
+}
+
 type Process struct {
 	ID                types.ProcessID
 	EntityID          string
@@ -35,4 +55,76 @@ type Process struct {
 	CreationTime      time.Time
 	SourceBlockHeight int64
 	SourceNetworkID   string
+	StartDate         time.Time
+	EndDate           time.Time
+}
+
+type ProcessMetadatum struct {
+	ProcessID      types.ProcessID
+	Uri            string
+	Title          string
+	Description    string
+	Questions      string
+	FailedAttempts int64
+}
+
+type ReindexStatus struct {
+	ID     int64
+	Height int64
+}
+
+type Result struct {
+	ProcessID      types.ProcessID
+	Votes          string
+	Weight         string
+	EnvelopeHeight int64
+	EnvelopePb     types.EncodedProtoBuf
+	VoteOptsPb     types.EncodedProtoBuf
+	Signatures     string
+	Final          bool
+	BlockHeight    int64
+	RankedSeats    int64
+	Ballots        string
+	Ranked         string
+}
+
+type Transaction struct {
+	ID          int64
+	Hash        types.HexBytes
+	BlockHeight int64
+	BlockIndex  int64
+	TxType      string
+	Sender      types.HexBytes
+	Amount      int64
+	Cost        int64
+}
+
+type TransactionRecipient struct {
+	TxID    int64
+	Account types.HexBytes
+}
+
+type TxTypeStat struct {
+	TxType  string
+	TxCount int64
+	Amount  int64
+	Fees    int64
+}
+
+type Vote struct {
+	Nullifier      types.HexBytes
+	ProcessID      types.ProcessID
+	Height         int64
+	Weight         string
+	TxIndex        int64
+	QuestionIndex  int64
+	OverwriteCount int64
+	CreationTime   time.Time
+}
+
+type VoteOverwrite struct {
+	Nullifier    types.HexBytes
+	Height       int64
+	TxIndex      int64
+	CreationTime time.Time
 }
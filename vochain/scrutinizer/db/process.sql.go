@@ -11,6 +11,17 @@ import (
 	"go.vocdoni.io/dvote/types"
 )
 
+const countProcesses = `-- name: CountProcesses :one
+SELECT COUNT(*) FROM processes
+`
+
+func (q *Queries) CountProcesses(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countProcesses)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createProcess = `-- name: CreateProcess :execresult
 INSERT INTO processes (
 	id, entity_id, entity_index, start_block, end_block,
@@ -21,7 +32,8 @@ INSERT INTO processes (
 	envelope_pb, mode_pb, vote_opts_pb,
 	private_keys, public_keys,
 	question_index, creation_time,
-	source_block_height, source_network_id
+	source_block_height, source_network_id,
+	start_date, end_date
 ) VALUES (
 	?, ?, ?, ?, ?,
 	?, ?, ?,
@@ -31,6 +43,7 @@ INSERT INTO processes (
 	?, ?, ?,
 	?, ?,
 	?, ?,
+	?, ?,
 	?, ?
 )
 `
@@ -62,6 +75,8 @@ type CreateProcessParams struct {
 	CreationTime      time.Time
 	SourceBlockHeight int64
 	SourceNetworkID   string
+	StartDate         time.Time
+	EndDate           time.Time
 }
 
 func (q *Queries) CreateProcess(ctx context.Context, arg CreateProcessParams) (sql.Result, error) {
@@ -92,11 +107,25 @@ func (q *Queries) CreateProcess(ctx context.Context, arg CreateProcessParams) (s
 		arg.CreationTime,
 		arg.SourceBlockHeight,
 		arg.SourceNetworkID,
+		arg.StartDate,
+		arg.EndDate,
 	)
 }
 
+const getEntityProcessCount = `-- name: GetEntityProcessCount :one
+SELECT COUNT(*) FROM processes
+WHERE entity_id = ?
+`
+
+func (q *Queries) GetEntityProcessCount(ctx context.Context, entityID string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getEntityProcessCount, entityID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const getProcess = `-- name: GetProcess :one
-SELECT id, entity_id, entity_index, start_block, end_block, results_height, have_results, final_results, census_root, rolling_census_root, rolling_census_size, max_census_size, census_uri, metadata, census_origin, status, namespace, envelope_pb, mode_pb, vote_opts_pb, private_keys, public_keys, question_index, creation_time, source_block_height, source_network_id FROM processes
+SELECT id, entity_id, entity_index, start_block, end_block, results_height, have_results, final_results, census_root, rolling_census_root, rolling_census_size, max_census_size, census_uri, metadata, census_origin, status, namespace, envelope_pb, mode_pb, vote_opts_pb, private_keys, public_keys, question_index, creation_time, source_block_height, source_network_id, start_date, end_date FROM processes
 WHERE id = ?
 LIMIT 1
 `
@@ -131,10 +160,68 @@ func (q *Queries) GetProcess(ctx context.Context, id types.ProcessID) (Process,
 		&i.CreationTime,
 		&i.SourceBlockHeight,
 		&i.SourceNetworkID,
+		&i.StartDate,
+		&i.EndDate,
 	)
 	return i, err
 }
 
+const getProcessIDsByFinalResults = `-- name: GetProcessIDsByFinalResults :many
+SELECT id FROM processes
+WHERE final_results = ?
+`
+
+func (q *Queries) GetProcessIDsByFinalResults(ctx context.Context, finalResults bool) ([]types.ProcessID, error) {
+	rows, err := q.db.QueryContext(ctx, getProcessIDsByFinalResults, finalResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []types.ProcessID
+	for rows.Next() {
+		var id types.ProcessID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getProcessIDsByResultsHeight = `-- name: GetProcessIDsByResultsHeight :many
+SELECT id FROM processes
+WHERE results_height = ?
+`
+
+func (q *Queries) GetProcessIDsByResultsHeight(ctx context.Context, resultsHeight int64) ([]types.ProcessID, error) {
+	rows, err := q.db.QueryContext(ctx, getProcessIDsByResultsHeight, resultsHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []types.ProcessID
+	for rows.Next() {
+		var id types.ProcessID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getProcessStatus = `-- name: GetProcessStatus :one
 SELECT status FROM processes
 WHERE id = ?
@@ -259,7 +346,8 @@ SET end_block           = ?,
 	public_keys         = ?,
 	metadata            = ?,
 	rolling_census_size = ?,
-	status              = ?
+	status              = ?,
+	question_index      = ?
 WHERE id = ?
 `
 
@@ -273,6 +361,7 @@ type UpdateProcessFromStateParams struct {
 	Metadata          string
 	RollingCensusSize int64
 	Status            int64
+	QuestionIndex     int64
 	ID                types.ProcessID
 }
 
@@ -287,6 +376,7 @@ func (q *Queries) UpdateProcessFromState(ctx context.Context, arg UpdateProcessF
 		arg.Metadata,
 		arg.RollingCensusSize,
 		arg.Status,
+		arg.QuestionIndex,
 		arg.ID,
 	)
 }
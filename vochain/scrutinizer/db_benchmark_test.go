@@ -90,7 +90,7 @@ func benchmarkFetchTx(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		sc.Rollback()
 		for j := 0; j < numTxs; j++ {
-			sc.OnNewTx([]byte(fmt.Sprintf("hash%d%d", i, j)), uint32(i), int32(j))
+			sc.OnNewTx(testVoteTx(fmt.Sprintf("hash%d%d", i, j)), uint32(i), int32(j))
 		}
 		err = sc.Commit(uint32(i))
 		qt.Assert(b, err, qt.IsNil)
@@ -106,7 +106,7 @@ func benchmarkFetchTx(b *testing.B) {
 			numTxs, (i+1)*numTxs, time.Since(startTime))
 		startTime = time.Now()
 		for j := 0; j < numTxs; j++ {
-			_, err = sc.GetTxHashReference([]byte(fmt.Sprintf("hash%d%d", i, j)))
+			_, err = sc.GetTxHashReference(testVoteTx(fmt.Sprintf("hash%d%d", i, j)).TxID[:])
 			qt.Assert(b, err, qt.IsNil)
 		}
 		log.Infof("fetched %d transactions (out of %d total) by hash, took %s",
@@ -0,0 +1,46 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package scrutinizer
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+)
+
+// fullTextSearch is true when the metadata is indexed by the FTS5 tables,
+// which go-sqlite3 only builds with the sqlite_fts5 tag.
+const fullTextSearch = true
+
+//go:embed fts5.sql
+var fts5Schema string
+
+// initFullTextSearch creates the FTS5 tables indexing the metadata, and their
+// triggers, if they do not exist yet.  If the triggers are missing, because
+// the database is new or was used by a build without FTS5, the indexes are
+// rebuilt from the metadata tables.
+func initFullTextSearch(sqlDB *sql.DB) error {
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var triggers int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'trigger' AND name LIKE '%_metadata_after_%'`).Scan(&triggers); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fts5Schema); err != nil {
+		return fmt.Errorf("cannot create the full-text indexes: %w", err)
+	}
+	if triggers == 0 {
+		for _, table := range []string{"process_search", "entity_search"} {
+			if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s(%s) VALUES ('rebuild')`,
+				table, table)); err != nil {
+				return fmt.Errorf("cannot rebuild %s: %w", table, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,24 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package scrutinizer
+
+import "database/sql"
+
+// fullTextSearch is false when go-sqlite3 is built without FTS5, and the
+// metadata is searched by substring instead.
+const fullTextSearch = false
+
+// initFullTextSearch drops the triggers of the FTS5 indexes, left by a build
+// with FTS5, since the metadata cannot be updated while they exist.  The
+// indexes are rebuilt by the next build with FTS5.
+func initFullTextSearch(sqlDB *sql.DB) error {
+	_, err := sqlDB.Exec(`
+		DROP TRIGGER IF EXISTS process_metadata_after_insert;
+		DROP TRIGGER IF EXISTS process_metadata_after_delete;
+		DROP TRIGGER IF EXISTS process_metadata_after_update;
+		DROP TRIGGER IF EXISTS entity_metadata_after_insert;
+		DROP TRIGGER IF EXISTS entity_metadata_after_delete;
+		DROP TRIGGER IF EXISTS entity_metadata_after_update;`)
+	return err
+}
@@ -0,0 +1,46 @@
+-- The full-text indexes of the metadata tables, by rowid, kept up to date by
+-- triggers.  Only created on the builds with the sqlite_fts5 tag.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS process_search USING fts5(
+  title, description, questions,
+  content='process_metadata', tokenize='unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS process_metadata_after_insert AFTER INSERT ON process_metadata BEGIN
+  INSERT INTO process_search(rowid, title, description, questions)
+  VALUES (new.rowid, new.title, new.description, new.questions);
+END;
+
+CREATE TRIGGER IF NOT EXISTS process_metadata_after_delete AFTER DELETE ON process_metadata BEGIN
+  INSERT INTO process_search(process_search, rowid, title, description, questions)
+  VALUES ('delete', old.rowid, old.title, old.description, old.questions);
+END;
+
+CREATE TRIGGER IF NOT EXISTS process_metadata_after_update AFTER UPDATE ON process_metadata BEGIN
+  INSERT INTO process_search(process_search, rowid, title, description, questions)
+  VALUES ('delete', old.rowid, old.title, old.description, old.questions);
+  INSERT INTO process_search(rowid, title, description, questions)
+  VALUES (new.rowid, new.title, new.description, new.questions);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS entity_search USING fts5(
+  name, description,
+  content='entity_metadata', tokenize='unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS entity_metadata_after_insert AFTER INSERT ON entity_metadata BEGIN
+  INSERT INTO entity_search(rowid, name, description)
+  VALUES (new.rowid, new.name, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS entity_metadata_after_delete AFTER DELETE ON entity_metadata BEGIN
+  INSERT INTO entity_search(entity_search, rowid, name, description)
+  VALUES ('delete', old.rowid, old.name, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS entity_metadata_after_update AFTER UPDATE ON entity_metadata BEGIN
+  INSERT INTO entity_search(entity_search, rowid, name, description)
+  VALUES ('delete', old.rowid, old.name, old.description);
+  INSERT INTO entity_search(rowid, name, description)
+  VALUES (new.rowid, new.name, new.description);
+END;
@@ -0,0 +1,438 @@
+package indexertypes
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// RankedBallots holds the weight of each distinct ranking cast on a ranked
+// choice process, indexed by the comma separated list of options.
+type RankedBallots map[string]*types.BigInt
+
+// Add adds the weight of a ranking, most preferred option first.
+func (b RankedBallots) Add(ranking []int, weight *big.Int) {
+	values := make([]string, len(ranking))
+	for i, o := range ranking {
+		values[i] = strconv.Itoa(o)
+	}
+	b.add(strings.Join(values, ","), weight)
+}
+
+func (b RankedBallots) add(key string, weight *big.Int) {
+	if b[key] == nil {
+		b[key] = new(types.BigInt).SetUint64(0)
+	}
+	b[key].Add(b[key], (*types.BigInt)(weight))
+}
+
+// RankedResults holds the results of a ranked choice process.
+type RankedResults struct {
+	InstantRunoff *RankedTally      `json:"instantRunoff"`
+	STV           *RankedTally      `json:"stv"`
+	Condorcet     *CondorcetResults `json:"condorcet"`
+}
+
+// RankedTally holds the round by round count of an Instant-Runoff or Single
+// Transferable Vote election.
+type RankedTally struct {
+	Seats int `json:"seats"`
+	// Quota is the Droop quota of the Single Transferable Vote count
+	Quota   *types.BigInt  `json:"quota,omitempty"`
+	Rounds  []*RankedRound `json:"rounds"`
+	Elected []int          `json:"elected"`
+}
+
+// RankedRound holds the tally of a round of a ranked choice count.  The
+// tallies are rounded down on fractional surplus transfers.
+type RankedRound struct {
+	Tallies    []*types.BigInt `json:"tallies"`
+	Exhausted  *types.BigInt   `json:"exhausted"`
+	Elected    []int           `json:"elected,omitempty"`
+	Eliminated []int           `json:"eliminated,omitempty"`
+}
+
+// CondorcetResults holds the pairwise comparison of the options of a ranked
+// choice process.
+type CondorcetResults struct {
+	// Pairwise holds, for each pair of options i and j, the weight of the
+	// ballots that prefer i over j
+	Pairwise [][]*types.BigInt `json:"pairwise"`
+	// Winner is the option preferred over every other option, or -1 if
+	// there is none
+	Winner int `json:"winner"`
+	// Ranking is the Schulze method ranking of the options
+	Ranking []int `json:"ranking"`
+}
+
+// Proto returns the protobuf representation of the ranked results, published
+// by the oracles along with the process results.  Returns nil if r is nil.
+func (r *RankedResults) Proto() *models.RankedResults {
+	if r == nil {
+		return nil
+	}
+	pr := &models.RankedResults{
+		InstantRunoff: r.InstantRunoff.proto(),
+		Stv:           r.STV.proto(),
+	}
+	if c := r.Condorcet; c != nil {
+		pr.Condorcet = &models.CondorcetResults{
+			Winner:  int32(c.Winner),
+			Ranking: uint32Slice(c.Ranking),
+		}
+		for _, row := range c.Pairwise {
+			pr.Condorcet.Pairwise = append(pr.Condorcet.Pairwise,
+				&models.QuestionResult{Question: bigIntsBytes(row)})
+		}
+	}
+	return pr
+}
+
+func (t *RankedTally) proto() *models.RankedTally {
+	if t == nil {
+		return nil
+	}
+	pt := &models.RankedTally{
+		Seats:   uint32(t.Seats),
+		Elected: uint32Slice(t.Elected),
+	}
+	if t.Quota != nil {
+		pt.Quota = t.Quota.Bytes()
+	}
+	for _, round := range t.Rounds {
+		pr := &models.RankedRound{
+			Tallies:    bigIntsBytes(round.Tallies),
+			Elected:    uint32Slice(round.Elected),
+			Eliminated: uint32Slice(round.Eliminated),
+		}
+		if round.Exhausted != nil {
+			pr.Exhausted = round.Exhausted.Bytes()
+		}
+		pt.Rounds = append(pt.Rounds, pr)
+	}
+	return pt
+}
+
+// RankedResultsFromProto returns the ranked results published by an oracle.
+// Returns nil if pr is nil.
+func RankedResultsFromProto(pr *models.RankedResults) *RankedResults {
+	if pr == nil {
+		return nil
+	}
+	r := &RankedResults{
+		InstantRunoff: rankedTallyFromProto(pr.InstantRunoff),
+		STV:           rankedTallyFromProto(pr.Stv),
+	}
+	if c := pr.Condorcet; c != nil {
+		r.Condorcet = &CondorcetResults{
+			Pairwise: [][]*types.BigInt{},
+			Winner:   int(c.Winner),
+			Ranking:  intSlice(c.Ranking),
+		}
+		for _, row := range c.Pairwise {
+			r.Condorcet.Pairwise = append(r.Condorcet.Pairwise, bigIntsFromBytes(row.GetQuestion()))
+		}
+	}
+	return r
+}
+
+func rankedTallyFromProto(pt *models.RankedTally) *RankedTally {
+	if pt == nil {
+		return nil
+	}
+	t := &RankedTally{
+		Seats:   int(pt.Seats),
+		Rounds:  []*RankedRound{},
+		Elected: intSlice(pt.Elected),
+	}
+	if pt.Quota != nil {
+		t.Quota = new(types.BigInt).SetBytes(pt.Quota)
+	}
+	for _, pr := range pt.Rounds {
+		round := &RankedRound{
+			Tallies:   bigIntsFromBytes(pr.Tallies),
+			Exhausted: new(types.BigInt).SetBytes(pr.Exhausted),
+		}
+		if len(pr.Elected) > 0 {
+			round.Elected = intSlice(pr.Elected)
+		}
+		if len(pr.Eliminated) > 0 {
+			round.Eliminated = intSlice(pr.Eliminated)
+		}
+		t.Rounds = append(t.Rounds, round)
+	}
+	return t
+}
+
+func uint32Slice(values []int) []uint32 {
+	s := make([]uint32, len(values))
+	for i, v := range values {
+		s[i] = uint32(v)
+	}
+	return s
+}
+
+func intSlice(values []uint32) []int {
+	s := make([]int, len(values))
+	for i, v := range values {
+		s[i] = int(v)
+	}
+	return s
+}
+
+func bigIntsBytes(values []*types.BigInt) [][]byte {
+	b := make([][]byte, len(values))
+	for i, v := range values {
+		b[i] = v.Bytes()
+	}
+	return b
+}
+
+func bigIntsFromBytes(values [][]byte) []*types.BigInt {
+	s := make([]*types.BigInt, len(values))
+	for i, v := range values {
+		s[i] = new(types.BigInt).SetBytes(v)
+	}
+	return s
+}
+
+type rankedBallot struct {
+	ranking []int
+	weight  *big.Int
+	value   *big.Rat
+}
+
+// ComputeRankedResults computes the Instant-Runoff, Single Transferable Vote
+// (electing seats options) and Condorcet results of the ballots of a ranked
+// choice process with the given number of options.
+func ComputeRankedResults(ballots RankedBallots, options, seats int) *RankedResults {
+	keys := make([]string, 0, len(ballots))
+	for k := range ballots {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parsed := []*rankedBallot{}
+	for _, k := range keys {
+		b := &rankedBallot{weight: ballots[k].ToInt()}
+		for _, v := range strings.Split(k, ",") {
+			if o, err := strconv.Atoi(v); err == nil && o >= 0 && o < options {
+				b.ranking = append(b.ranking, o)
+			}
+		}
+		parsed = append(parsed, b)
+	}
+	return &RankedResults{
+		InstantRunoff: countRanked(parsed, options, 1, true),
+		STV:           countRanked(parsed, options, seats, false),
+		Condorcet:     condorcet(parsed, options),
+	}
+}
+
+const (
+	rankedContinuing = iota
+	rankedElected
+	rankedEliminated
+)
+
+// countRanked counts the ballots round by round.  On Instant-Runoff, an
+// option is elected once it has the majority of the non exhausted ballots.
+// On Single Transferable Vote, options are elected once they reach the Droop
+// quota, and their surplus is transferred to the next preferences of their
+// ballots with the Gregory method.  On each round without elected options,
+// the least voted option is eliminated (the highest index on ties).
+func countRanked(ballots []*rankedBallot, options, seats int, irv bool) *RankedTally {
+	tally := &RankedTally{Seats: seats, Elected: []int{}}
+	total := new(big.Rat)
+	for _, b := range ballots {
+		b.value = new(big.Rat).SetInt(b.weight)
+		total.Add(total, b.value)
+	}
+	var quota *big.Rat
+	if !irv {
+		q := new(big.Int).Quo(total.Num(), new(big.Int).Mul(total.Denom(), big.NewInt(int64(seats+1))))
+		q.Add(q, big.NewInt(1))
+		quota = new(big.Rat).SetInt(q)
+		tally.Quota = (*types.BigInt)(q)
+	}
+	status := make([]int, options)
+	current := make([]int, len(ballots))
+	for len(tally.Elected) < seats {
+		continuing := []int{}
+		for o, s := range status {
+			if s == rankedContinuing {
+				continuing = append(continuing, o)
+			}
+		}
+		if len(continuing) == 0 {
+			break
+		}
+		votes := make([]*big.Rat, options)
+		for o := range votes {
+			votes[o] = new(big.Rat)
+		}
+		exhausted := new(big.Rat)
+		for i, b := range ballots {
+			current[i] = -1
+			for _, o := range b.ranking {
+				if status[o] == rankedContinuing {
+					current[i] = o
+					break
+				}
+			}
+			if current[i] < 0 {
+				exhausted.Add(exhausted, b.value)
+			} else {
+				votes[current[i]].Add(votes[current[i]], b.value)
+			}
+		}
+		round := &RankedRound{Exhausted: floorRat(exhausted)}
+		for _, v := range votes {
+			round.Tallies = append(round.Tallies, floorRat(v))
+		}
+		tally.Rounds = append(tally.Rounds, round)
+		// most voted first, the lowest index on ties
+		sort.SliceStable(continuing, func(i, j int) bool {
+			return votes[continuing[i]].Cmp(votes[continuing[j]]) > 0
+		})
+		// if the remaining options fill the seats, all of them are elected
+		if len(continuing) <= seats-len(tally.Elected) {
+			round.Elected = continuing
+			tally.Elected = append(tally.Elected, continuing...)
+			break
+		}
+		for _, o := range continuing {
+			if len(tally.Elected) == seats {
+				break
+			}
+			if irv {
+				active := new(big.Rat).Sub(total, exhausted)
+				if new(big.Rat).Add(votes[o], votes[o]).Cmp(active) <= 0 {
+					break
+				}
+			} else if votes[o].Cmp(quota) < 0 {
+				break
+			}
+			status[o] = rankedElected
+			round.Elected = append(round.Elected, o)
+			tally.Elected = append(tally.Elected, o)
+			if irv {
+				continue
+			}
+			// transfer the surplus
+			ratio := new(big.Rat).Sub(votes[o], quota)
+			ratio.Quo(ratio, votes[o])
+			for i, b := range ballots {
+				if current[i] == o {
+					b.value.Mul(b.value, ratio)
+				}
+			}
+		}
+		if len(round.Elected) > 0 {
+			continue
+		}
+		loser := continuing[len(continuing)-1]
+		for _, o := range continuing {
+			if votes[o].Cmp(votes[loser]) == 0 && o > loser {
+				loser = o
+			}
+		}
+		status[loser] = rankedEliminated
+		round.Eliminated = []int{loser}
+	}
+	return tally
+}
+
+// condorcet computes the pairwise preferences of the ballots, the Condorcet
+// winner and the Schulze ranking.  Ranked options are preferred over the not
+// ranked ones.
+func condorcet(ballots []*rankedBallot, options int) *CondorcetResults {
+	d := make([][]*big.Int, options)
+	for i := range d {
+		d[i] = make([]*big.Int, options)
+		for j := range d[i] {
+			d[i][j] = new(big.Int)
+		}
+	}
+	for _, b := range ballots {
+		pos := make([]int, options)
+		for o := range pos {
+			pos[o] = options
+		}
+		for p, o := range b.ranking {
+			pos[o] = p
+		}
+		for i := 0; i < options; i++ {
+			for j := 0; j < options; j++ {
+				if pos[i] < pos[j] {
+					d[i][j].Add(d[i][j], b.weight)
+				}
+			}
+		}
+	}
+	res := &CondorcetResults{Winner: -1}
+	for i := range d {
+		row := []*types.BigInt{}
+		wins := 0
+		for j := range d[i] {
+			row = append(row, (*types.BigInt)(new(big.Int).Set(d[i][j])))
+			if d[i][j].Cmp(d[j][i]) > 0 {
+				wins++
+			}
+		}
+		res.Pairwise = append(res.Pairwise, row)
+		if wins == options-1 {
+			res.Winner = i
+		}
+	}
+	// strongest paths
+	p := make([][]*big.Int, options)
+	for i := range p {
+		p[i] = make([]*big.Int, options)
+		for j := range p[i] {
+			p[i][j] = new(big.Int)
+			if i != j && d[i][j].Cmp(d[j][i]) > 0 {
+				p[i][j].Set(d[i][j])
+			}
+		}
+	}
+	for k := 0; k < options; k++ {
+		for i := 0; i < options; i++ {
+			if i == k {
+				continue
+			}
+			for j := 0; j < options; j++ {
+				if j == i || j == k {
+					continue
+				}
+				s := p[i][k]
+				if p[k][j].Cmp(s) < 0 {
+					s = p[k][j]
+				}
+				if s.Cmp(p[i][j]) > 0 {
+					p[i][j].Set(s)
+				}
+			}
+		}
+	}
+	wins := make([]int, options)
+	for i := 0; i < options; i++ {
+		res.Ranking = append(res.Ranking, i)
+		for j := 0; j < options; j++ {
+			if p[i][j].Cmp(p[j][i]) > 0 {
+				wins[i]++
+			}
+		}
+	}
+	sort.SliceStable(res.Ranking, func(i, j int) bool {
+		return wins[res.Ranking[i]] > wins[res.Ranking[j]]
+	})
+	return res
+}
+
+func floorRat(r *big.Rat) *types.BigInt {
+	return (*types.BigInt)(new(big.Int).Quo(r.Num(), r.Denom()))
+}
@@ -2,12 +2,17 @@ package indexertypes
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
 
 	"go.vocdoni.io/dvote/types"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
 	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -19,7 +24,7 @@ const (
 
 // Results holds the final results and relevant process info for a vochain process
 type Results struct {
-	ProcessID      types.HexBytes             `badgerholdKey:"ProcessID" json:"processId"`
+	ProcessID      types.HexBytes             `json:"processId"`
 	Votes          [][]*types.BigInt          `json:"votes"`
 	Weight         *types.BigInt              `json:"weight"`
 	EnvelopeHeight uint64                     `json:"envelopeHeight"`
@@ -28,6 +33,98 @@ type Results struct {
 	Signatures     []types.HexBytes           `json:"signatures"`
 	Final          bool                       `json:"final"`
 	BlockHeight    uint32                     `json:"blockHeight"`
+	// RankedSeats is the number of options elected on ranked choice
+	// processes, whose ballots are kept to compute the Ranked results
+	RankedSeats uint32         `json:"-"`
+	Ballots     RankedBallots  `json:"-"`
+	Ranked      *RankedResults `json:"ranked,omitempty"`
+}
+
+// ResultsFromDB decodes the results stored in the database.
+func ResultsFromDB(dbresults *scrutinizerdb.Result) (*Results, error) {
+	results := &Results{
+		ProcessID:      types.HexBytes(dbresults.ProcessID),
+		Weight:         new(types.BigInt),
+		EnvelopeHeight: uint64(dbresults.EnvelopeHeight),
+		EnvelopeType:   new(models.EnvelopeType),
+		VoteOpts:       new(models.ProcessVoteOptions),
+		Signatures:     []types.HexBytes{},
+		Final:          dbresults.Final,
+		BlockHeight:    uint32(dbresults.BlockHeight),
+		RankedSeats:    uint32(dbresults.RankedSeats),
+	}
+	if err := json.Unmarshal([]byte(dbresults.Votes), &results.Votes); err != nil {
+		return nil, fmt.Errorf("cannot decode votes: %w", err)
+	}
+	if err := results.Weight.UnmarshalText([]byte(dbresults.Weight)); err != nil {
+		return nil, fmt.Errorf("cannot decode weight: %w", err)
+	}
+	if err := proto.Unmarshal(dbresults.EnvelopePb, results.EnvelopeType); err != nil {
+		return nil, fmt.Errorf("cannot decode envelope type: %w", err)
+	}
+	if err := proto.Unmarshal(dbresults.VoteOptsPb, results.VoteOpts); err != nil {
+		return nil, fmt.Errorf("cannot decode vote options: %w", err)
+	}
+	for _, sig := range nonEmptySplit(dbresults.Signatures, ",") {
+		signature, err := hex.DecodeString(sig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode signature: %w", err)
+		}
+		results.Signatures = append(results.Signatures, signature)
+	}
+	if err := json.Unmarshal([]byte(dbresults.Ballots), &results.Ballots); err != nil {
+		return nil, fmt.Errorf("cannot decode ranked ballots: %w", err)
+	}
+	if err := json.Unmarshal([]byte(dbresults.Ranked), &results.Ranked); err != nil {
+		return nil, fmt.Errorf("cannot decode ranked results: %w", err)
+	}
+	return results, nil
+}
+
+// ResultsToDB encodes the results to be stored in the database.
+func ResultsToDB(r *Results) (scrutinizerdb.SetResultsParams, error) {
+	params := scrutinizerdb.SetResultsParams{
+		ProcessID:      r.ProcessID,
+		EnvelopeHeight: int64(r.EnvelopeHeight),
+		Final:          r.Final,
+		BlockHeight:    int64(r.BlockHeight),
+		RankedSeats:    int64(r.RankedSeats),
+	}
+	votes, err := json.Marshal(r.Votes)
+	if err != nil {
+		return params, err
+	}
+	params.Votes = string(votes)
+	weight := new(types.BigInt)
+	if r.Weight != nil {
+		weight = r.Weight
+	}
+	params.Weight = weight.String()
+	if params.EnvelopePb, err = proto.Marshal(r.EnvelopeType); err != nil {
+		return params, err
+	}
+	if params.VoteOptsPb, err = proto.Marshal(r.VoteOpts); err != nil {
+		return params, err
+	}
+	// the pb columns are not nullable
+	params.EnvelopePb = append(types.EncodedProtoBuf{}, params.EnvelopePb...)
+	params.VoteOptsPb = append(types.EncodedProtoBuf{}, params.VoteOptsPb...)
+	signatures := []string{}
+	for _, sig := range r.Signatures {
+		signatures = append(signatures, hex.EncodeToString(sig))
+	}
+	params.Signatures = strings.Join(signatures, ",")
+	ballots, err := json.Marshal(r.Ballots)
+	if err != nil {
+		return params, err
+	}
+	params.Ballots = string(ballots)
+	ranked, err := json.Marshal(r.Ranked)
+	if err != nil {
+		return params, err
+	}
+	params.Ranked = string(ranked)
+	return params, nil
 }
 
 // String formats the results in a human-readable string
@@ -54,6 +151,12 @@ func (r *Results) Add(new *Results) error {
 		r.BlockHeight = new.BlockHeight
 	}
 	r.EnvelopeHeight += new.EnvelopeHeight
+	for k, w := range new.Ballots {
+		if r.Ballots == nil {
+			r.Ballots = make(RankedBallots)
+		}
+		r.Ballots.add(k, w.ToInt())
+	}
 	// Update votes only if present
 	if len(new.Votes) == 0 {
 		return nil
@@ -80,6 +183,48 @@ func (r *Results) Add(new *Results) error {
 // AddVote adds the voteValues and weight to the Results struct.
 // Checks are performed according the Ballot Protocol.
 func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex) error {
+	return r.addVote(0, voteValues, weight, false, mutex)
+}
+
+// RemoveVote subtracts the voteValues and weight of a vote previously added to
+// the Results struct, which has been overwritten by a new vote.  On partial
+// results, the subtracted values might be negative until they are added to
+// the stored results.
+func (r *Results) RemoveVote(voteValues []int, weight *big.Int, mutex *sync.Mutex) error {
+	return r.addVote(0, voteValues, weight, true, mutex)
+}
+
+// AddSerialVote adds the vote of a serial process to the Results struct. On
+// serial processes each envelope contains a single value for the question
+// questionIndex.
+func (r *Results) AddSerialVote(questionIndex uint32, voteValues []int, weight *big.Int,
+	mutex *sync.Mutex) error {
+	if len(voteValues) != 1 {
+		return fmt.Errorf("addSerialVote: expected a single value, got %d", len(voteValues))
+	}
+	if r.VoteOpts != nil && questionIndex >= r.VoteOpts.MaxCount {
+		return fmt.Errorf("addSerialVote: question index overflow %d", questionIndex)
+	}
+	return r.addVote(int(questionIndex), voteValues, weight, false, mutex)
+}
+
+// RemoveSerialVote subtracts the vote of a serial process previously added to
+// the Results struct, which has been overwritten by a new vote.
+func (r *Results) RemoveSerialVote(questionIndex uint32, voteValues []int, weight *big.Int,
+	mutex *sync.Mutex) error {
+	if len(voteValues) != 1 {
+		return fmt.Errorf("removeSerialVote: expected a single value, got %d", len(voteValues))
+	}
+	if r.VoteOpts != nil && questionIndex >= r.VoteOpts.MaxCount {
+		return fmt.Errorf("removeSerialVote: question index overflow %d", questionIndex)
+	}
+	return r.addVote(int(questionIndex), voteValues, weight, true, mutex)
+}
+
+// addVote adds the voteValues, starting at question offset, and weight to the
+// Results struct.  If remove is true, they are subtracted instead.
+func (r *Results) addVote(offset int, voteValues []int, weight *big.Int, remove bool,
+	mutex *sync.Mutex) error {
 	if r.VoteOpts == nil {
 		return fmt.Errorf("addVote: processVoteOptions is nil")
 	}
@@ -93,7 +238,7 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 		return fmt.Errorf("addVote: envelopeType is nil")
 	}
 	// MaxCount
-	if len(voteValues) > int(r.VoteOpts.MaxCount) || len(voteValues) > MaxOptions {
+	if offset+len(voteValues) > int(r.VoteOpts.MaxCount) || len(voteValues) > MaxOptions {
 		return fmt.Errorf("max count overflow %d", len(voteValues))
 	}
 
@@ -145,14 +290,31 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 	if weight == nil {
 		weight = new(big.Int).SetUint64(1)
 	}
+	// The values of a removed vote are added with a negative weight. The
+	// EnvelopeHeight of partial results might wrap around, but it is added
+	// to the stored results with modular arithmetic.
+	sign := big.NewInt(1)
+	if remove {
+		sign.Neg(sign)
+		weight = new(big.Int).Neg(weight)
+		r.EnvelopeHeight--
+	} else {
+		// Increase EnvelopeHeight by the number of votes added
+		r.EnvelopeHeight++
+	}
 
 	// Add the Election weight (tells how much voting power have already been processed)
 	r.Weight.Add(r.Weight, (*types.BigInt)(weight))
 	if len(r.Votes) == 0 {
 		r.Votes = NewEmptyVotes(int(r.VoteOpts.MaxCount), int(r.VoteOpts.MaxValue)+1)
 	}
-	// Increase EnvelopeHeight by the number of votes added
-	r.EnvelopeHeight++
+	// Keep the rankings of ranked choice processes
+	if r.RankedSeats > 0 {
+		if r.Ballots == nil {
+			r.Ballots = make(RankedBallots)
+		}
+		r.Ballots.Add(voteValues, weight)
+	}
 
 	// If MaxValue is zero, consider discrete value couting. So for each questoin, the value
 	// is aggregated. The weight is multiplied for the value if costFromWeight=False.
@@ -161,7 +323,7 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 	if r.VoteOpts.MaxValue == 0 {
 		// If CostFromWeight, the Weight is used for computing the cost and not as a value multiplier
 		if r.EnvelopeType.CostFromWeight {
-			weight = new(big.Int).SetUint64(1)
+			weight = sign
 		}
 		// Example if maxValue=0 and CostFromWeight=false
 		// Vote1: [1, 2, 3] w=10
@@ -171,7 +333,8 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 		//
 		// If CostFromWeight=true then we assume the weight is already represented on the vote value.
 		// This is why we set weight=1.
-		for q, value := range voteValues {
+		for i, value := range voteValues {
+			q := offset + i
 			r.Votes[q][0].Add(
 				r.Votes[q][0],
 				new(types.BigInt).Mul(
@@ -182,7 +345,8 @@ func (r *Results) AddVote(voteValues []int, weight *big.Int, mutex *sync.Mutex)
 	} else {
 		// For the other cases, we use the results matrix index weighted
 		// as described in the Ballot Protocol.
-		for q, opt := range voteValues {
+		for i, opt := range voteValues {
+			q := offset + i
 			r.Votes[q][opt].Add(r.Votes[q][opt], (*types.BigInt)(weight))
 		}
 	}
@@ -13,40 +13,25 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-const (
-	// CountStoreEntities is the key for the entity count db reference
-	CountStoreEntities = uint8(iota)
-	// CountStoreProcesses is the key for the process count db reference
-	CountStoreProcesses
-	// CountStoreEnvelopes is the key for the envelope count db reference
-	CountStoreEnvelopes
-	// CountStoreTransactions is the key for the transaction count db reference
-	CountStoreTransactions
-)
-
-// CountStore holds the count of envelopes, processes, entities, or transactions
-type CountStore struct {
-	Type  uint8 `badgerholdKey:"Type"`
-	Count uint64
-}
-
 // Process represents an election process handled by the Vochain.
 // The scrutinizer Process data type is different from the vochain state data type
 // since it is optimized for querying purposes and not for keeping a shared consensus state.
 type Process struct {
-	ID                types.HexBytes             `badgerholdKey:"ID" json:"processId"`
-	EntityID          types.HexBytes             `badgerholdIndex:"EntityID" json:"entityId"`
+	ID                types.HexBytes             `json:"processId"`
+	EntityID          types.HexBytes             `json:"entityId"`
 	EntityIndex       uint32                     `json:"entityIndex"`
 	StartBlock        uint32                     `json:"startBlock"`
-	EndBlock          uint32                     `badgerholdIndex:"EndBlock" json:"endBlock"`
-	Rheight           uint32                     `badgerholdIndex:"Rheight" json:"-"`
+	EndBlock          uint32                     `json:"endBlock"`
+	Rheight           uint32                     `json:"-"`
+	StartDate         time.Time                  `json:"startDate"`
+	EndDate           time.Time                  `json:"endDate"`
 	CensusRoot        types.HexBytes             `json:"censusRoot"`
 	RollingCensusRoot types.HexBytes             `json:"rollingCensusRoot"`
 	CensusURI         string                     `json:"censusURI"`
 	Metadata          string                     `json:"metadata"`
 	CensusOrigin      int32                      `json:"censusOrigin"`
-	Status            int32                      `badgerholdIndex:"Status" json:"status"`
-	Namespace         uint32                     `badgerholdIndex:"Namespace" json:"namespace"`
+	Status            int32                      `json:"status"`
+	Namespace         uint32                     `json:"namespace"`
 	Envelope          *models.EnvelopeType       `json:"envelopeType"`
 	Mode              *models.ProcessMode        `json:"processMode"`
 	VoteOpts          *models.ProcessVoteOptions `json:"voteOptions"`
@@ -57,7 +42,7 @@ type Process struct {
 	HaveResults       bool                       `json:"haveResults"`
 	FinalResults      bool                       `json:"finalResults"`
 	SourceBlockHeight uint64                     `json:"sourceBlockHeight"`
-	SourceNetworkId   string                     `badgerholdIndex:"SourceNetworkId" json:"sourceNetworkId"`
+	SourceNetworkId   string                     `json:"sourceNetworkId"`
 	MaxCensusSize     uint64                     `json:"maxCensusSize"`
 	RollingCensusSize uint64                     `json:"rollingCensusSize"`
 }
@@ -70,6 +55,8 @@ func ProcessFromDB(dbproc *scrutinizerdb.Process) *Process {
 		StartBlock:        uint32(dbproc.StartBlock),
 		EndBlock:          uint32(dbproc.EndBlock),
 		Rheight:           uint32(dbproc.ResultsHeight),
+		StartDate:         dbproc.StartDate,
+		EndDate:           dbproc.EndDate,
 		HaveResults:       dbproc.HaveResults,
 		FinalResults:      dbproc.FinalResults,
 		CensusRoot:        nonEmptyBytes(dbproc.CensusRoot),
@@ -82,6 +69,7 @@ func ProcessFromDB(dbproc *scrutinizerdb.Process) *Process {
 		Namespace:         uint32(dbproc.Namespace),
 		PrivateKeys:       nonEmptySplit(dbproc.PrivateKeys, ","),
 		PublicKeys:        nonEmptySplit(dbproc.PublicKeys, ","),
+		QuestionIndex:     uint32(dbproc.QuestionIndex),
 		CreationTime:      dbproc.CreationTime,
 		SourceBlockHeight: uint64(dbproc.SourceBlockHeight),
 		SourceNetworkId:   dbproc.SourceNetworkID,
@@ -130,8 +118,7 @@ func (p Process) String() string {
 
 // Entity holds the db reference for an entity
 type Entity struct {
-	ID           types.HexBytes `badgerholdKey:"ID"`
-	ProcessCount uint32
+	ID           types.HexBytes
 	CreationTime time.Time
 }
 
@@ -143,12 +130,52 @@ type VotePackage struct {
 
 // VoteReference holds the db reference for a single vote
 type VoteReference struct {
-	Nullifier    types.HexBytes `badgerholdKey:"Nullifier"`
-	ProcessID    types.HexBytes `badgerholdIndex:"ProcessID"`
+	Nullifier    types.HexBytes
+	ProcessID    types.HexBytes
 	Height       uint32
 	Weight       *types.BigInt
 	TxIndex      int32
 	CreationTime time.Time
+	// QuestionIndex is the question the vote is cast for, only for serial processes
+	QuestionIndex uint32
+	// OverwriteHistory holds the previous envelopes of an overwritten vote,
+	// oldest first
+	OverwriteHistory []*OverwrittenVoteReference
+}
+
+// VoteReferenceFromDB returns the VoteReference of a database vote, along with
+// the overwritten envelopes if any.
+func VoteReferenceFromDB(dbvote *scrutinizerdb.Vote,
+	overwrites []scrutinizerdb.VoteOverwrite) (*VoteReference, error) {
+	weight := new(types.BigInt)
+	if err := weight.UnmarshalText([]byte(dbvote.Weight)); err != nil {
+		return nil, fmt.Errorf("cannot decode vote weight: %w", err)
+	}
+	ref := &VoteReference{
+		Nullifier:     dbvote.Nullifier,
+		ProcessID:     types.HexBytes(dbvote.ProcessID),
+		Height:        uint32(dbvote.Height),
+		Weight:        weight,
+		TxIndex:       int32(dbvote.TxIndex),
+		CreationTime:  dbvote.CreationTime,
+		QuestionIndex: uint32(dbvote.QuestionIndex),
+	}
+	for _, o := range overwrites {
+		ref.OverwriteHistory = append(ref.OverwriteHistory, &OverwrittenVoteReference{
+			Height:       uint32(o.Height),
+			TxIndex:      int32(o.TxIndex),
+			CreationTime: o.CreationTime,
+		})
+	}
+	return ref, nil
+}
+
+// OverwrittenVoteReference holds the db reference for an envelope which has
+// been overwritten by a newer one with the same nullifier
+type OverwrittenVoteReference struct {
+	Height       uint32
+	TxIndex      int32
+	CreationTime time.Time
 }
 
 // EnvelopeMetadata contains vote information for the EnvelopeList api
@@ -158,6 +185,8 @@ type EnvelopeMetadata struct {
 	TxIndex   int32          `json:"tx_index"`
 	Height    uint32         `json:"height"`
 	TxHash    types.HexBytes `json:"tx_hash"`
+	// OverwriteCount is the number of times the vote has been overwritten
+	OverwriteCount uint32 `json:"overwrite_count,omitempty"`
 }
 
 // EnvelopePackage contains a VoteEnvelope and auxiliary information for the Envelope api
@@ -168,6 +197,8 @@ type EnvelopePackage struct {
 	Signature            types.HexBytes   `json:"signature"`
 	VotePackage          []byte           `json:"vote_package"`
 	Weight               string           `json:"weight"`
+	// OverwriteHistory holds the previous envelopes of the vote, oldest first
+	OverwriteHistory []*EnvelopeMetadata `json:"overwrite_history,omitempty"`
 }
 
 // TxPackage contains a SignedTx and auxiliary information for the Transaction api
@@ -188,12 +219,83 @@ type TxMetadata struct {
 	Hash        types.HexBytes `json:"hash"`
 }
 
-// TxReference holds the db reference for a single transaction
+// TxReference holds the db reference for a single transaction, along with
+// the accounts involved and the tokens moved between them.
 type TxReference struct {
-	Index        uint64         `badgerholdKey:"Index"`
-	Hash         types.HexBytes `badgerholdIndex:"Hash"`
-	BlockHeight  uint32
-	TxBlockIndex int32
+	Index        uint64           `json:"height"`
+	Hash         types.HexBytes   `json:"hash"`
+	BlockHeight  uint32           `json:"block_height"`
+	TxBlockIndex int32            `json:"index"`
+	Type         string           `json:"type"`
+	Sender       types.HexBytes   `json:"sender,omitempty"`
+	Recipients   []types.HexBytes `json:"recipients,omitempty"`
+	Amount       uint64           `json:"amount,omitempty"`
+	Cost         uint64           `json:"cost,omitempty"`
+}
+
+// TxReferenceFromDB returns the TxReference of a database transaction.
+func TxReferenceFromDB(dbtx *scrutinizerdb.Transaction) *TxReference {
+	return &TxReference{
+		Index:        uint64(dbtx.ID),
+		Hash:         dbtx.Hash,
+		BlockHeight:  uint32(dbtx.BlockHeight),
+		TxBlockIndex: int32(dbtx.BlockIndex),
+		Type:         dbtx.TxType,
+		Sender:       dbtx.Sender,
+		Amount:       uint64(dbtx.Amount),
+		Cost:         uint64(dbtx.Cost),
+	}
+}
+
+// TokenTransfer contains a transfer of tokens for the TokenTransfers api
+type TokenTransfer struct {
+	Height      uint64         `json:"height"`
+	Hash        types.HexBytes `json:"hash"`
+	BlockHeight uint32         `json:"block_height"`
+	Index       int32          `json:"index"`
+	Type        string         `json:"type"`
+	From        types.HexBytes `json:"from"`
+	To          types.HexBytes `json:"to"`
+	Amount      uint64         `json:"amount"`
+}
+
+// TokenTransferFromDB returns the TokenTransfer of a database transfer.
+func TokenTransferFromDB(dbtr *scrutinizerdb.SearchTokenTransfersRow) *TokenTransfer {
+	return &TokenTransfer{
+		Height:      uint64(dbtr.ID),
+		Hash:        dbtr.Hash,
+		BlockHeight: uint32(dbtr.BlockHeight),
+		Index:       int32(dbtr.BlockIndex),
+		Type:        dbtr.TxType,
+		From:        dbtr.Sender,
+		To:          dbtr.Recipient,
+		Amount:      uint64(dbtr.Amount),
+	}
+}
+
+// AccountBalance contains the balance of an account after a block, for the
+// AccountBalanceHistory api
+type AccountBalance struct {
+	Height  uint32 `json:"height"`
+	Balance uint64 `json:"balance"`
+}
+
+// TokenStats contains the token supply statistics for the TokenStats api.
+// The Supply is the number of minted tokens that have not been burned.
+type TokenStats struct {
+	Minted  uint64         `json:"minted"`
+	Burned  uint64         `json:"burned"`
+	Supply  uint64         `json:"supply"`
+	TxTypes []*TxTypeStats `json:"txTypes"`
+}
+
+// TxTypeStats contains the number of transactions of a type, the amount they
+// transferred and the fees they paid
+type TxTypeStats struct {
+	Type   string `json:"type"`
+	Count  uint64 `json:"count"`
+	Amount uint64 `json:"amount"`
+	Fees   uint64 `json:"fees"`
 }
 
 // BlockMetadata contains the metadata for a single tendermint block
@@ -204,6 +306,7 @@ type BlockMetadata struct {
 	NumTxs          uint64         `json:"num_txs"`
 	LastBlockHash   types.HexBytes `json:"last_block_hash"`
 	ProposerAddress types.HexBytes `json:"proposer_address"`
+	AppHash         types.HexBytes `json:"app_hash,omitempty"`
 }
 
 // String prints the BlockMetadata in a human-readable format
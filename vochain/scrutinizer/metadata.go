@@ -0,0 +1,418 @@
+package scrutinizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// Process and entity metadata
+//
+// The metadata of processes and entities are JSON documents published on the
+// data storage, whose URIs are stored on the process and on the entity
+// account, as its info URI.  FetchMetadata retrieves the documents in the
+// background and stores their texts, in all of their languages, in the
+// process_metadata and entity_metadata tables: the process titles,
+// descriptions and questions, and the entity names and descriptions.  The
+// tables are indexed by the process_search and entity_search FTS5 tables,
+// kept up to date by triggers, so SearchProcesses and SearchEntities can find
+// them by their text, ranked by bm25.  The documents that cannot be fetched
+// are retried up to metadataMaxAttempts times, and fetched again whenever
+// their URI changes.  Since the metadata is not part of the blocks, a reindex
+// keeps it.
+//
+// FTS5 is only built into go-sqlite3 with the sqlite_fts5 build tag.  Without
+// it, the FTS5 tables are not created, and the metadata is searched by
+// case-insensitive substring instead, ranked by the field that matched.
+
+const (
+	// metadataMaxSize is the maximum size of a process metadata document.
+	metadataMaxSize = 1 << 20
+	// metadataMaxAttempts is the number of attempts to fetch the metadata
+	// of a process before giving up.
+	metadataMaxAttempts = 5
+	// metadataFetchTimeout is the timeout to fetch a metadata document.
+	metadataFetchTimeout = 30 * time.Second
+	// metadataFetchInterval is the interval between the fetch rounds.
+	metadataFetchInterval = 10 * time.Second
+	// metadataFetchBatch is the maximum number of documents fetched on
+	// each round.
+	metadataFetchBatch = 32
+)
+
+// The process search sort orders, newest first.
+const (
+	SortByRelevance    = "relevance"
+	SortByCreationTime = "creationTime"
+	SortByStartDate    = "startDate"
+	SortByEndDate      = "endDate"
+)
+
+// MetadataStorage is the part of data.Storage used to fetch the process and
+// entity metadata.
+type MetadataStorage interface {
+	URIprefix() string
+	Retrieve(ctx context.Context, id string, maxSize int64) ([]byte, error)
+}
+
+// multilingualString is a text in several languages, by language code.
+type multilingualString map[string]string
+
+// String returns all the translations of m, one per line, sorted by language.
+func (m multilingualString) String() string {
+	langs := make([]string, 0, len(m))
+	for lang := range m {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	texts := make([]string, 0, len(m))
+	for _, lang := range langs {
+		if text := strings.TrimSpace(m[lang]); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// processMetadata holds the searchable fields of a process metadata document.
+type processMetadata struct {
+	Title       multilingualString `json:"title"`
+	Description multilingualString `json:"description"`
+	Questions   []struct {
+		Title       multilingualString `json:"title"`
+		Description multilingualString `json:"description"`
+		Choices     []struct {
+			Title multilingualString `json:"title"`
+		} `json:"choices"`
+	} `json:"questions"`
+}
+
+// entityMetadata holds the searchable fields of an entity metadata document.
+type entityMetadata struct {
+	Name        multilingualString `json:"name"`
+	Description multilingualString `json:"description"`
+}
+
+// questionsText returns the questions and choices of m, one per line.
+func (m *processMetadata) questionsText() string {
+	texts := []string{}
+	add := func(s multilingualString) {
+		if text := s.String(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	for _, q := range m.Questions {
+		add(q.Title)
+		add(q.Description)
+		for _, c := range q.Choices {
+			add(c.Title)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// FetchMetadata constantly fetches the metadata of the indexed processes and
+// entities from storage.  The function is blocking, should be called in a go routine.
+// If storage is nil, do nothing.
+func (s *Scrutinizer) FetchMetadata(storage MetadataStorage) {
+	if storage == nil {
+		return
+	}
+	for {
+		time.Sleep(metadataFetchInterval)
+		if err := s.fetchPendingMetadata(storage); err != nil {
+			log.Warnf("cannot fetch process metadata: %v", err)
+		}
+		if err := s.fetchPendingEntityMetadata(storage); err != nil {
+			log.Warnf("cannot fetch entity metadata: %v", err)
+		}
+	}
+}
+
+// fetchPendingMetadata fetches the metadata of the processes that have not
+// been fetched yet, whose URI changed or whose previous attempts failed.
+func (s *Scrutinizer) fetchPendingMetadata(storage MetadataStorage) error {
+	queries, ctx, cancel := s.timeoutQueries()
+	pending, err := queries.GetPendingProcessMetadata(ctx,
+		scrutinizerdb.GetPendingProcessMetadataParams{
+			MaxAttempts: metadataMaxAttempts,
+			Limit:       metadataFetchBatch,
+		})
+	cancel()
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		params := scrutinizerdb.UpdateProcessMetadataParams{
+			ProcessID: p.ID,
+			Uri:       p.Metadata,
+		}
+		metadata := &processMetadata{}
+		if err := fetchMetadata(storage, p.Metadata, metadata); err != nil {
+			log.Debugf("cannot fetch process %x metadata: %v", p.ID, err)
+			params.FailedAttempts = 1
+			if p.Uri.String == p.Metadata {
+				params.FailedAttempts += p.FailedAttempts.Int64
+			}
+		} else {
+			params.Title = metadata.Title.String()
+			params.Description = metadata.Description.String()
+			params.Questions = metadata.questionsText()
+		}
+		if err := s.setProcessMetadata(params); err != nil {
+			return fmt.Errorf("cannot store process %x metadata: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// fetchPendingEntityMetadata fetches the metadata of the entities that have
+// not been fetched yet, whose info URI changed or whose previous attempts
+// failed.
+func (s *Scrutinizer) fetchPendingEntityMetadata(storage MetadataStorage) error {
+	queries, ctx, cancel := s.timeoutQueries()
+	pending, err := queries.GetPendingEntityMetadata(ctx,
+		scrutinizerdb.GetPendingEntityMetadataParams{
+			MaxAttempts: metadataMaxAttempts,
+			Limit:       metadataFetchBatch,
+		})
+	cancel()
+	if err != nil {
+		return err
+	}
+	for _, e := range pending {
+		params := scrutinizerdb.UpdateEntityMetadataParams{
+			EntityID: e.EntityID,
+			Uri:      e.InfoUri,
+		}
+		metadata := &entityMetadata{}
+		if err := fetchMetadata(storage, e.InfoUri, metadata); err != nil {
+			log.Debugf("cannot fetch entity %x metadata: %v", e.EntityID, err)
+			params.FailedAttempts = 1
+			if e.Uri == e.InfoUri {
+				params.FailedAttempts += e.FailedAttempts
+			}
+		} else {
+			params.Name = metadata.Name.String()
+			params.Description = metadata.Description.String()
+		}
+		queries, ctx, cancel := s.timeoutQueries()
+		_, err := queries.UpdateEntityMetadata(ctx, params)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("cannot store entity %x metadata: %w", e.EntityID, err)
+		}
+	}
+	return nil
+}
+
+// fetchMetadata retrieves the metadata document at uri and decodes it into
+// metadata.
+func fetchMetadata(storage MetadataStorage, uri string, metadata interface{}) error {
+	prefix := storage.URIprefix()
+	if !strings.HasPrefix(uri, prefix) || len(uri) <= len(prefix) {
+		return fmt.Errorf("unsupported metadata uri %q", uri)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metadataFetchTimeout)
+	defer cancel()
+	content, err := storage.Retrieve(ctx, uri[len(prefix):], metadataMaxSize)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, metadata); err != nil {
+		return fmt.Errorf("cannot decode metadata: %w", err)
+	}
+	return nil
+}
+
+// setProcessMetadata updates the stored metadata of a process, or creates it
+// if it does not exist.
+func (s *Scrutinizer) setProcessMetadata(params scrutinizerdb.UpdateProcessMetadataParams) error {
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	res, err := queries.UpdateProcessMetadata(ctx, params)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+	_, err = queries.CreateProcessMetadata(ctx, scrutinizerdb.CreateProcessMetadataParams{
+		ProcessID:      params.ProcessID,
+		Uri:            params.Uri,
+		Title:          params.Title,
+		Description:    params.Description,
+		Questions:      params.Questions,
+		FailedAttempts: params.FailedAttempts,
+	})
+	return err
+}
+
+// indexEntityInfoURIs stores the info URIs of the accounts whose info was set
+// by the SET_ACCOUNT_INFO transactions of txList, so their metadata is
+// fetched.
+func (s *Scrutinizer) indexEntityInfoURIs(ctx context.Context, queries *scrutinizerdb.Queries,
+	txList []*indexertypes.TxReference) error {
+	for _, tx := range txList {
+		if tx.Type != models.TxType_SET_ACCOUNT_INFO.String() {
+			continue
+		}
+		// The account is either the sender or the recipient
+		for _, addr := range append([]types.HexBytes{tx.Sender}, tx.Recipients...) {
+			if len(addr) == 0 {
+				continue
+			}
+			acc, err := s.App.State.GetAccount(common.BytesToAddress(addr), true)
+			if err != nil {
+				return fmt.Errorf("cannot get account %x: %w", addr, err)
+			}
+			if acc == nil {
+				continue
+			}
+			res, err := queries.SetEntityInfoURI(ctx, scrutinizerdb.SetEntityInfoURIParams{
+				EntityID: addr,
+				InfoUri:  acc.InfoURI,
+			})
+			if err != nil {
+				return fmt.Errorf("cannot store entity %x info uri: %w", addr, err)
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return err
+			} else if n > 0 {
+				continue
+			}
+			if _, err := queries.CreateEntityMetadata(ctx, scrutinizerdb.CreateEntityMetadataParams{
+				EntityID: addr,
+				InfoUri:  acc.InfoURI,
+			}); err != nil {
+				return fmt.Errorf("cannot store entity %x info uri: %w", addr, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SearchProcesses returns the identifiers of the processes whose metadata
+// matches the full-text query, skipping the first from results and returning
+// at most max.  The query uses the SQLite FTS5 syntax, such as "vote*" or
+// "title:budget", or is a plain substring in builds without FTS5, and an
+// empty query matches all the processes.  If dateFrom
+// or dateTo are not zero, only the processes whose voting period overlaps
+// with them, as unix timestamps, are returned.  The results are sorted by
+// sortBy, which defaults to SortByRelevance for text queries and to
+// SortByCreationTime otherwise.
+func (s *Scrutinizer) SearchProcesses(query string, dateFrom, dateTo int64, sortBy string,
+	from, max int) ([][]byte, error) {
+	if from < 0 {
+		return nil, fmt.Errorf("searchProcesses: invalid value: from is invalid value %d", from)
+	}
+	query = strings.TrimSpace(query)
+	switch sortBy {
+	case "":
+		sortBy = SortByCreationTime
+		if query != "" {
+			sortBy = SortByRelevance
+		}
+	case SortByRelevance:
+		if query == "" {
+			return nil, fmt.Errorf("searchProcesses: sorting by %s needs a query", sortBy)
+		}
+	case SortByCreationTime, SortByStartDate, SortByEndDate:
+	default:
+		return nil, fmt.Errorf("searchProcesses: sort %s is unknown", sortBy)
+	}
+	startTime := time.Now()
+	defer func() { log.Debugf("SearchProcesses took %s", time.Since(startTime)) }()
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+
+	if query == "" {
+		return queries.SearchProcessesByDate(ctx, scrutinizerdb.SearchProcessesByDateParams{
+			DateFrom: dateFrom,
+			DateTo:   dateTo,
+			SortBy:   sortBy,
+			Limit:    int32(max),
+			Offset:   int32(from),
+		})
+	}
+	var pids []types.ProcessID
+	var err error
+	if fullTextSearch {
+		pids, err = queries.SearchProcessesByText(ctx, scrutinizerdb.SearchProcessesByTextParams{
+			Query:    query,
+			DateFrom: dateFrom,
+			DateTo:   dateTo,
+			SortBy:   sortBy,
+			Limit:    int32(max),
+			Offset:   int32(from),
+		})
+	} else {
+		pids, err = queries.SearchProcessesBySubstring(ctx, scrutinizerdb.SearchProcessesBySubstringParams{
+			Pattern:  likePattern(query),
+			DateFrom: dateFrom,
+			DateTo:   dateTo,
+			SortBy:   sortBy,
+			Limit:    int32(max),
+			Offset:   int32(from),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("searchProcesses: %w", err)
+	}
+	return pids, nil
+}
+
+// SearchEntities returns the identifiers of the entities whose metadata
+// matches the full-text query, ranked by relevance, skipping the first from
+// results and returning at most max.  The query uses the SQLite FTS5 syntax,
+// such as "city*" or "name:council", or is a plain substring in builds
+// without FTS5.
+func (s *Scrutinizer) SearchEntities(query string, from, max int) ([]types.HexBytes, error) {
+	if from < 0 {
+		return nil, fmt.Errorf("searchEntities: invalid value: from is invalid value %d", from)
+	}
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("searchEntities: the query is empty")
+	}
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	var eids []types.HexBytes
+	var err error
+	if fullTextSearch {
+		eids, err = queries.SearchEntitiesByText(ctx, scrutinizerdb.SearchEntitiesByTextParams{
+			Query:  query,
+			Limit:  int32(max),
+			Offset: int32(from),
+		})
+	} else {
+		eids, err = queries.SearchEntitiesBySubstring(ctx, scrutinizerdb.SearchEntitiesBySubstringParams{
+			Pattern: likePattern(query),
+			Limit:   int32(max),
+			Offset:  int32(from),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("searchEntities: %w", err)
+	}
+	return eids, nil
+}
+
+// likePattern returns the LIKE pattern matching the texts containing query,
+// escaping its wildcards with a backslash.
+func likePattern(query string) string {
+	query = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query)
+	return "%" + query + "%"
+}
@@ -0,0 +1,81 @@
+package scrutinizer
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/metrics"
+)
+
+// Scrutinizer collectors
+var (
+	// TokensMinted ...
+	TokensMinted = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "scrutinizer",
+		Name:      "tokens_minted",
+		Help:      "Number of tokens minted",
+	})
+	// TokensBurned ...
+	TokensBurned = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "scrutinizer",
+		Name:      "tokens_burned",
+		Help:      "Number of tokens burned by the transaction fees",
+	})
+	// TokensSupply ...
+	TokensSupply = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "scrutinizer",
+		Name:      "tokens_supply",
+		Help:      "Number of minted tokens not burned",
+	})
+	// TxCount ...
+	TxCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scrutinizer",
+		Name:      "tx_count",
+		Help:      "Number of transactions per type",
+	}, []string{"tx_type"})
+	// TxFees ...
+	TxFees = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scrutinizer",
+		Name:      "tx_fees",
+		Help:      "Fees collected per transaction type",
+	}, []string{"tx_type"})
+)
+
+// registerMetrics registers each of the scrutinizer prometheus metrics
+func (s *Scrutinizer) registerMetrics(ma *metrics.Agent) {
+	ma.Register(TokensMinted)
+	ma.Register(TokensBurned)
+	ma.Register(TokensSupply)
+	ma.Register(TxCount)
+	ma.Register(TxFees)
+}
+
+// getMetrics updates the metrics values to the current token stats
+func (s *Scrutinizer) getMetrics() {
+	stats, err := s.GetTokenStats()
+	if err != nil {
+		log.Warnf("cannot get token stats: %v", err)
+		return
+	}
+	TokensMinted.Set(float64(stats.Minted))
+	TokensBurned.Set(float64(stats.Burned))
+	TokensSupply.Set(float64(stats.Supply))
+	for _, st := range stats.TxTypes {
+		TxCount.WithLabelValues(st.Type).Set(float64(st.Count))
+		TxFees.WithLabelValues(st.Type).Set(float64(st.Fees))
+	}
+}
+
+// CollectMetrics constantly updates the metric values for prometheus
+// The function is blocking, should be called in a go routine
+// If the metrics Agent is nil, do nothing
+func (s *Scrutinizer) CollectMetrics(ma *metrics.Agent) {
+	if ma != nil {
+		s.registerMetrics(ma)
+		for {
+			time.Sleep(ma.RefreshInterval)
+			s.getMetrics()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package scrutinizer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/timshannon/badgerhold/v3"
+	"go.vocdoni.io/dvote/log"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+)
+
+// openBadgerhold opens the badgerhold db used by previous versions of the
+// scrutinizer at the location given by dataDir.
+func openBadgerhold(dataDir string) (*badgerhold.Store, error) {
+	opts := badgerhold.DefaultOptions
+
+	// Note that these "With" options return a modified copy.
+	opts.Options = opts.WithCompression(0)
+	opts.Options = opts.WithBlockCacheSize(0)
+	opts.Options = opts.WithVerifyValueChecksum(false)
+	opts.Options = opts.WithDetectConflicts(true)
+
+	opts.SequenceBandwith = 10000
+	opts.Dir = dataDir
+	opts.ValueDir = dataDir
+
+	// TODO: support configurable logging
+	opts.Options = opts.WithLogger(nil)
+	opts.Logger = nil
+
+	return badgerhold.Open(opts)
+}
+
+// migrateBadgerhold imports the processes, entities, results, votes and
+// transactions of the badgerhold db at dataDir, used by previous versions of
+// the scrutinizer, into the sqlite database.  The badgerhold db is removed
+// once imported, so the migration only runs once.
+func (s *Scrutinizer) migrateBadgerhold(dataDir string) error {
+	if _, err := os.Stat(filepath.Join(dataDir, "MANIFEST")); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	log.Infof("migrating the badgerhold indexer database at %s", dataDir)
+	startTime := time.Now()
+	store, err := openBadgerhold(dataDir)
+	if err != nil {
+		return err
+	}
+	// The import might take long on big databases, so it has no timeout.
+	ctx := context.Background()
+	tx, err := s.db().BeginTx(ctx, nil)
+	if err != nil {
+		store.Close()
+		return err
+	}
+	err = importBadgerhold(ctx, scrutinizerdb.New(tx), store)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if closeErr := store.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("badgerhold indexer database migrated, took %s", time.Since(startTime))
+	return os.RemoveAll(dataDir)
+}
+
+// importBadgerhold copies the badgerhold db records to the sqlite database.
+// The processes already stored in sqlite are kept as they are.
+func importBadgerhold(ctx context.Context, queries *scrutinizerdb.Queries,
+	store *badgerhold.Store) error {
+	if err := store.ForEach(nil, func(p *indexertypes.Process) error {
+		if _, err := queries.GetProcess(ctx, p.ID); err == nil {
+			return nil
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		_, err := queries.CreateProcess(ctx, processToDB(p))
+		return err
+	}); err != nil {
+		return fmt.Errorf("cannot import processes: %w", err)
+	}
+	if err := store.ForEach(nil, func(e *indexertypes.Entity) error {
+		_, err := queries.CreateEntity(ctx, scrutinizerdb.CreateEntityParams{
+			ID:           string(e.ID), // NOTE: we store as string instead of []byte; see sqlc.yaml
+			CreationTime: e.CreationTime,
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("cannot import entities: %w", err)
+	}
+	if err := store.ForEach(nil, func(r *indexertypes.Results) error {
+		params, err := indexertypes.ResultsToDB(r)
+		if err != nil {
+			return err
+		}
+		_, err = queries.SetResults(ctx, params)
+		return err
+	}); err != nil {
+		return fmt.Errorf("cannot import results: %w", err)
+	}
+	if err := store.ForEach(nil, func(v *indexertypes.VoteReference) error {
+		if _, err := queries.CreateVote(ctx, scrutinizerdb.CreateVoteParams{
+			Nullifier:      v.Nullifier,
+			ProcessID:      v.ProcessID,
+			Height:         int64(v.Height),
+			Weight:         v.Weight.String(),
+			TxIndex:        int64(v.TxIndex),
+			QuestionIndex:  int64(v.QuestionIndex),
+			OverwriteCount: int64(len(v.OverwriteHistory)),
+			CreationTime:   v.CreationTime,
+		}); err != nil {
+			return err
+		}
+		for _, o := range v.OverwriteHistory {
+			if _, err := queries.CreateVoteOverwrite(ctx, scrutinizerdb.CreateVoteOverwriteParams{
+				Nullifier:    v.Nullifier,
+				Height:       int64(o.Height),
+				TxIndex:      int64(o.TxIndex),
+				CreationTime: o.CreationTime,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("cannot import votes: %w", err)
+	}
+	// The transactions are numbered by their insertion order, so they are
+	// inserted sorted by their badgerhold index.
+	txs := []*indexertypes.TxReference{}
+	if err := store.ForEach(nil, func(tx *indexertypes.TxReference) error {
+		txs = append(txs, tx)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("cannot import transactions: %w", err)
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Index < txs[j].Index })
+	for _, tx := range txs {
+		index := tx.Index
+		if err := indexTxs(ctx, queries, []*indexertypes.TxReference{tx}); err != nil {
+			return fmt.Errorf("cannot import transactions: %w", err)
+		}
+		if tx.Index != index {
+			log.Warnf("transaction %x index changed from %d to %d", tx.Hash, index, tx.Index)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE processes ADD COLUMN start_date DATETIME NOT NULL DEFAULT 0;
+ALTER TABLE processes ADD COLUMN end_date   DATETIME NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE processes DROP COLUMN start_date;
+ALTER TABLE processes DROP COLUMN end_date;
@@ -0,0 +1,77 @@
+-- +goose Up
+CREATE INDEX index_processes_results_height
+ON processes(results_height);
+
+CREATE TABLE entities (
+  id            BLOB NOT NULL PRIMARY KEY, -- stored as TEXT like processes.entity_id; see sqlc.yaml
+  creation_time DATETIME NOT NULL
+);
+
+CREATE TABLE votes (
+  nullifier       BLOB NOT NULL PRIMARY KEY,
+  process_id      BLOB NOT NULL,
+  height          INTEGER NOT NULL,
+  weight          TEXT NOT NULL, -- decimal big integer
+  tx_index        INTEGER NOT NULL,
+  question_index  INTEGER NOT NULL,
+  overwrite_count INTEGER NOT NULL,
+  creation_time   DATETIME NOT NULL
+);
+
+CREATE INDEX index_votes_process_id
+ON votes(process_id);
+
+-- previous envelopes of the overwritten votes
+CREATE TABLE vote_overwrites (
+  nullifier     BLOB NOT NULL,
+  height        INTEGER NOT NULL,
+  tx_index      INTEGER NOT NULL,
+  creation_time DATETIME NOT NULL
+);
+
+CREATE INDEX index_vote_overwrites_nullifier
+ON vote_overwrites(nullifier);
+
+CREATE TABLE transactions (
+  id           INTEGER NOT NULL PRIMARY KEY, -- starts at 1
+  hash         BLOB NOT NULL,
+  block_height INTEGER NOT NULL,
+  block_index  INTEGER NOT NULL
+);
+
+CREATE INDEX index_transactions_hash
+ON transactions(hash);
+
+CREATE TABLE results (
+  process_id      BLOB NOT NULL PRIMARY KEY,
+  votes           TEXT NOT NULL, -- JSON matrix of decimal big integers
+  weight          TEXT NOT NULL, -- decimal big integer
+  envelope_height INTEGER NOT NULL,
+  envelope_pb     BLOB NOT NULL,
+  vote_opts_pb    BLOB NOT NULL,
+  signatures      TEXT NOT NULL, -- comma-separated list of hex signatures
+  final           BOOLEAN NOT NULL,
+  block_height    INTEGER NOT NULL,
+  ranked_seats    INTEGER NOT NULL,
+  ballots         TEXT NOT NULL, -- JSON object of rankings and their weight
+  ranked          TEXT NOT NULL  -- JSON ranked results, null if not computed
+);
+
+-- +goose Down
+DROP TABLE results;
+
+DROP INDEX index_transactions_hash;
+
+DROP TABLE transactions;
+
+DROP INDEX index_vote_overwrites_nullifier;
+
+DROP TABLE vote_overwrites;
+
+DROP INDEX index_votes_process_id;
+
+DROP TABLE votes;
+
+DROP TABLE entities;
+
+DROP INDEX index_processes_results_height;
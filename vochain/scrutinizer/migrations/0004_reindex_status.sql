@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE reindex_status (
+  id     INTEGER NOT NULL PRIMARY KEY CHECK (id = 1),
+  height INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE reindex_status;
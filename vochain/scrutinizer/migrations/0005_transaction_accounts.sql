@@ -0,0 +1,29 @@
+-- +goose Up
+ALTER TABLE transactions ADD COLUMN tx_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN sender BLOB NOT NULL DEFAULT x'';
+ALTER TABLE transactions ADD COLUMN amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN cost INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX index_transactions_sender
+ON transactions(sender);
+
+CREATE TABLE transaction_recipients (
+  tx_id   INTEGER NOT NULL REFERENCES transactions(id),
+  account BLOB NOT NULL,
+  PRIMARY KEY (tx_id, account)
+);
+
+CREATE INDEX index_transaction_recipients_account
+ON transaction_recipients(account);
+
+-- +goose Down
+DROP INDEX index_transaction_recipients_account;
+
+DROP TABLE transaction_recipients;
+
+DROP INDEX index_transactions_sender;
+
+ALTER TABLE transactions DROP COLUMN cost;
+ALTER TABLE transactions DROP COLUMN amount;
+ALTER TABLE transactions DROP COLUMN sender;
+ALTER TABLE transactions DROP COLUMN tx_type;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE account_balances (
+  account BLOB NOT NULL,
+  height  INTEGER NOT NULL,
+  balance INTEGER NOT NULL,
+  PRIMARY KEY (account, height)
+);
+
+CREATE TABLE tx_type_stats (
+  tx_type  TEXT NOT NULL PRIMARY KEY,
+  tx_count INTEGER NOT NULL,
+  amount   INTEGER NOT NULL,
+  fees     INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE tx_type_stats;
+
+DROP TABLE account_balances;
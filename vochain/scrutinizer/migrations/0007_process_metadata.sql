@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE process_metadata (
+  process_id      BLOB NOT NULL PRIMARY KEY,
+  uri             TEXT NOT NULL,
+  title           TEXT NOT NULL,
+  description     TEXT NOT NULL,
+  questions       TEXT NOT NULL, -- questions and choices, one per line
+  failed_attempts INTEGER NOT NULL -- zero once fetched
+);
+
+CREATE TABLE entity_metadata (
+  entity_id       BLOB NOT NULL PRIMARY KEY,
+  info_uri        TEXT NOT NULL, -- the account info URI on chain
+  uri             TEXT NOT NULL, -- the URI of the fetched document
+  name            TEXT NOT NULL,
+  description     TEXT NOT NULL,
+  failed_attempts INTEGER NOT NULL -- zero once fetched
+);
+
+-- The full-text indexes of the metadata tables are not migrations, since
+-- they need the FTS5 extension; see fts5.sql.
+
+-- +goose Down
+DROP TABLE entity_metadata;
+
+DROP TABLE process_metadata;
@@ -1,20 +1,18 @@
 package scrutinizer
 
 import (
+	"context"
 	"encoding/hex"
-	"flag"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/timshannon/badgerhold/v3"
 	"go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
 
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain"
 	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
 	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 )
@@ -28,6 +26,15 @@ func nonNullBytes(p []byte) []byte {
 	return p
 }
 
+// rollingCensusRoot returns the rolling census root of p, which is its
+// FinalRollingCensusRoot once the rolling census is purged.
+func rollingCensusRoot(p *models.Process) []byte {
+	if p.FinalRollingCensusRoot != nil {
+		return p.FinalRollingCensusRoot
+	}
+	return p.GetRollingCensusRoot()
+}
+
 // TODO(mvdan): funcs to safely convert integers
 
 func encodedPb(msg proto.Message) types.EncodedProtoBuf {
@@ -38,41 +45,51 @@ func encodedPb(msg proto.Message) types.EncodedProtoBuf {
 	return nonNullBytes(enc)
 }
 
-func sqliteWarnf(format string, args ...interface{}) {
-	if flag.Lookup("test.run") != nil {
-		log.Fatalf(format, args...)
+// processToDB returns the parameters to store the process p in the database.
+func processToDB(p *indexertypes.Process) scrutinizerdb.CreateProcessParams {
+	return scrutinizerdb.CreateProcessParams{
+		ID:                p.ID,
+		EntityID:          string(p.EntityID), // NOTE: we store as string instead of []byte; see sqlc.yaml
+		EntityIndex:       int64(p.EntityIndex),
+		StartBlock:        int64(p.StartBlock),
+		EndBlock:          int64(p.EndBlock),
+		ResultsHeight:     int64(p.Rheight),
+		HaveResults:       p.HaveResults,
+		FinalResults:      p.FinalResults,
+		CensusRoot:        nonNullBytes(p.CensusRoot),
+		RollingCensusRoot: nonNullBytes(p.RollingCensusRoot),
+		RollingCensusSize: int64(p.RollingCensusSize),
+		MaxCensusSize:     int64(p.MaxCensusSize),
+		CensusUri:         p.CensusURI,
+		CensusOrigin:      int64(p.CensusOrigin),
+		Status:            int64(p.Status),
+		Namespace:         int64(p.Namespace),
+		EnvelopePb:        encodedPb(p.Envelope),
+		ModePb:            encodedPb(p.Mode),
+		VoteOptsPb:        encodedPb(p.VoteOpts),
+		PrivateKeys:       strings.Join(p.PrivateKeys, ","),
+		PublicKeys:        strings.Join(p.PublicKeys, ","),
+		QuestionIndex:     int64(p.QuestionIndex),
+		CreationTime:      p.CreationTime,
+		SourceBlockHeight: int64(p.SourceBlockHeight),
+		SourceNetworkID:   p.SourceNetworkId, // TODO: store the integer?
+		Metadata:          p.Metadata,
+		StartDate:         p.StartDate,
+		EndDate:           p.EndDate,
 	}
-	log.Warnf(format, args...)
 }
 
 // ProcessInfo returns the available information regarding an election process id
 func (s *Scrutinizer) ProcessInfo(pid []byte) (*indexertypes.Process, error) {
-	bhStartTime := time.Now()
-	proc := &indexertypes.Process{}
-	if err := s.db.FindOne(proc, badgerhold.Where(badgerhold.Key).Eq(pid)); err != nil {
-		return nil, err
-	}
-	log.Debugf("ProcessInfo badgerhold took %s", time.Since(bhStartTime))
-
-	sqlStartTime := time.Now()
-
+	startTime := time.Now()
+	defer func() { log.Debugf("ProcessInfo took %s", time.Since(startTime)) }()
 	queries, ctx, cancel := s.timeoutQueries()
 	defer cancel()
-	sqlProcInner, err := queries.GetProcess(ctx, pid)
+	procInner, err := queries.GetProcess(ctx, pid)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("ProcessInfo sqlite took %s", time.Since(sqlStartTime))
-	sqlProc := indexertypes.ProcessFromDB(&sqlProcInner)
-	if diff := cmp.Diff(proc, sqlProc, cmpopts.IgnoreUnexported(
-		models.EnvelopeType{},
-		models.ProcessMode{},
-		models.ProcessVoteOptions{},
-	)); diff != "" {
-		sqliteWarnf("ping mvdan to fix the bug with the information below:\nparams: %x\ndiff (-badger +sql):\n%s", pid, diff)
-	}
-
-	return proc, nil
+	return indexertypes.ProcessFromDB(&procInner), nil
 }
 
 // ProcessList returns a list of process identifiers (PIDs) registered in the Vochain.
@@ -90,8 +107,6 @@ func (s *Scrutinizer) ProcessList(entityID []byte,
 	if from < 0 {
 		return nil, fmt.Errorf("processList: invalid value: from is invalid value %d", from)
 	}
-	// For filtering on Status we use a badgerhold match function.
-	// If status is not defined, then the match function will return always true.
 	statusnum := int32(0)
 	statusfound := false
 	if status != "" {
@@ -99,114 +114,16 @@ func (s *Scrutinizer) ProcessList(entityID []byte,
 			return nil, fmt.Errorf("processList: status %s is unknown", status)
 		}
 	}
-	statusMatchFunc := func(r *badgerhold.RecordAccess) (bool, error) {
-		if statusnum == 0 {
-			return true, nil
-		}
-		if r.Field().(int32) == statusnum {
-			return true, nil
-		}
-		return false, nil
-	}
-	// Filter match function for source network Id
 	if srcNetworkIdstr != "" {
 		if _, ok := models.SourceNetworkId_value[srcNetworkIdstr]; !ok {
 			return nil, fmt.Errorf("sourceNetworkId is unknown %s", srcNetworkIdstr)
 		}
 	}
-	netIdMatchFunc := func(r *badgerhold.RecordAccess) (bool, error) {
-		if srcNetworkIdstr == "" {
-			return true, nil
-		}
-		if r.Field().(string) == srcNetworkIdstr {
-			return true, nil
-		}
-		return false, nil
-	}
-	// For filtering on withResults we use also a match function
-	wResultsMatchFunc := func(r *badgerhold.RecordAccess) (bool, error) {
-		if !withResults {
-			return true, nil
-		}
-		return r.Field().(bool), nil
-	}
+	startTime := time.Now()
+	defer func() { log.Debugf("ProcessList took %s", time.Since(startTime)) }()
 	queries, ctx, cancel := s.timeoutQueries()
 	defer cancel()
-
-	bhStartTime := time.Now()
-
-	// For EntityID and Namespace we use different queries, since they are indexes and the
-	// performance improvement is quite relevant.
-	var err error
-	var procs [][]byte
-	switch {
-	case namespace == 0 && len(entityID) > 0:
-		err = s.db.ForEach(
-			badgerhold.Where("EntityID").Eq(entityID).
-				Index("EntityID").
-				And("Status").MatchFunc(statusMatchFunc).
-				And("SourceNetworkId").MatchFunc(netIdMatchFunc).
-				And("HaveResults").MatchFunc(wResultsMatchFunc).
-				And("ID").MatchFunc(searchMatchFunc(searchTerm)).
-				SortBy("CreationTime", "ID").
-				Skip(from).
-				Limit(max),
-			func(p *indexertypes.Process) error {
-				procs = append(procs, p.ID)
-				return nil
-			})
-	case namespace > 0 && len(entityID) == 0:
-		err = s.db.ForEach(
-			badgerhold.Where("Namespace").Eq(namespace).
-				Index("Namespace").
-				And("Status").MatchFunc(statusMatchFunc).
-				And("SourceNetworkId").MatchFunc(netIdMatchFunc).
-				And("HaveResults").MatchFunc(wResultsMatchFunc).
-				And("ID").MatchFunc(searchMatchFunc(searchTerm)).
-				SortBy("CreationTime", "ID").
-				Skip(from).
-				Limit(max),
-			func(p *indexertypes.Process) error {
-				procs = append(procs, p.ID)
-				return nil
-			})
-	case namespace == 0 && len(entityID) == 0:
-		err = s.db.ForEach(
-			badgerhold.Where("Status").MatchFunc(statusMatchFunc).
-				Index("Status").
-				And("SourceNetworkId").MatchFunc(netIdMatchFunc).
-				And("HaveResults").MatchFunc(wResultsMatchFunc).
-				And("ID").MatchFunc(searchMatchFunc(searchTerm)).
-				SortBy("CreationTime", "ID").
-				Skip(from).
-				Limit(max),
-			func(p *indexertypes.Process) error {
-				procs = append(procs, p.ID)
-				return nil
-			})
-	default:
-		err = s.db.ForEach(
-			badgerhold.Where("EntityID").Eq(entityID).
-				Index("EntityID").
-				And("Namespace").Eq(namespace).
-				And("SourceNetworkId").MatchFunc(netIdMatchFunc).
-				And("Status").MatchFunc(statusMatchFunc).
-				And("HaveResults").MatchFunc(wResultsMatchFunc).
-				And("ID").MatchFunc(searchMatchFunc(searchTerm)).
-				SortBy("CreationTime", "ID").
-				Skip(from).
-				Limit(max),
-			func(p *indexertypes.Process) error {
-				procs = append(procs, p.ID)
-				return nil
-			})
-	}
-	log.Debugf("ProcessList badgerhold took %s", time.Since(bhStartTime))
-	if err != nil {
-		return nil, err
-	}
-	sqlStartTime := time.Now()
-	sqlProcs, err := queries.SearchProcesses(ctx, scrutinizerdb.SearchProcessesParams{
+	procs, err := queries.SearchProcesses(ctx, scrutinizerdb.SearchProcessesParams{
 		EntityID:        hex.EncodeToString(entityID), // NOTE: we search as hex string instead of []byte; see sqlc.yaml
 		Namespace:       int64(namespace),
 		Status:          int64(statusnum),
@@ -216,20 +133,9 @@ func (s *Scrutinizer) ProcessList(entityID []byte,
 		Limit:           int32(max),
 		WithResults:     withResults,
 	})
-	log.Debugf("ProcessList sqlite took %s", time.Since(sqlStartTime))
 	if err != nil {
 		return nil, err
 	}
-	// []string in hex form is easier to debug when we get mismatches
-	if diff := cmp.Diff(procs, sqlProcs); diff != "" {
-		params := []interface{}{
-			entityID, from, max,
-			searchTerm, namespace,
-			srcNetworkIdstr, status, withResults,
-		}
-		sqliteWarnf("ping mvdan to fix the bug with the information below:\nparams: %#v\ndiff (-badger +sql):\n%s", params, diff)
-	}
-
 	return procs, nil
 }
 
@@ -237,18 +143,19 @@ func (s *Scrutinizer) ProcessList(entityID []byte,
 func (s *Scrutinizer) ProcessCount(entityID []byte) uint64 {
 	startTime := time.Now()
 	defer func() { log.Debugf("ProcessCount took %s", time.Since(startTime)) }()
-	var c uint32
-	var err error
 	if len(entityID) == 0 {
-		// If no entity ID, return the stored count of all processes
-		processCountStore := &indexertypes.CountStore{}
-		if err = s.db.Get(indexertypes.CountStoreProcesses, processCountStore); err != nil {
+		// If no entity ID, return the count of all processes
+		queries, ctx, cancel := s.timeoutQueries()
+		defer cancel()
+		count, err := queries.CountProcesses(ctx)
+		if err != nil {
 			log.Errorf("could not get the process count: %v", err)
 			return 0
 		}
-		return processCountStore.Count
+		return uint64(count)
 	}
-	if c, err = s.EntityProcessCount(entityID); err != nil {
+	c, err := s.EntityProcessCount(entityID)
+	if err != nil {
 		log.Errorf("processCount: cannot fetch entity process count: %v", err)
 		return 0
 	}
@@ -259,38 +166,45 @@ func (s *Scrutinizer) ProcessCount(entityID []byte) uint64 {
 // searchTerm is optional, if declared as zero-value
 // will be ignored. Searches against the ID field.
 func (s *Scrutinizer) EntityList(max, from int, searchTerm string) []string {
-	entities := []string{}
-	if err := s.db.ForEach(
-		badgerhold.Where("ID").MatchFunc(searchMatchFunc(searchTerm)).
-			SortBy("CreationTime", "ID").
-			Skip(from).
-			Limit(max),
-		func(e *indexertypes.Entity) error {
-			entities = append(entities, fmt.Sprintf("%x", e.ID))
-			return nil
-		}); err != nil {
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	entityIDs, err := queries.SearchEntities(ctx, scrutinizerdb.SearchEntitiesParams{
+		EntitySubstr: searchTerm,
+		Offset:       int32(from),
+		Limit:        int32(max),
+	})
+	if err != nil {
 		log.Warnf("error listing entities: %v", err)
 	}
+	entities := []string{}
+	for _, eid := range entityIDs {
+		entities = append(entities, hex.EncodeToString([]byte(eid)))
+	}
 	return entities
 }
 
 // EntityProcessCount returns the number of processes that an entity holds
 func (s *Scrutinizer) EntityProcessCount(entityId []byte) (uint32, error) {
-	entity := &indexertypes.Entity{}
-	if err := s.db.FindOne(entity, badgerhold.Where(badgerhold.Key).Eq(entityId)); err != nil {
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	// NOTE: we query as string instead of []byte; see sqlc.yaml
+	count, err := queries.GetEntityProcessCount(ctx, string(entityId))
+	if err != nil {
 		return 0, err
 	}
-	return entity.ProcessCount, nil
+	return uint32(count), nil
 }
 
 // EntityCount return the number of entities indexed by the scrutinizer
 func (s *Scrutinizer) EntityCount() uint64 {
-	entityCountStore := &indexertypes.CountStore{}
-	if err := s.db.Get(indexertypes.CountStoreEntities, entityCountStore); err != nil {
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	count, err := queries.CountEntities(ctx)
+	if err != nil {
 		log.Errorf("could not get the entity count: %v", err)
 		return 0
 	}
-	return entityCountStore.Count
+	return uint64(count)
 }
 
 // Return whether a process must have live results or not
@@ -307,17 +221,20 @@ func (s *Scrutinizer) isOpenProcess(processID []byte) (bool, error) {
 
 // compute results if the current heigh has scheduled ending processes
 func (s *Scrutinizer) computePendingProcesses(height uint32) {
-	if err := s.db.ForEach(badgerhold.Where("Rheight").Eq(height).Index("Rheight"),
-		func(p *indexertypes.Process) error {
-			initT := time.Now()
-			if err := s.ComputeResult(p.ID); err != nil {
-				log.Warnf("cannot compute results for %x: (%v)", p.ID, err)
-				return nil
-			}
-			log.Infof("results computation on %x took %s", p.ID, time.Since(initT).String())
-			return nil
-		}); err != nil {
+	queries, ctx, cancel := s.timeoutQueries()
+	pids, err := queries.GetProcessIDsByResultsHeight(ctx, int64(height))
+	cancel()
+	if err != nil {
 		log.Warn(err)
+		return
+	}
+	for _, pid := range pids {
+		initT := time.Now()
+		if err := s.ComputeResult(pid); err != nil {
+			log.Warnf("cannot compute results for %x: (%v)", pid, err)
+			continue
+		}
+		log.Infof("results computation on %x took %s", pid, time.Since(initT).String())
 	}
 }
 
@@ -341,68 +258,6 @@ func (s *Scrutinizer) newEmptyProcess(pid []byte) error {
 		return fmt.Errorf("maxCount or maxValue overflows hardcoded maximums")
 	}
 
-	// Create results in the indexer database
-	s.addVoteLock.Lock()
-	if err := s.queryWithRetries(func() error {
-		return s.db.Insert(pid, &indexertypes.Results{
-			ProcessID: pid,
-			// MaxValue requires +1 since 0 is also an option
-			Votes:        indexertypes.NewEmptyVotes(int(options.MaxCount), int(options.MaxValue)+1),
-			Weight:       new(types.BigInt).SetUint64(0),
-			Signatures:   []types.HexBytes{},
-			VoteOpts:     p.GetVoteOptions(),
-			EnvelopeType: p.GetEnvelopeType(),
-		})
-	}); err != nil {
-		s.addVoteLock.Unlock()
-		return err
-	}
-	s.addVoteLock.Unlock()
-
-	// Increment the total process count storage
-	s.db.UpdateMatching(&indexertypes.CountStore{}, badgerhold.Where(badgerhold.Key).
-		Eq(indexertypes.CountStoreProcesses), func(record interface{}) error {
-		update, ok := record.(*indexertypes.CountStore)
-		if !ok {
-			return fmt.Errorf("record isn't the correct type! Wanted CountStore, got %T", record)
-		}
-		update.Count++
-		return nil
-	},
-	)
-
-	// Get the block time from the Header
-	currentBlockTime := time.Unix(s.App.TimestampStartBlock(), 0)
-
-	// Add the entity to the indexer database
-	eid := p.GetEntityId()
-	entity := &indexertypes.Entity{}
-	// If entity is not registered in db, add to entity count cache and insert to db
-	if err := s.db.FindOne(entity, badgerhold.Where(badgerhold.Key).Eq(eid)); err != nil {
-		if err != badgerhold.ErrNotFound {
-			return err
-		}
-		entity.ID = eid
-		entity.CreationTime = currentBlockTime
-		entity.ProcessCount = 0
-		// Increment the total entity count storage
-		s.db.UpdateMatching(&indexertypes.CountStore{}, badgerhold.Where(badgerhold.Key).Eq(indexertypes.CountStoreEntities), func(record interface{}) error {
-			update, ok := record.(*indexertypes.CountStore)
-			if !ok {
-				return fmt.Errorf("record isn't the correct type! Wanted CountStore, got %T", record)
-			}
-			update.Count++
-			return nil
-		})
-	}
-	// Increase the entity process count (and create new entity if does not exist)
-	entity.ProcessCount++
-	if err := s.queryWithRetries(func() error {
-		return s.db.Upsert(eid, entity)
-	}); err != nil {
-		return err
-	}
-
 	compResultsHeight := uint32(0)
 	if live, err := s.isOpenProcess(pid); err != nil {
 		return fmt.Errorf("cannot check if process is live: %w", err)
@@ -412,16 +267,24 @@ func (s *Scrutinizer) newEmptyProcess(pid []byte) error {
 		}
 	}
 
+	// Get the block time from the Header
+	currentBlockTime := time.Unix(s.App.TimestampStartBlock(), 0)
+
+	startDate, endDate := s.processDates(p)
+
 	// Create and store process in the indexer database
+	eid := p.GetEntityId()
 	proc := &indexertypes.Process{
 		ID:                pid,
 		EntityID:          eid,
 		StartBlock:        p.GetStartBlock(),
 		EndBlock:          p.GetBlockCount() + p.GetStartBlock(),
 		Rheight:           compResultsHeight,
+		StartDate:         startDate,
+		EndDate:           endDate,
 		HaveResults:       compResultsHeight > 0,
 		CensusRoot:        p.GetCensusRoot(),
-		RollingCensusRoot: p.GetRollingCensusRoot(),
+		RollingCensusRoot: rollingCensusRoot(p),
 		CensusURI:         p.GetCensusURI(),
 		CensusOrigin:      int32(p.GetCensusOrigin()),
 		Status:            int32(p.GetStatus()),
@@ -435,44 +298,50 @@ func (s *Scrutinizer) newEmptyProcess(pid []byte) error {
 		SourceBlockHeight: p.GetSourceBlockHeight(),
 		SourceNetworkId:   p.SourceNetworkId.String(),
 		Metadata:          p.GetMetadata(),
-		EntityIndex:       entity.ProcessCount,
 		MaxCensusSize:     p.GetMaxCensusSize(),
 		RollingCensusSize: p.GetRollingCensusSize(),
+		QuestionIndex:     p.GetQuestionIndex(),
 	}
-	log.Debugf("new indexer process %s", proc.String())
 
-	queries, ctx, cancel := s.timeoutQueries()
-	defer cancel()
-	if _, err := queries.CreateProcess(ctx, scrutinizerdb.CreateProcessParams{
-		ID:                pid,
-		EntityID:          string(eid), // NOTE: we store as string instead of []byte; see sqlc.yaml
-		EntityIndex:       int64(entity.ProcessCount),
-		StartBlock:        int64(p.GetStartBlock()),
-		EndBlock:          int64(p.GetBlockCount() + p.GetStartBlock()),
-		ResultsHeight:     int64(compResultsHeight),
-		HaveResults:       compResultsHeight > 0,
-		CensusRoot:        nonNullBytes(p.GetCensusRoot()),
-		RollingCensusRoot: nonNullBytes(p.GetRollingCensusRoot()),
-		RollingCensusSize: int64(p.GetRollingCensusSize()),
-		MaxCensusSize:     int64(p.GetMaxCensusSize()),
-		CensusUri:         p.GetCensusURI(),
-		CensusOrigin:      int64(p.GetCensusOrigin()),
-		Status:            int64(p.GetStatus()),
-		Namespace:         int64(p.GetNamespace()),
-		EnvelopePb:        encodedPb(p.GetEnvelopeType()),
-		ModePb:            encodedPb(p.GetMode()),
-		VoteOptsPb:        encodedPb(p.GetVoteOptions()),
-		PrivateKeys:       strings.Join(p.EncryptionPrivateKeys, ","),
-		PublicKeys:        strings.Join(p.EncryptionPublicKeys, ","),
-		CreationTime:      currentBlockTime,
-		SourceBlockHeight: int64(p.GetSourceBlockHeight()),
-		SourceNetworkID:   p.SourceNetworkId.String(), // TODO: store the integer?
-		Metadata:          p.GetMetadata(),
-	}); err != nil {
-		return fmt.Errorf("sql create process: %w", err)
+	// Create results in the indexer database
+	results, err := indexertypes.ResultsToDB(&indexertypes.Results{
+		ProcessID: pid,
+		// MaxValue requires +1 since 0 is also an option
+		Votes:        indexertypes.NewEmptyVotes(int(options.MaxCount), int(options.MaxValue)+1),
+		Weight:       new(types.BigInt).SetUint64(0),
+		Signatures:   []types.HexBytes{},
+		VoteOpts:     p.GetVoteOptions(),
+		EnvelopeType: p.GetEnvelopeType(),
+	})
+	if err != nil {
+		return err
 	}
 
-	return s.queryWithRetries(func() error { return s.db.Insert(pid, proc) })
+	return s.withTx(func(ctx context.Context, queries *scrutinizerdb.Queries) error {
+		if _, err := queries.SetResults(ctx, results); err != nil {
+			return fmt.Errorf("sql set results: %w", err)
+		}
+		// Add the entity to the indexer database if not registered yet
+		// NOTE: we query as string instead of []byte; see sqlc.yaml
+		entityProcessCount, err := queries.GetEntityProcessCount(ctx, string(eid))
+		if err != nil {
+			return err
+		}
+		if entityProcessCount == 0 {
+			if _, err := queries.CreateEntity(ctx, scrutinizerdb.CreateEntityParams{
+				ID:           string(eid), // NOTE: we store as string instead of []byte; see sqlc.yaml
+				CreationTime: currentBlockTime,
+			}); err != nil {
+				return fmt.Errorf("sql create entity: %w", err)
+			}
+		}
+		proc.EntityIndex = uint32(entityProcessCount) + 1
+		log.Debugf("new indexer process %s", proc.String())
+		if _, err := queries.CreateProcess(ctx, processToDB(proc)); err != nil {
+			return fmt.Errorf("sql create process: %w", err)
+		}
+		return nil
+	})
 }
 
 // updateProcess synchronize those fields that can be updated on a existing process
@@ -483,91 +352,70 @@ func (s *Scrutinizer) updateProcess(pid []byte) error {
 		return fmt.Errorf("updateProcess: cannot fetch process %x: %w", pid, err)
 	}
 
-	updateFunc := func(record interface{}) error {
-		update, ok := record.(*indexertypes.Process)
-		if !ok {
-			return fmt.Errorf("record isn't the correct type! Wanted Process, got %T", record)
+	return s.withTx(func(ctx context.Context, queries *scrutinizerdb.Queries) error {
+		previous, err := queries.GetProcess(ctx, pid)
+		if err != nil {
+			return err
 		}
-		update.EndBlock = p.GetBlockCount() + p.GetStartBlock()
-		update.CensusRoot = p.GetCensusRoot()
-		update.RollingCensusRoot = p.GetRollingCensusRoot()
-		update.CensusURI = p.GetCensusURI()
-		update.PrivateKeys = p.EncryptionPrivateKeys
-		update.PublicKeys = p.EncryptionPublicKeys
-		update.Metadata = p.GetMetadata()
-		update.RollingCensusSize = p.GetRollingCensusSize()
-		// If the process is transacting to CANCELED, ensure results are not computed and remove
-		// them from the KV database.
-		if update.Status != int32(models.ProcessStatus_CANCELED) &&
-			p.GetStatus() == models.ProcessStatus_CANCELED {
-			update.HaveResults = false
-			update.FinalResults = true
-			update.Rheight = 0
-			if err := s.db.UpdateMatching(&indexertypes.Results{},
-				badgerhold.Where(badgerhold.Key).Eq(pid), func(record interface{}) error {
-					results, ok := record.(*indexertypes.Results)
-					if !ok {
-						return fmt.Errorf("record isn't the correct type! Wanted Result, got %T", record)
-					}
-					// On cancelled process, remove all results except for envelope height, weight, pid
-					results.Votes = [][]*types.BigInt{}
-					results.EnvelopeType = &models.EnvelopeType{}
-					results.VoteOpts = &models.ProcessVoteOptions{}
-					results.Signatures = []types.HexBytes{}
-					results.Final = false
-					results.BlockHeight = 0
-					return nil
-				}); err != nil {
-				if err != badgerhold.ErrNotFound {
-					log.Warnf("cannot remove CANCELED results: %v", err)
-				}
+		// If the end block of a timed process changes, its live results
+		// are computed on the new end block
+		if endBlock := int64(p.GetBlockCount() + p.GetStartBlock()); endBlock != previous.EndBlock &&
+			!previous.FinalResults && previous.ResultsHeight == previous.EndBlock+1 {
+			if _, err := queries.SetProcessResultsHeight(ctx, scrutinizerdb.SetProcessResultsHeightParams{
+				ID:            pid,
+				ResultsHeight: endBlock + 1,
+			}); err != nil {
+				return err
 			}
 		}
-		update.Status = int32(p.GetStatus())
-		return nil
-	}
-	if err := s.queryWithRetries(func() error {
-		return s.db.UpdateMatching(&indexertypes.Process{},
-			badgerhold.Where(badgerhold.Key).Eq(pid), updateFunc)
-	}); err != nil {
-		return err
-	}
-	// TODO: remove from results table
-	// TODO: hold a sql db transaction for multiple queries
-	queries, ctx, cancel := s.timeoutQueries()
-	defer cancel()
-	previousStatus, err := queries.GetProcessStatus(ctx, pid)
-	if err != nil {
-		return err
-	}
-	if _, err := queries.UpdateProcessFromState(ctx, scrutinizerdb.UpdateProcessFromStateParams{
-		ID:                pid,
-		EndBlock:          int64(p.GetBlockCount() + p.GetStartBlock()),
-		CensusRoot:        nonNullBytes(p.GetCensusRoot()),
-		RollingCensusRoot: nonNullBytes(p.GetRollingCensusRoot()),
-		RollingCensusSize: int64(p.GetRollingCensusSize()),
-		CensusUri:         p.GetCensusURI(),
-		PrivateKeys:       strings.Join(p.EncryptionPrivateKeys, ","),
-		PublicKeys:        strings.Join(p.EncryptionPublicKeys, ","),
-		Metadata:          p.GetMetadata(),
-		Status:            int64(p.GetStatus()),
-	}); err != nil {
-		return err
-	}
-	if models.ProcessStatus(previousStatus) != models.ProcessStatus_CANCELED &&
-		p.GetStatus() == models.ProcessStatus_CANCELED {
-
-		if _, err := queries.SetProcessResultsHeight(ctx, scrutinizerdb.SetProcessResultsHeightParams{
-			ID:            pid,
-			ResultsHeight: 0,
+		if _, err := queries.UpdateProcessFromState(ctx, scrutinizerdb.UpdateProcessFromStateParams{
+			ID:                pid,
+			EndBlock:          int64(p.GetBlockCount() + p.GetStartBlock()),
+			CensusRoot:        nonNullBytes(p.GetCensusRoot()),
+			RollingCensusRoot: nonNullBytes(rollingCensusRoot(p)),
+			RollingCensusSize: int64(p.GetRollingCensusSize()),
+			CensusUri:         p.GetCensusURI(),
+			PrivateKeys:       strings.Join(p.EncryptionPrivateKeys, ","),
+			PublicKeys:        strings.Join(p.EncryptionPublicKeys, ","),
+			Metadata:          p.GetMetadata(),
+			Status:            int64(p.GetStatus()),
+			QuestionIndex:     int64(p.GetQuestionIndex()),
 		}); err != nil {
 			return err
 		}
-		if _, err := queries.SetProcessResultsCancelled(ctx, pid); err != nil {
-			return err
+		// If the process is transacting to CANCELED, ensure results are not computed and remove
+		// them from the database, except for envelope height and weight.
+		if models.ProcessStatus(previous.Status) != models.ProcessStatus_CANCELED &&
+			p.GetStatus() == models.ProcessStatus_CANCELED {
+			if _, err := queries.SetProcessResultsHeight(ctx, scrutinizerdb.SetProcessResultsHeightParams{
+				ID:            pid,
+				ResultsHeight: 0,
+			}); err != nil {
+				return err
+			}
+			if _, err := queries.SetProcessResultsCancelled(ctx, pid); err != nil {
+				return err
+			}
+			if _, err := queries.CancelResults(ctx, pid); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+}
+
+// processDates returns the start and end dates of a process.  The dates of
+// the processes that are not timed are estimated from their blocks.
+func (s *Scrutinizer) processDates(p *models.Process) (time.Time, time.Time) {
+	if end := p.GetEndTime(); end > 0 {
+		return time.Unix(p.GetStartTime(), 0), time.Unix(end, 0)
 	}
-	return nil
+	now := time.Unix(s.App.TimestampStartBlock(), 0)
+	height := int64(s.App.State.CurrentHeight())
+	blockDate := func(block uint32) time.Time {
+		return now.Add(time.Duration(int64(block)-height) * vochain.DefaultBlockTime)
+	}
+	return blockDate(p.GetStartBlock()), blockDate(p.GetStartBlock() + p.GetBlockCount())
 }
 
 // setResultsHeight updates the Rheight of any process whose ID is pid.
@@ -575,19 +423,6 @@ func (s *Scrutinizer) setResultsHeight(pid []byte, height uint32) error {
 	if height == 0 {
 		panic("setting results height to 0?")
 	}
-	if err := s.queryWithRetries(func() error {
-		return s.db.UpdateMatching(&indexertypes.Process{}, badgerhold.Where(badgerhold.Key).Eq(pid),
-			func(record interface{}) error {
-				update, ok := record.(*indexertypes.Process)
-				if !ok {
-					return fmt.Errorf("record isn't the correct type! Wanted Result, got %T", record)
-				}
-				update.Rheight = height
-				return nil
-			})
-	}); err != nil {
-		return err
-	}
 	queries, ctx, cancel := s.timeoutQueries()
 	defer cancel()
 	if _, err := queries.SetProcessResultsHeight(ctx, scrutinizerdb.SetProcessResultsHeightParams{
@@ -598,18 +433,3 @@ func (s *Scrutinizer) setResultsHeight(pid []byte, height uint32) error {
 	}
 	return nil
 }
-
-// searchMatchFunc generates a function which compares a badgerhold record against searchTerm.
-// The record should be types.HexBytes, and is encoded as a string to compare to searchTerm.
-func searchMatchFunc(searchTerm string) func(r *badgerhold.RecordAccess) (bool, error) {
-	return func(r *badgerhold.RecordAccess) (bool, error) {
-		if searchTerm == "" {
-			return true, nil
-		}
-		// TODO search without having to convert each field to strings
-		if strings.Contains(hex.EncodeToString(r.Field().(types.HexBytes)), searchTerm) {
-			return true, nil
-		}
-		return false, nil
-	}
-}
@@ -0,0 +1,17 @@
+-- name: CreateEntity :execresult
+INSERT INTO entities (
+	id, creation_time
+) VALUES (
+	?, ?
+);
+
+-- name: CountEntities :one
+SELECT COUNT(*) FROM entities;
+
+-- name: SearchEntities :many
+SELECT id FROM entities
+WHERE (sqlc.arg(entity_substr) = "" OR (INSTR(LOWER(HEX(id)), sqlc.arg(entity_substr)) > 0))
+ORDER BY creation_time ASC, id ASC
+LIMIT ?
+OFFSET ?
+;
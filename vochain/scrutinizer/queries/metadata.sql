@@ -0,0 +1,124 @@
+-- name: CreateProcessMetadata :execresult
+INSERT INTO process_metadata (
+	process_id, uri, title, description, questions, failed_attempts
+) VALUES (
+	?, ?, ?, ?, ?, ?
+);
+
+-- name: UpdateProcessMetadata :execresult
+UPDATE process_metadata
+SET uri             = sqlc.arg(uri),
+	title           = sqlc.arg(title),
+	description     = sqlc.arg(description),
+	questions       = sqlc.arg(questions),
+	failed_attempts = sqlc.arg(failed_attempts)
+WHERE process_id = sqlc.arg(process_id);
+
+-- name: GetPendingProcessMetadata :many
+SELECT p.id, p.metadata, m.uri, m.failed_attempts FROM processes AS p
+LEFT JOIN process_metadata AS m ON m.process_id = p.id
+WHERE p.metadata != ""
+	AND (m.uri IS NULL OR m.uri != p.metadata
+		OR (m.failed_attempts > 0 AND m.failed_attempts < sqlc.arg(max_attempts)))
+ORDER BY IFNULL(m.failed_attempts, 0) ASC, p.creation_time ASC
+LIMIT ?
+;
+
+-- name: SearchProcessesByText :many
+SELECT p.id FROM process_search
+JOIN process_metadata AS m ON m.rowid = process_search.rowid
+JOIN processes AS p ON p.id = m.process_id
+WHERE process_search MATCH sqlc.arg(query)
+	AND (sqlc.arg(date_from) = 0 OR CAST(strftime('%s', p.end_date) AS INTEGER) >= sqlc.arg(date_from))
+	AND (sqlc.arg(date_to) = 0 OR CAST(strftime('%s', p.start_date) AS INTEGER) <= sqlc.arg(date_to))
+ORDER BY CASE sqlc.arg(sort_by)
+		WHEN "relevance" THEN bm25(process_search, 4.0, 1.0, 2.0)
+		ELSE 0 END ASC,
+	CAST(strftime('%s', CASE sqlc.arg(sort_by)
+		WHEN "startDate" THEN p.start_date
+		WHEN "endDate" THEN p.end_date
+		ELSE p.creation_time END) AS INTEGER) DESC, p.id ASC
+LIMIT ?
+OFFSET ?
+;
+
+-- name: SearchProcessesBySubstring :many
+SELECT p.id FROM process_metadata AS m
+JOIN processes AS p ON p.id = m.process_id
+WHERE (m.title LIKE sqlc.arg(pattern) ESCAPE '\'
+		OR m.description LIKE sqlc.arg(pattern) ESCAPE '\'
+		OR m.questions LIKE sqlc.arg(pattern) ESCAPE '\')
+	AND (sqlc.arg(date_from) = 0 OR CAST(strftime('%s', p.end_date) AS INTEGER) >= sqlc.arg(date_from))
+	AND (sqlc.arg(date_to) = 0 OR CAST(strftime('%s', p.start_date) AS INTEGER) <= sqlc.arg(date_to))
+ORDER BY CASE sqlc.arg(sort_by)
+		WHEN "relevance" THEN (CASE
+			WHEN m.title LIKE sqlc.arg(pattern) ESCAPE '\' THEN 0
+			WHEN m.questions LIKE sqlc.arg(pattern) ESCAPE '\' THEN 1
+			ELSE 2 END)
+		ELSE 0 END ASC,
+	CAST(strftime('%s', CASE sqlc.arg(sort_by)
+		WHEN "startDate" THEN p.start_date
+		WHEN "endDate" THEN p.end_date
+		ELSE p.creation_time END) AS INTEGER) DESC, p.id ASC
+LIMIT ?
+OFFSET ?
+;
+
+-- name: SearchProcessesByDate :many
+SELECT id FROM processes
+WHERE (sqlc.arg(date_from) = 0 OR CAST(strftime('%s', end_date) AS INTEGER) >= sqlc.arg(date_from))
+	AND (sqlc.arg(date_to) = 0 OR CAST(strftime('%s', start_date) AS INTEGER) <= sqlc.arg(date_to))
+ORDER BY CAST(strftime('%s', CASE sqlc.arg(sort_by)
+		WHEN "startDate" THEN start_date
+		WHEN "endDate" THEN end_date
+		ELSE creation_time END) AS INTEGER) DESC, id ASC
+LIMIT ?
+OFFSET ?
+;
+
+-- name: CreateEntityMetadata :execresult
+INSERT INTO entity_metadata (
+	entity_id, info_uri, uri, name, description, failed_attempts
+) VALUES (
+	?, ?, "", "", "", 0
+);
+
+-- name: SetEntityInfoURI :execresult
+UPDATE entity_metadata
+SET info_uri = sqlc.arg(info_uri)
+WHERE entity_id = sqlc.arg(entity_id);
+
+-- name: UpdateEntityMetadata :execresult
+UPDATE entity_metadata
+SET uri             = sqlc.arg(uri),
+	name            = sqlc.arg(name),
+	description     = sqlc.arg(description),
+	failed_attempts = sqlc.arg(failed_attempts)
+WHERE entity_id = sqlc.arg(entity_id);
+
+-- name: GetPendingEntityMetadata :many
+SELECT * FROM entity_metadata
+WHERE uri != info_uri
+	OR (failed_attempts > 0 AND failed_attempts < sqlc.arg(max_attempts))
+ORDER BY failed_attempts ASC, rowid ASC
+LIMIT ?
+;
+
+-- name: SearchEntitiesByText :many
+SELECT e.entity_id FROM entity_search
+JOIN entity_metadata AS e ON e.rowid = entity_search.rowid
+WHERE entity_search MATCH sqlc.arg(query)
+ORDER BY bm25(entity_search, 4.0, 1.0) ASC, e.entity_id ASC
+LIMIT ?
+OFFSET ?
+;
+
+-- name: SearchEntitiesBySubstring :many
+SELECT entity_id FROM entity_metadata
+WHERE name LIKE sqlc.arg(pattern) ESCAPE '\'
+	OR description LIKE sqlc.arg(pattern) ESCAPE '\'
+ORDER BY (CASE WHEN name LIKE sqlc.arg(pattern) ESCAPE '\' THEN 0 ELSE 1 END) ASC,
+	entity_id ASC
+LIMIT ?
+OFFSET ?
+;
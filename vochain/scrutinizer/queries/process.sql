@@ -8,7 +8,8 @@ INSERT INTO processes (
 	envelope_pb, mode_pb, vote_opts_pb,
 	private_keys, public_keys,
 	question_index, creation_time,
-	source_block_height, source_network_id
+	source_block_height, source_network_id,
+	start_date, end_date
 ) VALUES (
 	?, ?, ?, ?, ?,
 	?, ?, ?,
@@ -18,6 +19,7 @@ INSERT INTO processes (
 	?, ?, ?,
 	?, ?,
 	?, ?,
+	?, ?,
 	?, ?
 );
 
@@ -54,7 +56,8 @@ SET end_block           = sqlc.arg(end_block),
 	public_keys         = sqlc.arg(public_keys),
 	metadata            = sqlc.arg(metadata),
 	rolling_census_size = sqlc.arg(rolling_census_size),
-	status              = sqlc.arg(status)
+	status              = sqlc.arg(status),
+	question_index      = sqlc.arg(question_index)
 WHERE id = sqlc.arg(id);
 
 -- name: GetProcessStatus :one
@@ -76,3 +79,18 @@ WHERE id = sqlc.arg(id);
 UPDATE processes
 SET have_results = FALSE, final_results = TRUE
 WHERE id = sqlc.arg(id);
+
+-- name: CountProcesses :one
+SELECT COUNT(*) FROM processes;
+
+-- name: GetEntityProcessCount :one
+SELECT COUNT(*) FROM processes
+WHERE entity_id = sqlc.arg(entity_id);
+
+-- name: GetProcessIDsByResultsHeight :many
+SELECT id FROM processes
+WHERE results_height = sqlc.arg(results_height);
+
+-- name: GetProcessIDsByFinalResults :many
+SELECT id FROM processes
+WHERE final_results = sqlc.arg(final_results);
@@ -0,0 +1,11 @@
+-- name: GetReindexHeight :one
+SELECT height FROM reindex_status
+WHERE id = 1
+LIMIT 1;
+
+-- name: SetReindexHeight :execresult
+REPLACE INTO reindex_status (
+	id, height
+) VALUES (
+	1, ?
+);
@@ -0,0 +1,27 @@
+-- name: SetResults :execresult
+REPLACE INTO results (
+	process_id, votes, weight, envelope_height,
+	envelope_pb, vote_opts_pb, signatures,
+	final, block_height,
+	ranked_seats, ballots, ranked
+) VALUES (
+	?, ?, ?, ?,
+	?, ?, ?,
+	?, ?,
+	?, ?, ?
+);
+
+-- name: GetResults :one
+SELECT * FROM results
+WHERE process_id = ?
+LIMIT 1;
+
+-- name: CancelResults :execresult
+UPDATE results
+SET votes        = "[]",
+	envelope_pb  = "",
+	vote_opts_pb = "",
+	signatures   = "",
+	final        = FALSE,
+	block_height = 0
+WHERE process_id = sqlc.arg(process_id);
@@ -0,0 +1,32 @@
+-- name: SetAccountBalance :execresult
+REPLACE INTO account_balances (
+	account, height, balance
+) VALUES (
+	?, ?, ?
+);
+
+-- name: GetAccountBalances :many
+SELECT * FROM account_balances
+WHERE account = ?
+ORDER BY height DESC
+LIMIT ?
+OFFSET ?
+;
+
+-- name: CreateTxTypeStats :execresult
+INSERT INTO tx_type_stats (
+	tx_type, tx_count, amount, fees
+) VALUES (
+	?, ?, ?, ?
+);
+
+-- name: UpdateTxTypeStats :execresult
+UPDATE tx_type_stats
+SET tx_count = tx_count + sqlc.arg(tx_count),
+	amount   = amount + sqlc.arg(amount),
+	fees     = fees + sqlc.arg(fees)
+WHERE tx_type = sqlc.arg(tx_type);
+
+-- name: GetTxTypeStats :many
+SELECT * FROM tx_type_stats
+ORDER BY tx_type ASC;
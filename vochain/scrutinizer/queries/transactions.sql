@@ -0,0 +1,53 @@
+-- name: CreateTransaction :execresult
+INSERT INTO transactions (
+	hash, block_height, block_index, tx_type, sender, amount, cost
+) VALUES (
+	?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: CreateTransactionRecipient :execresult
+INSERT INTO transaction_recipients (
+	tx_id, account
+) VALUES (
+	?, ?
+);
+
+-- name: GetTransaction :one
+SELECT * FROM transactions
+WHERE id = ?
+LIMIT 1;
+
+-- name: GetTransactionByHash :one
+SELECT * FROM transactions
+WHERE hash = ?
+LIMIT 1;
+
+-- name: GetTransactionRecipients :many
+SELECT account FROM transaction_recipients
+WHERE tx_id = ?
+ORDER BY account ASC;
+
+-- name: CountTransactions :one
+SELECT COUNT(*) FROM transactions;
+
+-- name: SearchAccountTransactions :many
+SELECT * FROM transactions
+WHERE sender = sqlc.arg(account)
+	OR id IN (SELECT tx_id FROM transaction_recipients WHERE account = sqlc.arg(account))
+ORDER BY id DESC
+LIMIT ?
+OFFSET ?
+;
+
+-- name: SearchTokenTransfers :many
+SELECT t.id, t.hash, t.block_height, t.block_index, t.tx_type, t.sender,
+	r.account AS recipient, t.amount
+FROM transactions AS t
+JOIN transaction_recipients AS r ON r.tx_id = t.id
+WHERE t.amount > 0
+	AND (LENGTH(sqlc.arg(account)) = 0 OR t.sender = sqlc.arg(account)
+		OR r.account = sqlc.arg(account))
+ORDER BY t.id DESC, r.account ASC
+LIMIT ?
+OFFSET ?
+;
@@ -0,0 +1,53 @@
+-- name: CreateVote :execresult
+INSERT INTO votes (
+	nullifier, process_id, height, weight,
+	tx_index, question_index, overwrite_count,
+	creation_time
+) VALUES (
+	?, ?, ?, ?,
+	?, ?, ?,
+	?
+);
+
+-- name: UpdateVote :execresult
+UPDATE votes
+SET height          = sqlc.arg(height),
+	weight          = sqlc.arg(weight),
+	tx_index        = sqlc.arg(tx_index),
+	question_index  = sqlc.arg(question_index),
+	overwrite_count = overwrite_count + 1,
+	creation_time   = sqlc.arg(creation_time)
+WHERE nullifier = sqlc.arg(nullifier);
+
+-- name: GetVote :one
+SELECT * FROM votes
+WHERE nullifier = ?
+LIMIT 1;
+
+-- name: GetProcessVotes :many
+SELECT * FROM votes
+WHERE process_id = ?;
+
+-- name: CountVotes :one
+SELECT COUNT(*) FROM votes;
+
+-- name: SearchVotes :many
+SELECT * FROM votes
+WHERE (LENGTH(sqlc.arg(process_id)) = 0 OR process_id = sqlc.arg(process_id))
+	AND (sqlc.arg(nullifier_substr) = "" OR (INSTR(LOWER(HEX(nullifier)), sqlc.arg(nullifier_substr)) > 0))
+ORDER BY height ASC, tx_index ASC
+LIMIT ?
+OFFSET ?
+;
+
+-- name: CreateVoteOverwrite :execresult
+INSERT INTO vote_overwrites (
+	nullifier, height, tx_index, creation_time
+) VALUES (
+	?, ?, ?, ?
+);
+
+-- name: GetVoteOverwrites :many
+SELECT * FROM vote_overwrites
+WHERE nullifier = ?
+ORDER BY height ASC, tx_index ASC;
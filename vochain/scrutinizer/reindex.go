@@ -0,0 +1,353 @@
+package scrutinizer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/vochain"
+)
+
+// Reindexing
+//
+// The indexes can be rebuilt from the blocks stored by the node, without
+// resyncing the chain.  The blocks are replayed on a separate state built
+// from the genesis (see vochain.NewReplayApplication), whose only event
+// listener is a new scrutinizer, so the transactions go through the same
+// EventListener callbacks as when they were delivered.  The replay state and
+// the new database are kept in a reindex directory, along with the last
+// replayed height, so an interrupted reindex continues where it stopped.  The
+// current indexes keep serving queries until the reindex catches up with the
+// chain.  Then the data not derived from the blocks is copied to the new
+// database, which replaces the current one on the next commit: it is moved
+// next to the current one as pending (see reindexedSQLiteSuffix) and the
+// database handle is swapped, so the commit does not wait for any copy.  The
+// replaced database is closed once its running queries finish, and the
+// pending database is moved in its place on the next start.
+
+// reindexProgressInterval is the interval between the reindex progress logs.
+const reindexProgressInterval = 30 * time.Second
+
+// reindexedSQLiteSuffix is the suffix of the path of a reindexed database
+// which replaces the current one, at dbPath+"-sqlite", on the next start.
+const reindexedSQLiteSuffix = "-sqlite-reindexed"
+
+// reindexKeptTables are the tables holding the fetched metadata, which is not
+// derived from the blocks, so their rows are copied from the current database
+// to the reindexed one.
+var reindexKeptTables = []string{"process_metadata", "entity_metadata"}
+
+// errReindexInconsistent is returned when the replay state and the reindexed
+// database of a reindex directory are at different heights.
+var errReindexInconsistent = errors.New("inconsistent reindex")
+
+// Reindexer rebuilds the indexes by replaying the blocks stored by a vochain
+// application into a new scrutinizer database.
+type Reindexer struct {
+	dir         string
+	app         *vochain.BaseApplication
+	scrutinizer *Scrutinizer
+}
+
+// NewReindexer opens the reindex at dir, or creates it if it does not exist,
+// to replay the blocks stored by app.  The replay state uses a dbType
+// database, initialized with genesisAppState.  If the reindex at dir was
+// interrupted while committing a block, it starts over.
+func NewReindexer(dir string, app *vochain.BaseApplication, dbType string,
+	genesisAppState []byte) (*Reindexer, error) {
+	r, err := openReindexer(dir, app, dbType, genesisAppState)
+	if errors.Is(err, errReindexInconsistent) {
+		log.Warnf("%v, starting the reindex over", err)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		r, err = openReindexer(dir, app, dbType, genesisAppState)
+	}
+	return r, err
+}
+
+func openReindexer(dir string, app *vochain.BaseApplication, dbType string,
+	genesisAppState []byte) (*Reindexer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	replay, err := vochain.NewReplayApplication(app, dbType,
+		filepath.Join(dir, "state"), genesisAppState)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the replay application: %w", err)
+	}
+	sc, err := NewScrutinizer(filepath.Join(dir, "scrutinizer"), replay, false)
+	if err != nil {
+		replay.State.Close()
+		return nil, err
+	}
+	r := &Reindexer{dir: dir, app: replay, scrutinizer: sc}
+
+	// The indexes of a block are committed right after its state, so both
+	// heights only differ if the reindex was stopped in between.
+	height, err := replay.State.LastHeight()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	queries, ctx, cancel := sc.timeoutQueries()
+	defer cancel()
+	indexed, err := queries.GetReindexHeight(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		r.Close()
+		return nil, err
+	}
+	if uint32(indexed) != height {
+		r.Close()
+		return nil, fmt.Errorf("%w at %s: state height %d, indexes height %d",
+			errReindexInconsistent, dir, height, indexed)
+	}
+	return r, nil
+}
+
+// Height returns the last replayed block height.
+func (r *Reindexer) Height() uint32 {
+	return r.app.Height()
+}
+
+// Replay replays the stored blocks until the given height, logging the
+// progress periodically.  It stops early if ctx is cancelled.
+func (r *Reindexer) Replay(ctx context.Context, height uint32) error {
+	from := r.Height()
+	startTime, logTime := time.Now(), time.Now()
+	for h := from + 1; h <= height; h++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		block := r.app.GetBlockByHeight(int64(h))
+		if block == nil {
+			return fmt.Errorf("cannot get block %d", h)
+		}
+		if err := r.app.ReplayBlock(block); err != nil {
+			return fmt.Errorf("cannot replay block %d: %w", h, err)
+		}
+		queries, qctx, cancel := r.scrutinizer.timeoutQueries()
+		_, err := queries.SetReindexHeight(qctx, int64(h))
+		cancel()
+		if err != nil {
+			return fmt.Errorf("cannot store the reindex height: %w", err)
+		}
+		if time.Since(logTime) >= reindexProgressInterval || h == height {
+			log.Infof("reindexed block %d of %d (%.1f blocks/s)", h, height,
+				float64(h-from)/time.Since(startTime).Seconds())
+			logTime = time.Now()
+		}
+	}
+	return nil
+}
+
+// Close waits for the pending results computations and closes the replay
+// state and the reindexed database.
+func (r *Reindexer) Close() error {
+	r.scrutinizer.pendingResults.Wait()
+	err := r.scrutinizer.db().Close()
+	if closeErr := r.app.State.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Reindex rebuilds the indexes from the blocks stored by the node, in the
+// reindex directory dbPath+"-reindex" with a dbType replay state, and
+// replaces the current indexes on the first commit after catching up with
+// the chain.  It blocks until the indexes are replaced, ctx is cancelled or
+// an error occurs, and the current indexes keep serving queries meanwhile.
+// The reindex continues on the next call if stopped.
+func (s *Scrutinizer) Reindex(ctx context.Context, dbType string, genesisAppState []byte) error {
+	if err := s.checkNoPendingReindex(); err != nil {
+		return err
+	}
+	r, err := NewReindexer(s.dbPath+"-reindex", s.App, dbType, genesisAppState)
+	if err != nil {
+		return fmt.Errorf("cannot open the reindex: %w", err)
+	}
+	log.Infof("reindexing from block %d", r.Height()+1)
+	for r.Height() < s.App.Height() {
+		if err := r.Replay(ctx, s.App.Height()); err != nil {
+			r.Close()
+			return err
+		}
+	}
+	// The rows stored from now on are not copied, the process metadata is
+	// fetched again once the indexes are replaced
+	if err := s.copyKeptTables(r); err != nil {
+		r.Close()
+		return fmt.Errorf("cannot copy the kept tables: %w", err)
+	}
+	// The blocks committed from now on are replayed by Commit()
+	done := make(chan error, 1)
+	s.reindexLock.Lock()
+	s.reindexer, s.reindexDone = r, done
+	s.reindexLock.Unlock()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	s.reindexLock.Lock()
+	pending := s.reindexer == r
+	if pending {
+		s.reindexer = nil
+	}
+	s.reindexLock.Unlock()
+	if !pending {
+		return <-done
+	}
+	r.Close()
+	return ctx.Err()
+}
+
+// applyLiveReindex replays the blocks committed before height on r and
+// replaces the indexes with the rebuilt ones.  The replaced database is
+// closed and the reindex directory removed in the background.  The live
+// results are recomputed afterwards, since the replay does not count them.
+func (s *Scrutinizer) applyLiveReindex(r *Reindexer, height uint32) error {
+	if err := r.Replay(context.Background(), height-1); err != nil {
+		r.Close()
+		return err
+	}
+	replaced, err := s.swapReindexedDB(r)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := closeReplacedDB(replaced, r); err != nil {
+			log.Warnf("cannot close the replaced indexes: %v", err)
+		}
+	}()
+	go s.AfterSyncBootstrap()
+	return nil
+}
+
+// ApplyReindex closes r and replaces the indexes with the ones it rebuilt,
+// which must include all the committed blocks.  The rows of the tables not
+// derived from the blocks are copied to the rebuilt indexes, and the reindex
+// directory is removed afterwards.
+func (s *Scrutinizer) ApplyReindex(r *Reindexer) error {
+	if err := s.checkNoPendingReindex(); err != nil {
+		r.Close()
+		return err
+	}
+	if err := s.copyKeptTables(r); err != nil {
+		r.Close()
+		return fmt.Errorf("cannot copy the kept tables: %w", err)
+	}
+	replaced, err := s.swapReindexedDB(r)
+	if err != nil {
+		return err
+	}
+	return closeReplacedDB(replaced, r)
+}
+
+// checkNoPendingReindex returns an error if a reindexed database is pending
+// to be moved on the next start, as it is the one being used.
+func (s *Scrutinizer) checkNoPendingReindex() error {
+	if _, err := os.Stat(s.dbPath + reindexedSQLiteSuffix); err == nil {
+		return fmt.Errorf("the previous reindex is pending a restart")
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// copyKeptTables copies the rows of reindexKeptTables from the current
+// database to the one of r.
+func (s *Scrutinizer) copyKeptTables(r *Reindexer) error {
+	// The copy might take long on big databases, so it has no timeout.
+	ctx := context.Background()
+	conn, err := r.scrutinizer.db().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS live", s.dbPath+"-sqlite"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE live")
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range reindexKeptTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM main."%s"`, table)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`INSERT INTO main."%s" SELECT * FROM live."%s"`, table, table)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// swapReindexedDB closes r, moves its database to the pending reindexed
+// path and replaces the database of the indexes with it.  It returns the
+// replaced database, which is still open.
+func (s *Scrutinizer) swapReindexedDB(r *Reindexer) (*sql.DB, error) {
+	// Closing the database checkpoints its WAL, so only the main file
+	// must be moved
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+	path := s.dbPath + reindexedSQLiteSuffix
+	if err := os.Rename(r.scrutinizer.dbPath+"-sqlite", path); err != nil {
+		return nil, err
+	}
+	sqlDB, err := InitDB(path)
+	if err != nil {
+		return nil, err
+	}
+	replaced := s.db()
+	s.sqlDB.Store(sqlDB)
+	s.envelopeHeightCache.Purge()
+	log.Infof("indexes replaced by the reindexed ones at height %d", r.Height())
+	return replaced, nil
+}
+
+// closeReplacedDB closes the database replaced by the reindex r, which waits
+// for its running queries, and removes the reindex directory.
+func closeReplacedDB(replaced *sql.DB, r *Reindexer) error {
+	if err := replaced.Close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(r.dir)
+}
+
+// movePendingReindex moves the reindexed database pending at dbPath, if any,
+// in place of the current one.  The databases must not be open.
+func movePendingReindex(dbPath string) error {
+	path, current := dbPath+reindexedSQLiteSuffix, dbPath+"-sqlite"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// Opening and closing the database checkpoints its WAL, if the node
+	// stopped while it was open
+	sqlDB, err := InitDB(path)
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.Close(); err != nil {
+		return err
+	}
+	// The WAL of the current database must not be applied to the new one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(current + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	log.Infof("moving the reindexed database %s to %s", path, current)
+	return os.Rename(path, current)
+}
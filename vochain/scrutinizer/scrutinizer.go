@@ -1,18 +1,15 @@
 package scrutinizer
 
 import (
-	"bytes"
 	"context"
-	"database/sql"
 	"embed"
 	"errors"
 	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/dgraph-io/badger/v3"
-	"github.com/timshannon/badgerhold/v3"
 	"go.vocdoni.io/dvote/db/lru"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
@@ -21,7 +18,6 @@ import (
 	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 	"go.vocdoni.io/proto/build/go/models"
 
-	"github.com/pressly/goose/v3"
 	// modernc is a pure-Go version, but its errors have less useful info.
 	// We use mattn while developing and testing, and we can swap them later.
 	// _ "modernc.org/sqlite"
@@ -41,7 +37,6 @@ const (
 	MaxEnvelopeListSize = 32 << 18
 
 	countEnvelopeCacheSize = 1024
-	resultsCacheSize       = 512
 )
 
 // EventListener is an interface used for executing custom functions during the
@@ -64,7 +59,7 @@ type Scrutinizer struct {
 	// voteIndexPool is the list of votes that will be indexed in the database
 	voteIndexPool []*VoteWithIndex
 	// votePool is the list of votes that should be live counted, grouped by processId
-	votePool map[string][]*models.Vote
+	votePool map[string][]*VoteWithIndex
 	// newProcessPool is the list of new process IDs on the current block
 	newProcessPool []*indexertypes.ScrutinizerOnProcessData
 	// updateProcessPool is the list of process IDs that require sync with the state database
@@ -77,162 +72,78 @@ type Scrutinizer struct {
 	liveResultsProcs sync.Map
 	// eventOnResults is the list of external callbacks that will be executed by the scrutinizer
 	eventOnResults []EventListener
-	db             *badgerhold.Store
-	sqlDB          *sql.DB
+	// sqlDB holds the *sql.DB of the indexes, which is replaced by a
+	// reindex, so it must be read with db()
+	sqlDB atomic.Value
 	// envelopeHeightCache and countTotalEnvelopes are in memory counters that helps reducing the
 	// access time when GenEnvelopeHeight() is called.
 	envelopeHeightCache *lru.Cache
-	// resultsCache is a memory cache for final results results, stores processId:<results>
-	resultsCache *lru.Cache
-	// addVoteLock is used to avoid Transaction Conflicts on the KV database.
-	// It is not critical and the code should be able to recover from a Conflict, but we
-	// try to minimize this situations in order to improve performance on the KV.
-	// TODO (pau): remove this mutex and relay on the KV layer
-	addVoteLock sync.RWMutex
-	// voteTxLock is used to avoid Transaction Conflicts on the vote index KV database.
-	voteTxLock sync.RWMutex
-	// txIndexLock is used to avoid Transaction Conflicts on the transaction index KV database.
-	txIndexLock sync.Mutex
 	// recoveryBootLock prevents Commit() to add new votes while the recovery bootstratp is
 	// being executed.
 	recoveryBootLock sync.RWMutex
 	// ignoreLiveResults if true, partial/live results won't be calculated (only final results)
 	ignoreLiveResults bool
+	// pendingResults tracks the results computations started on Commit()
+	pendingResults sync.WaitGroup
+	// dbPath is the database path given to NewScrutinizer
+	dbPath string
+	// reindexer is set by Reindex() once it has caught up with the chain, so
+	// the next Commit() replaces the indexes with the rebuilt ones and sends
+	// the outcome to reindexDone.
+	reindexer   *Reindexer
+	reindexDone chan error
+	reindexLock sync.Mutex
 }
 
 // VoteWithIndex holds a Vote and a txIndex. Model for the VotePool.
+// For serial processes, questionIndex is the question the vote is cast for.
 type VoteWithIndex struct {
-	vote    *models.Vote
-	txIndex int32
+	vote          *models.Vote
+	txIndex       int32
+	questionIndex uint32
+	// overwrite is true if the vote overwrites a previous one, which is
+	// subtracted from the live results if known
+	overwrite bool
+	previous  *VoteWithIndex
 }
 
 // NewScrutinizer returns an instance of the Scrutinizer
-// using the local storage database of dbPath and integrated into the state vochain instance
+// using the local storage database of dbPath and integrated into the state vochain instance.
+// The sqlite database is stored at dbPath+"-sqlite", and the badgerhold database
+// of previous versions at dbPath, if any, is migrated to it.
 func NewScrutinizer(dbPath string, app *vochain.BaseApplication, countLiveResults bool) (*Scrutinizer, error) {
-	s := &Scrutinizer{App: app, ignoreLiveResults: !countLiveResults}
-	var err error
-	s.db, err = InitDB(dbPath)
+	s := &Scrutinizer{App: app, ignoreLiveResults: !countLiveResults, dbPath: dbPath}
+	if err := movePendingReindex(dbPath); err != nil {
+		return nil, fmt.Errorf("could not move the reindexed database: %w", err)
+	}
+	sqlDB, err := InitDB(dbPath + "-sqlite")
 	if err != nil {
 		return nil, err
 	}
+	s.sqlDB.Store(sqlDB)
+	if err := s.migrateBadgerhold(dbPath); err != nil {
+		return nil, fmt.Errorf("could not migrate the badgerhold database: %w", err)
+	}
 
 	startTime := time.Now()
-
-	countMap, err := s.retrieveCounts()
+	txCount, err := s.TransactionCount()
 	if err != nil {
-		return nil, fmt.Errorf("could not create scrutinizer: %v", err)
+		return nil, fmt.Errorf("could not create scrutinizer: %w", err)
 	}
-
-	log.Infof("indexer initialization took %s, stored %d "+
-		"transactions, %d envelopes, %d processes and %d entities",
-		time.Since(startTime),
-		countMap[indexertypes.CountStoreTransactions],
-		countMap[indexertypes.CountStoreEnvelopes],
-		countMap[indexertypes.CountStoreProcesses],
-		countMap[indexertypes.CountStoreEntities])
-
-	sqlPath := dbPath + "-sqlite"
-	// s.sqlDB, err = sql.Open("sqlite", sqlPath) // modernc
-	s.sqlDB, err = sql.Open("sqlite3", sqlPath) // mattn
+	envelopeCount, err := s.GetEnvelopeHeight(nil)
 	if err != nil {
-		return nil, err
-	}
-
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return nil, err
-	}
-	// goose.SetLogger(log.Logger()) // TODO: interfaces aren't compatible
-	goose.SetBaseFS(embedMigrations)
-	if err := goose.Up(s.sqlDB, "migrations"); err != nil {
-		return nil, fmt.Errorf("goose up: %w", err)
+		return nil, fmt.Errorf("could not create scrutinizer: %w", err)
 	}
+	log.Infof("indexer initialization took %s, stored %d "+
+		"transactions, %d envelopes, %d processes and %d entities",
+		time.Since(startTime), txCount, envelopeCount, s.ProcessCount(nil), s.EntityCount())
 
 	// Subscrive to events
 	s.App.State.AddEventListener(s)
 	s.envelopeHeightCache = lru.New(countEnvelopeCacheSize)
-	s.resultsCache = lru.New(resultsCacheSize)
 	return s, nil
 }
 
-func (s *Scrutinizer) timeoutQueries() (*scrutinizerdb.Queries, context.Context, context.CancelFunc) {
-	ctx := context.TODO()
-	ctx, cancel := context.WithTimeout(ctx, time.Minute)
-	queries := scrutinizerdb.New(s.sqlDB)
-	return queries, ctx, cancel
-}
-
-// retrieveCounts returns a count for txs, envelopes, processes, and entities, in that order.
-// If no CountStore model is stored for the type, it counts all db entries of that type.
-func (s *Scrutinizer) retrieveCounts() (map[uint8]uint64, error) {
-	var err error
-	txCountStore := new(indexertypes.CountStore)
-	if err = s.db.Get(indexertypes.CountStoreTransactions, txCountStore); err != nil {
-		log.Warnf("could not get the transaction count: %v", err)
-		count, err := s.db.Count(&indexertypes.TxReference{}, &badgerhold.Query{})
-		if err != nil {
-			if err != badger.ErrKeyNotFound {
-				return nil, fmt.Errorf("could not count total transactions: %v", err)
-			}
-			// If keyNotFound error, ensure count is 0
-			count = 0
-		}
-		// Store new countStore value
-		txCountStore.Count = uint64(count)
-		txCountStore.Type = indexertypes.CountStoreTransactions
-		if err := s.db.Upsert(txCountStore.Type, txCountStore); err != nil {
-			return nil, fmt.Errorf("could not store transaction count: %v", err)
-		}
-	}
-	envelopeCountStore := new(indexertypes.CountStore)
-	if err = s.db.Get(indexertypes.CountStoreEnvelopes, envelopeCountStore); err != nil {
-		log.Warnf("could not get the envelope count: %v", err)
-		count, err := s.db.Count(&indexertypes.VoteReference{}, &badgerhold.Query{})
-		if err != nil && err != badger.ErrKeyNotFound {
-			return nil, fmt.Errorf("could not count total envelopes: %v", err)
-		}
-		// Store new countStore value
-		envelopeCountStore.Count = uint64(count)
-		envelopeCountStore.Type = indexertypes.CountStoreEnvelopes
-		if err := s.db.Upsert(envelopeCountStore.Type, envelopeCountStore); err != nil {
-			return nil, fmt.Errorf("could not store envelope count: %v", err)
-		}
-	}
-	processCountStore := new(indexertypes.CountStore)
-	if err = s.db.Get(indexertypes.CountStoreProcesses, processCountStore); err != nil {
-		log.Warnf("could not get the process count: %v", err)
-		count, err := s.db.Count(&indexertypes.Process{}, &badgerhold.Query{})
-		if err != nil && err != badger.ErrKeyNotFound {
-			return nil, fmt.Errorf("could not count total processes: %v", err)
-		}
-		// Store new countStore value
-		processCountStore.Count = uint64(count)
-		processCountStore.Type = indexertypes.CountStoreProcesses
-		if err := s.db.Upsert(processCountStore.Type, processCountStore); err != nil {
-			return nil, fmt.Errorf("could not store process count: %v", err)
-		}
-	}
-	entityCountStore := new(indexertypes.CountStore)
-	if err = s.db.Get(indexertypes.CountStoreEntities, entityCountStore); err != nil {
-		log.Warnf("could not get the entity count: %v", err)
-		count, err := s.db.Count(&indexertypes.Entity{}, &badgerhold.Query{})
-		if err != nil && err != badger.ErrKeyNotFound {
-			return nil, fmt.Errorf("could not count total entities: %v", err)
-		}
-		// Store new countStore value
-		entityCountStore.Count = uint64(count)
-		entityCountStore.Type = indexertypes.CountStoreEntities
-		if err := s.db.Upsert(entityCountStore.Type, entityCountStore); err != nil {
-			return nil, fmt.Errorf("could not store entity count: %v", err)
-		}
-	}
-	return map[uint8]uint64{
-		indexertypes.CountStoreTransactions: txCountStore.Count,
-		indexertypes.CountStoreEnvelopes:    envelopeCountStore.Count,
-		indexertypes.CountStoreProcesses:    processCountStore.Count,
-		indexertypes.CountStoreEntities:     entityCountStore.Count,
-	}, nil
-}
-
 // AfterSyncBootstrap is a blocking function that waits until the Vochain is synchronized
 // and then execute a set of recovery actions. It mainly checks for those processes which are
 // still open (live) and updates all temporary data (current voting weight and live results
@@ -267,13 +178,9 @@ func (s *Scrutinizer) AfterSyncBootstrap() {
 	// Find those processes which do not have yet final results,
 	// they are considered live so we need to compute the temporary
 	// results (or only its weight in case of Encrypted)
-	prcs := [][]byte{}
-	err := s.db.ForEach(
-		badgerhold.Where("FinalResults").Eq(false),
-		func(p *indexertypes.Process) error {
-			prcs = append(prcs, p.ID)
-			return nil
-		})
+	queries, ctx, cancel := s.timeoutQueries()
+	prcs, err := queries.GetProcessIDsByFinalResults(ctx, false)
+	cancel()
 	if err != nil {
 		log.Error(err)
 	}
@@ -292,16 +199,14 @@ func (s *Scrutinizer) AfterSyncBootstrap() {
 			continue
 		}
 		options := process.GetVoteOptions()
-		if err := s.queryWithRetries(func() error {
-			return s.db.Upsert(p, &indexertypes.Results{
-				ProcessID: p,
-				// MaxValue requires +1 since 0 is also an option
-				Votes:        indexertypes.NewEmptyVotes(int(options.MaxCount), int(options.MaxValue)+1),
-				Weight:       new(types.BigInt).SetUint64(0),
-				VoteOpts:     options,
-				EnvelopeType: process.GetEnvelopeType(),
-				Signatures:   []types.HexBytes{},
-			})
+		if err := s.setResults(&indexertypes.Results{
+			ProcessID: p,
+			// MaxValue requires +1 since 0 is also an option
+			Votes:        indexertypes.NewEmptyVotes(int(options.MaxCount), int(options.MaxValue)+1),
+			Weight:       new(types.BigInt).SetUint64(0),
+			VoteOpts:     options,
+			EnvelopeType: process.GetEnvelopeType(),
+			Signatures:   []types.HexBytes{},
 		}); err != nil {
 			log.Errorf("cannot upsert results to db: %v", err)
 			continue
@@ -313,9 +218,10 @@ func (s *Scrutinizer) AfterSyncBootstrap() {
 			VoteOpts:     options,
 			EnvelopeType: process.EnvelopeType,
 		}
-		if err := s.WalkEnvelopes(p, false, func(vote *models.VoteEnvelope, weight *big.Int) {
+		if err := s.WalkEnvelopes(p, false, func(vote *models.VoteEnvelope,
+			txRef *indexertypes.VoteReference) {
 			if err := s.addLiveVote(vote.ProcessId, vote.VotePackage,
-				weight, results); err != nil {
+				txRef.Weight.ToInt(), txRef.QuestionIndex, results); err != nil {
 				log.Warn(err)
 			}
 		}); err != nil {
@@ -335,6 +241,15 @@ func (s *Scrutinizer) AfterSyncBootstrap() {
 
 // Commit is called by the APP when a block is confirmed and included into the chain
 func (s *Scrutinizer) Commit(height uint32) error {
+	// Replace the indexes with the ones rebuilt by Reindex(), if ready
+	s.reindexLock.Lock()
+	r, reindexDone := s.reindexer, s.reindexDone
+	s.reindexer = nil
+	s.reindexLock.Unlock()
+	if r != nil {
+		reindexDone <- s.applyLiveReindex(r, height)
+	}
+
 	// Add Entity and register new active process
 	for _, p := range s.newProcessPool {
 		if err := s.newEmptyProcess(p.ProcessID); err != nil {
@@ -353,9 +268,6 @@ func (s *Scrutinizer) Commit(height uint32) error {
 		}
 	}
 
-	// Index new transactions
-	go s.indexNewTxs(s.newTxPool)
-
 	// Schedule results computation
 	for _, p := range s.resultsPool {
 		if err := s.setResultsHeight(p.ProcessID, height+1); err != nil {
@@ -366,52 +278,45 @@ func (s *Scrutinizer) Commit(height uint32) error {
 		log.Infof("scheduled results computation on next block for %x", p.ProcessID)
 	}
 
+	// Index new transactions and envelopes
 	startTime := time.Now()
-	txn := s.db.Badger().NewTransaction(true)
-	for _, v := range s.voteIndexPool {
-		if err := s.addVoteIndex(
-			v.vote.Nullifier,
-			v.vote.ProcessId,
-			height,
-			v.vote.Weight,
-			v.txIndex, txn); err != nil {
-			log.Warn(err)
+	if err := s.withTx(func(ctx context.Context, queries *scrutinizerdb.Queries) error {
+		if err := indexTxs(ctx, queries, s.newTxPool); err != nil {
+			return err
 		}
-	}
-	if len(s.voteIndexPool) > 0 {
-		s.voteTxLock.Lock()
-		wg := sync.WaitGroup{}
-		wg.Add(1)
-		txn.CommitWith(func(err error) {
-			if err != nil {
-				log.Error(err)
+		if err := s.indexTokenStats(ctx, queries, s.newTxPool, height); err != nil {
+			return err
+		}
+		if err := s.indexEntityInfoURIs(ctx, queries, s.newTxPool); err != nil {
+			return err
+		}
+		for _, v := range s.voteIndexPool {
+			addIndex := s.addVoteIndex
+			if v.overwrite {
+				addIndex = s.overwriteVoteIndex
 			}
-			wg.Done()
-		})
-		wg.Wait()
-		s.voteTxLock.Unlock()
+			if err := addIndex(ctx, queries,
+				v.vote.Nullifier,
+				v.vote.ProcessId,
+				height,
+				v.vote.Weight,
+				v.txIndex,
+				v.questionIndex); err != nil {
+				log.Warn(err)
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Errorf("commit: cannot index transactions and envelopes: %v", err)
+	} else if len(s.voteIndexPool) > 0 {
 		log.Infof("indexed %d new envelopes, took %s",
 			len(s.voteIndexPool), time.Since(startTime))
-
-		if err := s.db.UpdateMatching(&indexertypes.CountStore{},
-			badgerhold.Where(badgerhold.Key).Eq(indexertypes.CountStoreEnvelopes),
-			func(record interface{}) error {
-				update, ok := record.(*indexertypes.CountStore)
-				if !ok {
-					return fmt.Errorf("record isn't the correct type! Wanted CountStore, got %T", record)
-				}
-				update.Count += uint64(len(s.voteIndexPool))
-				return nil
-			},
-		); err != nil {
-			log.Errorf("could not get envelope count: %v", err)
-		}
 	}
-	txn.Discard()
 
 	// Add votes collected by onVote (live results)
 	nvotes := 0
 	startTime = time.Now()
+	var overwriteErr error
 
 	for pid, votes := range s.votePool {
 		// Get the process information
@@ -428,10 +333,29 @@ func (s *Scrutinizer) Commit(height uint32) error {
 			EnvelopeType: proc.Envelope,
 		}
 		for _, v := range votes {
-			if err := s.addLiveVote(v.ProcessId,
-				v.VotePackage,
+			// the previous vote of an overwritten one is subtracted, if
+			// it cannot be, the new vote is not added so it is not
+			// counted twice
+			if v.previous != nil {
+				if err := s.removeLiveVote(v.previous.vote.ProcessId,
+					v.previous.vote.VotePackage,
+					new(big.Int).SetBytes(v.previous.vote.GetWeight()),
+					v.previous.questionIndex,
+					results); err != nil {
+					log.Errorf("overwritten vote %x cannot be removed from process %x live results: %v",
+						v.vote.Nullifier, v.vote.ProcessId, err)
+					if overwriteErr == nil {
+						overwriteErr = fmt.Errorf("cannot remove overwritten vote %x: %w",
+							v.vote.Nullifier, err)
+					}
+					continue
+				}
+			}
+			if err := s.addLiveVote(v.vote.ProcessId,
+				v.vote.VotePackage,
 				// TBD: Not 100% sure what happens if weight=nil
-				new(big.Int).SetBytes(v.GetWeight()),
+				new(big.Int).SetBytes(v.vote.GetWeight()),
+				v.questionIndex,
 				results); err != nil {
 				log.Warnf("vote cannot be added: %v", err)
 			} else {
@@ -450,13 +374,17 @@ func (s *Scrutinizer) Commit(height uint32) error {
 
 	// Check if there are processes that need results computing
 	// this can be run async
-	go s.computePendingProcesses(height)
-	return nil
+	s.pendingResults.Add(1)
+	go func() {
+		defer s.pendingResults.Done()
+		s.computePendingProcesses(height)
+	}()
+	return overwriteErr
 }
 
 // Rollback removes the non committed pending operations
 func (s *Scrutinizer) Rollback() {
-	s.votePool = make(map[string][]*models.Vote)
+	s.votePool = make(map[string][]*VoteWithIndex)
 	s.voteIndexPool = []*VoteWithIndex{}
 	s.newProcessPool = []*indexertypes.ScrutinizerOnProcessData{}
 	s.resultsPool = []*indexertypes.ScrutinizerOnProcessData{}
@@ -473,10 +401,23 @@ func (s *Scrutinizer) OnProcess(pid, eid []byte, censusRoot, censusURI string, t
 // OnVote scrutinizer stores the votes if the processId is live results (on going)
 // and the blockchain is not synchronizing.
 func (s *Scrutinizer) OnVote(v *models.Vote, txIndex int32) {
+	vi := &VoteWithIndex{vote: v, txIndex: txIndex, questionIndex: v.QuestionIndex}
+	if overwrites, err := s.App.State.EnvelopeOverwrites(v.ProcessId, v.Nullifier,
+		false); err != nil {
+		log.Warnf("cannot get vote %x overwrites: %v", v.Nullifier, err)
+	} else if overwrites > 0 {
+		vi.overwrite = true
+	}
 	if !s.ignoreLiveResults && s.isProcessLiveResults(v.ProcessId) {
-		s.votePool[string(v.ProcessId)] = append(s.votePool[string(v.ProcessId)], v)
+		if vi.overwrite {
+			var err error
+			if vi.previous, err = s.previousVote(v.ProcessId, v.Nullifier); err != nil {
+				log.Warnf("cannot get overwritten vote %x: %v", v.Nullifier, err)
+			}
+		}
+		s.votePool[string(v.ProcessId)] = append(s.votePool[string(v.ProcessId)], vi)
 	}
-	s.voteIndexPool = append(s.voteIndexPool, &VoteWithIndex{vote: v, txIndex: txIndex})
+	s.voteIndexPool = append(s.voteIndexPool, vi)
 }
 
 // OnCancel scrutinizer stores the processID and entityID
@@ -502,6 +443,16 @@ func (s *Scrutinizer) OnProcessStatusChange(pid []byte, status models.ProcessSta
 	s.updateProcessPool = append(s.updateProcessPool, pid)
 }
 
+// OnProcessQuestionIndex adds the process to the updateProcessPool
+func (s *Scrutinizer) OnProcessQuestionIndex(pid []byte, questionIndex uint32, txIndex int32) {
+	s.updateProcessPool = append(s.updateProcessPool, pid)
+}
+
+// OnProcessDurationChange adds the process to the updateProcessPool
+func (s *Scrutinizer) OnProcessDurationChange(pid []byte, blockCount uint32) {
+	s.updateProcessPool = append(s.updateProcessPool, pid)
+}
+
 // OnRevealKeys checks if all keys have been revealed and in such case add the
 // process to the results queue
 func (s *Scrutinizer) OnRevealKeys(pid []byte, priv string, txIndex int32) {
@@ -514,8 +465,30 @@ func (s *Scrutinizer) OnRevealKeys(pid []byte, priv string, txIndex int32) {
 		log.Errorf("keyindex is nil")
 		return
 	}
-	// if all keys have been revealed, compute the results
-	if *p.KeyIndex < 1 {
+	// if all keys have been revealed, or the threshold of shares on
+	// threshold keys, compute the results
+	required, count, err := s.App.State.KeyKeepersThreshold(false)
+	if err != nil {
+		log.Errorf("cannot get keykeepers threshold: (%s)", err)
+		return
+	}
+	allRevealed := *p.KeyIndex < 1
+	if count > 0 && p.EnvelopeType.GetHomomorphic() {
+		decryptions, err := vochain.TallyDecryptions(p.EncryptionPrivateKeys, count)
+		if err != nil {
+			log.Errorf("cannot get tally decryptions: (%s)", err)
+			return
+		}
+		allRevealed = len(decryptions) == int(required)
+	} else if count > 0 {
+		shares, err := vochain.ThresholdShares(p.EncryptionPrivateKeys, count)
+		if err != nil {
+			log.Errorf("cannot get threshold shares: (%s)", err)
+			return
+		}
+		allRevealed = len(shares) == int(required)
+	}
+	if allRevealed {
 		data := indexertypes.ScrutinizerOnProcessData{EntityID: p.EntityId, ProcessID: pid}
 		s.resultsPool = append(s.resultsPool, &data)
 	}
@@ -569,29 +542,9 @@ func (s *Scrutinizer) OnProcessResults(pid []byte, results *models.ProcessResult
 			return
 		}
 
-		myVotes := BuildProcessResult(myResults, results.EntityId).GetVotes()
-		correct := len(myVotes) == len(results.Votes)
-		if !correct {
-			log.Errorf("results validation failed: wrong number of votes")
-		}
-		for i, q := range results.GetVotes() {
-			if !correct {
-				break
-			}
-			if len(q.Question) != len(myVotes[i].Question) {
-				log.Errorf("results validation failed: wrong question size")
-				correct = false
-				break
-			}
-			for j, v := range q.Question {
-				if !bytes.Equal(v, myVotes[i].Question[j]) {
-					log.Errorf("results validation failed: wrong question result")
-					correct = false
-					break
-				}
-			}
-		}
-		if correct {
+		// the votes and, on ranked choice processes, the ranked results
+		// must match
+		if vochain.SameResults(BuildProcessResult(myResults, results.EntityId), results) {
 			log.Infof("published results for process %x are correct", pid)
 		} else {
 			log.Errorf("published results for process %x are not correct", pid)
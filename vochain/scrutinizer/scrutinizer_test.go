@@ -1,23 +1,32 @@
 package scrutinizer
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	stdlog "log"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	qt "github.com/frankban/quicktest"
 	"github.com/pressly/goose/v3"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"go.vocdoni.io/dvote/censustree"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/db/metadb"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	"go.vocdoni.io/dvote/vochain"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
 	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 	models "go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
@@ -632,6 +641,7 @@ func TestLiveResults(t *testing.T) {
 			pid,
 			vp,
 			new(big.Int).SetUint64(1),
+			0,
 			r),
 			qt.IsNil)
 	}
@@ -664,6 +674,73 @@ func TestLiveResults(t *testing.T) {
 	}
 }
 
+func TestSerialResults(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+
+	pid := util.RandomBytes(32)
+	questionCount := uint32(2)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:     pid,
+		EnvelopeType:  &models.EnvelopeType{Serial: true},
+		Status:        models.ProcessStatus_READY,
+		BlockCount:    10,
+		VoteOptions:   &models.ProcessVoteOptions{MaxCount: 2, MaxValue: 2},
+		Mode:          &models.ProcessMode{AutoStart: true, Interruptible: true},
+		QuestionIndex: new(uint32),
+		QuestionCount: &questionCount,
+	}), qt.IsNil)
+	app.AdvanceTestBlock()
+	sc.addProcessToLiveResults(pid)
+
+	addVotes := func(question uint32, n, value int) [][]byte {
+		vp, err := json.Marshal(vochain.VotePackage{
+			Nonce: fmt.Sprintf("%x", util.RandomHex(32)),
+			Votes: []int{value},
+		})
+		qt.Assert(t, err, qt.IsNil)
+		nullifiers := [][]byte{}
+		for i := 0; i < n; i++ {
+			v := &models.Vote{ProcessId: pid, VotePackage: vp, QuestionIndex: question,
+				Nullifier: util.RandomBytes(32), Weight: big.NewInt(1).Bytes()}
+			sc.OnVote(v, int32(i))
+			nullifiers = append(nullifiers, v.Nullifier)
+		}
+		return nullifiers
+	}
+	// 10 votes for the first question, option 1
+	n0 := addVotes(0, 10, 1)
+	// 5 votes for the second question, option 2
+	qt.Assert(t, app.State.SetProcessQuestionIndex(pid, 1, true), qt.IsNil)
+	n1 := addVotes(1, 5, 2)
+	qt.Assert(t, sc.Commit(app.Height()), qt.IsNil)
+
+	ref, err := sc.GetEnvelopeReference(n0[0])
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ref.QuestionIndex, qt.Equals, uint32(0))
+	ref, err = sc.GetEnvelopeReference(n1[0])
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ref.QuestionIndex, qt.Equals, uint32(1))
+
+	proc, err := sc.ProcessInfo(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, proc.QuestionIndex, qt.Equals, uint32(1))
+
+	result, err := sc.GetResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, result.EnvelopeHeight, qt.Equals, uint64(15))
+	qt.Assert(t, result.Votes[0][1].String(), qt.Equals, "10")
+	qt.Assert(t, result.Votes[0][2].String(), qt.Equals, "0")
+	qt.Assert(t, result.Votes[1][1].String(), qt.Equals, "0")
+	qt.Assert(t, result.Votes[1][2].String(), qt.Equals, "5")
+
+	// A serial vote must contain a single value
+	qt.Assert(t, result.AddSerialVote(0, []int{1, 1}, nil, nil), qt.Not(qt.IsNil))
+	qt.Assert(t, result.AddSerialVote(2, []int{1}, nil, nil), qt.Not(qt.IsNil))
+}
+
 func TestAddVote(t *testing.T) {
 	app := vochain.TestBaseApplication(t)
 
@@ -766,7 +843,7 @@ var vote = func(v []int, sc *Scrutinizer, pid []byte, weight *big.Int) error {
 		EnvelopeType: proc.Envelope,
 	}
 	sc.addProcessToLiveResults(pid)
-	if err := sc.addLiveVote(pid, vp, weight, r); err != nil {
+	if err := sc.addLiveVote(pid, vp, weight, 0, r); err != nil {
 		return err
 	}
 	return sc.commitVotes(pid, r, 1)
@@ -977,11 +1054,10 @@ func TestTxIndexer(t *testing.T) {
 
 	for i := 0; i < 10; i++ {
 		for j := 0; j < 10; j++ {
-			sc.OnNewTx([]byte(fmt.Sprintf("hash%d%d", i, j)), uint32(i), int32(j))
+			sc.OnNewTx(testVoteTx(fmt.Sprintf("hash%d%d", i, j)), uint32(i), int32(j))
 		}
 	}
 	qt.Assert(t, sc.Commit(0), qt.IsNil)
-	time.Sleep(3 * time.Second)
 
 	for i := 0; i < 10; i++ {
 		for j := 0; j < 10; j++ {
@@ -989,11 +1065,789 @@ func TestTxIndexer(t *testing.T) {
 			qt.Assert(t, err, qt.IsNil)
 			qt.Assert(t, ref.BlockHeight, qt.Equals, uint32(i))
 			qt.Assert(t, ref.TxBlockIndex, qt.Equals, int32(j))
+			qt.Assert(t, ref.Type, qt.Equals, models.TxType_VOTE.String())
 
-			hashRef, err := sc.GetTxHashReference([]byte(fmt.Sprintf("hash%d%d", i, j)))
+			hashRef, err := sc.GetTxHashReference(testVoteTx(fmt.Sprintf("hash%d%d", i, j)).TxID[:])
 			qt.Assert(t, err, qt.IsNil)
 			qt.Assert(t, hashRef.BlockHeight, qt.Equals, uint32(i))
 			qt.Assert(t, hashRef.TxBlockIndex, qt.Equals, int32(j))
 		}
 	}
 }
+
+// testVoteTx returns an anonymous vote transaction with the given hash.
+func testVoteTx(hash string) *vochain.VochainTx {
+	tx := &vochain.VochainTx{Tx: &models.Tx{Payload: &models.Tx_Vote{Vote: &models.VoteEnvelope{}}}}
+	copy(tx.TxID[:], hash)
+	return tx
+}
+
+func TestAccountTxIndexer(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+
+	treasurer := ethereum.NewSignKeys()
+	qt.Assert(t, treasurer.Generate(), qt.IsNil)
+	receiver := ethereum.NewSignKeys()
+	qt.Assert(t, receiver.Generate(), qt.IsNil)
+	qt.Assert(t, app.State.SetAccount(vochain.BurnAddress, &vochain.Account{}), qt.IsNil)
+	qt.Assert(t, app.State.SetTreasurer(treasurer.Address(), 0), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_SEND_TOKENS, 10), qt.IsNil)
+	for _, acc := range []*ethereum.SignKeys{treasurer, receiver} {
+		qt.Assert(t, app.State.CreateAccount(acc.Address(), "ipfs://",
+			make([]common.Address, 0), 0), qt.IsNil)
+	}
+	qt.Assert(t, app.State.MintBalance(treasurer.Address(), 1000), qt.IsNil)
+	app.Commit()
+
+	// the treasurer mints 50 tokens to the receiver and sends it 100 more
+	beginBlock := func(height int64) {
+		header := tmtypes.Header{Height: height, Time: time.Now()}
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: *header.ToProto()})
+	}
+	deliverTx := func(tx *models.Tx) []byte {
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(tx)
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = treasurer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes})
+		qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Data))
+		return tmtypes.Tx(stxBytes).Hash()
+	}
+	beginBlock(1)
+	deliverTx(&models.Tx{Payload: &models.Tx_MintTokens{MintTokens: &models.MintTokensTx{
+		Txtype: models.TxType_MINT_TOKENS,
+		To:     receiver.Address().Bytes(),
+		Value:  50,
+	}}})
+	sendHash := deliverTx(&models.Tx{Payload: &models.Tx_SendTokens{SendTokens: &models.SendTokensTx{
+		Txtype: models.TxType_SEND_TOKENS,
+		From:   treasurer.Address().Bytes(),
+		To:     receiver.Address().Bytes(),
+		Value:  100,
+	}}})
+	app.Commit()
+
+	sendRef, err := sc.GetTxHashReference(sendHash)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sendRef.Type, qt.Equals, models.TxType_SEND_TOKENS.String())
+	qt.Assert(t, sendRef.Sender, qt.DeepEquals, types.HexBytes(treasurer.Address().Bytes()))
+	qt.Assert(t, sendRef.Recipients, qt.DeepEquals,
+		[]types.HexBytes{receiver.Address().Bytes()})
+	qt.Assert(t, sendRef.Amount, qt.Equals, uint64(100))
+	qt.Assert(t, sendRef.Cost, qt.Equals, uint64(10))
+
+	// both accounts are involved in both transactions, newest first
+	for _, acc := range []*ethereum.SignKeys{treasurer, receiver} {
+		txs, err := sc.GetAccountTransactions(acc.Address().Bytes(), 0, 10)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, txs, qt.HasLen, 2)
+		qt.Assert(t, txs[0], qt.DeepEquals, sendRef)
+		qt.Assert(t, txs[1].Type, qt.Equals, models.TxType_MINT_TOKENS.String())
+		qt.Assert(t, txs[1].Amount, qt.Equals, uint64(50))
+		qt.Assert(t, txs[1].Cost, qt.Equals, uint64(0))
+	}
+	txs, err := sc.GetAccountTransactions(receiver.Address().Bytes(), 1, 10)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, txs, qt.HasLen, 1)
+	txs, err = sc.GetAccountTransactions(util.RandomBytes(types.EthereumAddressSize), 0, 10)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, txs, qt.HasLen, 0)
+
+	transfers, err := sc.GetTokenTransfers(nil, 0, 10)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, transfers, qt.HasLen, 2)
+	qt.Assert(t, transfers[0], qt.DeepEquals, &indexertypes.TokenTransfer{
+		Height:      sendRef.Index,
+		Hash:        sendHash,
+		BlockHeight: sendRef.BlockHeight,
+		Index:       sendRef.TxBlockIndex,
+		Type:        models.TxType_SEND_TOKENS.String(),
+		From:        treasurer.Address().Bytes(),
+		To:          receiver.Address().Bytes(),
+		Amount:      100,
+	})
+	transfers, err = sc.GetTokenTransfers(receiver.Address().Bytes(), 1, 10)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, transfers, qt.HasLen, 1)
+	qt.Assert(t, transfers[0].Amount, qt.Equals, uint64(50))
+	transfers, err = sc.GetTokenTransfers(util.RandomBytes(types.EthereumAddressSize), 0, 10)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, transfers, qt.HasLen, 0)
+
+	// the balances are stored for each block that moved the tokens
+	beginBlock(2)
+	deliverTx(&models.Tx{Payload: &models.Tx_SendTokens{SendTokens: &models.SendTokensTx{
+		Txtype: models.TxType_SEND_TOKENS,
+		From:   treasurer.Address().Bytes(),
+		To:     receiver.Address().Bytes(),
+		Value:  5,
+		Nonce:  1,
+	}}})
+	app.Commit()
+	for _, b := range []struct {
+		account  []byte
+		balances []*indexertypes.AccountBalance
+	}{
+		{treasurer.Address().Bytes(), []*indexertypes.AccountBalance{
+			{Height: 2, Balance: 875}, {Height: 1, Balance: 890}}},
+		{receiver.Address().Bytes(), []*indexertypes.AccountBalance{
+			{Height: 2, Balance: 155}, {Height: 1, Balance: 150}}},
+		{vochain.BurnAddress.Bytes(), []*indexertypes.AccountBalance{
+			{Height: 2, Balance: 20}, {Height: 1, Balance: 10}}},
+	} {
+		balances, err := sc.GetAccountBalances(b.account, 0, 10)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, balances, qt.DeepEquals, b.balances)
+	}
+
+	stats, err := sc.GetTokenStats()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, stats, qt.DeepEquals, &indexertypes.TokenStats{
+		Minted: 50,
+		Burned: 20,
+		Supply: 30,
+		TxTypes: []*indexertypes.TxTypeStats{
+			{Type: models.TxType_MINT_TOKENS.String(), Count: 1, Amount: 50},
+			{Type: models.TxType_SEND_TOKENS.String(), Count: 2, Amount: 105, Fees: 20},
+		},
+	})
+}
+
+func TestRankedResults(t *testing.T) {
+	// Four options, 19 voters ranking them
+	results := &indexertypes.Results{
+		Votes:        indexertypes.NewEmptyVotes(4, 4),
+		Weight:       new(types.BigInt).SetUint64(0),
+		VoteOpts:     &models.ProcessVoteOptions{MaxCount: 4, MaxValue: 3},
+		EnvelopeType: &models.EnvelopeType{UniqueValues: true},
+		RankedSeats:  2,
+		Ballots:      indexertypes.RankedBallots{},
+	}
+	for _, b := range []struct {
+		ranking []int
+		weight  int64
+	}{
+		{[]int{0, 1, 2}, 8},
+		{[]int{1, 2, 0}, 5},
+		{[]int{2, 1, 0}, 4},
+		{[]int{3, 2, 1}, 1},
+		{[]int{3, 2, 1}, 1},
+	} {
+		qt.Assert(t, results.AddVote(b.ranking, big.NewInt(b.weight), nil), qt.IsNil)
+	}
+	qt.Assert(t, results.Ballots, qt.HasLen, 4)
+	ranked := indexertypes.ComputeRankedResults(results.Ballots, 4, 2)
+
+	// Instant-Runoff: 3 is eliminated, then 1, and 2 wins with 11 votes
+	irv := ranked.InstantRunoff
+	qt.Assert(t, irv.Elected, qt.DeepEquals, []int{2})
+	qt.Assert(t, irv.Rounds, qt.HasLen, 3)
+	qt.Assert(t, irv.Rounds[0].Eliminated, qt.DeepEquals, []int{3})
+	qt.Assert(t, irv.Rounds[1].Eliminated, qt.DeepEquals, []int{1})
+	qt.Assert(t, irv.Rounds[2].Tallies[0].String(), qt.Equals, "8")
+	qt.Assert(t, irv.Rounds[2].Tallies[2].String(), qt.Equals, "11")
+
+	// STV: 0 reaches the quota of 7 and its surplus goes to 1; then 3 and 2
+	// are eliminated, and 1 takes the second seat
+	stv := ranked.STV
+	qt.Assert(t, stv.Quota.String(), qt.Equals, "7")
+	qt.Assert(t, stv.Elected, qt.DeepEquals, []int{0, 1})
+	qt.Assert(t, stv.Rounds[0].Elected, qt.DeepEquals, []int{0})
+	qt.Assert(t, stv.Rounds[1].Tallies[1].String(), qt.Equals, "6")
+	qt.Assert(t, stv.Rounds[1].Eliminated, qt.DeepEquals, []int{3})
+
+	// Condorcet: 1 beats every other option
+	qt.Assert(t, ranked.Condorcet.Winner, qt.Equals, 1)
+	qt.Assert(t, ranked.Condorcet.Pairwise[1][0].String(), qt.Equals, "11")
+	qt.Assert(t, ranked.Condorcet.Pairwise[0][1].String(), qt.Equals, "8")
+	qt.Assert(t, ranked.Condorcet.Ranking, qt.DeepEquals, []int{1, 2, 0, 3})
+
+	// the oracles publish the ranked results, with their rounds, along with
+	// the votes
+	results.Ranked = ranked
+	pr := BuildProcessResult(results, nil)
+	qt.Assert(t, pr.Votes, qt.HasLen, 4)
+	published, err := json.Marshal(indexertypes.RankedResultsFromProto(pr.Ranked))
+	qt.Assert(t, err, qt.IsNil)
+	computed, err := json.Marshal(ranked)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, string(published), qt.Equals, string(computed))
+	other := BuildProcessResult(results, nil)
+	qt.Assert(t, vochain.SameResults(pr, other), qt.IsTrue)
+	other.Ranked.Condorcet.Winner = 0
+	qt.Assert(t, vochain.SameResults(pr, other), qt.IsFalse)
+}
+
+func TestOverwriteVote(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+
+	pid := util.RandomBytes(32)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Status:       models.ProcessStatus_READY,
+		Mode:         &models.ProcessMode{AutoStart: true},
+		BlockCount:   10,
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 2, MaxVoteOverwrites: 1},
+	}), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	addVote := func(nullifier []byte, value int) {
+		vp, err := json.Marshal(vochain.VotePackage{Votes: []int{value}})
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, app.State.AddVote(&models.Vote{
+			ProcessId:   pid,
+			Nullifier:   nullifier,
+			VotePackage: vp,
+			Weight:      big.NewInt(2).Bytes(),
+		}), qt.IsNil)
+	}
+	// the second vote of the first voter replaces its first vote
+	voter := util.RandomBytes(32)
+	addVote(voter, 0)
+	addVote(util.RandomBytes(32), 1)
+	addVote(voter, 2)
+	app.AdvanceTestBlock()
+
+	result, err := sc.GetResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, GetFriendlyResults(result.Votes), qt.DeepEquals, [][]string{{"0", "2", "2"}})
+	qt.Assert(t, result.Weight.String(), qt.Equals, "4")
+	qt.Assert(t, result.EnvelopeHeight, qt.Equals, uint64(2))
+
+	height, err := sc.GetEnvelopeHeight([]byte{})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, height, qt.Equals, uint64(2))
+	ref, err := sc.GetEnvelopeReference(voter)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ref.OverwriteHistory, qt.HasLen, 1)
+	qt.Assert(t, ref.OverwriteHistory[0].Height, qt.Equals, ref.Height)
+
+	// if the overwritten vote cannot be removed, the new one is not counted
+	// and the error is returned
+	newVote := func(value int) *models.Vote {
+		vp, err := json.Marshal(vochain.VotePackage{Votes: []int{value}})
+		qt.Assert(t, err, qt.IsNil)
+		return &models.Vote{
+			ProcessId:   pid,
+			Nullifier:   voter,
+			VotePackage: vp,
+			Weight:      big.NewInt(2).Bytes(),
+		}
+	}
+	sc.votePool[string(pid)] = []*VoteWithIndex{{
+		vote:      newVote(1),
+		overwrite: true,
+		previous:  &VoteWithIndex{vote: newVote(5)},
+	}}
+	qt.Assert(t, sc.Commit(app.Height()), qt.ErrorMatches, "cannot remove overwritten vote .*")
+	sc.Rollback()
+	result, err = sc.GetResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, GetFriendlyResults(result.Votes), qt.DeepEquals, [][]string{{"0", "2", "2"}})
+}
+
+func TestProcessDates(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+	app.AdvanceTestBlock()
+	now := time.Unix(app.TimestampStartBlock(), 0)
+
+	// the dates of a process defined by blocks are estimated
+	pid := util.RandomBytes(32)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Status:       models.ProcessStatus_READY,
+		Mode:         &models.ProcessMode{AutoStart: true},
+		StartBlock:   app.State.CurrentHeight() + 1,
+		BlockCount:   10,
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 2},
+	}), qt.IsNil)
+
+	// timed processes keep their dates
+	timedPid := util.RandomBytes(32)
+	timed := &models.Process{
+		ProcessId:    timedPid,
+		EnvelopeType: &models.EnvelopeType{},
+		Status:       models.ProcessStatus_READY,
+		Mode:         &models.ProcessMode{AutoStart: true},
+		StartBlock:   app.State.CurrentHeight() + 1,
+		BlockCount:   10,
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 2},
+	}
+	vochain.SetProcessSchedule(timed, now.Add(time.Minute), now.Add(time.Hour))
+	qt.Assert(t, app.State.AddProcess(timed), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	proc, err := sc.ProcessInfo(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, proc.StartDate.Equal(now.Add(vochain.DefaultBlockTime)), qt.IsTrue)
+	qt.Assert(t, proc.EndDate.Equal(now.Add(11*vochain.DefaultBlockTime)), qt.IsTrue)
+
+	proc, err = sc.ProcessInfo(timedPid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, proc.StartDate.Equal(now.Add(time.Minute)), qt.IsTrue)
+	qt.Assert(t, proc.EndDate.Equal(now.Add(time.Hour)), qt.IsTrue)
+}
+
+// testStorage is a MetadataStorage serving files from memory.
+type testStorage struct {
+	files     map[string]string
+	retrieved map[string]int
+}
+
+func (s *testStorage) URIprefix() string { return "ipfs://" }
+
+func (s *testStorage) Retrieve(ctx context.Context, id string, maxSize int64) ([]byte, error) {
+	s.retrieved[id]++
+	content, ok := s.files[id]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", id)
+	}
+	return []byte(content), nil
+}
+
+func TestProcessMetadataSearch(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+	app.AdvanceTestBlock()
+	now := time.Unix(app.TimestampStartBlock(), 0)
+
+	storage := &testStorage{
+		files: map[string]string{
+			"budget": `{"title": {"default": "City budget", "ca": "Pressupost de la ciutat"},
+				"description": {"default": "Where should we invest?"},
+				"questions": [{"title": {"default": "Which area?"},
+					"choices": [{"title": {"default": "North park"}}, {"title": {"default": "South park"}}]}]}`,
+			"park": `{"title": {"default": "Park renovation"},
+				"description": {"default": "Approve the park budget"}}`,
+			"board":   `{"title": {"default": "Board election"}}`,
+			"invalid": `{"title": "not multilingual"}`,
+		},
+		retrieved: map[string]int{},
+	}
+	// addProcess adds a process with the metadata at uri, open from start
+	// to end hours from now
+	addProcess := func(uri string, start, end time.Duration) []byte {
+		pid := util.RandomBytes(32)
+		p := &models.Process{
+			ProcessId:    pid,
+			EntityId:     util.RandomBytes(20),
+			EnvelopeType: &models.EnvelopeType{},
+			Status:       models.ProcessStatus_READY,
+			Mode:         &models.ProcessMode{AutoStart: true},
+			VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 2},
+			Metadata:     &uri,
+		}
+		vochain.SetProcessSchedule(p, now.Add(start*time.Hour), now.Add(end*time.Hour))
+		qt.Assert(t, app.State.AddProcess(p), qt.IsNil)
+		return pid
+	}
+	budget := addProcess("ipfs://budget", 1, 2)
+	park := addProcess("ipfs://park", 2, 3)
+	board := addProcess("ipfs://board", 3, 4)
+	addProcess("ipfs://missing", 5, 6)
+	addProcess("ipfs://invalid", 5, 6)
+	addProcess("https://example.com/budget", 5, 6)
+	app.AdvanceTestBlock()
+
+	// the failed fetches are retried until metadataMaxAttempts
+	for i := 0; i < metadataMaxAttempts+2; i++ {
+		qt.Assert(t, sc.fetchPendingMetadata(storage), qt.IsNil)
+	}
+	qt.Assert(t, storage.retrieved, qt.DeepEquals, map[string]int{
+		"budget":  1,
+		"park":    1,
+		"board":   1,
+		"missing": metadataMaxAttempts,
+		"invalid": metadataMaxAttempts,
+	})
+
+	search := func(query string, dateFrom, dateTo time.Duration, sortBy string,
+		from, max int) [][]byte {
+		unix := func(d time.Duration) int64 {
+			if d == 0 {
+				return 0
+			}
+			return now.Add(d).Unix()
+		}
+		pids, err := sc.SearchProcesses(query, unix(dateFrom), unix(dateTo), sortBy, from, max)
+		qt.Assert(t, err, qt.IsNil)
+		return pids
+	}
+	// the title matches rank above the questions ones, before the results
+	// are limited
+	qt.Assert(t, search("park", 0, 0, "", 0, 10), qt.DeepEquals, [][]byte{park, budget})
+	qt.Assert(t, search("park", 0, 0, "", 0, 1), qt.DeepEquals, [][]byte{park})
+	qt.Assert(t, search("park", 0, 0, "", 1, 10), qt.DeepEquals, [][]byte{budget})
+	qt.Assert(t, search("budget", 0, 0, "", 0, 1), qt.DeepEquals, [][]byte{budget})
+	qt.Assert(t, search("budget", 0, 0, SortByStartDate, 0, 1), qt.DeepEquals,
+		[][]byte{park})
+	qt.Assert(t, search("park", 0, 0, SortByStartDate, 0, 10), qt.DeepEquals,
+		[][]byte{park, budget})
+	qt.Assert(t, search("PRESSUPOST", 0, 0, "", 0, 10), qt.DeepEquals, [][]byte{budget})
+	if fullTextSearch {
+		qt.Assert(t, search("elect*", 0, 0, "", 0, 10), qt.DeepEquals, [][]byte{board})
+		qt.Assert(t, search("title:budget", 0, 0, "", 0, 10), qt.DeepEquals, [][]byte{budget})
+	} else {
+		qt.Assert(t, search("elect", 0, 0, "", 0, 10), qt.DeepEquals, [][]byte{board})
+		qt.Assert(t, search("100%", 0, 0, "", 0, 10), qt.HasLen, 0)
+	}
+	qt.Assert(t, search("unknown", 0, 0, "", 0, 10), qt.HasLen, 0)
+
+	// the date ranges match the processes open at any time within them
+	qt.Assert(t, search("budget", 150*time.Minute, 0, "", 0, 10), qt.DeepEquals,
+		[][]byte{park})
+	qt.Assert(t, search("", 90*time.Minute, 150*time.Minute, SortByEndDate, 0, 10),
+		qt.DeepEquals, [][]byte{park, budget})
+	qt.Assert(t, search("", 0, 210*time.Minute, "", 0, 10), qt.HasLen, 3)
+	qt.Assert(t, search("", 0, 0, "", 0, 10), qt.HasLen, 6)
+
+	_, err = sc.SearchProcesses("park", 0, 0, "votes", 0, 10)
+	qt.Assert(t, err, qt.ErrorMatches, ".*sort votes is unknown")
+	_, err = sc.SearchProcesses("", 0, 0, SortByRelevance, 0, 10)
+	qt.Assert(t, err, qt.ErrorMatches, ".*needs a query")
+}
+
+func TestEntityMetadataSearch(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+
+	sc, err := NewScrutinizer(t.TempDir(), app, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, app.State.SetAccount(vochain.BurnAddress, &vochain.Account{}), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_SET_ACCOUNT_INFO, 10), qt.IsNil)
+	app.Commit()
+
+	storage := &testStorage{
+		files: map[string]string{
+			"council": `{"name": {"default": "City council", "ca": "Ajuntament"},
+				"description": {"default": "The council of the city"}}`,
+			"club":  `{"name": {"default": "Chess club"}, "description": {"default": "Chess in the city"}}`,
+			"club2": `{"name": {"default": "Go club"}}`,
+		},
+		retrieved: map[string]int{},
+	}
+	council := ethereum.NewSignKeys()
+	qt.Assert(t, council.Generate(), qt.IsNil)
+	club := ethereum.NewSignKeys()
+	qt.Assert(t, club.Generate(), qt.IsNil)
+
+	// setAccountInfo creates the account of signer or sets its info uri
+	height := int64(0)
+	setAccountInfo := func(signer *ethereum.SignKeys, uri string, nonce uint32) {
+		height++
+		header := tmtypes.Header{Height: height, Time: time.Now()}
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: *header.ToProto()})
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_SetAccountInfo{
+			SetAccountInfo: &models.SetAccountInfoTx{
+				Txtype:  models.TxType_SET_ACCOUNT_INFO,
+				Nonce:   nonce,
+				InfoURI: uri,
+				Account: signer.Address().Bytes(),
+			}}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes})
+		qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Data))
+		app.Commit()
+	}
+	setAccountInfo(council, "ipfs://council", 0)
+	setAccountInfo(club, "ipfs://missing", 0)
+	qt.Assert(t, app.State.MintBalance(club.Address(), 100), qt.IsNil)
+	app.Commit()
+	for i := 0; i < metadataMaxAttempts+2; i++ {
+		qt.Assert(t, sc.fetchPendingEntityMetadata(storage), qt.IsNil)
+	}
+	qt.Assert(t, storage.retrieved, qt.DeepEquals, map[string]int{
+		"council": 1,
+		"missing": metadataMaxAttempts,
+	})
+
+	search := func(query string, from, max int) []types.HexBytes {
+		eids, err := sc.SearchEntities(query, from, max)
+		qt.Assert(t, err, qt.IsNil)
+		return eids
+	}
+	councilID := types.HexBytes(council.Address().Bytes())
+	clubID := types.HexBytes(club.Address().Bytes())
+	qt.Assert(t, search("ajuntament", 0, 10), qt.DeepEquals, []types.HexBytes{councilID})
+	qt.Assert(t, search("club", 0, 10), qt.HasLen, 0)
+
+	// the metadata is fetched again when the info uri changes
+	setAccountInfo(club, "ipfs://club", 0)
+	qt.Assert(t, sc.fetchPendingEntityMetadata(storage), qt.IsNil)
+	qt.Assert(t, search("club", 0, 10), qt.DeepEquals, []types.HexBytes{clubID})
+
+	// the name matches rank above the description ones
+	qt.Assert(t, search("city", 0, 10), qt.DeepEquals, []types.HexBytes{councilID, clubID})
+	qt.Assert(t, search("city", 0, 1), qt.DeepEquals, []types.HexBytes{councilID})
+	qt.Assert(t, search("city", 1, 10), qt.DeepEquals, []types.HexBytes{clubID})
+	if fullTextSearch {
+		qt.Assert(t, search("name:chess", 0, 10), qt.DeepEquals, []types.HexBytes{clubID})
+	} else {
+		qt.Assert(t, search("ss c", 0, 10), qt.DeepEquals, []types.HexBytes{clubID})
+	}
+
+	setAccountInfo(club, "ipfs://club2", 1)
+	qt.Assert(t, sc.fetchPendingEntityMetadata(storage), qt.IsNil)
+	qt.Assert(t, search("chess", 0, 10), qt.HasLen, 0)
+	qt.Assert(t, search("go", 0, 10), qt.DeepEquals, []types.HexBytes{clubID})
+
+	_, err = sc.SearchEntities("", 0, 10)
+	qt.Assert(t, err, qt.ErrorMatches, ".*query is empty")
+}
+
+func TestMigrateBadgerhold(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+	dbPath := t.TempDir()
+
+	// store the indexes of a previous version on badgerhold
+	store, err := openBadgerhold(dbPath)
+	qt.Assert(t, err, qt.IsNil)
+	pid, eid, nullifier := util.RandomBytes(32), util.RandomBytes(20), util.RandomBytes(32)
+	now := time.Now().Truncate(time.Second)
+	qt.Assert(t, store.Insert(pid, &indexertypes.Process{
+		ID:           pid,
+		EntityID:     eid,
+		EndBlock:     10,
+		Rheight:      11,
+		Status:       int32(models.ProcessStatus_READY),
+		Envelope:     &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{AutoStart: true},
+		VoteOpts:     &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+		HaveResults:  true,
+		EntityIndex:  1,
+		CreationTime: now,
+	}), qt.IsNil)
+	qt.Assert(t, store.Insert(eid, &indexertypes.Entity{ID: eid, CreationTime: now}), qt.IsNil)
+	qt.Assert(t, store.Insert(pid, &indexertypes.Results{
+		ProcessID:      pid,
+		Votes:          indexertypes.NewEmptyVotes(1, 2),
+		Weight:         new(types.BigInt).SetUint64(3),
+		EnvelopeHeight: 1,
+		EnvelopeType:   &models.EnvelopeType{},
+		VoteOpts:       &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 1},
+		Signatures:     []types.HexBytes{},
+	}), qt.IsNil)
+	qt.Assert(t, store.Insert(nullifier, &indexertypes.VoteReference{
+		Nullifier:    nullifier,
+		ProcessID:    pid,
+		Height:       5,
+		Weight:       new(types.BigInt).SetUint64(3),
+		TxIndex:      1,
+		CreationTime: now,
+		OverwriteHistory: []*indexertypes.OverwrittenVoteReference{
+			{Height: 4, TxIndex: 0, CreationTime: now},
+		},
+	}), qt.IsNil)
+	for i := uint64(1); i <= 3; i++ {
+		qt.Assert(t, store.Insert(i, &indexertypes.TxReference{
+			Index:       i,
+			Hash:        util.RandomBytes(32),
+			BlockHeight: uint32(i),
+		}), qt.IsNil)
+	}
+	qt.Assert(t, store.Close(), qt.IsNil)
+
+	// the scrutinizer imports them into sqlite and removes badgerhold
+	sc, err := NewScrutinizer(dbPath, app, true)
+	qt.Assert(t, err, qt.IsNil)
+	_, err = os.Stat(dbPath)
+	qt.Assert(t, os.IsNotExist(err), qt.IsTrue)
+
+	proc, err := sc.ProcessInfo(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, proc.EntityID, qt.DeepEquals, types.HexBytes(eid))
+	qt.Assert(t, proc.Rheight, qt.Equals, uint32(11))
+	qt.Assert(t, sc.EntityCount(), qt.Equals, uint64(1))
+	count, err := sc.EntityProcessCount(eid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, count, qt.Equals, uint32(1))
+
+	results, err := sc.GetResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, results.Weight.String(), qt.Equals, "3")
+	qt.Assert(t, GetFriendlyResults(results.Votes), qt.DeepEquals, [][]string{{"0", "0"}})
+
+	ref, err := sc.GetEnvelopeReference(nullifier)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ref.Height, qt.Equals, uint32(5))
+	qt.Assert(t, ref.OverwriteHistory, qt.HasLen, 1)
+	qt.Assert(t, ref.OverwriteHistory[0].Height, qt.Equals, uint32(4))
+
+	txCount, err := sc.TransactionCount()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, txCount, qt.Equals, uint64(3))
+	txRef, err := sc.GetTxReference(2)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, txRef.BlockHeight, qt.Equals, uint32(2))
+}
+
+func TestReindex(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+	dbPath := filepath.Join(t.TempDir(), "scrutinizer")
+	sc, err := NewScrutinizer(dbPath, app, true)
+	qt.Assert(t, err, qt.IsNil)
+
+	oracle := ethereum.NewSignKeys()
+	qt.Assert(t, oracle.Generate(), qt.IsNil)
+	genesis, err := json.Marshal(&vochain.GenesisAppState{
+		Oracles:   []string{oracle.AddressString()},
+		Treasurer: oracle.AddressString(),
+	})
+	qt.Assert(t, err, qt.IsNil)
+
+	tr, err := censustree.New(censustree.Options{Name: "testreindex",
+		ParentDB: metadb.NewTest(t), MaxLevels: 256, CensusType: models.Census_ARBO_BLAKE2B})
+	qt.Assert(t, err, qt.IsNil)
+	voter := ethereum.NewSignKeys()
+	qt.Assert(t, voter.Generate(), qt.IsNil)
+	key, err := tr.Hash(voter.PublicKey())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tr.Add(key, nil), qt.IsNil)
+	_, siblings, err := tr.GenProof(key)
+	qt.Assert(t, err, qt.IsNil)
+	root, err := tr.Root()
+	qt.Assert(t, err, qt.IsNil)
+
+	signedTx := func(signer *ethereum.SignKeys, tx *models.Tx) []byte {
+		var stx models.SignedTx
+		stx.Tx, err = proto.Marshal(tx)
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		return stxBytes
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	censusURI := "ipfs://123456789"
+	newProcessTx := signedTx(oracle, &models.Tx{Payload: &models.Tx_NewProcess{
+		NewProcess: &models.NewProcessTx{Txtype: models.TxType_NEW_PROCESS, Process: &models.Process{
+			ProcessId:    pid,
+			EnvelopeType: &models.EnvelopeType{},
+			Mode:         &models.ProcessMode{},
+			VoteOptions:  &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 3},
+			Status:       models.ProcessStatus_READY,
+			EntityId:     util.RandomBytes(types.EthereumAddressSize),
+			CensusRoot:   root,
+			CensusURI:    &censusURI,
+			CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+			BlockCount:   2,
+		}}}})
+	vp, err := json.Marshal(vochain.VotePackage{Votes: []int{1}})
+	qt.Assert(t, err, qt.IsNil)
+	voteTx := signedTx(voter, &models.Tx{Payload: &models.Tx_Vote{Vote: &models.VoteEnvelope{
+		Nonce:     util.RandomBytes(32),
+		ProcessId: pid,
+		Proof: &models.Proof{Payload: &models.Proof_Arbo{Arbo: &models.ProofArbo{
+			Type:     models.ProofArbo_BLAKE2B,
+			Siblings: siblings,
+		}}},
+		VotePackage: vp,
+	}}})
+
+	// the process is created on block 1, voted on block 2 along with an
+	// invalid transaction, ended on block 4 and its results are computed
+	// on block 5.  The app hashes are the ones of a first replay.
+	now := time.Now()
+	blocks := map[int64]*tmtypes.Block{}
+	app.SetFnGetBlockByHeight(func(height int64) *tmtypes.Block { return blocks[height] })
+	replay, err := vochain.NewReplayApplication(app, metadb.ForTest(), t.TempDir(), genesis)
+	qt.Assert(t, err, qt.IsNil)
+	for h, txs := range [][]tmtypes.Tx{
+		{newProcessTx},
+		{voteTx, tmtypes.Tx("invalid")},
+		{}, {}, {},
+	} {
+		height := int64(h + 1)
+		blocks[height] = &tmtypes.Block{
+			Header: tmtypes.Header{Height: height, Time: now.Add(time.Duration(h) * 10 * time.Second)},
+			Data:   tmtypes.Data{Txs: txs},
+		}
+		if height > 1 {
+			blocks[height].AppHash = replay.State.WorkingHash()
+		}
+		qt.Assert(t, replay.ReplayBlock(blocks[height]), qt.IsNil)
+	}
+	qt.Assert(t, replay.State.Close(), qt.IsNil)
+
+	// the replay stops on the first app hash mismatch
+	appHash := blocks[3].AppHash
+	blocks[3].AppHash = util.RandomBytes(32)
+	r, err := NewReindexer(t.TempDir(), app, metadb.ForTest(), genesis)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, r.Replay(context.Background(), 5), qt.ErrorMatches,
+		"cannot replay block 2: app hash mismatch on block 3.*")
+	qt.Assert(t, r.Height(), qt.Equals, uint32(2))
+	qt.Assert(t, r.Close(), qt.IsNil)
+	blocks[3].AppHash = appHash
+
+	// an interrupted reindex continues from the last replayed block
+	dir := t.TempDir()
+	r, err = NewReindexer(dir, app, metadb.ForTest(), genesis)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, r.Replay(context.Background(), 2), qt.IsNil)
+	qt.Assert(t, r.Close(), qt.IsNil)
+	r, err = NewReindexer(dir, app, metadb.ForTest(), genesis)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, r.Height(), qt.Equals, uint32(2))
+	qt.Assert(t, r.Replay(context.Background(), 5), qt.IsNil)
+
+	// the reindexed indexes replace the current ones, keeping the process
+	// metadata, and are moved in place on the next start
+	qt.Assert(t, sc.ProcessCount(nil), qt.Equals, uint64(0))
+	qt.Assert(t, sc.setProcessMetadata(scrutinizerdb.UpdateProcessMetadataParams{
+		ProcessID: pid,
+		Uri:       "ipfs://metadata",
+		Title:     "budget",
+	}), qt.IsNil)
+	qt.Assert(t, sc.ApplyReindex(r), qt.IsNil)
+	_, err = os.Stat(dir)
+	qt.Assert(t, os.IsNotExist(err), qt.IsTrue)
+	pids, err := sc.SearchProcesses("budget", 0, 0, "", 0, 10)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, pids, qt.DeepEquals, [][]byte{pid})
+
+	qt.Assert(t, sc.db().Close(), qt.IsNil)
+	sc, err = NewScrutinizer(dbPath, app, true)
+	qt.Assert(t, err, qt.IsNil)
+	_, err = os.Stat(dbPath + reindexedSQLiteSuffix)
+	qt.Assert(t, os.IsNotExist(err), qt.IsTrue)
+
+	proc, err := sc.ProcessInfo(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, proc.Status, qt.Equals, int32(models.ProcessStatus_ENDED))
+	qt.Assert(t, proc.FinalResults, qt.IsTrue)
+	results, err := sc.GetResults(pid)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, GetFriendlyResults(results.Votes), qt.DeepEquals, [][]string{{"0", "1", "0", "0"}})
+
+	ref, err := sc.GetEnvelopeReference(vochain.GenerateNullifier(voter.Address(), pid))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ref.Height, qt.Equals, uint32(2))
+	txCount, err := sc.TransactionCount()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, txCount, qt.Equals, uint64(2))
+	txRef, err := sc.GetTxHashReference(tmtypes.Tx(voteTx).Hash())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, txRef.BlockHeight, qt.Equals, uint32(2))
+}
@@ -0,0 +1,163 @@
+package scrutinizer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
+	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// Token statistics
+//
+// On each block, the scrutinizer stores the balance of the accounts whose
+// tokens moved, as found in the committed state, so the balance history of an
+// account can be audited.  It also adds up the number of transactions, the
+// transferred amount and the fees paid for each transaction type.  The minted
+// supply is the amount transferred by the MINT_TOKENS transactions, and the
+// burned supply is the sum of all the fees, since they are transferred to
+// vochain.BurnAddress.  The transactions indexed before their accounts were
+// are not included; they can be added with a reindex.
+
+// GetAccountBalances returns the balance history of account, newest first,
+// skipping the first from balances and returning at most max.  There is a
+// balance for each block that moved the account tokens.
+func (s *Scrutinizer) GetAccountBalances(account types.HexBytes, from, max int) (
+	[]*indexertypes.AccountBalance, error) {
+	if from < 0 {
+		return nil, fmt.Errorf("accountBalances: invalid value: from is invalid value %d", from)
+	}
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbbalances, err := queries.GetAccountBalances(ctx, scrutinizerdb.GetAccountBalancesParams{
+		Account: account,
+		Offset:  int32(from),
+		Limit:   int32(max),
+	})
+	if err != nil {
+		return nil, err
+	}
+	balances := []*indexertypes.AccountBalance{}
+	for _, b := range dbbalances {
+		balances = append(balances, &indexertypes.AccountBalance{
+			Height:  uint32(b.Height),
+			Balance: uint64(b.Balance),
+		})
+	}
+	return balances, nil
+}
+
+// GetTokenStats returns the minted and burned token supply, along with the
+// statistics of each transaction type.
+func (s *Scrutinizer) GetTokenStats() (*indexertypes.TokenStats, error) {
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbstats, err := queries.GetTxTypeStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := &indexertypes.TokenStats{TxTypes: []*indexertypes.TxTypeStats{}}
+	for _, st := range dbstats {
+		if st.TxType == models.TxType_MINT_TOKENS.String() {
+			stats.Minted = uint64(st.Amount)
+		}
+		stats.Burned += uint64(st.Fees)
+		stats.TxTypes = append(stats.TxTypes, &indexertypes.TxTypeStats{
+			Type:   st.TxType,
+			Count:  uint64(st.TxCount),
+			Amount: uint64(st.Amount),
+			Fees:   uint64(st.Fees),
+		})
+	}
+	if stats.Minted > stats.Burned {
+		stats.Supply = stats.Minted - stats.Burned
+	}
+	return stats, nil
+}
+
+// indexTokenStats adds the txs of the block at height to the transaction type
+// statistics, and stores the balances of the accounts whose tokens they moved.
+func (s *Scrutinizer) indexTokenStats(ctx context.Context, queries *scrutinizerdb.Queries,
+	txs []*indexertypes.TxReference, height uint32) error {
+	stats := map[string]*scrutinizerdb.UpdateTxTypeStatsParams{}
+	txTypes := []string{}
+	accounts := map[common.Address]bool{}
+	changed := []common.Address{}
+	addChanged := func(account []byte) {
+		if len(account) == 0 {
+			return
+		}
+		addr := common.BytesToAddress(account)
+		if !accounts[addr] {
+			accounts[addr] = true
+			changed = append(changed, addr)
+		}
+	}
+	for _, tx := range txs {
+		if tx.Type == "" {
+			continue
+		}
+		st, ok := stats[tx.Type]
+		if !ok {
+			st = &scrutinizerdb.UpdateTxTypeStatsParams{TxType: tx.Type}
+			stats[tx.Type] = st
+			txTypes = append(txTypes, tx.Type)
+		}
+		st.TxCount++
+		st.Amount += int64(tx.Amount * uint64(len(tx.Recipients)))
+		st.Fees += int64(tx.Cost)
+		if tx.Amount > 0 {
+			addChanged(tx.Sender)
+			for _, recipient := range tx.Recipients {
+				addChanged(recipient)
+			}
+		}
+		if tx.Cost > 0 {
+			addChanged(tx.Sender)
+			addChanged(vochain.BurnAddress.Bytes())
+		}
+	}
+
+	for _, txType := range txTypes {
+		st := stats[txType]
+		res, err := queries.UpdateTxTypeStats(ctx, *st)
+		if err != nil {
+			return fmt.Errorf("cannot update %s tx stats: %w", txType, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n > 0 {
+			continue
+		}
+		if _, err := queries.CreateTxTypeStats(ctx, scrutinizerdb.CreateTxTypeStatsParams{
+			TxType:  st.TxType,
+			TxCount: st.TxCount,
+			Amount:  st.Amount,
+			Fees:    st.Fees,
+		}); err != nil {
+			return fmt.Errorf("cannot create %s tx stats: %w", txType, err)
+		}
+	}
+
+	for _, addr := range changed {
+		acc, err := s.App.State.GetAccount(addr, true)
+		if err != nil {
+			return fmt.Errorf("cannot get account %s: %w", addr.Hex(), err)
+		}
+		if acc == nil {
+			continue
+		}
+		if _, err := queries.SetAccountBalance(ctx, scrutinizerdb.SetAccountBalanceParams{
+			Account: addr.Bytes(),
+			Height:  int64(height),
+			Balance: int64(acc.Balance),
+		}); err != nil {
+			return fmt.Errorf("cannot store account %s balance: %w", addr.Hex(), err)
+		}
+	}
+	return nil
+}
@@ -1,76 +1,175 @@
 package scrutinizer
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/timshannon/badgerhold/v3"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
 	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 )
 
 // TransactionCount returns the number of transactions indexed
 func (s *Scrutinizer) TransactionCount() (uint64, error) {
-	txCountStore := &indexertypes.CountStore{}
-	if err := s.db.Get(indexertypes.CountStoreTransactions, txCountStore); err != nil {
-		return txCountStore.Count, err
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	count, err := queries.CountTransactions(ctx)
+	if err != nil {
+		return 0, err
 	}
-	return txCountStore.Count, nil
+	return uint64(count), nil
 }
 
 // GetTxReference fetches the txReference for the given tx height
 func (s *Scrutinizer) GetTxReference(height uint64) (*indexertypes.TxReference, error) {
-	txReference := &indexertypes.TxReference{}
-	err := s.db.FindOne(txReference, badgerhold.Where(badgerhold.Key).Eq(height))
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbtx, err := queries.GetTransaction(ctx, int64(height))
 	if err != nil {
-		return nil, fmt.Errorf("tx height %d not found: %v", height, err)
+		return nil, fmt.Errorf("tx height %d not found: %w", height, err)
 	}
-	return txReference, nil
+	return txReferenceFromDB(ctx, queries, &dbtx)
 }
 
 // GetTxReference fetches the txReference for the given tx hash
 func (s *Scrutinizer) GetTxHashReference(hash types.HexBytes) (*indexertypes.TxReference, error) {
-	txReference := &indexertypes.TxReference{}
-	err := s.db.FindOne(txReference, badgerhold.Where("Hash").Eq(hash).Index("Hash"))
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbtx, err := queries.GetTransactionByHash(ctx, hash)
 	if err != nil {
-		return nil, fmt.Errorf("tx hash %x not found: %v", hash, err)
+		return nil, fmt.Errorf("tx hash %x not found: %w", hash, err)
 	}
-	return txReference, nil
+	return txReferenceFromDB(ctx, queries, &dbtx)
 }
 
-// OnNewTx stores the transaction reference in the indexer database
-func (s *Scrutinizer) OnNewTx(hash []byte, blockHeight uint32, txIndex int32) {
-	s.newTxPool = append(s.newTxPool, &indexertypes.TxReference{
-		Hash:         types.HexBytes(hash),
-		BlockHeight:  blockHeight,
-		TxBlockIndex: txIndex,
+// GetAccountTransactions returns the transactions sent or received by account,
+// newest first, skipping the first from transactions and returning at most
+// max.
+func (s *Scrutinizer) GetAccountTransactions(account types.HexBytes, from, max int) (
+	[]*indexertypes.TxReference, error) {
+	if from < 0 {
+		return nil, fmt.Errorf("accountTxList: invalid value: from is invalid value %d", from)
+	}
+	if len(account) == 0 {
+		return nil, fmt.Errorf("accountTxList: missing account")
+	}
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbtxs, err := queries.SearchAccountTransactions(ctx, scrutinizerdb.SearchAccountTransactionsParams{
+		Account: account,
+		Offset:  int32(from),
+		Limit:   int32(max),
+	})
+	if err != nil {
+		return nil, err
+	}
+	txs := []*indexertypes.TxReference{}
+	for i := range dbtxs {
+		tx, err := txReferenceFromDB(ctx, queries, &dbtxs[i])
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// GetTokenTransfers returns the token transfers from or to account, or from
+// any account if account is empty, newest first, skipping the first from
+// transfers and returning at most max.  A transaction transferring tokens to
+// several recipients results in one transfer for each of them.
+func (s *Scrutinizer) GetTokenTransfers(account types.HexBytes, from, max int) (
+	[]*indexertypes.TokenTransfer, error) {
+	if from < 0 {
+		return nil, fmt.Errorf("tokenTransfers: invalid value: from is invalid value %d", from)
+	}
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbtransfers, err := queries.SearchTokenTransfers(ctx, scrutinizerdb.SearchTokenTransfersParams{
+		Account: nonNullBytes(account),
+		Offset:  int32(from),
+		Limit:   int32(max),
 	})
+	if err != nil {
+		return nil, err
+	}
+	transfers := []*indexertypes.TokenTransfer{}
+	for i := range dbtransfers {
+		transfers = append(transfers, indexertypes.TokenTransferFromDB(&dbtransfers[i]))
+	}
+	return transfers, nil
 }
 
-// indexNewTxs indexes the txs pending in the newTxPool and updates the transaction count
-// this function should only be called within Commit(), on a new block.
-func (s *Scrutinizer) indexNewTxs(txList []*indexertypes.TxReference) {
-	if len(txList) == 0 {
-		return
+// txReferenceFromDB returns the TxReference of a database transaction,
+// including its recipients.
+func txReferenceFromDB(ctx context.Context, queries *scrutinizerdb.Queries,
+	dbtx *scrutinizerdb.Transaction) (*indexertypes.TxReference, error) {
+	tx := indexertypes.TxReferenceFromDB(dbtx)
+	recipients, err := queries.GetTransactionRecipients(ctx, dbtx.ID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get tx %x recipients: %w", dbtx.Hash, err)
 	}
-	s.txIndexLock.Lock()
-	defer s.txIndexLock.Unlock()
-	txCount := &indexertypes.CountStore{}
-	if err := s.db.Get(indexertypes.CountStoreTransactions, txCount); err != nil {
-		log.Errorf("could not get tx count: %v", err)
-		return
+	tx.Recipients = recipients
+	return tx, nil
+}
+
+// OnNewTx stores the transaction reference in the indexer database, along
+// with the accounts involved in the transaction
+func (s *Scrutinizer) OnNewTx(tx *vochain.VochainTx, blockHeight uint32, txIndex int32) {
+	txRef := &indexertypes.TxReference{
+		Hash:         types.HexBytes(tx.TxID[:]),
+		BlockHeight:  blockHeight,
+		TxBlockIndex: txIndex,
 	}
-	for i, tx := range txList {
-		// Add confirmed txs to transaction count
-		tx.Index = txCount.Count + uint64(i) + 1 // Start indexing at 1
-		if err := s.db.Insert(tx.Index, tx); err != nil {
-			log.Errorf("cannot store tx at height %d: %v", tx.Index, err)
-			return
+	accounts, err := s.App.TxAccounts(tx)
+	if err != nil {
+		log.Warnf("cannot get the accounts of tx %x: %v", tx.TxID, err)
+	} else {
+		txRef.Type = accounts.Type.String()
+		if accounts.Sender != types.EthereumZeroAddress {
+			txRef.Sender = accounts.Sender.Bytes()
+		}
+		for _, recipient := range accounts.Recipients {
+			txRef.Recipients = append(txRef.Recipients, recipient.Bytes())
 		}
+		txRef.Amount, txRef.Cost = accounts.Amount, accounts.Cost
 	}
-	// Store new transaction count
-	txCount.Count += uint64(len(txList))
-	if err := s.db.Upsert(indexertypes.CountStoreTransactions, txCount); err != nil {
-		log.Errorf("could not update tx count: %v", err)
+	s.newTxPool = append(s.newTxPool, txRef)
+}
+
+// indexTxs indexes the txs of txList, which are numbered sequentially
+// starting at 1, and sets their Index.
+func indexTxs(ctx context.Context, queries *scrutinizerdb.Queries,
+	txList []*indexertypes.TxReference) error {
+	for _, tx := range txList {
+		res, err := queries.CreateTransaction(ctx, scrutinizerdb.CreateTransactionParams{
+			Hash:        tx.Hash,
+			BlockHeight: int64(tx.BlockHeight),
+			BlockIndex:  int64(tx.TxBlockIndex),
+			TxType:      tx.Type,
+			Sender:      nonNullBytes(tx.Sender),
+			Amount:      int64(tx.Amount),
+			Cost:        int64(tx.Cost),
+		})
+		if err != nil {
+			return fmt.Errorf("cannot store tx %x: %w", tx.Hash, err)
+		}
+		index, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		tx.Index = uint64(index)
+		for _, recipient := range tx.Recipients {
+			if _, err := queries.CreateTransactionRecipient(ctx,
+				scrutinizerdb.CreateTransactionRecipientParams{
+					TxID:    index,
+					Account: recipient,
+				}); err != nil {
+				return fmt.Errorf("cannot store tx %x recipient: %w", tx.Hash, err)
+			}
+		}
 	}
+	return nil
 }
@@ -1,6 +1,9 @@
 package scrutinizer
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,16 +12,16 @@ import (
 	"sync/atomic"
 	"time"
 
-	badger "github.com/dgraph-io/badger/v3"
-	"github.com/timshannon/badgerhold/v3"
 	"go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
 
 	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/threshold"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	"go.vocdoni.io/dvote/vochain"
+	scrutinizerdb "go.vocdoni.io/dvote/vochain/scrutinizer/db"
 	"go.vocdoni.io/dvote/vochain/scrutinizer/indexertypes"
 )
 
@@ -27,15 +30,24 @@ import (
 var ErrNoResultsYet = fmt.Errorf("no results yet")
 
 // ErrNotFoundIndatabase is raised if a database query returns no results
-var ErrNotFoundInDatabase = badgerhold.ErrNotFound
+var ErrNotFoundInDatabase = sql.ErrNoRows
 
 // Getindexertypes.VoteReference gets the reference for an AddVote transaction.
 // This reference can then be used to fetch the vote transaction directly from the BlockStore.
 func (s *Scrutinizer) GetEnvelopeReference(nullifier []byte) (*indexertypes.VoteReference, error) {
 	startTime := time.Now()
 	defer func() { log.Debugf("GetEnvelopeReference took %s", time.Since(startTime)) }()
-	txRef := &indexertypes.VoteReference{}
-	return txRef, s.db.FindOne(txRef, badgerhold.Where(badgerhold.Key).Eq(nullifier))
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbvote, err := queries.GetVote(ctx, nullifier)
+	if err != nil {
+		return nil, err
+	}
+	overwrites, err := queries.GetVoteOverwrites(ctx, nullifier)
+	if err != nil {
+		return nil, err
+	}
+	return indexertypes.VoteReferenceFromDB(&dbvote, overwrites)
 }
 
 // GetEnvelope retreives an Envelope from the Blockchain block store identified by its nullifier.
@@ -60,6 +72,21 @@ func (s *Scrutinizer) GetEnvelope(nullifier []byte) (*indexertypes.EnvelopePacka
 	if envelope == nil {
 		return nil, fmt.Errorf("transaction is not an Envelope")
 	}
+	// the previous envelopes of an overwritten vote
+	var history []*indexertypes.EnvelopeMetadata
+	for _, ref := range voteRef.OverwriteHistory {
+		_, prevHash, err := s.App.GetTxHash(ref.Height, ref.TxIndex)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, &indexertypes.EnvelopeMetadata{
+			ProcessId: envelope.ProcessId,
+			Nullifier: nullifier,
+			TxIndex:   ref.TxIndex,
+			Height:    ref.Height,
+			TxHash:    prevHash,
+		})
+	}
 	log.Debugf("getEnvelope took %s", time.Since(t))
 	return &indexertypes.EnvelopePackage{
 		Nonce:                envelope.Nonce,
@@ -68,20 +95,23 @@ func (s *Scrutinizer) GetEnvelope(nullifier []byte) (*indexertypes.EnvelopePacka
 		Weight:               voteRef.Weight.String(),
 		Signature:            stx.Signature,
 		Meta: indexertypes.EnvelopeMetadata{
-			ProcessId: envelope.ProcessId,
-			Nullifier: nullifier,
-			TxIndex:   voteRef.TxIndex,
-			Height:    voteRef.Height,
-			TxHash:    txHash,
+			ProcessId:      envelope.ProcessId,
+			Nullifier:      nullifier,
+			TxIndex:        voteRef.TxIndex,
+			Height:         voteRef.Height,
+			TxHash:         txHash,
+			OverwriteCount: uint32(len(history)),
 		},
+		OverwriteHistory: history,
 	}, nil
 }
 
-// WalkEnvelopes executes callback for each envelopes of the ProcessId.
+// WalkEnvelopes executes callback for each envelopes of the ProcessId, along
+// with its vote reference.
 // The callback function is executed async (in a goroutine) if async=true.
 // The method will return once all goroutines have finished the work.
 func (s *Scrutinizer) WalkEnvelopes(processId []byte, async bool,
-	callback func(*models.VoteEnvelope, *big.Int)) error {
+	callback func(*models.VoteEnvelope, *indexertypes.VoteReference)) error {
 	wg := sync.WaitGroup{}
 
 	// There might be tens of thousands of votes.
@@ -92,42 +122,52 @@ func (s *Scrutinizer) WalkEnvelopes(processId []byte, async bool,
 	const limitConcurrentProcessing = 20
 	semaphore := make(chan bool, limitConcurrentProcessing)
 
-	err := s.db.ForEach(
-		badgerhold.Where("ProcessID").Eq(processId).Index("ProcessID"),
-		func(txRef *indexertypes.VoteReference) error {
-			wg.Add(1)
-			processVote := func() {
-				defer wg.Done()
-				stx, err := s.App.GetTx(txRef.Height, txRef.TxIndex)
-				if err != nil {
-					log.Errorf("could not get tx: %v", err)
-					return
-				}
-				tx := &models.Tx{}
-				if err := proto.Unmarshal(stx.Tx, tx); err != nil {
-					log.Warnf("could not unmarshal tx: %v", err)
-					return
-				}
-				envelope := tx.GetVote()
-				if envelope == nil {
-					log.Errorf("transaction is not an Envelope")
-					return
-				}
-				callback(envelope, txRef.Weight.ToInt())
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbvotes, err := queries.GetProcessVotes(ctx, processId)
+	if err != nil {
+		return err
+	}
+	txRefs := make([]*indexertypes.VoteReference, len(dbvotes))
+	for i := range dbvotes {
+		if txRefs[i], err = indexertypes.VoteReferenceFromDB(&dbvotes[i], nil); err != nil {
+			return err
+		}
+	}
+	for _, txRef := range txRefs {
+		txRef := txRef
+		wg.Add(1)
+		processVote := func() {
+			defer wg.Done()
+			stx, err := s.App.GetTx(txRef.Height, txRef.TxIndex)
+			if err != nil {
+				log.Errorf("could not get tx: %v", err)
+				return
 			}
-			if async {
-				go func() {
-					semaphore <- true
-					processVote()
-					<-semaphore
-				}()
-			} else {
-				processVote()
+			tx := &models.Tx{}
+			if err := proto.Unmarshal(stx.Tx, tx); err != nil {
+				log.Warnf("could not unmarshal tx: %v", err)
+				return
 			}
-			return nil
-		})
+			envelope := tx.GetVote()
+			if envelope == nil {
+				log.Errorf("transaction is not an Envelope")
+				return
+			}
+			callback(envelope, txRef)
+		}
+		if async {
+			go func() {
+				semaphore <- true
+				processVote()
+				<-semaphore
+			}()
+		} else {
+			processVote()
+		}
+	}
 	wg.Wait()
-	return err
+	return nil
 }
 
 // GetEnvelopes retreives all Envelopes of a ProcessId from the Blockchain block store
@@ -136,73 +176,48 @@ func (s *Scrutinizer) GetEnvelopes(processId []byte, max, from int,
 	if from < 0 {
 		return nil, fmt.Errorf("envelopeList: invalid value: from is invalid value %d", from)
 	}
-	envelopes := []*indexertypes.EnvelopeMetadata{}
-	var err error
 	// check pid
+	var pid types.ProcessID
 	if len(processId) == types.ProcessIDsize {
-		err = s.db.ForEach(
-			badgerhold.Where("ProcessID").Eq(processId).Index("ProcessID").
-				And("Nullifier").MatchFunc(searchMatchFunc(searchTerm)).
-				SortBy("Height").
-				Skip(from).
-				Limit(max),
-			func(txRef *indexertypes.VoteReference) error {
-				stx, txHash, err := s.App.GetTxHash(txRef.Height, txRef.TxIndex)
-				if err != nil {
-					return err
-				}
-				tx := &models.Tx{}
-				if err := proto.Unmarshal(stx.Tx, tx); err != nil {
-					return err
-				}
-				envelope := tx.GetVote()
-				if envelope == nil {
-					return fmt.Errorf("transaction is not an Envelope")
-				}
-				envelope.Nullifier = txRef.Nullifier
-				envelopes = append(envelopes, &indexertypes.EnvelopeMetadata{
-					ProcessId: processId,
-					Nullifier: txRef.Nullifier,
-					TxIndex:   txRef.TxIndex,
-					Height:    txRef.Height,
-					TxHash:    txHash,
-				})
-				return nil
-			})
-	} else if len(searchTerm) > 0 { // Search nullifiers without process id
-		err = s.db.ForEach(
-			badgerhold.
-				Where("Nullifier").MatchFunc(searchMatchFunc(searchTerm)).
-				SortBy("Height").
-				Skip(from).
-				Limit(max),
-			func(txRef *indexertypes.VoteReference) error {
-				stx, txHash, err := s.App.GetTxHash(txRef.Height, txRef.TxIndex)
-				if err != nil {
-					return err
-				}
-				tx := &models.Tx{}
-				if err := proto.Unmarshal(stx.Tx, tx); err != nil {
-					return err
-				}
-				envelope := tx.GetVote()
-				if envelope == nil {
-					return fmt.Errorf("transaction is not an Envelope")
-				}
-				envelope.Nullifier = txRef.Nullifier
-				envelopes = append(envelopes, &indexertypes.EnvelopeMetadata{
-					ProcessId: processId,
-					Nullifier: txRef.Nullifier,
-					TxIndex:   txRef.TxIndex,
-					Height:    txRef.Height,
-					TxHash:    txHash,
-				})
-				return nil
-			})
-	} else {
+		pid = processId
+	} else if len(searchTerm) == 0 { // Search nullifiers without process id
 		return nil, fmt.Errorf("cannot get envelope status: (malformed processId)")
 	}
-	return envelopes, err
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbvotes, err := queries.SearchVotes(ctx, scrutinizerdb.SearchVotesParams{
+		ProcessID:       nonNullBytes(pid),
+		NullifierSubstr: searchTerm,
+		Offset:          int32(from),
+		Limit:           int32(max),
+	})
+	if err != nil {
+		return nil, err
+	}
+	envelopes := []*indexertypes.EnvelopeMetadata{}
+	for _, txRef := range dbvotes {
+		stx, txHash, err := s.App.GetTxHash(uint32(txRef.Height), int32(txRef.TxIndex))
+		if err != nil {
+			return nil, err
+		}
+		tx := &models.Tx{}
+		if err := proto.Unmarshal(stx.Tx, tx); err != nil {
+			return nil, err
+		}
+		envelope := tx.GetVote()
+		if envelope == nil {
+			return nil, fmt.Errorf("transaction is not an Envelope")
+		}
+		envelopes = append(envelopes, &indexertypes.EnvelopeMetadata{
+			ProcessId:      types.HexBytes(txRef.ProcessID),
+			Nullifier:      txRef.Nullifier,
+			TxIndex:        int32(txRef.TxIndex),
+			Height:         uint32(txRef.Height),
+			TxHash:         txHash,
+			OverwriteCount: uint32(txRef.OverwriteCount),
+		})
+	}
+	return envelopes, nil
 }
 
 // GetEnvelopeHeight returns the number of envelopes for a processId.
@@ -210,13 +225,15 @@ func (s *Scrutinizer) GetEnvelopes(processId []byte, max, from int,
 func (s *Scrutinizer) GetEnvelopeHeight(processID []byte) (uint64, error) {
 	startTime := time.Now()
 	defer func() { log.Debugf("GetEnvelopeHeight took %s", time.Since(startTime)) }()
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
 	if len(processID) == 0 {
 		// If no processID is provided, count all envelopes
-		envelopeCountStore := &indexertypes.CountStore{}
-		if err := s.db.Get(indexertypes.CountStoreEnvelopes, envelopeCountStore); err != nil {
+		count, err := queries.CountVotes(ctx)
+		if err != nil {
 			return 0, err
 		}
-		return envelopeCountStore.Count, nil
+		return uint64(count), nil
 	}
 	// Check if the envelope height is cached
 	val := s.envelopeHeightCache.Get(string(processID))
@@ -224,15 +241,15 @@ func (s *Scrutinizer) GetEnvelopeHeight(processID []byte) (uint64, error) {
 		return val.(uint64), nil
 	}
 	// If not cached, make the expensive query
-	results := &indexertypes.Results{}
-	if err := s.db.FindOne(results, badgerhold.Where(badgerhold.Key).Eq(processID)); err != nil {
+	results, err := queries.GetResults(ctx, processID)
+	if err != nil {
 		return 0, err
 	}
 	// If final, store them in cache (won't change anymore)
 	if results.Final {
-		s.envelopeHeightCache.Add(string(processID), results.EnvelopeHeight)
+		s.envelopeHeightCache.Add(string(processID), uint64(results.EnvelopeHeight))
 	}
-	return results.EnvelopeHeight, nil
+	return uint64(results.EnvelopeHeight), nil
 }
 
 // ComputeResult process a finished voting, compute the results and saves it in the Storage.
@@ -258,35 +275,18 @@ func (s *Scrutinizer) ComputeResult(processID []byte) error {
 		return err
 	}
 
-	// We need to use UpdateMatching here because of a race condition between ComputeResult and
-	// updateProcess. If we fetch the process, compute the results, then update the process,
-	// ComputeResult can override the process status set by updateProcess. UpdateMatching
-	// gets rid of the time between fetching and updating the process, eliminating this race.
-	if err := s.queryWithRetries(func() error {
-		return s.db.UpdateMatching(&indexertypes.Process{},
-			badgerhold.Where(badgerhold.Key).Eq(processID),
-			func(record interface{}) error {
-				update, ok := record.(*indexertypes.Process)
-				if !ok {
-					return fmt.Errorf("record isn't the correct type! Wanted Result, got %T", record)
-				}
-				update.HaveResults = true
-				update.FinalResults = true
-				return nil
-			},
-		)
-	}); err != nil {
-		return fmt.Errorf("computeResult: cannot update processID %x: %v", processID, err)
-	}
-	queries, ctx, cancel := s.timeoutQueries()
-	defer cancel()
-	if _, err := queries.SetProcessResultsReady(ctx, processID); err != nil {
+	dbresults, err := indexertypes.ResultsToDB(results)
+	if err != nil {
 		return err
 	}
-	s.addVoteLock.Lock()
-	defer s.addVoteLock.Unlock()
-	if err := s.queryWithRetries(func() error { return s.db.Upsert(processID, results) }); err != nil {
+	if err := s.withTx(func(ctx context.Context, queries *scrutinizerdb.Queries) error {
+		if _, err := queries.SetProcessResultsReady(ctx, processID); err != nil {
+			return err
+		}
+		_, err := queries.SetResults(ctx, dbresults)
 		return err
+	}); err != nil {
+		return fmt.Errorf("computeResult: cannot update processID %x: %w", processID, err)
 	}
 
 	// Execute callbacks
@@ -300,43 +300,33 @@ func (s *Scrutinizer) ComputeResult(processID []byte) error {
 func (s *Scrutinizer) GetResults(processID []byte) (*indexertypes.Results, error) {
 	startTime := time.Now()
 	defer func() { log.Debugf("GetResults took %s", time.Since(startTime)) }()
-	// Check if results are in cache and return them.
-	// Note that we don't use an atomic cache,
-	// since that would mean always inserting into the cache.
-	// TODO: remove this cache once we replace badgerhold
-	val := s.resultsCache.Get(string(processID))
-	if val != nil {
-		return val.(*indexertypes.Results), nil
-	}
-	// If not in cache, execute the expensive query
-	s.addVoteLock.RLock()
-	defer s.addVoteLock.RUnlock()
-	results := &indexertypes.Results{}
-	if err := s.db.FindOne(results, badgerhold.Where(badgerhold.Key).
-		Eq(processID)); err != nil {
-		if errors.Is(err, badgerhold.ErrNotFound) {
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	dbresults, err := queries.GetResults(ctx, processID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoResultsYet
 		}
 		return nil, err
 	}
-	// If results are final, store in cache for next queries
-	if results.Final {
-		s.resultsCache.Add(string(processID), results)
-	}
-	return results, nil
+	return indexertypes.ResultsFromDB(&dbresults)
 }
 
 // GetResultsWeight returns the current weight of cast votes for a processId.
 func (s *Scrutinizer) GetResultsWeight(processID []byte) (*big.Int, error) {
 	startTime := time.Now()
 	defer func() { log.Debugf("GetResultsWeight took %s", time.Since(startTime)) }()
-	s.addVoteLock.RLock()
-	defer s.addVoteLock.RUnlock()
-	results := &indexertypes.Results{}
-	if err := s.db.FindOne(results, badgerhold.Where(badgerhold.Key).Eq(processID)); err != nil {
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	results, err := queries.GetResults(ctx, processID)
+	if err != nil {
 		return nil, err
 	}
-	return results.Weight.ToInt(), nil
+	weight, ok := new(big.Int).SetString(results.Weight, 10)
+	if !ok {
+		return nil, fmt.Errorf("cannot decode results weight %q", results.Weight)
+	}
+	return weight, nil
 }
 
 // unmarshalVote decodes the base64 payload to a VotePackage struct type.
@@ -366,11 +356,57 @@ func unmarshalVote(VotePackage []byte, keys []string) (*vochain.VotePackage, err
 	return &vote, nil
 }
 
+// unmarshalThresholdVote decrypts a vote package encrypted with the threshold
+// public key, using the private key combined from the revealed shares.
+func unmarshalThresholdVote(votePackage []byte, privKey *big.Int) (*vochain.VotePackage, error) {
+	rawVote, err := threshold.Decrypt(votePackage, privKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt vote with threshold key: %w", err)
+	}
+	return unmarshalVote(rawVote, []string{})
+}
+
+// thresholdPrivateKey returns the private key of a process with threshold
+// keys, combined from the shares revealed by the keykeepers, or nil if the
+// process keys are not threshold keys.
+func (s *Scrutinizer) thresholdPrivateKey(p *indexertypes.Process) (*big.Int, error) {
+	required, count, err := s.App.State.KeyKeepersThreshold(true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get keykeepers threshold: %w", err)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	shares, err := vochain.ThresholdShares(p.PrivateKeys, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) < int(required) {
+		return nil, fmt.Errorf("not enough shares revealed (%d of %d)", len(shares), required)
+	}
+	return threshold.Combine(shares)
+}
+
 // addLiveVote adds the envelope vote to the results. It does not commit to the database.
 // This method is triggered by OnVote callback for each vote added to the blockchain.
 // If encrypted vote, only weight will be updated.
+// For serial processes, questionIndex is the question the vote is cast for.
 func (s *Scrutinizer) addLiveVote(pid []byte, VotePackage []byte, weight *big.Int,
-	results *indexertypes.Results) error {
+	questionIndex uint32, results *indexertypes.Results) error {
+	return s.countLiveVote(pid, VotePackage, weight, questionIndex, false, results)
+}
+
+// removeLiveVote subtracts the envelope vote of an overwritten vote from the
+// results.  It does not commit to the database.
+func (s *Scrutinizer) removeLiveVote(pid []byte, VotePackage []byte, weight *big.Int,
+	questionIndex uint32, results *indexertypes.Results) error {
+	return s.countLiveVote(pid, VotePackage, weight, questionIndex, true, results)
+}
+
+// countLiveVote adds the envelope vote to the results, or subtracts it if
+// remove is true.
+func (s *Scrutinizer) countLiveVote(pid []byte, VotePackage []byte, weight *big.Int,
+	questionIndex uint32, remove bool, results *indexertypes.Results) error {
 	// If live process, add vote to temporary results
 	var vote *vochain.VotePackage
 	if open, err := s.isOpenProcess(pid); open && err == nil {
@@ -385,42 +421,111 @@ func (s *Scrutinizer) addLiveVote(pid []byte, VotePackage []byte, weight *big.In
 
 	// Add the vote only if the election is unencrypted
 	if vote != nil {
-		if err := results.AddVote(vote.Votes, weight, nil); err != nil {
-			return err
+		switch {
+		case results.EnvelopeType.GetSerial() && remove:
+			return results.RemoveSerialVote(questionIndex, vote.Votes, weight, nil)
+		case results.EnvelopeType.GetSerial():
+			return results.AddSerialVote(questionIndex, vote.Votes, weight, nil)
+		case remove:
+			return results.RemoveVote(vote.Votes, weight, nil)
+		default:
+			return results.AddVote(vote.Votes, weight, nil)
 		}
+	}
+	// If encrypted, just add the weight
+	if remove {
+		results.Weight.Sub(results.Weight, (*types.BigInt)(weight))
+		results.EnvelopeHeight--
 	} else {
-		// If encrypted, just add the weight
 		results.Weight.Add(results.Weight, (*types.BigInt)(weight))
 		results.EnvelopeHeight++
 	}
 	return nil
 }
 
-// addVoteIndex adds the nullifier reference to the kv for fetching vote Txs from BlockStore.
+// previousVote returns the vote overwritten by a new vote with the same
+// nullifier, either from the current block or from the indexed envelopes.
+func (s *Scrutinizer) previousVote(pid, nullifier []byte) (*VoteWithIndex, error) {
+	for i := len(s.voteIndexPool) - 1; i >= 0; i-- {
+		v := s.voteIndexPool[i]
+		if bytes.Equal(v.vote.ProcessId, pid) && bytes.Equal(v.vote.Nullifier, nullifier) {
+			return v, nil
+		}
+	}
+	ref, err := s.GetEnvelopeReference(nullifier)
+	if err != nil {
+		return nil, err
+	}
+	stx, err := s.App.GetTx(ref.Height, ref.TxIndex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &models.Tx{}
+	if err := proto.Unmarshal(stx.Tx, tx); err != nil {
+		return nil, err
+	}
+	envelope := tx.GetVote()
+	if envelope == nil {
+		return nil, fmt.Errorf("transaction is not an Envelope")
+	}
+	return &VoteWithIndex{
+		vote: &models.Vote{
+			ProcessId:   pid,
+			Nullifier:   nullifier,
+			VotePackage: envelope.VotePackage,
+			Weight:      ref.Weight.ToInt().Bytes(),
+		},
+		txIndex:       ref.TxIndex,
+		questionIndex: ref.QuestionIndex,
+	}, nil
+}
+
+// addVoteIndex adds the nullifier reference to the database for fetching vote Txs from BlockStore.
 // This method is triggered by Commit callback for each vote added to the blockchain.
-// If txn is provided the vote will be added on the transaction (without performing a commit).
-func (s *Scrutinizer) addVoteIndex(nullifier, pid []byte, blockHeight uint32,
-	weight []byte, txIndex int32, txn *badger.Txn) error {
-	if txn != nil {
-		return s.db.TxInsert(txn, nullifier, &indexertypes.VoteReference{
-			Nullifier:    nullifier,
-			ProcessID:    pid,
-			Height:       blockHeight,
-			Weight:       new(types.BigInt).SetBytes(weight),
-			TxIndex:      txIndex,
-			CreationTime: time.Now(),
-		})
+func (s *Scrutinizer) addVoteIndex(ctx context.Context, queries *scrutinizerdb.Queries,
+	nullifier, pid []byte, blockHeight uint32, weight []byte, txIndex int32,
+	questionIndex uint32) error {
+	_, err := queries.CreateVote(ctx, scrutinizerdb.CreateVoteParams{
+		Nullifier:     nullifier,
+		ProcessID:     pid,
+		Height:        int64(blockHeight),
+		Weight:        new(types.BigInt).SetBytes(weight).String(),
+		TxIndex:       int64(txIndex),
+		QuestionIndex: int64(questionIndex),
+		CreationTime:  time.Now(),
+	})
+	return err
+}
+
+// overwriteVoteIndex updates the nullifier reference of an overwritten vote,
+// keeping the reference of the previous envelope on its overwrite history.
+func (s *Scrutinizer) overwriteVoteIndex(ctx context.Context, queries *scrutinizerdb.Queries,
+	nullifier, pid []byte, blockHeight uint32, weight []byte, txIndex int32,
+	questionIndex uint32) error {
+	ref, err := queries.GetVote(ctx, nullifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.addVoteIndex(ctx, queries, nullifier, pid, blockHeight, weight, txIndex,
+			questionIndex)
+	} else if err != nil {
+		return err
 	}
-	return s.queryWithRetries(func() error {
-		return s.db.Insert(nullifier, &indexertypes.VoteReference{
-			Nullifier:    nullifier,
-			ProcessID:    pid,
-			Height:       blockHeight,
-			Weight:       new(types.BigInt).SetBytes(weight),
-			TxIndex:      txIndex,
-			CreationTime: time.Now(),
-		})
+	if _, err := queries.CreateVoteOverwrite(ctx, scrutinizerdb.CreateVoteOverwriteParams{
+		Nullifier:    nullifier,
+		Height:       ref.Height,
+		TxIndex:      ref.TxIndex,
+		CreationTime: ref.CreationTime,
+	}); err != nil {
+		return err
+	}
+	_, err = queries.UpdateVote(ctx, scrutinizerdb.UpdateVoteParams{
+		Nullifier:     nullifier,
+		Height:        int64(blockHeight),
+		Weight:        new(types.BigInt).SetBytes(weight).String(),
+		TxIndex:       int64(txIndex),
+		QuestionIndex: int64(questionIndex),
+		CreationTime:  time.Now(),
 	})
+	return err
 }
 
 // addProcessToLiveResults adds the process id to the liveResultsProcs map
@@ -447,30 +552,34 @@ func (s *Scrutinizer) commitVotes(pid []byte,
 	// If the recovery bootstrap is running, wait
 	s.recoveryBootLock.RLock()
 	defer s.recoveryBootLock.RUnlock()
-	// The next lock avoid Transaction Conflicts
-	s.addVoteLock.Lock()
-	defer s.addVoteLock.Unlock()
 	return s.commitVotesUnsafe(pid, partialResults, height)
 }
 
 // commitVotesUnsafe does the same as commitVotes but it does not use locks.
 func (s *Scrutinizer) commitVotesUnsafe(pid []byte,
 	partialResults *indexertypes.Results, height uint32) error {
-	update := func(record interface{}) error {
-		stored, ok := record.(*indexertypes.Results)
-		if !ok {
-			return fmt.Errorf("record isn't the correct type! Wanted Result, got %T", record)
+	if err := s.withTx(func(ctx context.Context, queries *scrutinizerdb.Queries) error {
+		dbresults, err := queries.GetResults(ctx, pid)
+		if err != nil {
+			return err
+		}
+		stored, err := indexertypes.ResultsFromDB(&dbresults)
+		if err != nil {
+			return err
 		}
 		// If already final, don't update.
 		if stored.Final {
 			return nil
 		}
-		return stored.Add(partialResults)
-	}
-
-	if err := s.queryWithRetries(func() error {
-		return s.db.UpdateMatching(&indexertypes.Results{},
-			badgerhold.Where(badgerhold.Key).Eq(pid), update)
+		if err := stored.Add(partialResults); err != nil {
+			return err
+		}
+		params, err := indexertypes.ResultsToDB(stored)
+		if err != nil {
+			return err
+		}
+		_, err = queries.SetResults(ctx, params)
+		return err
 	}); err != nil {
 		log.Debugf("saved %d votes with total weight of %s on process %x", len(partialResults.Votes),
 			partialResults.Weight, pid)
@@ -479,6 +588,18 @@ func (s *Scrutinizer) commitVotesUnsafe(pid []byte,
 	return nil
 }
 
+// setResults stores the results, replacing the previous ones if any.
+func (s *Scrutinizer) setResults(results *indexertypes.Results) error {
+	params, err := indexertypes.ResultsToDB(results)
+	if err != nil {
+		return err
+	}
+	queries, ctx, cancel := s.timeoutQueries()
+	defer cancel()
+	_, err = queries.SetResults(ctx, params)
+	return err
+}
+
 // computeFinalResults walks through the envelopes of a process and computes the results.
 func (s *Scrutinizer) computeFinalResults(p *indexertypes.Process) (*indexertypes.Results, error) {
 	if p == nil {
@@ -500,15 +621,35 @@ func (s *Scrutinizer) computeFinalResults(p *indexertypes.Process) (*indexertype
 		BlockHeight:  s.App.Height(),
 	}
 
+	// on homomorphic tally processes, the results are the decrypted tally
+	process, err := s.App.State.Process(p.ID, true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get process from state: %w", err)
+	}
+	if process.EnvelopeType.GetHomomorphic() {
+		return s.computeHomomorphicResults(process, results)
+	}
+	// on ranked choice processes, the rankings are kept to count them
+	results.RankedSeats = process.VoteOptions.GetRankedSeats()
+
 	var nvotes uint64
-	var err error
 	lock := sync.Mutex{}
 
+	// on threshold keys, the private key is combined from the revealed shares
+	var thresholdKey *big.Int
+	if p.Envelope.GetEncryptedVotes() {
+		if thresholdKey, err = s.thresholdPrivateKey(p); err != nil {
+			return nil, err
+		}
+	}
+
 	if err = s.WalkEnvelopes(p.ID, true, func(vote *models.VoteEnvelope,
-		weight *big.Int) {
+		txRef *indexertypes.VoteReference) {
 		var vp *vochain.VotePackage
 		var err error
-		if p.Envelope.GetEncryptedVotes() {
+		if thresholdKey != nil {
+			vp, err = unmarshalThresholdVote(vote.GetVotePackage(), thresholdKey)
+		} else if p.Envelope.GetEncryptedVotes() {
 			if len(p.PrivateKeys) < len(vote.GetEncryptionKeyIndexes()) {
 				log.Errorf("encryptionKeyIndexes has too many fields")
 				return
@@ -534,7 +675,12 @@ func (s *Scrutinizer) computeFinalResults(p *indexertypes.Process) (*indexertype
 			return
 		}
 
-		if err = results.AddVote(vp.Votes, weight, &lock); err != nil {
+		if p.Envelope.GetSerial() {
+			err = results.AddSerialVote(txRef.QuestionIndex, vp.Votes, txRef.Weight.ToInt(), &lock)
+		} else {
+			err = results.AddVote(vp.Votes, txRef.Weight.ToInt(), &lock)
+		}
+		if err != nil {
 			log.Warnf("addVote failed: %v", err)
 			return
 		}
@@ -543,12 +689,42 @@ func (s *Scrutinizer) computeFinalResults(p *indexertypes.Process) (*indexertype
 		log.Infof("computed results for process %x with %d votes", p.ID, nvotes)
 		log.Debugf("results: %s", results)
 	}
+	if results.RankedSeats > 0 {
+		results.Ranked = indexertypes.ComputeRankedResults(results.Ballots,
+			int(p.VoteOpts.MaxValue)+1, int(results.RankedSeats))
+		results.Ballots = nil
+	}
 	results.EnvelopeHeight = nvotes
 	return results, err
 }
 
+// computeHomomorphicResults sets the results of a homomorphic tally process
+// from its decrypted tally.
+func (s *Scrutinizer) computeHomomorphicResults(process *models.Process,
+	results *indexertypes.Results) (*indexertypes.Results, error) {
+	tally, err := s.App.State.HomomorphicResults(process, true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt homomorphic tally: %w", err)
+	}
+	_, weight, err := s.App.State.EncryptedTally(process, true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get encrypted tally: %w", err)
+	}
+	for q := range tally {
+		for o, value := range tally[q] {
+			results.Votes[q][o] = (*types.BigInt)(value)
+		}
+	}
+	results.Weight = (*types.BigInt)(weight)
+	results.EnvelopeHeight = uint64(s.App.State.CountVotes(process.ProcessId, true))
+	log.Infof("computed homomorphic results for process %x with %d votes",
+		process.ProcessId, results.EnvelopeHeight)
+	return results, nil
+}
+
 // BuildProcessResult takes the indexer Results type and builds the protobuf type ProcessResult.
 // EntityId should be provided as addition field to include in ProcessResult.
+// On ranked choice processes, the ranked results are included too.
 func BuildProcessResult(results *indexertypes.Results, entityID []byte) *models.ProcessResult {
 	// build the protobuf type for Results
 	qr := []*models.QuestionResult{}
@@ -562,5 +738,6 @@ func BuildProcessResult(results *indexertypes.Results, entityID []byte) *models.
 		ProcessId: results.ProcessID,
 		EntityId:  entityID,
 		Votes:     qr,
+		Ranked:    results.Ranked.Proto(),
 	}
 }
@@ -0,0 +1,132 @@
+package vochain
+
+import (
+	"fmt"
+
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// TxSimulation is the would-be result of executing a transaction against the
+// current working state.
+type TxSimulation struct {
+	// TxID is the hash of the signed transaction.
+	TxID []byte
+	// Valid is true if the transaction would be accepted.
+	Valid bool
+	// Error contains the reason why the transaction would be rejected.
+	Error string
+	// Nullifier is the nullifier of the vote, only for vote transactions.
+	Nullifier []byte
+	// Cost is the amount of tokens that would be charged to the sender.
+	Cost uint64
+	// Nonce is the resulting nonce of the sender account (or the treasurer)
+	// after executing the transaction, or nil if the transaction does not
+	// use nonces.
+	Nonce *uint32
+}
+
+// SimulateTx decodes a marshaled models.SignedTx and runs all the checks of
+// AddTx against the current working state, without modifying the state, the
+// mempool nor the vote cache.  The reason of a rejection is set in
+// TxSimulation.Error, an error is only returned if the simulation itself fails
+// (for instance, if the transaction cannot be decoded).
+func (app *BaseApplication) SimulateTx(txBytes []byte) (*TxSimulation, error) {
+	vtx := new(VochainTx)
+	if err := vtx.Unmarshal(txBytes, app.ChainID()); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal transaction: %w", err)
+	}
+	sim := &TxSimulation{TxID: vtx.TxID[:]}
+	data, err := app.addTx(vtx, false, true)
+	if err != nil {
+		sim.Error = err.Error()
+		return sim, nil
+	}
+	charge, err := app.txCharge(vtx)
+	if err != nil {
+		return nil, err
+	}
+	sim.Valid = true
+	if _, ok := vtx.Tx.Payload.(*models.Tx_Vote); ok {
+		sim.Nullifier = data
+	}
+	sim.Cost, sim.Nonce = charge.cost, charge.nonce
+	return sim, nil
+}
+
+// txChargeValues are the cost and the resulting nonce of a transaction.
+type txChargeValues struct {
+	cost  uint64
+	nonce *uint32
+}
+
+// txCharge returns the cost that executing vtx would charge to its sender, and
+// the resulting nonce.  It assumes that vtx is valid.
+func (app *BaseApplication) txCharge(vtx *VochainTx) (*txChargeValues, error) {
+	var txType models.TxType
+	var nonce uint32
+	switch vtx.Tx.Payload.(type) {
+	case *models.Tx_SetAccountInfo:
+		tx := vtx.Tx.GetSetAccountInfo()
+		sender, err := ethereum.AddrFromSignature(vtx.SignedBody, vtx.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("cannot extract address from signature: %w", err)
+		}
+		acc, err := app.State.GetAccount(sender, false)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get account: %w", err)
+		}
+		if acc == nil {
+			// the account is created with nonce 0 and no cost
+			return &txChargeValues{nonce: new(uint32)}, nil
+		}
+		txType, nonce = models.TxType_SET_ACCOUNT_INFO, tx.Nonce
+	case *models.Tx_NewProcess:
+		_, txValues, err := app.NewProcessTxCheck(vtx.Tx, vtx.SignedBody, vtx.Signature, app.State)
+		if err != nil {
+			return nil, err
+		}
+		return app.processTxCharge(txValues)
+	case *models.Tx_SetProcess:
+		txValues, err := SetProcessTxCheck(vtx.Tx, vtx.SignedBody, vtx.Signature, app.State)
+		if err != nil {
+			return nil, err
+		}
+		return app.processTxCharge(txValues)
+	case *models.Tx_SendTokens:
+		txType, nonce = models.TxType_SEND_TOKENS, vtx.Tx.GetSendTokens().Nonce
+	case *models.Tx_SetAccountDelegateTx:
+		tx := vtx.Tx.GetSetAccountDelegateTx()
+		txType, nonce = tx.Txtype, tx.Nonce
+	case *models.Tx_SetTransactionCosts:
+		// treasurer transactions have no cost
+		nonce = vtx.Tx.GetSetTransactionCosts().Nonce + 1
+		return &txChargeValues{nonce: &nonce}, nil
+	case *models.Tx_MintTokens:
+		nonce = vtx.Tx.GetMintTokens().Nonce + 1
+		return &txChargeValues{nonce: &nonce}, nil
+	default:
+		return &txChargeValues{}, nil
+	}
+	cost, err := app.State.TxCost(txType, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s tx cost: %w", txType, err)
+	}
+	nonce++
+	return &txChargeValues{cost: cost, nonce: &nonce}, nil
+}
+
+// processTxCharge returns the cost and the resulting nonce of a process
+// transaction, which are not charged to oracles.
+func (app *BaseApplication) processTxCharge(txValues *processTxCheckValues) (*txChargeValues, error) {
+	if txValues.Account == types.EthereumZeroAddress {
+		return &txChargeValues{}, nil
+	}
+	acc, err := app.State.GetAccount(txValues.Account, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get account: %w", err)
+	}
+	nonce := acc.Nonce + 1
+	return &txChargeValues{cost: txValues.Cost, nonce: &nonce}, nil
+}
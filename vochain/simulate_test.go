@@ -0,0 +1,76 @@
+package vochain
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSimulateTx(t *testing.T) {
+	app := TestBaseApplication(t)
+
+	signer := ethereum.SignKeys{}
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+	toAddr := common.HexToAddress(randomEthAccount)
+
+	qt.Assert(t, app.State.SetAccount(BurnAddress, &Account{}), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_SEND_TOKENS, 10), qt.IsNil)
+	qt.Assert(t, app.State.CreateAccount(signer.Address(), "ipfs://", nil, 0), qt.IsNil)
+	qt.Assert(t, app.State.CreateAccount(toAddr, "ipfs://", nil, 0), qt.IsNil)
+	qt.Assert(t, app.State.MintBalance(signer.Address(), 1000), qt.IsNil)
+	app.Commit()
+
+	sendTokens := func(value uint64, nonce uint32) []byte {
+		tx, err := proto.Marshal(&models.Tx{Payload: &models.Tx_SendTokens{
+			SendTokens: &models.SendTokensTx{
+				Txtype: models.TxType_SEND_TOKENS,
+				From:   signer.Address().Bytes(),
+				To:     toAddr.Bytes(),
+				Value:  value,
+				Nonce:  nonce,
+			}}})
+		qt.Assert(t, err, qt.IsNil)
+		stx := &models.SignedTx{Tx: tx}
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(stx)
+		qt.Assert(t, err, qt.IsNil)
+		return stxBytes
+	}
+	hash := app.State.WorkingHash()
+
+	// valid transaction
+	sim, err := app.SimulateTx(sendTokens(100, 0))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sim.Valid, qt.IsTrue)
+	qt.Assert(t, sim.Error, qt.Equals, "")
+	qt.Assert(t, sim.Cost, qt.Equals, uint64(10))
+	qt.Assert(t, sim.Nonce, qt.Not(qt.IsNil))
+	qt.Assert(t, *sim.Nonce, qt.Equals, uint32(1))
+	qt.Assert(t, sim.Nullifier, qt.IsNil)
+
+	// invalid nonce and not enough balance
+	sim, err = app.SimulateTx(sendTokens(100, 1))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sim.Valid, qt.IsFalse)
+	qt.Assert(t, sim.Error, qt.Matches, ".*invalid nonce.*")
+	sim, err = app.SimulateTx(sendTokens(1000, 0))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sim.Valid, qt.IsFalse)
+	qt.Assert(t, sim.Error, qt.Not(qt.Equals), "")
+
+	// the state has not been modified
+	qt.Assert(t, app.State.WorkingHash(), qt.DeepEquals, hash)
+	acc, err := app.State.GetAccount(signer.Address(), false)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, acc.Balance, qt.Equals, uint64(1000))
+	qt.Assert(t, acc.Nonce, qt.Equals, uint32(0))
+
+	// undecodable transaction
+	_, err = app.SimulateTx([]byte("foo"))
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+}
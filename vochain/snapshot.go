@@ -0,0 +1,527 @@
+package vochain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/statedb"
+)
+
+const (
+	// snapshotFormat is the format version of the StateDB snapshots.  It
+	// must be increased every time the snapshot encoding changes, so that
+	// nodes reject snapshots they can't apply.
+	snapshotFormat = 1
+	// snapshotChunkSize is the size of each chunk served to other nodes
+	// during state sync (Tendermint limits chunks to 16 MiB).
+	snapshotChunkSize = 4 << 20 // 4 MiB
+	// snapshotFileExt is the extension of the snapshot files stored on disk.
+	snapshotFileExt = ".snapshot"
+)
+
+// snapshotManager creates, stores and restores chunked snapshots of the
+// StateDB, which are used by Tendermint state sync to bootstrap new nodes
+// without replaying the whole chain.
+//
+// A snapshot is a gzip compressed statedb.StateDB export, stored in a single
+// file named after the block height.  The snapshot Hash is the sha256 of the
+// whole file and the Metadata field contains the concatenation of the sha256
+// of each chunk, so every chunk can be verified as soon as it is received.
+type snapshotManager struct {
+	// dir is the directory where snapshot files are stored.
+	dir string
+	// interval is the number of blocks between snapshots.
+	interval uint32
+	// keepRecent is the number of snapshots to keep (0 keeps them all).
+	keepRecent int
+
+	// creating is 1 while a snapshot is being written, and wg waits for it.
+	creating int32
+	wg       sync.WaitGroup
+
+	lock      sync.RWMutex
+	snapshots []*abcitypes.Snapshot
+	// restore holds the snapshot being applied during state sync.
+	restore *snapshotRestore
+}
+
+// snapshotRestore keeps track of a snapshot offered by Tendermint while its
+// chunks are being applied.
+type snapshotRestore struct {
+	snapshot  *abcitypes.Snapshot
+	appHash   []byte
+	file      *os.File
+	nextChunk uint32
+}
+
+// newSnapshotManager returns a snapshotManager that stores its snapshots in
+// dir, loading the already existing ones.
+func newSnapshotManager(dir string, interval uint32, keepRecent int) (*snapshotManager, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	sm := &snapshotManager{
+		dir:        dir,
+		interval:   interval,
+		keepRecent: keepRecent,
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, snapshotFileExt) {
+			continue
+		}
+		height, err := strconv.ParseUint(strings.TrimSuffix(name, snapshotFileExt), 10, 64)
+		if err != nil {
+			log.Warnf("ignoring unknown snapshot file %s", name)
+			continue
+		}
+		snapshot, err := snapshotFromFile(filepath.Join(dir, name), height)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load snapshot %s: %w", name, err)
+		}
+		sm.snapshots = append(sm.snapshots, snapshot)
+	}
+	sort.Slice(sm.snapshots, func(i, j int) bool {
+		return sm.snapshots[i].Height < sm.snapshots[j].Height
+	})
+	log.Infof("found %d state snapshots in %s", len(sm.snapshots), dir)
+	return sm, nil
+}
+
+// snapshotFromFile computes the abcitypes.Snapshot description of an existing
+// snapshot file.
+func snapshotFromFile(path string, height uint64) (*abcitypes.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cw := newChunkHasher()
+	if _, err := io.Copy(cw, f); err != nil {
+		return nil, err
+	}
+	return cw.snapshot(height), nil
+}
+
+// chunkHasher is an io.Writer that computes the hash of the whole stream and
+// the hash of every snapshotChunkSize bytes.
+type chunkHasher struct {
+	hash        []byte
+	full        io.Writer
+	fullSum     func([]byte) []byte
+	chunk       []byte
+	chunkHashes [][]byte
+}
+
+func newChunkHasher() *chunkHasher {
+	h := sha256.New()
+	return &chunkHasher{full: h, fullSum: h.Sum}
+}
+
+// Write implements io.Writer.
+func (c *chunkHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	if _, err := c.full.Write(p); err != nil {
+		return 0, err
+	}
+	for len(p) > 0 {
+		free := snapshotChunkSize - len(c.chunk)
+		if free > len(p) {
+			free = len(p)
+		}
+		c.chunk = append(c.chunk, p[:free]...)
+		p = p[free:]
+		if len(c.chunk) == snapshotChunkSize {
+			sum := sha256.Sum256(c.chunk)
+			c.chunkHashes = append(c.chunkHashes, sum[:])
+			c.chunk = c.chunk[:0]
+		}
+	}
+	return n, nil
+}
+
+// snapshot returns the abcitypes.Snapshot of the data written so far.
+func (c *chunkHasher) snapshot(height uint64) *abcitypes.Snapshot {
+	if len(c.chunk) > 0 {
+		sum := sha256.Sum256(c.chunk)
+		c.chunkHashes = append(c.chunkHashes, sum[:])
+		c.chunk = nil
+	}
+	return &abcitypes.Snapshot{
+		Height:   height,
+		Format:   snapshotFormat,
+		Chunks:   uint32(len(c.chunkHashes)),
+		Hash:     c.fullSum(nil),
+		Metadata: bytes.Join(c.chunkHashes, nil),
+	}
+}
+
+// path returns the file path of the snapshot at height.
+func (sm *snapshotManager) path(height uint64) string {
+	return filepath.Join(sm.dir, strconv.FormatUint(height, 10)+snapshotFileExt)
+}
+
+// mustSnapshot returns true if a snapshot must be taken at height.
+func (sm *snapshotManager) mustSnapshot(height uint32) bool {
+	return sm.interval > 0 && height > 0 && height%sm.interval == 0
+}
+
+// create takes a snapshot of the committed state at height, which is written
+// in the background, and then prunes the old snapshots according to
+// keepRecent.  The snapshot is a read-only view of the state database, so the
+// following commits don't change it.  If the previous snapshot is still being
+// written, the new one is skipped.
+func (sm *snapshotManager) create(state *State, height uint32) error {
+	if !atomic.CompareAndSwapInt32(&sm.creating, 0, 1) {
+		return fmt.Errorf("previous snapshot still in progress")
+	}
+	view, err := state.Store.Snapshot()
+	if err != nil {
+		atomic.StoreInt32(&sm.creating, 0)
+		return err
+	}
+	sm.wg.Add(1)
+	go func() {
+		defer sm.wg.Done()
+		defer atomic.StoreInt32(&sm.creating, 0)
+		defer view.Close()
+		startTime := time.Now()
+		if err := sm.write(view, height); err != nil {
+			log.Errorf("cannot create state snapshot at height %d: %v", height, err)
+			return
+		}
+		log.Debugf("state snapshot at height %d took %s", height, time.Since(startTime))
+	}()
+	return nil
+}
+
+// wait waits for the snapshot being written, if any.
+func (sm *snapshotManager) wait() {
+	sm.wg.Wait()
+}
+
+// write stores the state view as the snapshot at height.
+func (sm *snapshotManager) write(view *statedb.Snapshot, height uint32) error {
+	tmpPath := sm.path(uint64(height)) + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	cw := newChunkHasher()
+	gw := gzip.NewWriter(io.MultiWriter(f, cw))
+	if err := view.Export(gw); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot export state: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, sm.path(uint64(height))); err != nil {
+		return err
+	}
+	snapshot := cw.snapshot(uint64(height))
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	sm.snapshots = append(sm.snapshots, snapshot)
+	log.Infof("created state snapshot at height %d with %d chunks and hash %x",
+		height, snapshot.Chunks, snapshot.Hash)
+	if sm.keepRecent > 0 && len(sm.snapshots) > sm.keepRecent {
+		for _, old := range sm.snapshots[:len(sm.snapshots)-sm.keepRecent] {
+			if err := os.Remove(sm.path(old.Height)); err != nil {
+				log.Warnf("cannot remove old snapshot at height %d: %v", old.Height, err)
+			}
+		}
+		sm.snapshots = sm.snapshots[len(sm.snapshots)-sm.keepRecent:]
+	}
+	return nil
+}
+
+// list returns the available snapshots.
+func (sm *snapshotManager) list() []*abcitypes.Snapshot {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	return append([]*abcitypes.Snapshot{}, sm.snapshots...)
+}
+
+// loadChunk returns the chunk with index chunk of the snapshot at height.
+func (sm *snapshotManager) loadChunk(height uint64, format, chunk uint32) ([]byte, error) {
+	if format != snapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format %d", format)
+	}
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	f, err := os.Open(sm.path(height))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, snapshotChunkSize)
+	n, err := f.ReadAt(buf, int64(chunk)*snapshotChunkSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("chunk %d out of range", chunk)
+	}
+	return buf[:n], nil
+}
+
+// offer starts the restoration of snapshot, whose state must match appHash.
+func (sm *snapshotManager) offer(snapshot *abcitypes.Snapshot,
+	appHash []byte) abcitypes.ResponseOfferSnapshot_Result {
+	if snapshot == nil || snapshot.Chunks == 0 {
+		return abcitypes.ResponseOfferSnapshot_REJECT
+	}
+	if snapshot.Format != snapshotFormat {
+		return abcitypes.ResponseOfferSnapshot_REJECT_FORMAT
+	}
+	if len(snapshot.Metadata) != int(snapshot.Chunks)*sha256.Size ||
+		len(snapshot.Hash) != sha256.Size {
+		return abcitypes.ResponseOfferSnapshot_REJECT
+	}
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	sm.abortRestore()
+	f, err := os.CreateTemp(sm.dir, "restore-*")
+	if err != nil {
+		log.Errorf("cannot create snapshot restore file: %v", err)
+		return abcitypes.ResponseOfferSnapshot_ABORT
+	}
+	sm.restore = &snapshotRestore{
+		snapshot: snapshot,
+		appHash:  appHash,
+		file:     f,
+	}
+	log.Infof("accepted state snapshot offer at height %d with %d chunks",
+		snapshot.Height, snapshot.Chunks)
+	return abcitypes.ResponseOfferSnapshot_ACCEPT
+}
+
+// abortRestore discards the snapshot being restored, if any.  Must be
+// called with the lock held.
+func (sm *snapshotManager) abortRestore() {
+	if sm.restore == nil {
+		return
+	}
+	sm.restore.file.Close()
+	os.Remove(sm.restore.file.Name())
+	sm.restore = nil
+}
+
+// applyChunk appends a chunk of the snapshot being restored.  Once the last
+// chunk is received, the snapshot is imported into the state and its hash is
+// checked against the app hash provided in the offer, and restored is true if
+// it succeeds.
+func (sm *snapshotManager) applyChunk(state *State, index uint32, chunk []byte,
+	sender string) (resp abcitypes.ResponseApplySnapshotChunk, restored bool) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	r := sm.restore
+	if r == nil {
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result: abcitypes.ResponseApplySnapshotChunk_ABORT,
+		}, false
+	}
+	// Chunks are applied in order, so we can just append them.
+	if index != r.nextChunk {
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result:        abcitypes.ResponseApplySnapshotChunk_RETRY,
+			RefetchChunks: []uint32{r.nextChunk},
+		}, false
+	}
+	sum := sha256.Sum256(chunk)
+	if !bytes.Equal(sum[:], r.snapshot.Metadata[index*sha256.Size:(index+1)*sha256.Size]) {
+		log.Warnf("snapshot chunk %d from %s does not match its hash", index, sender)
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result:        abcitypes.ResponseApplySnapshotChunk_RETRY,
+			RefetchChunks: []uint32{index},
+			RejectSenders: []string{sender},
+		}, false
+	}
+	if _, err := r.file.Write(chunk); err != nil {
+		log.Errorf("cannot write snapshot chunk: %v", err)
+		sm.abortRestore()
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result: abcitypes.ResponseApplySnapshotChunk_ABORT,
+		}, false
+	}
+	r.nextChunk++
+	if r.nextChunk < r.snapshot.Chunks {
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result: abcitypes.ResponseApplySnapshotChunk_ACCEPT,
+		}, false
+	}
+
+	// Last chunk received, restore the state.
+	defer sm.abortRestore()
+	if err := restoreSnapshotFile(state, r); err != nil {
+		log.Warnf("cannot restore state snapshot at height %d: %v", r.snapshot.Height, err)
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result: abcitypes.ResponseApplySnapshotChunk_REJECT_SNAPSHOT,
+		}, false
+	}
+	log.Infof("restored state snapshot at height %d with hash %x",
+		r.snapshot.Height, r.appHash)
+	return abcitypes.ResponseApplySnapshotChunk{
+		Result: abcitypes.ResponseApplySnapshotChunk_ACCEPT,
+	}, true
+}
+
+// restoreSnapshotFile verifies the complete snapshot received in r and
+// imports it into the state.
+func restoreSnapshotFile(state *State, r *snapshotRestore) error {
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r.file); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), r.snapshot.Hash) {
+		return fmt.Errorf("snapshot hash mismatch")
+	}
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	gr, err := gzip.NewReader(r.file)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	if err := state.RestoreSnapshot(gr); err != nil {
+		return err
+	}
+	version, err := state.LastHeight()
+	if err != nil {
+		return err
+	}
+	if uint64(version) != r.snapshot.Height {
+		return fmt.Errorf("restored state version %d does not match snapshot height %d",
+			version, r.snapshot.Height)
+	}
+	hash, err := state.Store.Hash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, r.appHash) {
+		return fmt.Errorf("restored state hash %x does not match app hash %x", hash, r.appHash)
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces the whole state by the StateDB export read from r,
+// and opens a new transaction on top of it.
+func (v *State) RestoreSnapshot(r io.Reader) error {
+	v.Tx.Lock()
+	defer v.Tx.Unlock()
+	v.Tx.Discard()
+	if err := v.Store.Import(r); err != nil {
+		return fmt.Errorf("cannot import snapshot: %w", err)
+	}
+	var err error
+	if v.Tx.TreeTx, err = v.Store.BeginTx(); err != nil {
+		return fmt.Errorf("cannot begin statedb tx: %w", err)
+	}
+	mainTreeView, err := v.Store.TreeView(nil)
+	if err != nil {
+		return fmt.Errorf("cannot get statedb mainTreeView: %w", err)
+	}
+	v.setMainTreeView(mainTreeView)
+	return nil
+}
+
+// EnableSnapshots makes the application take a snapshot of the state every
+// interval blocks, storing it in dir and keeping only the keepRecent most
+// recent ones (0 keeps them all).  The snapshots are served to other nodes
+// via Tendermint state sync.  If interval is 0, no snapshots are created but
+// the application is still able to restore a snapshot offered by a peer.
+func (app *BaseApplication) EnableSnapshots(dir string, interval uint32, keepRecent int) error {
+	sm, err := newSnapshotManager(dir, interval, keepRecent)
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot manager: %w", err)
+	}
+	app.snapshots = sm
+	return nil
+}
+
+// ListSnapshots returns the list of available state snapshots.
+func (app *BaseApplication) ListSnapshots(
+	req abcitypes.RequestListSnapshots) abcitypes.ResponseListSnapshots {
+	if app.snapshots == nil {
+		return abcitypes.ResponseListSnapshots{}
+	}
+	return abcitypes.ResponseListSnapshots{Snapshots: app.snapshots.list()}
+}
+
+// LoadSnapshotChunk returns a chunk of a local state snapshot.
+func (app *BaseApplication) LoadSnapshotChunk(
+	req abcitypes.RequestLoadSnapshotChunk) abcitypes.ResponseLoadSnapshotChunk {
+	if app.snapshots == nil {
+		return abcitypes.ResponseLoadSnapshotChunk{}
+	}
+	chunk, err := app.snapshots.loadChunk(req.Height, req.Format, req.Chunk)
+	if err != nil {
+		log.Warnf("cannot load snapshot chunk %d at height %d: %v", req.Chunk, req.Height, err)
+		return abcitypes.ResponseLoadSnapshotChunk{}
+	}
+	return abcitypes.ResponseLoadSnapshotChunk{Chunk: chunk}
+}
+
+// OfferSnapshot is called by Tendermint state sync when a snapshot from a peer
+// is available for restoring.
+func (app *BaseApplication) OfferSnapshot(
+	req abcitypes.RequestOfferSnapshot) abcitypes.ResponseOfferSnapshot {
+	if app.snapshots == nil {
+		return abcitypes.ResponseOfferSnapshot{Result: abcitypes.ResponseOfferSnapshot_ABORT}
+	}
+	return abcitypes.ResponseOfferSnapshot{Result: app.snapshots.offer(req.Snapshot, req.AppHash)}
+}
+
+// ApplySnapshotChunk applies a chunk of the snapshot previously accepted in
+// OfferSnapshot.  Once all the chunks are applied, the state is restored at
+// the snapshot height.
+func (app *BaseApplication) ApplySnapshotChunk(
+	req abcitypes.RequestApplySnapshotChunk) abcitypes.ResponseApplySnapshotChunk {
+	if app.snapshots == nil {
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result: abcitypes.ResponseApplySnapshotChunk_ABORT,
+		}
+	}
+	resp, restored := app.snapshots.applyChunk(app.State, req.Index, req.Chunk, req.Sender)
+	if restored {
+		height, err := app.State.LastHeight()
+		if err != nil {
+			log.Errorf("cannot get the restored state height: %v", err)
+			return abcitypes.ResponseApplySnapshotChunk{
+				Result: abcitypes.ResponseApplySnapshotChunk_ABORT,
+			}
+		}
+		app.State.SetHeight(height)
+		atomic.StoreUint32(&app.height, height)
+	}
+	return resp
+}
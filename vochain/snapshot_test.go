@@ -0,0 +1,135 @@
+package vochain
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestStateSyncSnapshot(t *testing.T) {
+	rng := testutil.NewRandom(0)
+	app := TestBaseApplication(t)
+	qt.Assert(t, app.EnableSnapshots(t.TempDir(), 2, 1), qt.IsNil)
+
+	var pids [][]byte
+	for height := uint32(1); height <= 4; height++ {
+		app.State.SetHeight(height)
+		for i := 0; i < 10; i++ {
+			censusURI := "ipfs://foobar"
+			pid := rng.RandomBytes(32)
+			pids = append(pids, pid)
+			qt.Assert(t, app.State.AddProcess(&models.Process{
+				ProcessId: pid,
+				EntityId:  rng.RandomBytes(32),
+				CensusURI: &censusURI,
+			}), qt.IsNil)
+			qt.Assert(t, app.State.AddVote(&models.Vote{
+				ProcessId:   pid,
+				Nullifier:   rng.RandomBytes(32),
+				VotePackage: []byte(fmt.Sprintf("%d%d", height, i)),
+			}), qt.IsNil)
+		}
+		app.Commit()
+		// snapshots are written in the background
+		app.snapshots.wait()
+	}
+	appHash, err := app.State.Store.Hash()
+	qt.Assert(t, err, qt.IsNil)
+
+	// Only the last snapshot (height 4) is kept
+	snapshots := app.ListSnapshots(abcitypes.RequestListSnapshots{}).Snapshots
+	qt.Assert(t, snapshots, qt.HasLen, 1)
+	snapshot := snapshots[0]
+	qt.Assert(t, snapshot.Height, qt.Equals, uint64(4))
+	qt.Assert(t, snapshot.Format, qt.Equals, uint32(snapshotFormat))
+	qt.Assert(t, snapshot.Metadata, qt.HasLen, int(snapshot.Chunks)*sha256.Size)
+
+	// Snapshots are loaded back from disk
+	sm, err := newSnapshotManager(app.snapshots.dir, 2, 1)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, sm.list(), qt.DeepEquals, snapshots)
+
+	var chunks [][]byte
+	for i := uint32(0); i < snapshot.Chunks; i++ {
+		resp := app.LoadSnapshotChunk(abcitypes.RequestLoadSnapshotChunk{
+			Height: snapshot.Height,
+			Format: snapshot.Format,
+			Chunk:  i,
+		})
+		qt.Assert(t, resp.Chunk, qt.Not(qt.HasLen), 0)
+		chunks = append(chunks, resp.Chunk)
+	}
+
+	// Restore the snapshot into a new application
+	app2 := TestBaseApplication(t)
+	qt.Assert(t, app2.EnableSnapshots(t.TempDir(), 0, 0), qt.IsNil)
+
+	// Unknown formats are rejected
+	offer := app2.OfferSnapshot(abcitypes.RequestOfferSnapshot{
+		Snapshot: &abcitypes.Snapshot{Height: 4, Format: snapshotFormat + 1, Chunks: 1},
+		AppHash:  appHash,
+	})
+	qt.Assert(t, offer.Result, qt.Equals, abcitypes.ResponseOfferSnapshot_REJECT_FORMAT)
+
+	offer = app2.OfferSnapshot(abcitypes.RequestOfferSnapshot{
+		Snapshot: snapshot,
+		AppHash:  appHash,
+	})
+	qt.Assert(t, offer.Result, qt.Equals, abcitypes.ResponseOfferSnapshot_ACCEPT)
+
+	// A corrupted chunk must be refetched
+	corrupted := append([]byte{}, chunks[0]...)
+	corrupted[0] ^= 0xff
+	apply := app2.ApplySnapshotChunk(abcitypes.RequestApplySnapshotChunk{
+		Index:  0,
+		Chunk:  corrupted,
+		Sender: "evil",
+	})
+	qt.Assert(t, apply.Result, qt.Equals, abcitypes.ResponseApplySnapshotChunk_RETRY)
+	qt.Assert(t, apply.RefetchChunks, qt.DeepEquals, []uint32{0})
+	qt.Assert(t, apply.RejectSenders, qt.DeepEquals, []string{"evil"})
+
+	for i, chunk := range chunks {
+		apply := app2.ApplySnapshotChunk(abcitypes.RequestApplySnapshotChunk{
+			Index:  uint32(i),
+			Chunk:  chunk,
+			Sender: "peer",
+		})
+		qt.Assert(t, apply.Result, qt.Equals, abcitypes.ResponseApplySnapshotChunk_ACCEPT)
+		// the height is only set once the whole snapshot is restored
+		if i < len(chunks)-1 {
+			qt.Assert(t, app2.Height(), qt.Equals, uint32(0))
+		}
+	}
+
+	appHash2, err := app2.State.Store.Hash()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, appHash2, qt.DeepEquals, appHash)
+	qt.Assert(t, app2.Height(), qt.Equals, uint32(4))
+	for _, pid := range pids {
+		_, err := app2.State.Process(pid, true)
+		qt.Assert(t, err, qt.IsNil)
+	}
+
+	// A snapshot whose state doesn't match the app hash is rejected
+	app3 := TestBaseApplication(t)
+	qt.Assert(t, app3.EnableSnapshots(t.TempDir(), 0, 0), qt.IsNil)
+	offer = app3.OfferSnapshot(abcitypes.RequestOfferSnapshot{
+		Snapshot: snapshot,
+		AppHash:  make([]byte, 32),
+	})
+	qt.Assert(t, offer.Result, qt.Equals, abcitypes.ResponseOfferSnapshot_ACCEPT)
+	var result abcitypes.ResponseApplySnapshotChunk_Result
+	for i, chunk := range chunks {
+		result = app3.ApplySnapshotChunk(abcitypes.RequestApplySnapshotChunk{
+			Index: uint32(i),
+			Chunk: chunk,
+		}).Result
+	}
+	qt.Assert(t, result, qt.Equals, abcitypes.ResponseApplySnapshotChunk_REJECT_SNAPSHOT)
+}
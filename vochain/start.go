@@ -64,6 +64,14 @@ func NewVochain(vochaincfg *config.VochainCfg, genesis []byte) *BaseApplication
 	if err != nil {
 		log.Fatalf("cannot initialize vochain application: %s", err)
 	}
+	if err := app.EnableSnapshots(vochaincfg.DataDir+"/data/snapshots",
+		vochaincfg.SnapshotInterval, vochaincfg.SnapshotKeepRecent); err != nil {
+		log.Fatal(err)
+	}
+	if vochaincfg.PruneKeepRecent > 0 || vochaincfg.PruneKeepEvery > 0 {
+		app.State.EnablePruning(vochaincfg.PruneKeepRecent,
+			vochaincfg.PruneKeepEvery, vochaincfg.PruneInterval)
+	}
 	log.Info("creating tendermint node and application")
 	err = app.SetNode(vochaincfg, genesis)
 	if err != nil {
@@ -456,6 +464,17 @@ func newTendermint(app *BaseApplication,
 	log.Infof("consensus block time target: commit=%.2fs propose=%.2fs",
 		tconfig.Consensus.TimeoutCommit.Seconds(), tconfig.Consensus.TimeoutPropose.Seconds())
 
+	// state sync config
+	if localConfig.StateSync {
+		tconfig.StateSync.Enable = true
+		tconfig.StateSync.RPCServers = localConfig.StateSyncRPCServers
+		tconfig.StateSync.TrustHeight = localConfig.StateSyncTrustHeight
+		tconfig.StateSync.TrustHash = localConfig.StateSyncTrustHash
+		tconfig.StateSync.TempDir = localConfig.DataDir + "/data"
+		log.Infof("state sync enabled, trusting block %d with hash %s",
+			tconfig.StateSync.TrustHeight, tconfig.StateSync.TrustHash)
+	}
+
 	// disable transaction indexer (we don't use it)
 	tconfig.TxIndex.Indexer = "null"
 
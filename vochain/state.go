@@ -168,6 +168,12 @@ func processSetVotesRoot(value []byte, root []byte) ([]byte, error) {
 var (
 	// TreasurerKey is the key representing the Treasurer entry on the Extra subtree
 	TreasurerKey = "treasurer"
+	// KeyKeepersThresholdKey is the key representing the keykeepers threshold
+	// entry on the Extra subtree
+	KeyKeepersThresholdKey = "keykeepersThreshold"
+	// OracleQuorumKey is the key representing the oracle results quorum
+	// entry on the Extra subtree
+	OracleQuorumKey = "oracleQuorum"
 	// ExtraCfg is the Extra subTree configuration.
 	ExtraCfg = statedb.NewTreeSingletonConfig(statedb.TreeParams{
 		HashFunc:          arbo.HashFunctionSha256,
@@ -280,14 +286,16 @@ var (
 // valid or not since the Vochain State do not validate results.
 type EventListener interface {
 	OnVote(vote *models.Vote, txIndex int32)
-	OnNewTx(hash []byte, blockHeight uint32, txIndex int32)
+	OnNewTx(tx *VochainTx, blockHeight uint32, txIndex int32)
 	OnProcess(pid, eid []byte, censusRoot, censusURI string, txIndex int32)
 	OnProcessStatusChange(pid []byte, status models.ProcessStatus, txIndex int32)
 	OnCancel(pid []byte, txIndex int32)
 	OnProcessKeys(pid []byte, encryptionPub string, txIndex int32)
 	OnRevealKeys(pid []byte, encryptionPriv string, txIndex int32)
+	OnProcessQuestionIndex(pid []byte, questionIndex uint32, txIndex int32)
 	OnProcessResults(pid []byte, results *models.ProcessResult, txIndex int32) error
 	OnProcessesStart(pids [][]byte)
+	OnProcessDurationChange(pid []byte, blockCount uint32)
 	Commit(height uint32) (err error)
 	Rollback()
 }
@@ -328,6 +336,9 @@ type State struct {
 	eventListeners      []EventListener
 	// currentHeight is the height of the current started block
 	currentHeight uint32
+	// pruner deletes old StateDB versions, it is nil if pruning is not
+	// enabled.
+	pruner *statePruner
 }
 
 // NewState creates a new State
@@ -488,12 +499,12 @@ func (v *State) RemoveOracle(address common.Address) error {
 	if err != nil {
 		return err
 	}
-	if _, err := oracles.Get(address.Bytes()); errors.Is(err, arbo.ErrKeyNotFound) {
+	if err := oracles.Del(address.Bytes()); errors.Is(err, arbo.ErrKeyNotFound) {
 		return fmt.Errorf("oracle not found: %w", err)
 	} else if err != nil {
 		return err
 	}
-	return oracles.Set(address.Bytes(), nil)
+	return nil
 }
 
 // Oracles returns the current oracles list
@@ -513,7 +524,8 @@ func (v *State) Oracles(committed bool) ([]common.Address, error) {
 
 	var oracles []common.Address
 	if err := oraclesTree.Iterate(func(key, value []byte) bool {
-		// removed oracles are still in the tree but with value set to nil
+		// oracles removed before the tree supported deletions are
+		// still in the tree but with value set to nil
 		if len(value) == 0 {
 			return true
 		}
@@ -681,15 +693,15 @@ func (v *State) SetFaucetNonce(key []byte) error {
 
 // hexPubKeyToTendermintEd25519 decodes a pubKey string to a ed25519 pubKey
 func hexPubKeyToTendermintEd25519(pubKey string) (tmcrypto.PubKey, error) {
-	var tmkey ed25519.PubKey
 	pubKeyBytes, err := hex.DecodeString(pubKey)
 	if err != nil {
 		return nil, err
 	}
-	if len(pubKeyBytes) != 32 {
+	if len(pubKeyBytes) != ed25519.PubKeySize {
 		return nil, fmt.Errorf("pubKey length is invalid")
 	}
-	copy(tmkey[:], pubKeyBytes[:])
+	tmkey := make(ed25519.PubKey, ed25519.PubKeySize)
+	copy(tmkey, pubKeyBytes)
 	return tmkey, nil
 }
 
@@ -712,12 +724,12 @@ func (v *State) RemoveValidator(address []byte) error {
 	if err != nil {
 		return err
 	}
-	if _, err := validators.Get(address); errors.Is(err, arbo.ErrKeyNotFound) {
+	if err := validators.Del(address); errors.Is(err, arbo.ErrKeyNotFound) {
 		return fmt.Errorf("validator not found: %w", err)
 	} else if err != nil {
 		return err
 	}
-	return validators.Set(address, nil)
+	return nil
 }
 
 // Validators returns a list of the chain validators
@@ -738,8 +750,8 @@ func (v *State) Validators(committed bool) ([]*models.Validator, error) {
 	var validators []*models.Validator
 	var callbackErr error
 	if err := validatorsTree.Iterate(func(key, value []byte) bool {
-		// removed validators are still in the tree but with value set
-		// to nil
+		// validators removed before the tree supported deletions are
+		// still in the tree but with value set to nil
 		if len(value) == 0 {
 			return true
 		}
@@ -768,6 +780,9 @@ func (v *State) AddProcessKeys(tx *models.AdminTx) error {
 	if err != nil {
 		return err
 	}
+	// on threshold keys, the second key published for an index is the
+	// keykeeper dealing, which does not add a new key
+	dealing := len(process.EncryptionPublicKeys[*tx.KeyIndex]) > 0
 	if tx.EncryptionPublicKey != nil {
 		process.EncryptionPublicKeys[*tx.KeyIndex] = fmt.Sprintf("%x", tx.EncryptionPublicKey)
 		log.Debugf("added encryption key %d for process %x: %x",
@@ -776,7 +791,17 @@ func (v *State) AddProcessKeys(tx *models.AdminTx) error {
 	if process.KeyIndex == nil {
 		process.KeyIndex = new(uint32)
 	}
-	*process.KeyIndex++
+	if dealing {
+		_, count, err := v.KeyKeepersThreshold(false)
+		if err != nil {
+			return err
+		}
+		if err := setThresholdPublicKey(process, count); err != nil {
+			return fmt.Errorf("cannot set threshold public key: %w", err)
+		}
+	} else {
+		*process.KeyIndex++
+	}
 	if err := v.updateProcess(process, tx.ProcessId); err != nil {
 		return err
 	}
@@ -815,6 +840,36 @@ func (v *State) RevealProcessKeys(tx *models.AdminTx) error {
 	return nil
 }
 
+// ExcludeProcessDealer excludes the threshold keys dealing of the keykeeper
+// complained about with a COMPLAIN_PROCESS_DEALING transaction, and sets the
+// process shared public key without it
+func (v *State) ExcludeProcessDealer(tx *models.AdminTx) error {
+	if tx.ProcessId == nil || tx.DealerIndex == nil {
+		return fmt.Errorf("no processId or dealerIndex provided on ExcludeProcessDealer")
+	}
+	process, err := v.Process(tx.ProcessId, false)
+	if err != nil {
+		return err
+	}
+	_, count, err := v.KeyKeepersThreshold(false)
+	if err != nil {
+		return err
+	}
+	process.ExcludedDealers = append(process.ExcludedDealers, *tx.DealerIndex)
+	if err := setThresholdPublicKey(process, count); err != nil {
+		return fmt.Errorf("cannot set threshold public key: %w", err)
+	}
+	log.Infof("excluded dealer %d from the threshold keys of process %x",
+		*tx.DealerIndex, tx.ProcessId)
+	if err := v.updateProcess(process, tx.ProcessId); err != nil {
+		return err
+	}
+	for _, l := range v.eventListeners {
+		l.OnProcessKeys(tx.ProcessId, process.EncryptionPublicKeys[ThresholdKeyIndex], v.TxCounter())
+	}
+	return nil
+}
+
 // VoteCount return the global vote count.
 // When committed is false, the operation is executed also on not yet commited
 // data from the currently open StateDB transaction.
@@ -850,7 +905,9 @@ func (v *State) voteCountInc() error {
 }
 
 // AddVote adds a new vote to a process and call the even listeners to OnVote.
-// This method does not check if the vote already exist!
+// If a vote with the same nullifier already exists, it is overwritten and its
+// overwrite counter increased.
+// This method does not check if the vote can be overwritten!
 func (v *State) AddVote(vote *models.Vote) error {
 	vid, err := v.voteID(vote.ProcessId, vote.Nullifier)
 	if err != nil {
@@ -868,16 +925,35 @@ func (v *State) AddVote(vote *models.Vote) error {
 		ProcessId: vote.ProcessId,
 		Nullifier: vote.Nullifier,
 	}
-	sdbVoteBytes, err := proto.Marshal(&sdbVote)
-	if err != nil {
-		return fmt.Errorf("cannot marshal sdbVote: %w", err)
-	}
 	v.Tx.Lock()
 	err = func() error {
+		prevBytes, err := v.Tx.DeepGet(vid, ProcessesCfg, VotesCfg.WithKey(vote.ProcessId))
+		if err == nil {
+			// the vote overwrites a previous one
+			var prev models.StateDBVote
+			if err := proto.Unmarshal(prevBytes, &prev); err != nil {
+				return fmt.Errorf("cannot unmarshal sdbVote: %w", err)
+			}
+			sdbVote.Overwrites = prev.Overwrites + 1
+			sdbVoteBytes, err := proto.Marshal(&sdbVote)
+			if err != nil {
+				return fmt.Errorf("cannot marshal sdbVote: %w", err)
+			}
+			return v.Tx.DeepSet(vid, sdbVoteBytes, ProcessesCfg, VotesCfg.WithKey(vote.ProcessId))
+		} else if !errors.Is(err, arbo.ErrKeyNotFound) {
+			return err
+		}
+		sdbVoteBytes, err := proto.Marshal(&sdbVote)
+		if err != nil {
+			return fmt.Errorf("cannot marshal sdbVote: %w", err)
+		}
 		if err := v.Tx.DeepAdd(vid, sdbVoteBytes,
 			ProcessesCfg, VotesCfg.WithKey(vote.ProcessId)); err != nil {
 			return err
 		}
+		if err := v.addToEncryptedTally(vote); err != nil {
+			return err
+		}
 		return v.voteCountInc()
 	}()
 	v.Tx.Unlock()
@@ -894,6 +970,12 @@ func (v *State) AddVote(vote *models.Vote) error {
 // hash(processID+nullifier) to allow using it as a key in Arbo tree.
 // voteID = hash(processID+nullifier)
 func (v *State) voteID(pid, nullifier []byte) ([]byte, error) {
+	return VoteID(pid, nullifier)
+}
+
+// VoteID returns the key of a vote in the Votes subTree of its process, which
+// is hash(processID+nullifier).
+func VoteID(pid, nullifier []byte) ([]byte, error) {
 	if len(pid) != types.ProcessIDsize {
 		return nil, fmt.Errorf("wrong processID size %d", len(pid))
 	}
@@ -911,6 +993,17 @@ func (v *State) voteID(pid, nullifier []byte) ([]byte, error) {
 // data from the currently open StateDB transaction.
 // When committed is true, the operation is executed on the last commited version.
 func (v *State) Envelope(processID, nullifier []byte, committed bool) (_ []byte, err error) {
+	sdbVote, err := v.stateDBVote(processID, nullifier, committed)
+	if err != nil {
+		return nil, err
+	}
+	return sdbVote.VoteHash, nil
+}
+
+// stateDBVote returns the stored vote identified by the processID and
+// nullifier.
+func (v *State) stateDBVote(processID, nullifier []byte,
+	committed bool) (*models.StateDBVote, error) {
 	vid, err := v.voteID(processID, nullifier)
 	if err != nil {
 		return nil, err
@@ -936,7 +1029,7 @@ func (v *State) Envelope(processID, nullifier []byte, committed bool) (_ []byte,
 	if err := proto.Unmarshal(sdbVoteBytes, &sdbVote); err != nil {
 		return nil, fmt.Errorf("cannot unmarshal sdbVote: %w", err)
 	}
-	return sdbVote.VoteHash, nil
+	return &sdbVote, nil
 }
 
 // EnvelopeExists returns true if the envelope identified with voteID exists
@@ -1083,7 +1176,7 @@ func (v *State) setRollingCensusSize(pids [][]byte) error {
 		if !process.Mode.PreRegister {
 			continue
 		}
-		censusSize, err := getRollingCensusSize(mainTreeView, pid)
+		censusSize, err := getRollingCensusSize(mainTreeView, process)
 		if err != nil {
 			return err
 		}
@@ -1099,7 +1192,7 @@ func (v *State) setRollingCensusSize(pids [][]byte) error {
 // Save persistent save of vochain mem trees
 func (v *State) Save() ([]byte, error) {
 	height := v.CurrentHeight()
-	var pidsStartNextBlock [][]byte
+	var pidsStartNextBlock, pidsEnded [][]byte
 	v.Tx.Lock()
 	err := func() error {
 		var err error
@@ -1107,9 +1200,26 @@ func (v *State) Save() ([]byte, error) {
 		if err != nil {
 			return fmt.Errorf("cannot get processIDs by StartBlock: %w", err)
 		}
+		// processes whose last block is this one are ended
+		if err := v.indexProcessesByEndBlock(height); err != nil {
+			return fmt.Errorf("cannot index processes by end block: %w", err)
+		}
+		pidsEndBlock, err := v.processIDsByEndBlock(height)
+		if err != nil {
+			return fmt.Errorf("cannot get processIDs by end block: %w", err)
+		}
+		if pidsEnded, err = v.endProcesses(pidsEndBlock); err != nil {
+			return fmt.Errorf("cannot end processes: %w", err)
+		}
+		if err := v.purgeRollingCensuses(); err != nil {
+			return fmt.Errorf("cannot purge rolling censuses: %w", err)
+		}
 		if err = v.setRollingCensusSize(pidsStartNextBlock); err != nil {
 			return fmt.Errorf("cannot set rollingCensusSize for processes")
 		}
+		if err = v.setAccountsCensusRoot(pidsStartNextBlock); err != nil {
+			return fmt.Errorf("cannot set accounts census root for processes: %w", err)
+		}
 
 		if err := v.Tx.Commit(height); err != nil {
 			return fmt.Errorf("cannot commit statedb tx: %w", err)
@@ -1128,6 +1238,11 @@ func (v *State) Save() ([]byte, error) {
 		return nil, fmt.Errorf("cannot get statdeb mainTreeView: %w", err)
 	}
 	v.setMainTreeView(mainTreeView)
+	for _, pid := range pidsEnded {
+		for _, l := range v.eventListeners {
+			l.OnProcessStatusChange(pid, models.ProcessStatus_ENDED, v.TxCounter())
+		}
+	}
 	for _, l := range v.eventListeners {
 		if err := l.Commit(height); err != nil {
 			if _, fatal := err.(ErrHaltVochain); fatal {
@@ -1143,10 +1258,7 @@ func (v *State) Save() ([]byte, error) {
 			l.OnProcessesStart(pidsStartNextBlock)
 		}
 	}
-	// TODO: Purge rolling censuses from all processes that start now
-	// for _, pid := range pids {
-	//   v.PurgeRollingCensus(pid)
-	// }
+	v.maybePrune(height)
 
 	return v.Store.Hash()
 }
@@ -1167,6 +1279,7 @@ func (v *State) Rollback() {
 }
 
 func (v *State) Close() error {
+	v.stopPruning()
 	v.Tx.Lock()
 	v.Tx.Discard()
 	v.Tx.Unlock()
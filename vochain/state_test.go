@@ -1,6 +1,7 @@
 package vochain
 
 import (
+	"bytes"
 	"fmt"
 	"runtime"
 	"testing"
@@ -152,18 +153,20 @@ type Listener struct {
 }
 
 func (l *Listener) OnVote(vote *models.Vote, txIndex int32)                                      {}
-func (l *Listener) OnNewTx(hash []byte, blockHeight uint32, txIndex int32)                       {}
+func (l *Listener) OnNewTx(tx *VochainTx, blockHeight uint32, txIndex int32)                     {}
 func (l *Listener) OnProcess(pid, eid []byte, censusRoot, censusURI string, txIndex int32)       {}
 func (l *Listener) OnProcessStatusChange(pid []byte, status models.ProcessStatus, txIndex int32) {}
 func (l *Listener) OnCancel(pid []byte, txIndex int32)                                           {}
 func (l *Listener) OnProcessKeys(pid []byte, encryptionPub string, txIndex int32)                {}
 func (l *Listener) OnRevealKeys(pid []byte, encryptionPriv string, txIndex int32)                {}
+func (l *Listener) OnProcessQuestionIndex(pid []byte, questionIndex uint32, txIndex int32)       {}
 func (l *Listener) OnProcessResults(pid []byte, results *models.ProcessResult, txIndex int32) error {
 	return nil
 }
 func (l *Listener) OnProcessesStart(pids [][]byte) {
 	l.processStart = append(l.processStart, pids)
 }
+func (l *Listener) OnProcessDurationChange(pid []byte, blockCount uint32) {}
 func (l *Listener) Commit(height uint32) (err error) {
 	return nil
 }
@@ -221,6 +224,176 @@ func TestOnProcessStart(t *testing.T) {
 	}
 }
 
+// endListener records the processes ended
+type endListener struct {
+	Listener
+	processEnd [][]byte
+}
+
+func (l *endListener) OnProcessStatusChange(pid []byte, status models.ProcessStatus, txIndex int32) {
+	if status == models.ProcessStatus_ENDED {
+		l.processEnd = append(l.processEnd, pid)
+	}
+}
+
+func TestOnProcessEnd(t *testing.T) {
+	s, err := NewState(db.TypePebble, t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	defer s.Close()
+	rng := testutil.NewRandom(0)
+	listener := &endListener{}
+	s.AddEventListener(listener)
+
+	doBlock := func(height uint32, fn func()) {
+		s.Rollback()
+		s.SetHeight(height)
+		fn()
+		_, err := s.Save()
+		qt.Assert(t, err, qt.IsNil)
+	}
+
+	// a READY process, a CANCELED one and one PAUSED until after its last
+	// block ending on the same block, and one created before the end block
+	// index existed ending later
+	pid, canceledPid, pausedPid := rng.RandomBytes(32), rng.RandomBytes(32), rng.RandomBytes(32)
+	oldPid := rng.RandomBytes(32)
+	doBlock(1, func() {
+		for _, p := range [][]byte{pid, canceledPid, pausedPid, oldPid} {
+			blockCount := uint32(2)
+			if bytes.Equal(p, oldPid) {
+				blockCount = 3
+			}
+			qt.Assert(t, s.AddProcess(&models.Process{
+				EntityId:     rng.RandomBytes(32),
+				ProcessId:    p,
+				StartBlock:   2,
+				BlockCount:   blockCount,
+				Mode:         &models.ProcessMode{Interruptible: true},
+				EnvelopeType: &models.EnvelopeType{},
+				Status:       models.ProcessStatus_READY,
+			}), qt.IsNil)
+		}
+		qt.Assert(t, s.SetProcessStatus(canceledPid, models.ProcessStatus_CANCELED, true), qt.IsNil)
+		qt.Assert(t, s.setProcessIDsByEndBlock(5, nil), qt.IsNil)
+	})
+	doBlock(2, func() {})
+	doBlock(3, func() {
+		qt.Assert(t, s.SetProcessStatus(pausedPid, models.ProcessStatus_PAUSED, true), qt.IsNil)
+	})
+	status := func(pid []byte) models.ProcessStatus {
+		p, err := s.Process(pid, true)
+		qt.Assert(t, err, qt.IsNil)
+		return p.Status
+	}
+	qt.Assert(t, status(pid), qt.Equals, models.ProcessStatus_READY)
+	qt.Assert(t, listener.processEnd, qt.HasLen, 0)
+
+	doBlock(4, func() {})
+	qt.Assert(t, status(pid), qt.Equals, models.ProcessStatus_ENDED)
+	qt.Assert(t, status(pausedPid), qt.Equals, models.ProcessStatus_PAUSED)
+	qt.Assert(t, status(oldPid), qt.Equals, models.ProcessStatus_READY)
+	qt.Assert(t, listener.processEnd, qt.DeepEquals, [][]byte{pid})
+
+	// the resumed process is ended at the end of the block
+	doBlock(5, func() {
+		qt.Assert(t, s.SetProcessStatus(pausedPid, models.ProcessStatus_READY, true), qt.IsNil)
+	})
+	qt.Assert(t, status(pausedPid), qt.Equals, models.ProcessStatus_ENDED)
+	qt.Assert(t, status(oldPid), qt.Equals, models.ProcessStatus_ENDED)
+	qt.Assert(t, listener.processEnd, qt.DeepEquals, [][]byte{pid, oldPid, pausedPid})
+	qt.Assert(t, status(canceledPid), qt.Equals, models.ProcessStatus_CANCELED)
+}
+
+func TestPurgeRollingCensus(t *testing.T) {
+	s, err := NewState(db.TypePebble, t.TempDir())
+	qt.Assert(t, err, qt.IsNil)
+	defer s.Close()
+	rng := testutil.NewRandom(0)
+
+	doBlock := func(height uint32, fn func()) {
+		s.Rollback()
+		s.SetHeight(height)
+		fn()
+		_, err := s.Save()
+		qt.Assert(t, err, qt.IsNil)
+	}
+
+	// a process ended with a transaction and one ended on its last block
+	pid, endedPid := rng.RandomBytes(32), rng.RandomBytes(32)
+	pids := [][]byte{pid, endedPid}
+	doBlock(1, func() {
+		maxCensusSize := uint64(16)
+		for _, pid := range pids {
+			p := &models.Process{
+				EntityId:   rng.RandomBytes(32),
+				ProcessId:  pid,
+				StartBlock: 3,
+				BlockCount: 2,
+				Status:     models.ProcessStatus_READY,
+				Mode: &models.ProcessMode{
+					PreRegister:   true,
+					Interruptible: true,
+				},
+				EnvelopeType: &models.EnvelopeType{
+					Anonymous: true,
+				},
+				MaxCensusSize: &maxCensusSize,
+			}
+			qt.Assert(t, s.AddProcess(p), qt.IsNil)
+		}
+	})
+	doBlock(2, func() {
+		for _, pid := range pids {
+			for i := 0; i < 8; i++ {
+				qt.Assert(t, s.AddToRollingCensus(pid, rng.RandomInZKField(), nil), qt.IsNil)
+				addr := ethereum.NewSignKeys()
+				qt.Assert(t, addr.Generate(), qt.IsNil)
+				qt.Assert(t, s.setPreRegisterAddrUsedWeight(pid, addr.Address(), bigOne), qt.IsNil)
+			}
+		}
+	})
+	roots := make(map[string][]byte)
+	for _, pid := range pids {
+		process, err := s.Process(pid, true)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, process.RollingCensusRoot, qt.Not(qt.DeepEquals), emptyCensusRoot)
+		qt.Assert(t, process.NullifiersRoot, qt.Not(qt.DeepEquals), emptyPreRegisterNullifiersRoot)
+		roots[string(pid)] = process.RollingCensusRoot
+	}
+
+	// the 32 keys of both processes are purged 10 on each block, once the
+	// processes end, keeping the final roots
+	defer func(batch int) { rollingCensusPurgeBatch = batch }(rollingCensusPurgeBatch)
+	rollingCensusPurgeBatch = 10
+	purged := func(pid []byte) bool {
+		process, err := s.Process(pid, true)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, process.Status, qt.Equals, models.ProcessStatus_ENDED)
+		qt.Assert(t, process.FinalRollingCensusRoot, qt.DeepEquals, roots[string(pid)])
+		return bytes.Equal(process.RollingCensusRoot, emptyCensusRoot) &&
+			bytes.Equal(process.NullifiersRoot, emptyPreRegisterNullifiersRoot)
+	}
+	doBlock(4, func() {
+		qt.Assert(t, s.SetProcessStatus(pid, models.ProcessStatus_ENDED, true), qt.IsNil)
+	})
+	qt.Assert(t, purged(pid), qt.IsFalse)
+	doBlock(5, func() {})
+	qt.Assert(t, purged(pid), qt.IsTrue)
+	qt.Assert(t, purged(endedPid), qt.IsFalse)
+	doBlock(6, func() {})
+	qt.Assert(t, purged(endedPid), qt.IsFalse)
+	doBlock(7, func() {})
+	for _, pid := range pids {
+		qt.Assert(t, purged(pid), qt.IsTrue)
+		size, err := s.GetRollingCensusSize(pid, true)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, size, qt.Equals, uint64(0))
+	}
+	queue, err := s.rollingCensusPurgeQueue()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, queue, qt.HasLen, 0)
+}
+
 // TestBlockMemoryUsage prints the Heap usage by the number of votes in a
 // block.  This is useful to analyze the memory taken by the underlying
 // database transaction in the StateDB in a real scenario.
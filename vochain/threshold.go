@@ -0,0 +1,327 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/threshold"
+	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// Threshold encryption keys
+//
+// When the keykeepers threshold is set, the encryption keys of a process are
+// generated jointly by the keykeepers 1 to count, and the votes can be
+// decrypted once any threshold keykeepers have revealed their shares:
+//
+//  1. Each keykeeper publishes its nacl transport key with an
+//     ADD_PROCESS_KEYS transaction (EncryptionPublicKeys[index]).
+//  2. Once all the transport keys are published, each keykeeper publishes
+//     its threshold.Dealing with a second ADD_PROCESS_KEYS transaction,
+//     which replaces its transport key (the dealing starts with it).
+//  3. Once all the dealings are published, the shared public key is set on
+//     EncryptionPublicKeys[ThresholdKeyIndex], and the votes must be
+//     encrypted with it.  Each keykeeper checks the shares it received
+//     against the dealings commitments, and, until the process starts, can
+//     complain about a dealer with a COMPLAIN_PROCESS_DEALING transaction by
+//     revealing its transport key.  If the share of the dealer does not
+//     match its commitments, the dealer is excluded (ExcludedDealers) and
+//     the shared public key is set again without its dealing.
+//  4. When the process finishes, each keykeeper reveals its share with a
+//     REVEAL_PROCESS_KEYS transaction (EncryptionPrivateKeys[index]), which
+//     is verified against the dealings commitments.  On homomorphic tally
+//     processes the partial decryption of the tally is revealed instead, see
+//     homomorphic.go.
+
+// ThresholdKeyIndex is the encryption key index of the shared public key of
+// the processes with threshold encryption keys.  The index 0 is never used by
+// a keykeeper.
+const ThresholdKeyIndex = 0
+
+// SetKeyKeepersThreshold sets the number of keykeepers that take part in the
+// threshold encryption keys of the processes, and the number of them required
+// to decrypt the votes.
+func (v *State) SetKeyKeepersThreshold(threshold, count uint32) error {
+	if count >= types.KeyKeeperMaxKeyIndex || threshold < 1 || threshold > count {
+		return fmt.Errorf("invalid keykeepers threshold %d of %d", threshold, count)
+	}
+	v.Tx.Lock()
+	defer v.Tx.Unlock()
+	value := make([]byte, 8)
+	binary.LittleEndian.PutUint32(value, threshold)
+	binary.LittleEndian.PutUint32(value[4:], count)
+	return v.Tx.DeepSet([]byte(KeyKeepersThresholdKey), value, ExtraCfg)
+}
+
+// KeyKeepersThreshold returns the keykeepers threshold and count set with
+// SetKeyKeepersThreshold.  If not set, count is zero and each keykeeper
+// publishes an independent key.
+// When committed is false, the operation is executed also on not yet commited
+// data from the currently open StateDB transaction.
+// When committed is true, the operation is executed on the last commited version.
+func (v *State) KeyKeepersThreshold(committed bool) (threshold, count uint32, err error) {
+	if !committed {
+		v.Tx.RLock()
+		defer v.Tx.RUnlock()
+	}
+	extraTree, err := v.mainTreeViewer(committed).SubTree(ExtraCfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	value, err := extraTree.Get([]byte(KeyKeepersThresholdKey))
+	if errors.Is(err, arbo.ErrKeyNotFound) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	if len(value) != 8 {
+		return 0, 0, fmt.Errorf("invalid keykeepers threshold value")
+	}
+	return binary.LittleEndian.Uint32(value), binary.LittleEndian.Uint32(value[4:]), nil
+}
+
+// ThresholdTransportKeys returns the transport keys of the keykeepers 1 to
+// count of a process, or nil if any of them has not been published yet.
+func ThresholdTransportKeys(process *models.Process, count uint32) ([][]byte, error) {
+	keys := make([][]byte, count)
+	for i := range keys {
+		key := process.EncryptionPublicKeys[i+1]
+		if key == "" {
+			return nil, nil
+		}
+		b, err := hex.DecodeString(key)
+		if err != nil || len(b) < nacl.KeyLength {
+			return nil, fmt.Errorf("cannot decode key %d", i+1)
+		}
+		keys[i] = b[:nacl.KeyLength]
+	}
+	return keys, nil
+}
+
+// ThresholdDealings returns the dealings of the keykeepers 1 to count of a
+// process, or nil if any of them has not been published yet.
+func ThresholdDealings(process *models.Process, count uint32) ([]*threshold.Dealing, error) {
+	dealings := make([]*threshold.Dealing, count)
+	for i := range dealings {
+		key := process.EncryptionPublicKeys[i+1]
+		if len(key) <= 2*nacl.KeyLength {
+			return nil, nil
+		}
+		b, err := hex.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode dealing %d: %w", i+1, err)
+		}
+		if dealings[i], err = threshold.UnmarshalDealing(b); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal dealing %d: %w", i+1, err)
+		}
+	}
+	return dealings, nil
+}
+
+// QualifiedDealings returns the dealings of the keykeepers 1 to count of a
+// process that have not been excluded after a complaint, or nil if any of
+// them has not been published yet.
+func QualifiedDealings(process *models.Process, count uint32) ([]*threshold.Dealing, error) {
+	dealings, err := ThresholdDealings(process, count)
+	if err != nil || dealings == nil {
+		return nil, err
+	}
+	qualified := make([]*threshold.Dealing, 0, len(dealings))
+	for i, d := range dealings {
+		if !IsExcludedDealer(process, uint32(i+1)) {
+			qualified = append(qualified, d)
+		}
+	}
+	return qualified, nil
+}
+
+// IsExcludedDealer returns true if the dealing of the keykeeper index has
+// been excluded from the process threshold keys.
+func IsExcludedDealer(process *models.Process, index uint32) bool {
+	for _, excluded := range process.ExcludedDealers {
+		if excluded == index {
+			return true
+		}
+	}
+	return false
+}
+
+// ThresholdShares returns the shares revealed by the keykeepers 1 to count
+// of a process, indexed by keykeeper index.
+func ThresholdShares(privateKeys []string, count uint32) (map[int]*big.Int, error) {
+	shares := make(map[int]*big.Int)
+	for i := 1; i <= int(count) && i < len(privateKeys); i++ {
+		if privateKeys[i] == "" {
+			continue
+		}
+		b, err := hex.DecodeString(privateKeys[i])
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode share %d: %w", i, err)
+		}
+		if shares[i], err = threshold.DecodeScalar(b); err != nil {
+			return nil, fmt.Errorf("cannot decode share %d: %w", i, err)
+		}
+	}
+	return shares, nil
+}
+
+// setThresholdPublicKey sets the shared public key of a process if all the
+// dealings have been published.
+func setThresholdPublicKey(process *models.Process, count uint32) error {
+	dealings, err := QualifiedDealings(process, count)
+	if err != nil || dealings == nil {
+		return err
+	}
+	process.EncryptionPublicKeys[ThresholdKeyIndex] = fmt.Sprintf("%x",
+		threshold.EncodePoint(threshold.SharedPublicKey(dealings)))
+	return nil
+}
+
+// checkThresholdKeyIndexes checks that, on threshold encryption keys, the
+// votes are encrypted only with the shared public key.
+func checkThresholdKeyIndexes(state *State, process *models.Process, indexes []uint32) error {
+	_, count, err := state.KeyKeepersThreshold(false)
+	if err != nil {
+		return fmt.Errorf("cannot get keykeepers threshold: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+	if process.EncryptionPublicKeys[ThresholdKeyIndex] == "" {
+		return fmt.Errorf("no threshold key available, voting is not possible")
+	}
+	if len(indexes) != 1 || indexes[0] != ThresholdKeyIndex {
+		return fmt.Errorf("votes must be encrypted with the threshold key index %d", ThresholdKeyIndex)
+	}
+	return nil
+}
+
+func checkAddProcessThresholdKeys(tx *models.AdminTx, process *models.Process,
+	required, count uint32) error {
+	if tx == nil {
+		return ErrNilTx
+	}
+	if tx.KeyIndex == nil {
+		return fmt.Errorf("key index is nil")
+	}
+	if tx.EncryptionPublicKey == nil || *tx.KeyIndex < 1 || *tx.KeyIndex > count {
+		return fmt.Errorf("no keys provided or invalid key index")
+	}
+	published := process.EncryptionPublicKeys[*tx.KeyIndex]
+	// first the transport key is published
+	if published == "" {
+		if len(tx.EncryptionPublicKey) != nacl.KeyLength {
+			return fmt.Errorf("invalid transport key length %d", len(tx.EncryptionPublicKey))
+		}
+		return nil
+	}
+	// then the dealing, once all the transport keys are published
+	if len(published) != 2*nacl.KeyLength {
+		return fmt.Errorf("dealing for key index %d already exists", *tx.KeyIndex)
+	}
+	transportKeys, err := ThresholdTransportKeys(process, count)
+	if err != nil {
+		return err
+	}
+	if transportKeys == nil {
+		return fmt.Errorf("the transport keys of all keykeepers are required to add a dealing")
+	}
+	dealing, err := threshold.UnmarshalDealing(tx.EncryptionPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid dealing: %w", err)
+	}
+	if fmt.Sprintf("%x", dealing.TransportKey) != published {
+		return fmt.Errorf("dealing transport key does not match the published key")
+	}
+	if dealing.Threshold() != int(required) || len(dealing.Shares) != int(count) {
+		return fmt.Errorf("dealing must be %d of %d, got %d of %d",
+			required, count, dealing.Threshold(), len(dealing.Shares))
+	}
+	// the shares are encrypted, so they are checked against the
+	// commitments by their recipients, who can complain about the dealer
+	// (see checkComplainProcessDealing)
+	return nil
+}
+
+func checkComplainProcessDealing(state *State, tx *models.AdminTx,
+	process *models.Process, count uint32) error {
+	if tx == nil {
+		return ErrNilTx
+	}
+	if tx.KeyIndex == nil || tx.DealerIndex == nil {
+		return fmt.Errorf("key index or dealer index is nil")
+	}
+	index, dealer := *tx.KeyIndex, *tx.DealerIndex
+	if index < 1 || index > count || dealer < 1 || dealer > count || index == dealer {
+		return fmt.Errorf("invalid key index %d or dealer index %d", index, dealer)
+	}
+	if tx.EncryptionPrivateKey == nil {
+		return fmt.Errorf("no transport key provided")
+	}
+	// excluding a dealing changes the shared public key, so it must happen
+	// before any vote is encrypted with it
+	if state.CurrentHeight() >= process.StartBlock {
+		return fmt.Errorf("cannot complain about a dealing once the process has started")
+	}
+	dealings, err := ThresholdDealings(process, count)
+	if err != nil {
+		return err
+	}
+	if dealings == nil {
+		return fmt.Errorf("the dealings of all keykeepers are required to complain")
+	}
+	if IsExcludedDealer(process, dealer) {
+		return fmt.Errorf("dealer %d already excluded", dealer)
+	}
+	if len(process.ExcludedDealers)+1 >= int(count) {
+		return fmt.Errorf("cannot exclude the last dealer")
+	}
+	transport, err := nacl.DecodePrivate(fmt.Sprintf("%x", tx.EncryptionPrivateKey))
+	if err != nil {
+		return fmt.Errorf("invalid transport key: %w", err)
+	}
+	if !bytes.Equal(transport.Public().Bytes(), dealings[index-1].TransportKey) {
+		return fmt.Errorf("transport key does not match the published key %d", index)
+	}
+	if _, err := dealings[dealer-1].DecryptShare(int(index), transport); err == nil {
+		return fmt.Errorf("the share of dealer %d for keykeeper %d is valid", dealer, index)
+	}
+	return nil
+}
+
+func checkRevealProcessThresholdKeys(state *State, tx *models.AdminTx,
+	process *models.Process, count uint32) error {
+	if tx == nil {
+		return ErrNilTx
+	}
+	if tx.KeyIndex == nil {
+		return fmt.Errorf("key index is nil")
+	}
+	if tx.EncryptionPrivateKey == nil || *tx.KeyIndex < 1 || *tx.KeyIndex > count {
+		return fmt.Errorf("no keys provided or invalid key index")
+	}
+	dealings, err := QualifiedDealings(process, count)
+	if err != nil {
+		return err
+	}
+	if dealings == nil {
+		return fmt.Errorf("process %x has no threshold key", process.ProcessId)
+	}
+	// on homomorphic tally processes, the share is never revealed
+	if process.EnvelopeType.GetHomomorphic() {
+		return checkTallyDecryption(state, process, dealings, int(*tx.KeyIndex),
+			tx.EncryptionPrivateKey)
+	}
+	share, err := threshold.DecodeScalar(tx.EncryptionPrivateKey)
+	if err != nil {
+		return err
+	}
+	return threshold.VerifyShare(dealings, int(*tx.KeyIndex), share)
+}
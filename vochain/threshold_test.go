@@ -0,0 +1,303 @@
+package vochain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/crypto"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/threshold"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestThresholdKeys(t *testing.T) {
+	app := TestBaseApplication(t)
+	const required, count = 2, 3
+	qt.Assert(t, app.State.SetKeyKeepersThreshold(required, count), qt.IsNil)
+
+	keykeepers := util.CreateEthRandomKeysBatch(count)
+	transport := make([]crypto.Cipher, count)
+	transportKeys := make([][]byte, count)
+	for i, k := range keykeepers {
+		qt.Assert(t, app.State.AddOracle(k.Address()), qt.IsNil)
+		var err error
+		transport[i], err = nacl.Generate(rand.Reader)
+		qt.Assert(t, err, qt.IsNil)
+		transportKeys[i] = transport[i].Public().Bytes()
+	}
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	censusURI := ipfsUrl
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:             pid,
+		StartBlock:            10,
+		BlockCount:            10,
+		EnvelopeType:          &models.EnvelopeType{EncryptedVotes: true},
+		Mode:                  &models.ProcessMode{Interruptible: true},
+		Status:                models.ProcessStatus_READY,
+		EntityId:              util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:            util.RandomBytes(32),
+		CensusURI:             &censusURI,
+		CensusOrigin:          models.CensusOrigin_OFF_CHAIN_TREE,
+		EncryptionPublicKeys:  make([]string, types.KeyKeeperMaxKeyIndex),
+		EncryptionPrivateKeys: make([]string, types.KeyKeeperMaxKeyIndex),
+	}), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	sendAdminTx := func(signer *ethereum.SignKeys, tx *models.AdminTx) error {
+		tx.ProcessId = pid
+		tx.Nonce = util.RandomBytes(32)
+		var stx models.SignedTx
+		var err error
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: tx}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return nil
+	}
+	addKey := func(index int, key []byte) error {
+		kindex := uint32(index)
+		return sendAdminTx(keykeepers[index-1], &models.AdminTx{
+			Txtype:              models.TxType_ADD_PROCESS_KEYS,
+			KeyIndex:            &kindex,
+			EncryptionPublicKey: key,
+		})
+	}
+	revealShare := func(index int, share *big.Int) error {
+		kindex := uint32(index)
+		return sendAdminTx(keykeepers[index-1], &models.AdminTx{
+			Txtype:               models.TxType_REVEAL_PROCESS_KEYS,
+			KeyIndex:             &kindex,
+			EncryptionPrivateKey: threshold.EncodeScalar(share),
+		})
+	}
+	newDealing := func(index int) []byte {
+		d, err := threshold.NewDealing([]byte{byte(index)}, required,
+			transportKeys[index-1], transportKeys)
+		qt.Assert(t, err, qt.IsNil)
+		return d.Marshal()
+	}
+
+	// transport keys, the dealings require all of them
+	qt.Assert(t, addKey(1, transportKeys[0]), qt.IsNil)
+	qt.Assert(t, addKey(2, transportKeys[1]), qt.IsNil)
+	qt.Assert(t, addKey(1, newDealing(1)), qt.Not(qt.IsNil))
+	outOfRange := uint32(count + 1)
+	qt.Assert(t, sendAdminTx(keykeepers[2], &models.AdminTx{
+		Txtype:              models.TxType_ADD_PROCESS_KEYS,
+		KeyIndex:            &outOfRange,
+		EncryptionPublicKey: transportKeys[2],
+	}), qt.Not(qt.IsNil))
+	qt.Assert(t, addKey(3, transportKeys[2]), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	// dealings, with the expected threshold and only once
+	bad, err := threshold.NewDealing([]byte{1}, count, transportKeys[0], transportKeys)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, addKey(1, bad.Marshal()), qt.Not(qt.IsNil))
+	qt.Assert(t, addKey(1, newDealing(2)), qt.Not(qt.IsNil))
+	for i := 1; i <= count; i++ {
+		qt.Assert(t, addKey(i, newDealing(i)), qt.IsNil)
+	}
+	qt.Assert(t, addKey(1, newDealing(1)), qt.Not(qt.IsNil))
+	app.AdvanceTestBlock()
+
+	process, err := app.State.Process(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, *process.KeyIndex, qt.Equals, uint32(count))
+	dealings, err := ThresholdDealings(process, count)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, dealings, qt.HasLen, count)
+	pubKey, err := hex.DecodeString(process.EncryptionPublicKeys[ThresholdKeyIndex])
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, pubKey, qt.DeepEquals, threshold.EncodePoint(threshold.SharedPublicKey(dealings)))
+
+	// votes must be encrypted with the shared key only
+	qt.Assert(t, checkThresholdKeyIndexes(app.State, process, []uint32{ThresholdKeyIndex}), qt.IsNil)
+	qt.Assert(t, checkThresholdKeyIndexes(app.State, process, []uint32{1}), qt.Not(qt.IsNil))
+	qt.Assert(t, checkThresholdKeyIndexes(app.State, process, []uint32{0, 1}), qt.Not(qt.IsNil))
+	pub, err := threshold.DecodePoint(pubKey)
+	qt.Assert(t, err, qt.IsNil)
+	ciphertext, err := threshold.Encrypt([]byte("vote"), pub)
+	qt.Assert(t, err, qt.IsNil)
+
+	// shares can be revealed once the process has ended
+	shares := make([]*big.Int, count)
+	for i := range shares {
+		shares[i], err = threshold.ParticipantShare(dealings, i+1, transport[i])
+		qt.Assert(t, err, qt.IsNil)
+	}
+	qt.Assert(t, revealShare(1, shares[0]), qt.Not(qt.IsNil))
+	qt.Assert(t, app.State.SetProcessStatus(pid, models.ProcessStatus_ENDED, true), qt.IsNil)
+	qt.Assert(t, revealShare(1, shares[1]), qt.Not(qt.IsNil))
+	qt.Assert(t, revealShare(1, shares[0]), qt.IsNil)
+	qt.Assert(t, revealShare(3, shares[2]), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	// the threshold shares decrypt the votes
+	process, err = app.State.Process(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	revealed, err := ThresholdShares(process.EncryptionPrivateKeys, count)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, revealed, qt.HasLen, required)
+	privKey, err := threshold.Combine(revealed)
+	qt.Assert(t, err, qt.IsNil)
+	message, err := threshold.Decrypt(ciphertext, privKey)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, string(message), qt.Equals, "vote")
+}
+
+func TestThresholdDealingComplaint(t *testing.T) {
+	app := TestBaseApplication(t)
+	const required, count = 2, 3
+	qt.Assert(t, app.State.SetKeyKeepersThreshold(required, count), qt.IsNil)
+
+	keykeepers := util.CreateEthRandomKeysBatch(count)
+	transport := make([]crypto.Cipher, count)
+	transportKeys := make([][]byte, count)
+	for i, k := range keykeepers {
+		qt.Assert(t, app.State.AddOracle(k.Address()), qt.IsNil)
+		var err error
+		transport[i], err = nacl.Generate(rand.Reader)
+		qt.Assert(t, err, qt.IsNil)
+		transportKeys[i] = transport[i].Public().Bytes()
+	}
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	censusURI := ipfsUrl
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:             pid,
+		StartBlock:            10,
+		BlockCount:            10,
+		EnvelopeType:          &models.EnvelopeType{EncryptedVotes: true},
+		Mode:                  &models.ProcessMode{Interruptible: true},
+		Status:                models.ProcessStatus_READY,
+		EntityId:              util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:            util.RandomBytes(32),
+		CensusURI:             &censusURI,
+		CensusOrigin:          models.CensusOrigin_OFF_CHAIN_TREE,
+		EncryptionPublicKeys:  make([]string, types.KeyKeeperMaxKeyIndex),
+		EncryptionPrivateKeys: make([]string, types.KeyKeeperMaxKeyIndex),
+	}), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	sendAdminTx := func(signer *ethereum.SignKeys, tx *models.AdminTx) error {
+		tx.ProcessId = pid
+		tx.Nonce = util.RandomBytes(32)
+		var stx models.SignedTx
+		var err error
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: tx}})
+		qt.Assert(t, err, qt.IsNil)
+		stx.Signature, err = signer.SignVocdoniTx(stx.Tx)
+		qt.Assert(t, err, qt.IsNil)
+		stxBytes, err := proto.Marshal(&stx)
+		qt.Assert(t, err, qt.IsNil)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("checkTx failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes}); resp.Code != 0 {
+			return fmt.Errorf("deliverTx failed: %s", resp.Data)
+		}
+		return nil
+	}
+	addKey := func(index int, key []byte) error {
+		kindex := uint32(index)
+		return sendAdminTx(keykeepers[index-1], &models.AdminTx{
+			Txtype:              models.TxType_ADD_PROCESS_KEYS,
+			KeyIndex:            &kindex,
+			EncryptionPublicKey: key,
+		})
+	}
+	complain := func(index, dealer int, transportKey []byte) error {
+		kindex, dindex := uint32(index), uint32(dealer)
+		return sendAdminTx(keykeepers[index-1], &models.AdminTx{
+			Txtype:               models.TxType_COMPLAIN_PROCESS_DEALING,
+			KeyIndex:             &kindex,
+			DealerIndex:          &dindex,
+			EncryptionPrivateKey: transportKey,
+		})
+	}
+
+	for i := 1; i <= count; i++ {
+		qt.Assert(t, addKey(i, transportKeys[i-1]), qt.IsNil)
+	}
+	app.AdvanceTestBlock()
+
+	// the dealer 2 sends an invalid share to the keykeeper 1
+	dealings := make([]*threshold.Dealing, count)
+	for i := range dealings {
+		var err error
+		dealings[i], err = threshold.NewDealing([]byte{byte(i + 1)}, required,
+			transportKeys[i], transportKeys)
+		qt.Assert(t, err, qt.IsNil)
+	}
+	invalidShare, err := nacl.Anonymous.Encrypt(threshold.EncodeScalar(big.NewInt(1)),
+		transport[0].Public())
+	qt.Assert(t, err, qt.IsNil)
+	dealings[1].Shares[0] = invalidShare
+	_, err = dealings[1].DecryptShare(1, transport[0])
+	qt.Assert(t, err, qt.Not(qt.IsNil))
+
+	// complaints require all the dealings
+	qt.Assert(t, addKey(2, dealings[1].Marshal()), qt.IsNil)
+	qt.Assert(t, complain(1, 2, transport[0].Bytes()), qt.Not(qt.IsNil))
+	qt.Assert(t, addKey(1, dealings[0].Marshal()), qt.IsNil)
+	qt.Assert(t, addKey(3, dealings[2].Marshal()), qt.IsNil)
+	app.AdvanceTestBlock()
+
+	// the complaints must reveal the transport key of the keykeeper, and
+	// the share must be invalid
+	qt.Assert(t, complain(1, 2, transport[2].Bytes()), qt.Not(qt.IsNil))
+	qt.Assert(t, complain(3, 2, transport[2].Bytes()), qt.Not(qt.IsNil))
+	qt.Assert(t, complain(1, 1, transport[0].Bytes()), qt.Not(qt.IsNil))
+	qt.Assert(t, complain(1, 3, transport[0].Bytes()), qt.Not(qt.IsNil))
+	qt.Assert(t, complain(1, 2, transport[0].Bytes()), qt.IsNil)
+	qt.Assert(t, complain(1, 2, transport[0].Bytes()), qt.Not(qt.IsNil))
+	app.AdvanceTestBlock()
+
+	// the shared key is generated without the excluded dealing
+	process, err := app.State.Process(pid, true)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, process.ExcludedDealers, qt.DeepEquals, []uint32{2})
+	qualified, err := QualifiedDealings(process, count)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, qualified, qt.HasLen, count-1)
+	pubKey, err := hex.DecodeString(process.EncryptionPublicKeys[ThresholdKeyIndex])
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, pubKey, qt.DeepEquals, threshold.EncodePoint(
+		threshold.SharedPublicKey([]*threshold.Dealing{dealings[0], dealings[2]})))
+
+	// and any threshold shares decrypt the votes
+	pub, err := threshold.DecodePoint(pubKey)
+	qt.Assert(t, err, qt.IsNil)
+	ciphertext, err := threshold.Encrypt([]byte("vote"), pub)
+	qt.Assert(t, err, qt.IsNil)
+	shares := make(map[int]*big.Int)
+	for _, i := range []int{1, 2} {
+		shares[i], err = threshold.ParticipantShare(qualified, i, transport[i-1])
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, threshold.VerifyShare(qualified, i, shares[i]), qt.IsNil)
+	}
+	privKey, err := threshold.Combine(shares)
+	qt.Assert(t, err, qt.IsNil)
+	message, err := threshold.Decrypt(ciphertext, privKey)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, string(message), qt.Equals, "vote")
+}
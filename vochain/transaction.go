@@ -10,6 +10,7 @@ import (
 	tmtypes "github.com/tendermint/tendermint/types"
 	"github.com/vocdoni/arbo"
 	"github.com/vocdoni/go-snark/verifier"
+	"go.vocdoni.io/dvote/crypto/babyjubjub"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
 	"go.vocdoni.io/dvote/crypto/zk"
@@ -29,6 +30,9 @@ type VochainTx struct {
 	SignedBody []byte
 	Signature  []byte
 	TxID       [32]byte
+	// CreatesAccount is set once the transaction is delivered, if it is a
+	// SET_ACCOUNT_INFO transaction creating the sender account.
+	CreatesAccount bool
 }
 
 // Unmarshal unarshal the content of a bytes serialized transaction.
@@ -59,6 +63,12 @@ func TxKey(tx tmtypes.Tx) [32]byte {
 //  Tx_Vote: vote nullifier
 //  default: []byte{}
 func (app *BaseApplication) AddTx(vtx *VochainTx, commit bool) ([]byte, error) {
+	return app.addTx(vtx, commit, false)
+}
+
+// addTx implements AddTx.  If dryRun is true (which requires commit=false), the
+// transaction is only checked and the vote cache is not modified.
+func (app *BaseApplication) addTx(vtx *VochainTx, commit, dryRun bool) ([]byte, error) {
 	if vtx.Tx == nil || app.State == nil || vtx.Tx.Payload == nil {
 		return nil, fmt.Errorf("transaction, state, and/or transaction payload is nil")
 	}
@@ -66,7 +76,7 @@ func (app *BaseApplication) AddTx(vtx *VochainTx, commit bool) ([]byte, error) {
 	case *models.Tx_Vote:
 		// get VoteEnvelope from tx
 		txVote := vtx.Tx.GetVote()
-		v, err := app.VoteEnvelopeCheck(txVote, vtx.SignedBody, vtx.Signature, vtx.TxID, commit)
+		v, err := app.voteEnvelopeCheck(txVote, vtx.SignedBody, vtx.Signature, vtx.TxID, commit, dryRun)
 		if err != nil || v == nil {
 			return []byte{}, fmt.Errorf("voteTxCheck: %w", err)
 		}
@@ -107,24 +117,33 @@ func (app *BaseApplication) AddTx(vtx *VochainTx, commit bool) ([]byte, error) {
 				return vtx.TxID[:], app.State.AddProcessKeys(tx)
 			case models.TxType_REVEAL_PROCESS_KEYS:
 				return vtx.TxID[:], app.State.RevealProcessKeys(tx)
+			case models.TxType_COMPLAIN_PROCESS_DEALING:
+				return vtx.TxID[:], app.State.ExcludeProcessDealer(tx)
+			case models.TxType_SET_ORACLE_QUORUM:
+				return vtx.TxID[:], app.State.SetOracleQuorum(tx.GetOracleQuorum())
 			}
 		}
 
 	case *models.Tx_NewProcess:
-		if p, err := app.NewProcessTxCheck(vtx.Tx, vtx.SignedBody, vtx.Signature, app.State); err == nil {
-			if commit {
-				tx := vtx.Tx.GetNewProcess()
-				if tx.Process == nil {
-					return []byte{}, fmt.Errorf("newProcess process is empty")
-				}
-				return vtx.TxID[:], app.State.AddProcess(p)
-			}
-		} else {
+		p, txValues, err := app.NewProcessTxCheck(vtx.Tx, vtx.SignedBody, vtx.Signature, app.State)
+		if err != nil {
 			return []byte{}, fmt.Errorf("newProcess: %w", err)
 		}
+		if commit {
+			tx := vtx.Tx.GetNewProcess()
+			if tx.Process == nil {
+				return []byte{}, fmt.Errorf("newProcess process is empty")
+			}
+			if err := app.State.AddProcess(p); err != nil {
+				return []byte{}, fmt.Errorf("newProcess: %w", err)
+			}
+			// substract the process cost and increment nonce
+			return vtx.TxID[:], app.State.chargeProcessTx(txValues)
+		}
 
 	case *models.Tx_SetProcess:
-		if err := SetProcessTxCheck(vtx.Tx, vtx.SignedBody, vtx.Signature, app.State); err != nil {
+		txValues, err := SetProcessTxCheck(vtx.Tx, vtx.SignedBody, vtx.Signature, app.State)
+		if err != nil {
 			return []byte{}, fmt.Errorf("setProcess: %w", err)
 		}
 		if commit {
@@ -134,20 +153,30 @@ func (app *BaseApplication) AddTx(vtx *VochainTx, commit bool) ([]byte, error) {
 				if tx.GetStatus() == models.ProcessStatus_PROCESS_UNKNOWN {
 					return []byte{}, fmt.Errorf("set process status, status unknown")
 				}
-				return vtx.TxID[:], app.State.SetProcessStatus(tx.ProcessId, *tx.Status, true)
+				err = app.State.SetProcessStatus(tx.ProcessId, *tx.Status, true)
 			case models.TxType_SET_PROCESS_RESULTS:
 				if tx.GetResults() == nil {
 					return []byte{}, fmt.Errorf("set process results, results is nil")
 				}
-				return vtx.TxID[:], app.State.SetProcessResults(tx.ProcessId, tx.Results, true)
+				err = app.State.SetProcessResults(tx.ProcessId, tx.Results, true)
 			case models.TxType_SET_PROCESS_CENSUS:
 				if tx.GetCensusRoot() == nil {
 					return []byte{}, fmt.Errorf("set process census, census root is nil")
 				}
-				return vtx.TxID[:], app.State.SetProcessCensus(tx.ProcessId, tx.CensusRoot, tx.GetCensusURI(), true)
+				err = app.State.SetProcessCensus(tx.ProcessId, tx.CensusRoot, tx.GetCensusURI(), true)
+			case models.TxType_SET_PROCESS_QUESTION_INDEX:
+				if tx.QuestionIndex == nil {
+					return []byte{}, fmt.Errorf("set process question index, question index is nil")
+				}
+				err = app.State.SetProcessQuestionIndex(tx.ProcessId, *tx.QuestionIndex, true)
 			default:
 				return []byte{}, fmt.Errorf("unknown set process tx type")
 			}
+			if err != nil {
+				return vtx.TxID[:], err
+			}
+			// substract the tx cost and increment nonce
+			return vtx.TxID[:], app.State.chargeProcessTx(txValues)
 		}
 
 	case *models.Tx_RegisterKey:
@@ -172,6 +201,7 @@ func (app *BaseApplication) AddTx(vtx *VochainTx, commit bool) ([]byte, error) {
 		if commit {
 			// create account
 			if txValues.Create {
+				vtx.CreatesAccount = true
 				// with faucet payload provided
 				if txValues.FaucetPayloadSigner != types.EthereumZeroAddress {
 					// create account
@@ -289,6 +319,13 @@ func (app *BaseApplication) AddTx(vtx *VochainTx, commit bool) ([]byte, error) {
 // All hexadecimal strings should be already sanitized (without 0x)
 func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes, signature []byte,
 	txID [32]byte, forCommit bool) (*models.Vote, error) {
+	return app.voteEnvelopeCheck(ve, txBytes, signature, txID, forCommit, false)
+}
+
+// voteEnvelopeCheck implements VoteEnvelopeCheck.  If dryRun is true, the vote
+// is not added to the vote cache.
+func (app *BaseApplication) voteEnvelopeCheck(ve *models.VoteEnvelope, txBytes, signature []byte,
+	txID [32]byte, forCommit, dryRun bool) (*models.Vote, error) {
 
 	// Perform basic/general checks
 	if ve == nil {
@@ -307,17 +344,36 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 	if height < process.StartBlock || height > endBlock {
 		return nil, fmt.Errorf("process %x not started or finished", ve.ProcessId)
 	}
+	if err := checkProcessSchedule(process, app.TimestampStartBlock()); err != nil {
+		return nil, err
+	}
 
 	if process.Status != models.ProcessStatus_READY {
 		return nil, fmt.Errorf("process %x not in READY state", ve.ProcessId)
 	}
 
+	// On serial processes the vote is cast for the question index signed in
+	// the envelope, which must be the current question of the process.
+	if process.EnvelopeType.Serial {
+		if ve.QuestionIndex != process.GetQuestionIndex() {
+			return nil, fmt.Errorf("vote for question %d, but process %x is on question %d",
+				ve.QuestionIndex, ve.ProcessId, process.GetQuestionIndex())
+		}
+	} else if ve.QuestionIndex != 0 {
+		return nil, fmt.Errorf("question index is only allowed on serial processes")
+	}
+
 	// Check in case of keys required, they have been sent by some keykeeper
 	if process.EnvelopeType.EncryptedVotes &&
 		process.KeyIndex != nil &&
 		*process.KeyIndex < 1 {
 		return nil, fmt.Errorf("no keys available, voting is not possible")
 	}
+	if process.EnvelopeType.EncryptedVotes {
+		if err := checkThresholdKeyIndexes(app.State, process, ve.EncryptionKeyIndexes); err != nil {
+			return nil, err
+		}
+	}
 
 	var vote *models.Vote
 	if process.EnvelopeType.Anonymous {
@@ -343,12 +399,8 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 
 			vote.Height = height // update vote height
 			defer app.State.CacheDel(txID)
-			if exist, err := app.State.EnvelopeExists(vote.ProcessId,
-				vote.Nullifier, false); err != nil || exist {
-				if err != nil {
-					return nil, err
-				}
-				return nil, fmt.Errorf("vote %x already exists", vote.Nullifier)
+			if err := checkVoteOverwrite(app.State, process, vote.Nullifier); err != nil {
+				return nil, err
 			}
 			return vote, nil
 		}
@@ -367,13 +419,9 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 		// ve.Nullifier is encoded in little-endian
 		nullifierBI := arbo.BytesToBigInt(ve.Nullifier)
 
-		// check if vote already exists
-		if exist, err := app.State.EnvelopeExists(ve.ProcessId,
-			ve.Nullifier, false); err != nil || exist {
-			if err != nil {
-				return nil, err
-			}
-			return nil, fmt.Errorf("vote %x already exists", ve.Nullifier)
+		// check if vote already exists and cannot be overwritten
+		if err := checkVoteOverwrite(app.State, process, ve.Nullifier); err != nil {
+			return nil, err
 		}
 		log.Debugf("new zk vote %x for process %x", ve.Nullifier, ve.ProcessId)
 
@@ -417,7 +465,8 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 			Nullifier:   ve.Nullifier,
 			// Anonymous Voting doesn't support weighted voting, so
 			// we assing always 1 to each vote.
-			Weight: big.NewInt(1).Bytes(),
+			Weight:        big.NewInt(1).Bytes(),
+			QuestionIndex: ve.QuestionIndex,
 		}
 		// If process encrypted, check the vote is encrypted (includes at least one key index)
 		if process.EnvelopeType.EncryptedVotes {
@@ -454,12 +503,8 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 			// if we are on DeliverTx and the vote is in cache, lazy check
 			defer app.State.CacheDel(txID)
 			vote.Height = height // update vote height
-			if exist, err := app.State.EnvelopeExists(vote.ProcessId,
-				vote.Nullifier, false); err != nil || exist {
-				if err != nil {
-					return nil, err
-				}
-				return nil, fmt.Errorf("vote %x already exists", vote.Nullifier)
+			if err := checkVoteOverwrite(app.State, process, vote.Nullifier); err != nil {
+				return nil, err
 			}
 			if height > process.GetStartBlock()+process.GetBlockCount() ||
 				process.GetStatus() != models.ProcessStatus_READY {
@@ -472,9 +517,10 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 		// extract pubKey, generate nullifier and check census proof.
 		// add the transaction in the cache
 		vote = &models.Vote{
-			Height:      height,
-			ProcessId:   ve.ProcessId,
-			VotePackage: ve.VotePackage,
+			Height:        height,
+			ProcessId:     ve.ProcessId,
+			VotePackage:   ve.VotePackage,
+			QuestionIndex: ve.QuestionIndex,
 		}
 
 		// check proof is nil
@@ -489,31 +535,60 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 			}
 			vote.EncryptionKeyIndexes = ve.EncryptionKeyIndexes
 		}
-		pubKey, err := ethereum.PubKeyFromSignature(txBytes, signature)
-		if err != nil {
-			return nil, fmt.Errorf("cannot extract public key from signature: %w", err)
-		}
-		addr, err := ethereum.AddrFromPublicKey(pubKey)
-		if err != nil {
-			return nil, fmt.Errorf("cannot extract address from public key: %w", err)
+		// iden3 proofs are signed with the BabyJubJub key in the census,
+		// the rest with a secp256k1 key
+		var pubKey []byte
+		var addr common.Address
+		if ve.Proof.GetIden3() != nil {
+			if pubKey, err = babyjubjub.PubKeyFromSignature(txBytes, signature); err != nil {
+				return nil, fmt.Errorf("cannot verify babyjubjub signature: %w", err)
+			}
+			addr = babyjubjub.AddrFromPublicKey(pubKey)
+		} else {
+			if pubKey, err = ethereum.PubKeyFromSignature(txBytes, signature); err != nil {
+				return nil, fmt.Errorf("cannot extract public key from signature: %w", err)
+			}
+			if addr, err = ethereum.AddrFromPublicKey(pubKey); err != nil {
+				return nil, fmt.Errorf("cannot extract address from public key: %w", err)
+			}
 		}
 
-		// assign a nullifier
-		vote.Nullifier = GenerateNullifier(addr, vote.ProcessId)
-
-		// check if vote already exists
-		if exist, err := app.State.EnvelopeExists(vote.ProcessId,
-			vote.Nullifier, false); err != nil || exist {
-			if err != nil {
+		// on vochain tokens census processes, a delegate can vote on
+		// behalf of the account, so the nullifier is derived from the
+		// account address and not from the signer
+		if process.CensusOrigin == models.CensusOrigin_VOCHAIN_TOKENS {
+			if addr, err = AccountsCensusVoter(ve.Proof); err != nil {
 				return nil, err
 			}
-			return nil, fmt.Errorf("vote %x already exists", vote.Nullifier)
+		}
+
+		// assign a nullifier, on serial processes the vote is cast for
+		// the signed question index
+		if process.EnvelopeType.Serial {
+			vote.Nullifier = GenerateSerialNullifier(addr, vote.ProcessId, ve.QuestionIndex)
+		} else {
+			vote.Nullifier = GenerateNullifier(addr, vote.ProcessId)
+		}
+
+		// check if vote already exists and cannot be overwritten
+		if err := checkVoteOverwrite(app.State, process, vote.Nullifier); err != nil {
+			return nil, err
 		}
 		log.Debugf("new vote %x for address %s and process %x", vote.Nullifier, addr.Hex(), ve.ProcessId)
 
-		valid, weight, err := VerifyProof(process, ve.Proof,
-			process.CensusOrigin, process.CensusRoot, process.ProcessId,
-			pubKey, addr)
+		// on pre-register processes the voter must be in the rolling
+		// census, built during the registration phase
+		var valid bool
+		var weight *big.Int
+		if process.Mode != nil && process.Mode.PreRegister {
+			valid, weight, err = VerifyProofRollingCensus(process, ve.Proof,
+				process.CensusOrigin, process.RollingCensusRoot, process.ProcessId,
+				pubKey, addr)
+		} else {
+			valid, weight, err = VerifyProof(process, ve.Proof,
+				process.CensusOrigin, process.CensusRoot, process.ProcessId,
+				pubKey, addr)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -521,8 +596,17 @@ func (app *BaseApplication) VoteEnvelopeCheck(ve *models.VoteEnvelope, txBytes,
 			return nil, fmt.Errorf("proof not valid")
 		}
 		vote.Weight = weight.Bytes()
+
+		// homomorphic tally processes are never anonymous, their ballots
+		// are bound to the voter
+		if process.EnvelopeType.GetHomomorphic() {
+			if err := checkHomomorphicVote(app.State, process, ve.VotePackage,
+				addr, weight); err != nil {
+				return nil, err
+			}
+		}
 	}
-	if !forCommit {
+	if !forCommit && !dryRun {
 		// add the vote to cache
 		app.State.CacheAdd(txID, vote)
 	}
@@ -553,7 +637,8 @@ func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error
 	}
 
 	switch tx.Txtype {
-	case models.TxType_ADD_PROCESS_KEYS, models.TxType_REVEAL_PROCESS_KEYS:
+	case models.TxType_ADD_PROCESS_KEYS, models.TxType_REVEAL_PROCESS_KEYS,
+		models.TxType_COMPLAIN_PROCESS_DEALING:
 		if tx.ProcessId == nil {
 			return fmt.Errorf("missing processId on AdminTxCheck")
 		}
@@ -570,6 +655,10 @@ func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error
 			return fmt.Errorf("process does not require keys")
 		}
 
+		threshold, count, err := state.KeyKeepersThreshold(false)
+		if err != nil {
+			return fmt.Errorf("cannot get keykeepers threshold: %w", err)
+		}
 		height := state.CurrentHeight()
 		// Specific checks
 		switch tx.Txtype {
@@ -587,11 +676,13 @@ func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error
 				process.Status == models.ProcessStatus_RESULTS {
 				return fmt.Errorf("cannot add process keys to a %s process", process.Status)
 			}
-			if len(process.EncryptionPublicKeys[*tx.KeyIndex]) > 0 {
-				return fmt.Errorf("keys for process %x already revealed", tx.ProcessId)
-			}
 			// check included keys and keyindex are valid
-			if err := checkAddProcessKeys(tx, process); err != nil {
+			if count > 0 {
+				err = checkAddProcessThresholdKeys(tx, process, threshold, count)
+			} else {
+				err = checkAddProcessKeys(tx, process)
+			}
+			if err != nil {
 				return err
 			}
 		case models.TxType_REVEAL_PROCESS_KEYS:
@@ -608,7 +699,23 @@ func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error
 				return fmt.Errorf("keys for process %x already revealed", tx.ProcessId)
 			}
 			// check the keys are valid
-			if err := checkRevealProcessKeys(tx, process); err != nil {
+			if count > 0 {
+				err = checkRevealProcessThresholdKeys(state, tx, process, count)
+			} else {
+				err = checkRevealProcessKeys(tx, process)
+			}
+			if err != nil {
+				return err
+			}
+		case models.TxType_COMPLAIN_PROCESS_DEALING:
+			if count == 0 {
+				return fmt.Errorf("process %x has no threshold keys", tx.ProcessId)
+			}
+			if process.Status != models.ProcessStatus_READY &&
+				process.Status != models.ProcessStatus_PAUSED {
+				return fmt.Errorf("cannot complain about a dealing of a %s process", process.Status)
+			}
+			if err := checkComplainProcessDealing(state, tx, process, count); err != nil {
 				return err
 			}
 		}
@@ -641,6 +748,51 @@ func AdminTxCheck(vtx *models.Tx, txBytes, signature []byte, state *State) error
 		if !found {
 			return fmt.Errorf("cannot remove oracle, not found")
 		}
+		// the remaining oracles must be able to reach the quorum, which
+		// must be lowered first otherwise
+		quorum, err := state.OracleQuorum(false)
+		if err != nil {
+			return fmt.Errorf("cannot get oracle quorum: %w", err)
+		}
+		if quorum > 1 {
+			if err := checkOracleQuorum(quorum, len(oracles)-1); err != nil {
+				return fmt.Errorf("cannot remove oracle: %w", err)
+			}
+		}
+	case models.TxType_SET_ORACLE_QUORUM:
+		if tx.OracleQuorum == nil {
+			return fmt.Errorf("missing oracleQuorum on AdminTxCheck")
+		}
+		if err := checkOracleQuorum(*tx.OracleQuorum, len(oracles)); err != nil {
+			return err
+		}
+	case models.TxType_ADD_VALIDATOR:
+		// the validator changes are sent to Tendermint on EndBlock, so
+		// they must be valid, otherwise the chain would halt
+		if _, err := hexPubKeyToTendermintEd25519(fmt.Sprintf("%x", tx.PublicKey)); err != nil {
+			return fmt.Errorf("invalid validator public key: %w", err)
+		}
+		if tx.Power == nil || *tx.Power == 0 || *tx.Power > uint64(tmtypes.MaxTotalVotingPower) {
+			return fmt.Errorf("invalid validator power")
+		}
+	case models.TxType_REMOVE_VALIDATOR:
+		validators, err := state.Validators(false)
+		if err != nil {
+			return fmt.Errorf("cannot get validators: %w", err)
+		}
+		var found bool
+		for _, validator := range validators {
+			if bytes.Equal(validator.Address, tx.Address) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("cannot remove validator, not found")
+		}
+		if len(validators) == 1 {
+			return fmt.Errorf("cannot remove the last validator")
+		}
 	}
 	return nil
 }
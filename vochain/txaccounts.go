@@ -0,0 +1,152 @@
+package vochain
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/crypto/babyjubjub"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// TxAccounts are the accounts involved in a transaction and the tokens moved
+// between them.
+type TxAccounts struct {
+	Type models.TxType
+	// Sender is the account paying the transferred amount and the cost, or
+	// the zero address for anonymous votes.
+	Sender common.Address
+	// Recipients are the accounts receiving the amount or affected by the
+	// transaction, such as a new delegate.
+	Recipients []common.Address
+	// Amount is the number of tokens transferred to each recipient.
+	Amount uint64
+	// Cost is the number of tokens charged to the sender and transferred to
+	// BurnAddress.
+	Cost uint64
+}
+
+// txSigner returns the address of the key that signed txBytes, which is a
+// BabyJubJub key if the signature has its length, or a secp256k1 key
+// otherwise.
+func txSigner(txBytes, signature []byte) (common.Address, error) {
+	if len(signature) == babyjubjub.VocdoniSignatureLength {
+		pubKey, err := babyjubjub.PubKeyFromSignature(txBytes, signature)
+		if err != nil {
+			return common.Address{}, err
+		}
+		return babyjubjub.AddrFromPublicKey(pubKey), nil
+	}
+	return ethereum.AddrFromSignature(txBytes, signature)
+}
+
+// TxAccounts returns the accounts involved in vtx.  It must be called right
+// after vtx is delivered, before any other transaction, since some of the
+// values depend on the resulting state.
+func (app *BaseApplication) TxAccounts(vtx *VochainTx) (*TxAccounts, error) {
+	accounts := &TxAccounts{}
+	if len(vtx.Signature) > 0 {
+		signer, err := txSigner(vtx.SignedBody, vtx.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("cannot extract address from signature: %w", err)
+		}
+		accounts.Sender = signer
+	}
+	txCost := func(txType models.TxType) (uint64, error) {
+		cost, err := app.State.TxCost(txType, false)
+		if err != nil {
+			return 0, fmt.Errorf("cannot get %s tx cost: %w", txType, err)
+		}
+		return cost, nil
+	}
+	var err error
+	switch vtx.Tx.Payload.(type) {
+	case *models.Tx_Vote:
+		accounts.Type = models.TxType_VOTE
+	case *models.Tx_Admin:
+		accounts.Type = vtx.Tx.GetAdmin().Txtype
+	case *models.Tx_RegisterKey:
+		accounts.Type = models.TxType_REGISTER_VOTER_KEY
+	case *models.Tx_NewProcess:
+		tx := vtx.Tx.GetNewProcess()
+		accounts.Type = tx.Txtype
+		_, isOracle, err := processTxSender(app.State, vtx.SignedBody, vtx.Signature)
+		if err != nil {
+			return nil, err
+		}
+		if !isOracle {
+			if accounts.Cost, err = txCost(models.TxType_NEW_PROCESS); err != nil {
+				return nil, err
+			}
+			if accounts.Cost, err = NewProcessCost(accounts.Cost, tx.Process); err != nil {
+				return nil, err
+			}
+		}
+	case *models.Tx_SetProcess:
+		tx := vtx.Tx.GetSetProcess()
+		accounts.Type = tx.Txtype
+		_, isOracle, err := processTxSender(app.State, vtx.SignedBody, vtx.Signature)
+		if err != nil {
+			return nil, err
+		}
+		if !isOracle {
+			if accounts.Cost, err = txCost(tx.Txtype); err != nil {
+				return nil, err
+			}
+		}
+	case *models.Tx_SetAccountInfo:
+		tx := vtx.Tx.GetSetAccountInfo()
+		accounts.Type = models.TxType_SET_ACCOUNT_INFO
+		// The accounts are created with no cost, and their faucet
+		// package, if any, is paid by its issuer.
+		if vtx.CreatesAccount {
+			if tx.FaucetPackage == nil {
+				break
+			}
+			payload, err := proto.Marshal(tx.FaucetPackage.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("cannot marshal faucet payload: %w", err)
+			}
+			issuer, err := ethereum.AddrFromSignature(payload, tx.FaucetPackage.Signature)
+			if err != nil {
+				return nil, fmt.Errorf("cannot extract faucet issuer: %w", err)
+			}
+			accounts.Recipients = []common.Address{accounts.Sender}
+			accounts.Sender = issuer
+			accounts.Amount = tx.FaucetPackage.Payload.Amount
+			accounts.Cost, err = txCost(models.TxType_COLLECT_FAUCET)
+			break
+		}
+		if account := common.BytesToAddress(tx.Account); len(tx.Account) > 0 &&
+			account != accounts.Sender {
+			accounts.Recipients = []common.Address{account}
+		}
+		accounts.Cost, err = txCost(models.TxType_SET_ACCOUNT_INFO)
+	case *models.Tx_SetTransactionCosts:
+		accounts.Type = vtx.Tx.GetSetTransactionCosts().Txtype
+	case *models.Tx_MintTokens:
+		tx := vtx.Tx.GetMintTokens()
+		accounts.Type = tx.Txtype
+		accounts.Recipients = []common.Address{common.BytesToAddress(tx.To)}
+		accounts.Amount = tx.Value
+	case *models.Tx_SendTokens:
+		tx := vtx.Tx.GetSendTokens()
+		accounts.Type = tx.Txtype
+		accounts.Sender = common.BytesToAddress(tx.From)
+		accounts.Recipients = []common.Address{common.BytesToAddress(tx.To)}
+		accounts.Amount = tx.Value
+		accounts.Cost, err = txCost(models.TxType_SEND_TOKENS)
+	case *models.Tx_SetAccountDelegateTx:
+		tx := vtx.Tx.GetSetAccountDelegateTx()
+		accounts.Type = tx.Txtype
+		accounts.Recipients = []common.Address{common.BytesToAddress(tx.Delegate)}
+		accounts.Cost, err = txCost(tx.Txtype)
+	default:
+		return nil, fmt.Errorf("unknown transaction type %T", vtx.Tx.Payload)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
@@ -0,0 +1,152 @@
+package vochain
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/censustree"
+	"go.vocdoni.io/dvote/crypto/babyjubjub"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/db/metadb"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// txAccountsListener records the accounts of the delivered transactions
+type txAccountsListener struct {
+	Listener
+	app      *BaseApplication
+	accounts []*TxAccounts
+	err      error
+}
+
+func (l *txAccountsListener) OnNewTx(tx *VochainTx, blockHeight uint32, txIndex int32) {
+	accounts, err := l.app.TxAccounts(tx)
+	if err != nil {
+		l.err = err
+		return
+	}
+	l.accounts = append(l.accounts, accounts)
+}
+
+// last returns the accounts of the last delivered transaction
+func (l *txAccountsListener) last(t *testing.T) *TxAccounts {
+	qt.Assert(t, l.err, qt.IsNil)
+	qt.Assert(t, l.accounts, qt.Not(qt.HasLen), 0)
+	return l.accounts[len(l.accounts)-1]
+}
+
+func TestTxAccountsSetAccountInfo(t *testing.T) {
+	app := TestBaseApplication(t)
+	listener := &txAccountsListener{app: app}
+	app.State.AddEventListener(listener)
+	faucet := ethereum.NewSignKeys()
+	qt.Assert(t, faucet.Generate(), qt.IsNil)
+	signer := ethereum.NewSignKeys()
+	qt.Assert(t, signer.Generate(), qt.IsNil)
+
+	qt.Assert(t, app.State.SetAccount(BurnAddress, &Account{}), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_SET_ACCOUNT_INFO, 10), qt.IsNil)
+	qt.Assert(t, app.State.SetTxCost(models.TxType_COLLECT_FAUCET, 20), qt.IsNil)
+	qt.Assert(t, app.State.CreateAccount(faucet.Address(), infoURI,
+		make([]common.Address, 0), 0), qt.IsNil)
+	qt.Assert(t, app.State.MintBalance(faucet.Address(), 1000), qt.IsNil)
+	app.Commit()
+
+	// deliverAccountInfo delivers a SET_ACCOUNT_INFO tx signed by signer and
+	// returns its accounts
+	deliverAccountInfo := func(tx *models.SetAccountInfoTx) *TxAccounts {
+		tx.Txtype, tx.InfoURI = models.TxType_SET_ACCOUNT_INFO, infoURI
+		stx := &models.SignedTx{}
+		var err error
+		stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_SetAccountInfo{SetAccountInfo: tx}})
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, sendTx(app, signer, stx), qt.IsNil)
+		app.Commit()
+		return listener.last(t)
+	}
+
+	// the faucet pays the tokens and the cost of creating the account
+	faucetPkg, err := GenerateFaucetPackage(faucet, signer.Address(), 100)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, deliverAccountInfo(&models.SetAccountInfoTx{FaucetPackage: faucetPkg}),
+		qt.DeepEquals, &TxAccounts{
+			Type:       models.TxType_SET_ACCOUNT_INFO,
+			Sender:     faucet.Address(),
+			Recipients: []common.Address{signer.Address()},
+			Amount:     100,
+			Cost:       20,
+		})
+
+	// the account updates are charged to the signer
+	qt.Assert(t, deliverAccountInfo(&models.SetAccountInfoTx{}),
+		qt.DeepEquals, &TxAccounts{
+			Type:   models.TxType_SET_ACCOUNT_INFO,
+			Sender: signer.Address(),
+			Cost:   10,
+		})
+}
+
+func TestTxAccountsBabyJubJubVote(t *testing.T) {
+	app := TestBaseApplication(t)
+	listener := &txAccountsListener{app: app}
+	app.State.AddEventListener(listener)
+
+	tr, err := censustree.New(censustree.Options{Name: "testtxaccounts", ParentDB: metadb.NewTest(t),
+		MaxLevels: 256, CensusType: models.Census_ARBO_POSEIDON})
+	qt.Assert(t, err, qt.IsNil)
+	voter := babyjubjub.NewSignKeys()
+	qt.Assert(t, voter.Generate(), qt.IsNil)
+	voter.VocdoniChainID = app.ChainID()
+	k, err := babyjubjub.CensusKey(voter.PublicKey())
+	qt.Assert(t, err, qt.IsNil)
+	censusKey := tr.BigIntToBytes(k)
+	qt.Assert(t, tr.Add(censusKey, tr.BigIntToBytes(bigOne)), qt.IsNil)
+	root, err := tr.Root()
+	qt.Assert(t, err, qt.IsNil)
+	_, siblings, err := tr.GenProof(censusKey)
+	qt.Assert(t, err, qt.IsNil)
+
+	censusURI := ipfsUrl
+	pid := util.RandomBytes(types.ProcessIDsize)
+	qt.Assert(t, app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EthereumAddressSize),
+		CensusRoot:   root,
+		CensusURI:    &censusURI,
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}), qt.IsNil)
+
+	var stx models.SignedTx
+	stx.Tx, err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{
+		Vote: &models.VoteEnvelope{
+			Nonce:     util.RandomBytes(32),
+			ProcessId: pid,
+			Proof: &models.Proof{Payload: &models.Proof_Iden3{
+				Iden3: &models.ProofIden3{Siblings: siblings},
+			}},
+			VotePackage: []byte("[1,2,3]"),
+		}}})
+	qt.Assert(t, err, qt.IsNil)
+	stx.Signature, err = voter.SignVocdoniTx(stx.Tx)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, stx.Signature, qt.HasLen, babyjubjub.VocdoniSignatureLength)
+	stxBytes, err := proto.Marshal(&stx)
+	qt.Assert(t, err, qt.IsNil)
+	resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: stxBytes})
+	qt.Assert(t, resp.Code, qt.Equals, uint32(0), qt.Commentf("%s", resp.Data))
+
+	// the sender is the address of the BabyJubJub key
+	qt.Assert(t, listener.last(t), qt.DeepEquals, &TxAccounts{
+		Type:   models.TxType_VOTE,
+		Sender: voter.Address(),
+	})
+}
@@ -14,8 +14,6 @@ import (
 const (
 	voteCachePurgeThreshold = uint32(180) // in blocks about 30 minutes
 	voteCacheSize           = 100000
-	NewProcessCost          = 0
-	SetProcessCost          = 0
 )
 
 var (
@@ -79,6 +77,8 @@ type QueryData struct {
 	ListSize    int64  `json:"listSize,omitempty"`
 	Timestamp   int64  `json:"timestamp,omitempty"`
 	ProcessType string `json:"type,omitempty"`
+	Address     string `json:"address,omitempty"`
+	TxType      string `json:"txType,omitempty"`
 }
 
 // ________________________ TRANSACTION COSTS __________________________
@@ -166,6 +166,18 @@ type GenesisAppState struct {
 	Oracles    []string           `json:"oracles"`
 	Treasurer  string             `json:"treasurer"`
 	TxCost     TransactionCosts   `json:"tx_cost"`
+	KeyKeepers *GenesisKeyKeepers `json:"keykeepers,omitempty"`
+	// OracleQuorum is the number of oracles that must submit identical
+	// results for a process to move to RESULTS.  If not set, it is one.
+	OracleQuorum uint32 `json:"oracleQuorum,omitempty"`
+}
+
+// GenesisKeyKeepers sets the threshold encryption keys, where the keykeepers
+// 1 to Count jointly generate the process keys and any Threshold of them can
+// reveal them.  If not set, each keykeeper publishes an independent key.
+type GenesisKeyKeepers struct {
+	Threshold uint32 `json:"threshold"`
+	Count     uint32 `json:"count"`
 }
 
 // The rest of these genesis app state types are copied from
@@ -231,8 +243,12 @@ type CensusProperties struct {
 	AllowCensusUpdate bool
 	NeedsDownload     bool
 	NeedsIndexSlot    bool
-	NeedsURI          bool
-	WeightedSupport   bool
+	// NeedsOwnersIndexSlot is set on the ERC721 processes, which also
+	// prove the ownership of a token
+	NeedsOwnersIndexSlot bool
+	NeedsTokenID         bool
+	NeedsURI             bool
+	WeightedSupport      bool
 }
 
 var CensusOrigins = map[models.CensusOrigin]CensusProperties{
@@ -244,6 +260,16 @@ var CensusOrigins = map[models.CensusOrigin]CensusProperties{
 	},
 	models.CensusOrigin_ERC20: {Name: "erc20", NeedsDownload: true,
 		WeightedSupport: true, NeedsIndexSlot: true},
+	models.CensusOrigin_ERC721: {Name: "erc721", WeightedSupport: true,
+		NeedsIndexSlot: true, NeedsOwnersIndexSlot: true},
+	models.CensusOrigin_ERC1155: {Name: "erc1155", WeightedSupport: true,
+		NeedsIndexSlot: true, NeedsTokenID: true},
 	models.CensusOrigin_OFF_CHAIN_CA: {Name: "ca", WeightedSupport: true,
 		NeedsURI: true, AllowCensusUpdate: true},
+	// the census of the vochain tokens processes are the Vochain accounts,
+	// being the weight of each voter the balance of its account when the
+	// process starts.  The process census root is set by the Vochain to the
+	// Accounts tree root of the state committed in the block before the
+	// process start block.
+	models.CensusOrigin_VOCHAIN_TOKENS: {Name: "vochain tokens", WeightedSupport: true},
 }
@@ -70,28 +70,65 @@ func (vi *VochainInfo) HeightTime(height int64) time.Time {
 		return blk.Header.Time
 	}
 
+	t := estimatedBlockTime(times, diffHeight)
+	return time.Now().Add(time.Duration(diffHeight*t) * time.Millisecond)
+}
+
+// HeightAtTime estimates the height of the block at a future UTC time, or
+// returns the height of the last block started before t if it is in the past.
+func (vi *VochainInfo) HeightAtTime(t time.Time) int64 {
+	currentHeight := vi.Height()
+	diff := time.Until(t).Milliseconds()
+
+	if diff < 0 {
+		// binary search of the last block started before t
+		low, high := int64(1), currentHeight
+		for low < high {
+			mid := (low + high + 1) / 2
+			blk := vi.vnode.GetBlockByHeight(mid)
+			if blk == nil {
+				log.Errorf("cannot get block height %d", mid)
+				return 0
+			}
+			if blk.Header.Time.After(t) {
+				high = mid - 1
+			} else {
+				low = mid
+			}
+		}
+		return low
+	}
+
+	times := vi.BlockTimes()
+	// estimate the number of blocks with the short term average first, to
+	// choose the average that fits the distance
+	diffHeight := diff / estimatedBlockTime(times, 0)
+	return currentHeight + diff/estimatedBlockTime(times, diffHeight)
+}
+
+// estimatedBlockTime returns the block time average in milliseconds that
+// better fits an estimation diffHeight blocks away.
+func estimatedBlockTime(times *[5]int32, diffHeight int64) int64 {
 	getMaxTimeFrom := func(i int) int64 {
 		for ; i >= 0; i-- {
 			if times[i] != 0 {
 				return int64(times[i])
 			}
 		}
-		return 10000 // fallback
+		return vochain.DefaultBlockTime.Milliseconds() // fallback
 	}
 
-	t := int64(0)
 	switch {
 	// if less than around 15 minutes missing
 	case diffHeight < 100:
-		t = getMaxTimeFrom(1)
+		return getMaxTimeFrom(1)
 	// if less than around 6 hours missing
 	case diffHeight < 1000:
-		t = getMaxTimeFrom(3)
-	// if less than around 6 hours missing
-	case diffHeight >= 1000:
-		t = getMaxTimeFrom(4)
+		return getMaxTimeFrom(3)
+	// if more than around 6 hours missing
+	default:
+		return getMaxTimeFrom(4)
 	}
-	return time.Now().Add(time.Duration(diffHeight*t) * time.Millisecond)
 }
 
 // Sync returns true if the Vochain is considered up-to-date
@@ -0,0 +1,46 @@
+package vochaininfo
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"go.vocdoni.io/dvote/vochain"
+)
+
+func TestHeightAtTime(t *testing.T) {
+	app := vochain.TestBaseApplication(t)
+	// 100 blocks, one every 10 seconds, the last one a second ago
+	const blocks, blockTime = 100, 10 * time.Second
+	genesis := time.Now().Add(-blocks*blockTime + blockTime - time.Second).Truncate(time.Second)
+	app.SetFnGetBlockByHeight(func(height int64) *tmtypes.Block {
+		if height < 1 || height > blocks {
+			return nil
+		}
+		return &tmtypes.Block{Header: tmtypes.Header{
+			Height: height,
+			Time:   genesis.Add(time.Duration(height-1) * blockTime),
+		}}
+	})
+	vi := NewVochainInfo(app)
+	vi.height = blocks
+
+	// past dates are searched on the blocks
+	qt.Assert(t, vi.HeightAtTime(genesis), qt.Equals, int64(1))
+	qt.Assert(t, vi.HeightAtTime(genesis.Add(-time.Hour)), qt.Equals, int64(1))
+	qt.Assert(t, vi.HeightAtTime(genesis.Add(25*blockTime)), qt.Equals, int64(26))
+	qt.Assert(t, vi.HeightAtTime(genesis.Add(25*blockTime+blockTime/2)), qt.Equals, int64(26))
+	qt.Assert(t, vi.HeightTime(26), qt.Equals, genesis.Add(25*blockTime))
+
+	// future dates are estimated with the block time averages, or the
+	// default block time if there are none yet
+	qt.Assert(t, vi.HeightAtTime(time.Now().Add(50*vochain.DefaultBlockTime)),
+		qt.Equals, int64(blocks+49))
+	vi.avg10 = 5000
+	qt.Assert(t, vi.HeightAtTime(time.Now().Add(50*vochain.DefaultBlockTime)),
+		qt.Equals, int64(blocks+99))
+	estimated := vi.HeightTime(blocks + 100)
+	qt.Assert(t, estimated.After(time.Now().Add(499*time.Second)), qt.IsTrue)
+	qt.Assert(t, estimated.Before(time.Now().Add(501*time.Second)), qt.IsTrue)
+}
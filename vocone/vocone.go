@@ -154,9 +154,9 @@ func (vc *Vocone) Start() {
 		vc.app.BeginBlock(bblock)
 		// Commit block
 		vc.commitBlock()
+		vc.app.EndBlock(abcitypes.RequestEndBlock{Height: bblock.Header.Height})
 		comres := vc.app.Commit()
 		log.Debugf("commit hash for block %d: %x", bblock.Header.Height, comres.Data)
-		vc.app.EndBlock(abcitypes.RequestEndBlock{Height: bblock.Header.Height})
 
 		// Waiting time
 		sinceLast := time.Since(vc.lastBlockTime)